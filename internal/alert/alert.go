@@ -0,0 +1,154 @@
+// Package alert evaluates alerting rules against SolarEdge updates and sends notifications when they fire or resolve.
+package alert
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"log/slog"
+	"time"
+)
+
+// An Engine evaluates its Rules whenever a new SolarEdge update is received, and every Interval.
+// When a rule's condition has been active for the rule's For duration, the Engine sends a notification to all Notifiers.
+// Once the condition clears, a recovery notification is sent.
+type Engine struct {
+	SolarEdge Publisher[publisher.SolarEdgeUpdate]
+	Logger    *slog.Logger
+	alerts    map[string]*alert
+	update    publisher.SolarEdgeUpdate
+	Rules     []Rule
+	Notifiers []Notifier
+	// Interval determines how often the rules are evaluated in the absence of updates. Defaults to one minute.
+	Interval time.Duration
+	// ResendInterval determines how often a notification is repeated while an alert is firing. Zero means never.
+	ResendInterval time.Duration
+}
+
+type Publisher[T any] interface {
+	Subscribe() <-chan T
+	Unsubscribe(<-chan T)
+}
+
+// A Notifier delivers a notification.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// A Notification reports a change in an alert's status.
+type Notification struct {
+	Since     time.Time `json:"since"`
+	Timestamp time.Time `json:"timestamp"`
+	Rule      string    `json:"rule"`
+	Key       string    `json:"key"`
+	Message   string    `json:"message"`
+	Status    string    `json:"status"`
+}
+
+// Title returns a one-line summary of the notification.
+func (n Notification) Title() string {
+	title := "[" + n.Status + "] " + n.Rule
+	if n.Key != "" {
+		title += ": " + n.Key
+	}
+	return title
+}
+
+type alert struct {
+	since    time.Time
+	lastSent time.Time
+	rule     string
+	key      string
+	message  string
+	firing   bool
+}
+
+func (e *Engine) Run(ctx context.Context) error {
+	e.Logger.Debug("starting alert engine", "rules", len(e.Rules), "notifiers", len(e.Notifiers))
+	defer e.Logger.Debug("stopped alert engine")
+
+	var ch <-chan publisher.SolarEdgeUpdate
+	if e.SolarEdge != nil {
+		ch = e.SolarEdge.Subscribe()
+		defer e.SolarEdge.Unsubscribe(ch)
+	}
+
+	interval := e.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e.update = <-ch:
+			e.evaluate(ctx, time.Now())
+		case <-ticker.C:
+			e.evaluate(ctx, time.Now())
+		}
+	}
+}
+
+func (e *Engine) evaluate(ctx context.Context, now time.Time) {
+	if e.alerts == nil {
+		e.alerts = make(map[string]*alert)
+	}
+
+	state := State{Now: now, Update: e.update}
+	active := make(map[string]struct{})
+	for _, rule := range e.Rules {
+		for _, condition := range rule.Evaluate(ctx, state) {
+			id := rule.Name + "|" + condition.Key
+			active[id] = struct{}{}
+			a, ok := e.alerts[id]
+			if !ok {
+				a = &alert{since: now, rule: rule.Name, key: condition.Key}
+				e.alerts[id] = a
+			}
+			a.message = condition.Message
+			switch {
+			case !a.firing && now.Sub(a.since) >= rule.For:
+				a.firing = true
+				e.notify(ctx, a, StatusFiring, now)
+			case a.firing && e.ResendInterval > 0 && now.Sub(a.lastSent) >= e.ResendInterval:
+				e.notify(ctx, a, StatusFiring, now)
+			}
+		}
+	}
+
+	// any alert that's no longer active has recovered
+	for id, a := range e.alerts {
+		if _, ok := active[id]; ok {
+			continue
+		}
+		if a.firing {
+			e.notify(ctx, a, StatusResolved, now)
+		}
+		delete(e.alerts, id)
+	}
+}
+
+func (e *Engine) notify(ctx context.Context, a *alert, status string, now time.Time) {
+	a.lastSent = now
+	notification := Notification{
+		Since:     a.since,
+		Timestamp: now,
+		Rule:      a.rule,
+		Key:       a.key,
+		Message:   a.message,
+		Status:    status,
+	}
+	e.Logger.Info("alert "+status, "rule", a.rule, "key", a.key, "message", a.message)
+	for _, n := range e.Notifiers {
+		if err := n.Notify(ctx, notification); err != nil {
+			e.Logger.Error("failed to send notification", "rule", a.rule, "key", a.key, "err", err)
+		}
+	}
+}
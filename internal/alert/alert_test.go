@@ -0,0 +1,112 @@
+package alert
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEngine_evaluate(t *testing.T) {
+	var n fakeNotifier
+	var active bool
+	e := Engine{
+		Logger: slog.New(slog.DiscardHandler),
+		Rules: []Rule{{
+			Name: "test",
+			For:  10 * time.Minute,
+			Evaluator: EvaluatorFunc(func(_ context.Context, _ State) []Condition {
+				if !active {
+					return nil
+				}
+				return []Condition{{Key: "foo", Message: "foo is broken"}}
+			}),
+		}},
+		Notifiers:      []Notifier{&n},
+		ResendInterval: time.Hour,
+	}
+	ctx := t.Context()
+	start := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	// condition becomes active: alert is pending
+	active = true
+	e.evaluate(ctx, start)
+	assert.Empty(t, n.get())
+
+	// condition active for longer than For: alert fires
+	e.evaluate(ctx, start.Add(10*time.Minute))
+	require.Len(t, n.get(), 1)
+	assert.Equal(t, Notification{
+		Since:     start,
+		Timestamp: start.Add(10 * time.Minute),
+		Rule:      "test",
+		Key:       "foo",
+		Message:   "foo is broken",
+		Status:    StatusFiring,
+	}, n.get()[0])
+
+	// no duplicate notifications
+	e.evaluate(ctx, start.Add(20*time.Minute))
+	assert.Len(t, n.get(), 1)
+
+	// notification is resent after ResendInterval
+	e.evaluate(ctx, start.Add(70*time.Minute))
+	require.Len(t, n.get(), 2)
+	assert.Equal(t, StatusFiring, n.get()[1].Status)
+
+	// condition clears: recovery notification
+	active = false
+	e.evaluate(ctx, start.Add(80*time.Minute))
+	require.Len(t, n.get(), 3)
+	assert.Equal(t, StatusResolved, n.get()[2].Status)
+	assert.Equal(t, start, n.get()[2].Since)
+
+	// pending alerts that clear don't send a notification
+	active = true
+	e.evaluate(ctx, start.Add(90*time.Minute))
+	active = false
+	e.evaluate(ctx, start.Add(95*time.Minute))
+	assert.Len(t, n.get(), 3)
+}
+
+func TestEngine_Run(t *testing.T) {
+	p := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+	var n fakeNotifier
+	e := Engine{
+		SolarEdge: p,
+		Logger:    slog.New(slog.DiscardHandler),
+		Rules:     []Rule{{Name: "temperature", Evaluator: InverterTemperature{Threshold: 30}}},
+		Notifiers: []Notifier{&n},
+		Interval:  time.Hour,
+	}
+	go func() { _ = e.Run(t.Context()) }()
+
+	p.Ch <- testutils.TestUpdate
+	require.Eventually(t, func() bool { return len(n.get()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "[firing] temperature: site=foo (1), inverter=inv1 (1234)", n.get()[0].Title())
+}
+
+var _ Notifier = &fakeNotifier{}
+
+type fakeNotifier struct {
+	notifications []Notification
+	lock          sync.Mutex
+}
+
+func (f *fakeNotifier) Notify(_ context.Context, notification Notification) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.notifications = append(f.notifications, notification)
+	return nil
+}
+
+func (f *fakeNotifier) get() []Notification {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.notifications
+}
@@ -0,0 +1,175 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+var _ Notifier = Webhook{}
+
+// Webhook posts each notification as JSON to a URL.
+type Webhook struct {
+	HTTPClient *http.Client
+	Headers    map[string]string
+	URL        string
+}
+
+func (w Webhook) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	maps.Copy(headers, w.Headers)
+	return post(ctx, w.HTTPClient, w.URL, headers, body)
+}
+
+var _ Notifier = Ntfy{}
+
+// Ntfy publishes each notification to an ntfy topic. URL includes the topic, e.g. https://ntfy.sh/my-solar-alerts.
+type Ntfy struct {
+	HTTPClient *http.Client
+	URL        string
+	// Token is the access token for the topic. Optional.
+	Token string
+}
+
+func (n Ntfy) Notify(ctx context.Context, notification Notification) error {
+	headers := map[string]string{"Title": notification.Title()}
+	if notification.Status == StatusFiring {
+		headers["Priority"] = "high"
+		headers["Tags"] = "warning"
+	} else {
+		headers["Tags"] = "white_check_mark"
+	}
+	if n.Token != "" {
+		headers["Authorization"] = "Bearer " + n.Token
+	}
+	return post(ctx, n.HTTPClient, n.URL, headers, []byte(notification.Message))
+}
+
+func post(ctx context.Context, httpClient *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+var _ Notifier = SMTP{}
+
+// SMTP emails each notification.
+type SMTP struct {
+	// Addr is the address of the mail server, e.g. smtp.example.com:587
+	Addr string
+	From string
+	// Username and Password are used for PLAIN authentication. If Username is blank, no authentication is done.
+	Username string
+	Password string
+	To       []string
+	// Timeout is the maximum time to send a notification. Defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+func (s SMTP) Notify(ctx context.Context, notification Notification) error {
+	if err := s.send(ctx, s.message(notification)); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return nil
+}
+
+// send sends the message like smtp.SendMail does, but it gives up when ctx is done or after Timeout.
+func (s SMTP) send(ctx context.Context, message []byte) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	// unblock any pending read or write if ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	host, _, _ := strings.Cut(s.Addr, ":")
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(message); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s SMTP) message(notification Notification) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + s.From + "\r\n")
+	b.WriteString("To: " + strings.Join(s.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + notification.Title() + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(notification.Message + "\r\n")
+	b.WriteString("\r\nActive since: " + notification.Since.Format("2006-01-02 15:04:05 MST") + "\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,165 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNotification = Notification{
+	Since:     time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC),
+	Timestamp: time.Date(2024, time.June, 1, 12, 30, 0, 0, time.UTC),
+	Rule:      "zero-power",
+	Key:       "site=foo (1)",
+	Message:   "site foo is not producing any power",
+	Status:    StatusFiring,
+}
+
+func TestWebhook(t *testing.T) {
+	var received Notification
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	t.Cleanup(s.Close)
+
+	w := Webhook{URL: s.URL, Headers: map[string]string{"X-Token": "secret"}}
+	require.NoError(t, w.Notify(t.Context(), testNotification))
+	assert.Equal(t, testNotification, received)
+
+	w.Headers = nil
+	assert.ErrorContains(t, w.Notify(t.Context(), testNotification), "400 Bad Request: bad request")
+}
+
+func TestNtfy(t *testing.T) {
+	var header http.Header
+	var body string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/solar" {
+			http.NotFound(w, r)
+			return
+		}
+		header = r.Header
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	t.Cleanup(s.Close)
+
+	n := Ntfy{URL: s.URL + "/solar", Token: "secret"}
+	require.NoError(t, n.Notify(t.Context(), testNotification))
+	assert.Equal(t, "site foo is not producing any power", body)
+	assert.Equal(t, "[firing] zero-power: site=foo (1)", header.Get("Title"))
+	assert.Equal(t, "high", header.Get("Priority"))
+	assert.Equal(t, "Bearer secret", header.Get("Authorization"))
+
+	resolved := testNotification
+	resolved.Status = StatusResolved
+	require.NoError(t, n.Notify(t.Context(), resolved))
+	assert.Equal(t, "[resolved] zero-power: site=foo (1)", header.Get("Title"))
+	assert.Empty(t, header.Get("Priority"))
+}
+
+func TestSMTP(t *testing.T) {
+	addr, messages := startSMTPServer(t)
+	s := SMTP{Addr: addr, From: "solaredge@example.com", To: []string{"me@example.com"}}
+	require.NoError(t, s.Notify(t.Context(), testNotification))
+
+	msg := <-messages
+	assert.Contains(t, msg, "Subject: [firing] zero-power: site=foo (1)\r\n")
+	assert.Contains(t, msg, "To: me@example.com\r\n")
+	assert.Contains(t, msg, "site foo is not producing any power\r\n")
+}
+
+func TestSMTP_Timeout(t *testing.T) {
+	// a mail server that accepts connections, but never responds
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	s := SMTP{Addr: l.Addr().String(), From: "solaredge@example.com", To: []string{"me@example.com"}, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	assert.Error(t, s.Notify(t.Context(), testNotification))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// the context is honoured too
+	s.Timeout = time.Hour
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	assert.Error(t, s.Notify(ctx, testNotification))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// startSMTPServer starts a minimal SMTP server that accepts any message and returns its content on the channel.
+func startSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+	return l.Addr().String(), messages
+}
+
+func serveSMTP(conn net.Conn, messages chan<- string) {
+	defer func() { _ = conn.Close() }()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 localhost")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			body, _ := io.ReadAll(bufio.NewReader(tp.DotReader()))
+			messages <- strings.ReplaceAll(string(body), "\n", "\r\n")
+			_ = tp.PrintfLine("250 ok")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"maps"
+	"slices"
+	"time"
+)
+
+// A Rule evaluates a condition. If the condition stays active for For, the alert fires.
+type Rule struct {
+	Evaluator
+	Name string
+	For  time.Duration
+}
+
+// An Evaluator returns all active conditions for the current state.
+type Evaluator interface {
+	Evaluate(ctx context.Context, state State) []Condition
+}
+
+// EvaluatorFunc is a function that implements Evaluator.
+type EvaluatorFunc func(context.Context, State) []Condition
+
+func (f EvaluatorFunc) Evaluate(ctx context.Context, state State) []Condition {
+	return f(ctx, state)
+}
+
+// State is the input to an Evaluator.
+type State struct {
+	Now time.Time
+	// Update is the last SolarEdge update received. Nil if no update has been received yet.
+	Update publisher.SolarEdgeUpdate
+}
+
+// A Condition is an active problem. Key identifies the affected entity (e.g. a site or an inverter).
+type Condition struct {
+	Key     string
+	Message string
+}
+
+var _ Evaluator = ZeroPower{}
+
+// ZeroPower reports each site that isn't producing any power during daylight.
+type ZeroPower struct {
+	IsDaylight func(time.Time) bool
+}
+
+func (z ZeroPower) Evaluate(_ context.Context, state State) []Condition {
+	if !z.IsDaylight(state.Now) {
+		return nil
+	}
+	var conditions []Condition
+	for _, site := range state.Update {
+		if site.PowerOverview.CurrentPower.Power == 0 {
+			conditions = append(conditions, Condition{
+				Key:     siteKey(site),
+				Message: fmt.Sprintf("site %s is not producing any power", site.Name),
+			})
+		}
+	}
+	return conditions
+}
+
+var _ Evaluator = InverterTemperature{}
+
+// InverterTemperature reports each inverter whose temperature exceeds the Threshold (in °C).
+type InverterTemperature struct {
+	Threshold float64
+}
+
+func (i InverterTemperature) Evaluate(_ context.Context, state State) []Condition {
+	var conditions []Condition
+	for _, site := range state.Update {
+		for _, inverter := range site.InverterUpdates {
+			if temperature := inverter.Telemetry.Temperature; temperature > i.Threshold {
+				conditions = append(conditions, Condition{
+					Key:     inverterKey(site, inverter),
+					Message: fmt.Sprintf("inverter %s temperature is %.1f°C (threshold: %.1f°C)", inverter.Name, temperature, i.Threshold),
+				})
+			}
+		}
+	}
+	return conditions
+}
+
+var _ Evaluator = PowerLimit{}
+
+// PowerLimit reports each inverter whose output is limited, i.e. whose power limit is below 100%.
+// Inverters without telemetry (e.g. at night) are skipped, as their power limit is unknown.
+type PowerLimit struct{}
+
+func (PowerLimit) Evaluate(_ context.Context, state State) []Condition {
+	var conditions []Condition
+	for _, site := range state.Update {
+		for _, inverter := range site.InverterUpdates {
+			if time.Time(inverter.Telemetry.Time).IsZero() {
+				continue
+			}
+			// SolarEdge reports the power limit as a fraction
+			if limit := inverter.Telemetry.PowerLimit; limit < 1 {
+				conditions = append(conditions, Condition{
+					Key:     inverterKey(site, inverter),
+					Message: fmt.Sprintf("inverter %s is limited to %.0f%% of its power", inverter.Name, 100*limit),
+				})
+			}
+		}
+	}
+	return conditions
+}
+
+var _ Evaluator = Unhealthy{}
+
+// Unhealthy reports each component that isn't healthy.
+type Unhealthy struct {
+	Components map[string]Component
+}
+
+type Component interface {
+	IsHealthy(context.Context) error
+}
+
+func (u Unhealthy) Evaluate(ctx context.Context, _ State) []Condition {
+	var conditions []Condition
+	for _, name := range slices.Sorted(maps.Keys(u.Components)) {
+		if err := u.Components[name].IsHealthy(ctx); err != nil {
+			conditions = append(conditions, Condition{Key: name, Message: name + " is not healthy: " + err.Error()})
+		}
+	}
+	return conditions
+}
+
+func siteKey(site publisher.SiteUpdate) string {
+	return fmt.Sprintf("site=%s (%d)", site.Name, site.ID)
+}
+
+func inverterKey(site publisher.SiteUpdate, inverter publisher.InverterUpdate) string {
+	return fmt.Sprintf("%s, inverter=%s (%s)", siteKey(site), inverter.Name, inverter.SerialNumber)
+}
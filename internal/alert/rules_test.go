@@ -0,0 +1,62 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/solaredge/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestZeroPower(t *testing.T) {
+	daylight := true
+	z := ZeroPower{IsDaylight: func(time.Time) bool { return daylight }}
+
+	assert.Empty(t, z.Evaluate(t.Context(), State{Update: testutils.TestUpdate}))
+	assert.Equal(t, []Condition{{Key: "site=foo (1)", Message: "site foo is not producing any power"}}, z.Evaluate(t.Context(), State{Update: testutils.EmptyUpdate}))
+
+	daylight = false
+	assert.Empty(t, z.Evaluate(t.Context(), State{Update: testutils.EmptyUpdate}))
+}
+
+func TestInverterTemperature(t *testing.T) {
+	assert.Empty(t, InverterTemperature{Threshold: 50}.Evaluate(t.Context(), State{Update: testutils.TestUpdate}))
+	assert.Equal(t, []Condition{{
+		Key:     "site=foo (1), inverter=inv1 (1234)",
+		Message: "inverter inv1 temperature is 40.0°C (threshold: 35.0°C)",
+	}}, InverterTemperature{Threshold: 35}.Evaluate(t.Context(), State{Update: testutils.TestUpdate}))
+}
+
+func TestPowerLimit(t *testing.T) {
+	assert.Empty(t, PowerLimit{}.Evaluate(t.Context(), State{Update: testutils.TestUpdate}))
+
+	update := publisher.SolarEdgeUpdate{{ID: 1, Name: "foo", InverterUpdates: []publisher.InverterUpdate{{Name: "inv1", SerialNumber: "1234"}}}}
+	update[0].InverterUpdates[0].Telemetry.PowerLimit = 0.6
+	// an inverter without telemetry has no power limit
+	assert.Empty(t, PowerLimit{}.Evaluate(t.Context(), State{Update: update}))
+
+	update[0].InverterUpdates[0].Telemetry.Time = solaredge.Time(time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, []Condition{{
+		Key:     "site=foo (1), inverter=inv1 (1234)",
+		Message: "inverter inv1 is limited to 60% of its power",
+	}}, PowerLimit{}.Evaluate(t.Context(), State{Update: update}))
+}
+
+func TestUnhealthy(t *testing.T) {
+	u := Unhealthy{Components: map[string]Component{
+		"up":   fakeComponent{},
+		"down": fakeComponent{err: errors.New("no data received")},
+	}}
+	assert.Equal(t, []Condition{{Key: "down", Message: "down is not healthy: no data received"}}, u.Evaluate(t.Context(), State{}))
+}
+
+type fakeComponent struct {
+	err error
+}
+
+func (f fakeComponent) IsHealthy(_ context.Context) error {
+	return f.err
+}
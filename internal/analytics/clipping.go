@@ -0,0 +1,115 @@
+package analytics
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"time"
+)
+
+// ClippingTolerance determines when power is at the ceiling: any power above ClippingTolerance times the ceiling is clipped.
+const ClippingTolerance = 0.98
+
+// minPlateau is the number of consecutive measurements at the ceiling needed to consider the power clipped.
+const minPlateau = 2
+
+// maxSampleDuration limits the time a single measurement represents, so gaps in the data don't inflate the lost energy.
+const maxSampleDuration = time.Hour
+
+// ClippingReport lists when the inverter capped the power of the panels.
+type ClippingReport struct {
+	// Clipped holds the index of each clipped measurement.
+	Clipped []int
+	Days    []ClippingDay
+	// Ceiling is the power (in W) at which the inverter clips.
+	Ceiling float64
+}
+
+// ClippingDay summarises the clipping for one day.
+type ClippingDay struct {
+	Date     time.Time
+	Duration time.Duration
+	// LostEnergy is the estimated energy (in Wh) lost due to clipping. Zero if no performance model is available.
+	LostEnergy float64
+}
+
+// LostEnergy returns the total estimated energy (in Wh) lost due to clipping.
+func (r ClippingReport) LostEnergy() float64 {
+	var total float64
+	for _, day := range r.Days {
+		total += day.LostEnergy
+	}
+	return total
+}
+
+// Clipping detects sustained plateaus at the ceiling in the (chronologically ordered) measurements.
+//
+// The ceiling is the inverter's rated power. If the ceiling is zero (i.e. unknown), no clipping is reported: the highest
+// measured power isn't necessarily a ceiling, e.g. on a clear day, the peak of the bell curve would be reported as clipped.
+// If model is not nil, the energy lost during a plateau is estimated as the difference between the expected and the
+// measured power.
+func Clipping(measurements repository.Measurements, ceiling float64, model *Model) ClippingReport {
+	if ceiling <= 0 {
+		return ClippingReport{}
+	}
+	report := ClippingReport{Ceiling: ceiling}
+
+	var plateau []int
+	flush := func() {
+		if len(plateau) >= minPlateau {
+			report.add(measurements, plateau, model)
+		}
+		plateau = plateau[:0]
+	}
+	for i, m := range measurements {
+		if len(plateau) > 0 && !sameDay(measurements[plateau[0]].Timestamp, m.Timestamp) {
+			flush()
+		}
+		if m.Power < ClippingTolerance*ceiling {
+			flush()
+			continue
+		}
+		plateau = append(plateau, i)
+	}
+	flush()
+	return report
+}
+
+func (r *ClippingReport) add(measurements repository.Measurements, plateau []int, model *Model) {
+	t := measurements[plateau[0]].Timestamp.Local()
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	if len(r.Days) == 0 || !r.Days[len(r.Days)-1].Date.Equal(date) {
+		r.Days = append(r.Days, ClippingDay{Date: date})
+	}
+	day := &r.Days[len(r.Days)-1]
+	for _, i := range plateau {
+		r.Clipped = append(r.Clipped, i)
+		duration := SampleDuration(measurements, i)
+		day.Duration += duration
+		if model == nil {
+			continue
+		}
+		if !measurements[i].HasIntensity() {
+			continue
+		}
+		if expected, ok := model.Expected(measurements[i].Timestamp, measurements[i].Intensity); ok && expected > measurements[i].Power {
+			day.LostEnergy += (expected - measurements[i].Power) * duration.Hours()
+		}
+	}
+}
+
+// SampleDuration returns the time represented by the i-th measurement: the time since the previous measurement of the same
+// day or, for the first measurement of the day, the time until the next one.
+func SampleDuration(measurements repository.Measurements, i int) time.Duration {
+	var duration time.Duration
+	switch {
+	case i > 0 && sameDay(measurements[i-1].Timestamp, measurements[i].Timestamp):
+		duration = measurements[i].Timestamp.Sub(measurements[i-1].Timestamp)
+	case i < len(measurements)-1 && sameDay(measurements[i].Timestamp, measurements[i+1].Timestamp):
+		duration = measurements[i+1].Timestamp.Sub(measurements[i].Timestamp)
+	}
+	return min(duration, maxSampleDuration)
+}
+
+func sameDay(a, b time.Time) bool {
+	a, b = a.Local(), b.Local()
+	return a.YearDay() == b.YearDay() && a.Year() == b.Year()
+}
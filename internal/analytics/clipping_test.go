@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestClipping(t *testing.T) {
+	day := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.Local)
+	measurements := repository.Measurements{
+		{Timestamp: day, Power: 2000, Intensity: 50},
+		{Timestamp: day.Add(15 * time.Minute), Power: 3000, Intensity: 75},
+		{Timestamp: day.Add(30 * time.Minute), Power: 3000, Intensity: 85},
+		{Timestamp: day.Add(45 * time.Minute), Power: 2990, Intensity: 90},
+		{Timestamp: day.Add(60 * time.Minute), Power: 2500, Intensity: 62},
+		// a single measurement at the ceiling isn't a plateau
+		{Timestamp: day.Add(75 * time.Minute), Power: 3000, Intensity: 75},
+		{Timestamp: day.Add(90 * time.Minute), Power: 2000, Intensity: 50},
+		// plateaus don't cross days
+		{Timestamp: day.Add(24 * time.Hour), Power: 3000, Intensity: 75},
+		{Timestamp: day.Add(48 * time.Hour), Power: 3000, Intensity: 75},
+	}
+
+	model := NewModel(makeMeasurements(100), 10)
+	report := Clipping(measurements, 3000, model)
+	assert.Equal(t, 3000.0, report.Ceiling)
+	assert.Equal(t, []int{1, 2, 3}, report.Clipped)
+	require.Len(t, report.Days, 1)
+	assert.Equal(t, time.Date(2024, time.July, 1, 0, 0, 0, 0, time.Local), report.Days[0].Date)
+	assert.Equal(t, 45*time.Minute, report.Days[0].Duration)
+	// expected power is 40 times the intensity
+	want := (3000-3000)*0.25 + (3400-3000)*0.25 + (3600-2990)*0.25
+	assert.InDelta(t, want, report.Days[0].LostEnergy, 0.1)
+	assert.InDelta(t, want, report.LostEnergy(), 0.1)
+
+	report = Clipping(measurements, 4000, model)
+	assert.Empty(t, report.Clipped)
+	assert.Zero(t, report.LostEnergy())
+
+	report = Clipping(measurements, 3000, nil)
+	assert.Equal(t, []int{1, 2, 3}, report.Clipped)
+	assert.Equal(t, 45*time.Minute, report.Days[0].Duration)
+	assert.Zero(t, report.LostEnergy())
+
+	// without the rated power, clipping can't be determined
+	assert.Equal(t, ClippingReport{}, Clipping(measurements, 0, model))
+	assert.Empty(t, Clipping(nil, 3000, nil).Clipped)
+}
+
+func TestClipping_ClearSky(t *testing.T) {
+	// a clear day: power follows a bell curve, peaking at 3000 W at noon
+	day := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.Local)
+	var measurements repository.Measurements
+	for timestamp := day.Add(6 * time.Hour); timestamp.Before(day.Add(18 * time.Hour)); timestamp = timestamp.Add(15 * time.Minute) {
+		hours := timestamp.Sub(day.Add(12 * time.Hour)).Hours()
+		measurements = append(measurements, repository.Measurement{Timestamp: timestamp, Power: 3000 * math.Exp(-hours*hours/8), Intensity: 50})
+	}
+
+	// the peak isn't clipping: without the rated power, nothing is reported
+	report := Clipping(measurements, 0, nil)
+	assert.Empty(t, report.Clipped)
+	assert.Empty(t, report.Days)
+
+	// the inverter is rated well above the peak
+	assert.Empty(t, Clipping(measurements, 4000, nil).Clipped)
+}
+
+func TestSampleDuration(t *testing.T) {
+	day := time.Date(2024, time.July, 1, 11, 0, 0, 0, time.Local)
+	measurements := repository.Measurements{
+		{Timestamp: day},
+		{Timestamp: day.Add(10 * time.Minute)},
+		{Timestamp: day.Add(4 * time.Hour)},
+		{Timestamp: day.Add(24 * time.Hour)},
+	}
+	assert.Equal(t, 10*time.Minute, SampleDuration(measurements, 0))
+	assert.Equal(t, 10*time.Minute, SampleDuration(measurements, 1))
+	assert.Equal(t, time.Hour, SampleDuration(measurements, 2))
+	assert.Zero(t, SampleDuration(measurements, 3))
+}
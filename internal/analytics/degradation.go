@@ -0,0 +1,159 @@
+package analytics
+
+import (
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"gonum.org/v1/gonum/stat/distuv"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// ErrInsufficientData indicates there aren't enough clear-sky measurements to determine the degradation rate.
+var ErrInsufficientData = errors.New("insufficient data")
+
+// minMonthlySamples is the minimum number of clear-sky measurements needed to include a month in the analysis.
+const minMonthlySamples = 10
+
+// clearSky is the weather state of clear-sky measurements.
+const clearSky = "SUN"
+
+// DegradationReport is the long-term trend of the installation's normalised output, i.e. its power per unit of
+// solar intensity under clear-sky conditions.
+type DegradationReport struct {
+	Years []YearlyOutput
+	// Rate is the relative change in normalised output per year, e.g. -0.005 for a degradation of 0.5% per year.
+	Rate float64
+	// Lower and Upper are the bounds of the Confidence interval of Rate.
+	Lower      float64
+	Upper      float64
+	Confidence float64
+	// Months is the number of months used to determine Rate.
+	Months int
+}
+
+// YearlyOutput summarises the normalised output for one year.
+type YearlyOutput struct {
+	Year    int
+	Samples int
+	// Output is the median normalised output, in W per % of solar intensity.
+	Output float64
+	// Index is the average normalised output, relative to the same months in other years.
+	Index float64
+}
+
+// Degradation determines the degradation rate of the installation from the measurements.
+//
+// Only clear-sky measurements with an intensity of at least minIntensity are used. To correct for seasonal effects,
+// the median normalised output of each month is compared against the average of that calendar month across all years.
+// The rate is the slope of a linear regression of these relative values against the year.
+// Returns ErrInsufficientData if the measurements don't cover at least two years.
+func Degradation(measurements repository.Measurements, minIntensity float64) (DegradationReport, error) {
+	type month struct {
+		start  time.Time
+		output []float64
+	}
+	months := make(map[time.Time]*month)
+	for _, m := range measurements {
+		if m.Weather != clearSky || !m.HasIntensity() || m.Intensity < minIntensity || m.Intensity <= 0 || m.Power <= 0 {
+			continue
+		}
+		t := m.Timestamp.Local()
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+		if months[start] == nil {
+			months[start] = &month{start: start}
+		}
+		months[start].output = append(months[start].output, m.Power/m.Intensity)
+	}
+
+	// median output per month. the seasonal index of a calendar month is the average of its medians across all years.
+	type monthlyOutput struct {
+		start  time.Time
+		median float64
+	}
+	var monthly []monthlyOutput
+	seasonal := make(map[time.Month][]float64)
+	yearly := make(map[int][]float64)
+	for _, m := range months {
+		yearly[m.start.Year()] = append(yearly[m.start.Year()], m.output...)
+		if len(m.output) < minMonthlySamples {
+			continue
+		}
+		monthMedian := median(m.output)
+		monthly = append(monthly, monthlyOutput{start: m.start, median: monthMedian})
+		seasonal[m.start.Month()] = append(seasonal[m.start.Month()], monthMedian)
+	}
+	slices.SortFunc(monthly, func(a, b monthlyOutput) int { return a.start.Compare(b.start) })
+
+	// only months that occur in multiple years say anything about degradation
+	var x, y []float64
+	yearIndex := make(map[int][]float64)
+	for _, m := range monthly {
+		if len(seasonal[m.start.Month()]) < 2 {
+			continue
+		}
+		relative := m.median / mean(seasonal[m.start.Month()])
+		// relative to the seasonal average, a month only differs from the same month in other years by its year
+		x = append(x, float64(m.start.Year()-monthly[0].start.Year()))
+		y = append(y, relative)
+		yearIndex[m.start.Year()] = append(yearIndex[m.start.Year()], relative)
+	}
+	if len(x) < 3 || len(yearIndex) < 2 {
+		return DegradationReport{}, ErrInsufficientData
+	}
+
+	report := DegradationReport{Confidence: 0.95, Months: len(x)}
+	slope, stdErr := linearRegression(x, y)
+	// y is relative to the seasonal average, so the slope is the relative change per year
+	margin := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(len(x) - 2)}.Quantile(1-(1-report.Confidence)/2) * stdErr
+	report.Rate, report.Lower, report.Upper = slope, slope-margin, slope+margin
+
+	for _, year := range slices.Sorted(maps.Keys(yearly)) {
+		output := YearlyOutput{Year: year, Samples: len(yearly[year]), Output: median(yearly[year])}
+		if index, ok := yearIndex[year]; ok {
+			output.Index = mean(index)
+		}
+		report.Years = append(report.Years, output)
+	}
+	return report, nil
+}
+
+// linearRegression returns the slope of the least-squares fit of y = a + b.x, and its standard error.
+func linearRegression(x, y []float64) (float64, float64) {
+	n := float64(len(x))
+	mx, my := mean(x), mean(y)
+	var sxx, sxy float64
+	for i := range x {
+		sxx += (x[i] - mx) * (x[i] - mx)
+		sxy += (x[i] - mx) * (y[i] - my)
+	}
+	if sxx == 0 {
+		return 0, math.Inf(1)
+	}
+	slope := sxy / sxx
+	intercept := my - slope*mx
+	var ssr float64
+	for i := range x {
+		r := y[i] - intercept - slope*x[i]
+		ssr += r * r
+	}
+	return slope, math.Sqrt(ssr / (n - 2) / sxx)
+}
+
+func mean(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
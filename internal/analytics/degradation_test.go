@@ -0,0 +1,71 @@
+package analytics
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// makeDegradingMeasurements returns daily clear-sky measurements, whose normalised output has a seasonal pattern and
+// degrades by rate per year.
+func makeDegradingMeasurements(start time.Time, years int, rate float64) repository.Measurements {
+	var measurements repository.Measurements
+	for day := range 365 * years {
+		timestamp := start.AddDate(0, 0, day)
+		seasonal := 30 + 10*float64(timestamp.Month())/12
+		age := timestamp.Sub(start).Hours() / (365.25 * 24)
+		intensity := float64(60 + day%30)
+		weather := clearSky
+		if day%5 == 0 {
+			weather = "CLOUDY"
+		}
+		measurements = append(measurements, repository.Measurement{
+			Timestamp: timestamp,
+			Weather:   weather,
+			Power:     seasonal * (1 + rate*age) * intensity,
+			Intensity: intensity,
+		})
+	}
+	return measurements
+}
+
+func TestDegradation(t *testing.T) {
+	start := time.Date(2021, time.January, 1, 12, 0, 0, 0, time.Local)
+	report, err := Degradation(makeDegradingMeasurements(start, 3, -0.01), 50)
+	require.NoError(t, err)
+
+	assert.InDelta(t, -0.01, report.Rate, 0.0005)
+	assert.Less(t, report.Lower, report.Rate)
+	assert.Greater(t, report.Upper, report.Rate)
+	assert.Less(t, report.Upper, 0.0)
+	assert.Equal(t, 0.95, report.Confidence)
+	assert.Equal(t, 36, report.Months)
+
+	require.Len(t, report.Years, 3)
+	assert.Equal(t, 2021, report.Years[0].Year)
+	assert.Equal(t, 292, report.Years[0].Samples)
+	assert.Greater(t, report.Years[0].Output, report.Years[2].Output)
+	assert.Greater(t, report.Years[0].Index, 1.0)
+	assert.Less(t, report.Years[2].Index, 1.0)
+}
+
+func TestDegradation_InsufficientData(t *testing.T) {
+	start := time.Date(2021, time.January, 1, 12, 0, 0, 0, time.Local)
+	_, err := Degradation(makeDegradingMeasurements(start, 1, -0.01), 50)
+	assert.ErrorIs(t, err, ErrInsufficientData)
+
+	// intensity too low
+	_, err = Degradation(makeDegradingMeasurements(start, 3, -0.01), 95)
+	assert.ErrorIs(t, err, ErrInsufficientData)
+}
+
+func TestLinearRegression(t *testing.T) {
+	slope, stdErr := linearRegression([]float64{0, 1, 2, 3}, []float64{1, 3, 5, 7})
+	assert.Equal(t, 2.0, slope)
+	assert.Zero(t, stdErr)
+
+	_, stdErr = linearRegression([]float64{1, 1, 1}, []float64{1, 2, 3})
+	assert.True(t, stdErr > 1e100)
+}
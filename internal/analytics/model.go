@@ -0,0 +1,147 @@
+// Package analytics models the expected output of the installation from its historical measurements.
+package analytics
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"time"
+)
+
+// MinExpectedPower is the lowest expected power (in W) for which a performance ratio is calculated.
+// Below this, e.g. at dawn and dusk, small absolute differences result in meaningless ratios.
+const MinExpectedPower = 100.0
+
+// UnderperformanceRatio is the performance ratio below which a measurement is considered underperforming.
+const UnderperformanceRatio = 0.75
+
+// A Model predicts the power of the installation for a given solar intensity.
+//
+// Since the relation between intensity and power depends on the position of the sun, the model fits a separate
+// linear relation for each hour of the day, per season. If a season has too few samples for an hour, the model
+// falls back to the relation for that hour across all seasons.
+type Model struct {
+	seasonal [4][24]Fit
+	hourly   [24]Fit
+}
+
+// Fit is the linear relation between intensity and power for one hour of the day.
+type Fit struct {
+	Intercept float64
+	Slope     float64
+	Samples   int
+}
+
+func (f Fit) predict(intensity float64) float64 {
+	return max(0, f.Intercept+f.Slope*intensity)
+}
+
+// NewModel fits a Model from the provided measurements. Hours with fewer than minSamples measurements are not modelled.
+// Measurements without intensity are ignored.
+func NewModel(measurements repository.Measurements, minSamples int) *Model {
+	var seasonal [4][24]accumulator
+	var hourly [24]accumulator
+	for _, m := range measurements.WithIntensity() {
+		s, h := season(m.Timestamp), hour(m.Timestamp)
+		seasonal[s][h].add(m.Intensity, m.Power)
+		hourly[h].add(m.Intensity, m.Power)
+	}
+
+	var model Model
+	for h := range 24 {
+		model.hourly[h] = hourly[h].fit(minSamples)
+		for s := range 4 {
+			model.seasonal[s][h] = seasonal[s][h].fit(minSamples)
+		}
+	}
+	return &model
+}
+
+// Expected returns the expected power at the timestamp for the provided intensity.
+// Returns false if the model has no data for the timestamp's hour.
+func (m *Model) Expected(timestamp time.Time, intensity float64) (float64, bool) {
+	s, h := season(timestamp), hour(timestamp)
+	if f := m.seasonal[s][h]; f.Samples > 0 {
+		return f.predict(intensity), true
+	}
+	if f := m.hourly[h]; f.Samples > 0 {
+		return f.predict(intensity), true
+	}
+	return 0, false
+}
+
+// PerformanceRatio returns the ratio of the measured power vs. the expected power. A ratio well below 1 indicates
+// the installation is underperforming, e.g. due to soiling, shading or a failing string.
+// Returns false if the measurement has no intensity, or the expected power can't be determined or is below MinExpectedPower.
+func (m *Model) PerformanceRatio(measurement repository.Measurement) (float64, float64, bool) {
+	if !measurement.HasIntensity() {
+		return 0, 0, false
+	}
+	expected, ok := m.Expected(measurement.Timestamp, measurement.Intensity)
+	if !ok || expected < MinExpectedPower {
+		return 0, expected, false
+	}
+	return measurement.Power / expected, expected, true
+}
+
+// A Performance is a measurement with its expected power and performance ratio.
+type Performance struct {
+	Timestamp time.Time `json:"timestamp"`
+	Weather   string    `json:"weather"`
+	Power     float64   `json:"power"`
+	Intensity float64   `json:"intensity"`
+	Expected  float64   `json:"expected"`
+	Ratio     float64   `json:"ratio"`
+}
+
+// Evaluate returns the Performance of each measurement. Measurements for which no performance ratio can be
+// calculated are skipped.
+func (m *Model) Evaluate(measurements repository.Measurements) []Performance {
+	performances := make([]Performance, 0, len(measurements))
+	for _, measurement := range measurements {
+		if ratio, expected, ok := m.PerformanceRatio(measurement); ok {
+			performances = append(performances, Performance{
+				Timestamp: measurement.Timestamp,
+				Weather:   measurement.Weather,
+				Power:     measurement.Power,
+				Intensity: measurement.Intensity,
+				Expected:  expected,
+				Ratio:     ratio,
+			})
+		}
+	}
+	return performances
+}
+
+// season returns the meteorological season (0: winter, 1: spring, 2: summer, 3: autumn) of the timestamp.
+func season(t time.Time) int {
+	return (int(t.Local().Month()) % 12) / 3
+}
+
+func hour(t time.Time) int {
+	return t.Local().Hour()
+}
+
+// accumulator calculates a least-squares fit of y = a + b.x
+type accumulator struct {
+	n, sx, sy, sxx, sxy float64
+}
+
+func (a *accumulator) add(x, y float64) {
+	a.n++
+	a.sx += x
+	a.sy += y
+	a.sxx += x * x
+	a.sxy += x * y
+}
+
+func (a *accumulator) fit(minSamples int) Fit {
+	if a.n == 0 || int(a.n) < minSamples {
+		return Fit{}
+	}
+	f := Fit{Intercept: a.sy / a.n, Samples: int(a.n)}
+	// if all intensities are the same, we can't determine the slope: use the average power
+	if d := a.n*a.sxx - a.sx*a.sx; d != 0 {
+		f.Slope = (a.n*a.sxy - a.sx*a.sy) / d
+		f.Intercept = (a.sy - f.Slope*a.sx) / a.n
+	}
+	return f
+}
@@ -0,0 +1,103 @@
+package analytics
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+// makeMeasurements returns measurements where power is 40 times the intensity at noon in summer,
+// and 20 times the intensity at noon in winter.
+func makeMeasurements(days int) repository.Measurements {
+	var measurements repository.Measurements
+	for day := range days {
+		for _, month := range []time.Month{time.January, time.July} {
+			factor := 40.0
+			if month == time.January {
+				factor = 20
+			}
+			intensity := float64(10 + day%90)
+			measurements = append(measurements, repository.Measurement{
+				Timestamp: time.Date(2024, month, 1+day%28, 12, 30, 0, 0, time.Local),
+				Power:     factor * intensity,
+				Intensity: intensity,
+				Weather:   "SUN",
+			})
+		}
+	}
+	return measurements
+}
+
+func TestModel_Expected(t *testing.T) {
+	m := NewModel(makeMeasurements(100), 10)
+
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		intensity float64
+		wantOK    bool
+		want      float64
+	}{
+		{name: "summer", timestamp: time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local), intensity: 50, wantOK: true, want: 2000},
+		{name: "winter", timestamp: time.Date(2025, time.February, 1, 12, 0, 0, 0, time.Local), intensity: 50, wantOK: true, want: 1000},
+		{name: "fallback to all seasons", timestamp: time.Date(2025, time.April, 1, 12, 0, 0, 0, time.Local), intensity: 50, wantOK: true, want: 1500},
+		{name: "no data", timestamp: time.Date(2025, time.August, 1, 3, 0, 0, 0, time.Local), intensity: 50},
+		{name: "never negative", timestamp: time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local), intensity: -50, wantOK: true, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.Expected(tt.timestamp, tt.intensity)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.InDelta(t, tt.want, got, 0.01)
+		})
+	}
+}
+
+func TestModel_MinSamples(t *testing.T) {
+	m := NewModel(makeMeasurements(4), 10)
+	_, ok := m.Expected(time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local), 50)
+	assert.False(t, ok)
+}
+
+func TestModel_ConstantIntensity(t *testing.T) {
+	measurements := repository.Measurements{
+		{Timestamp: time.Date(2024, time.July, 1, 12, 0, 0, 0, time.Local), Power: 1000, Intensity: 50},
+		{Timestamp: time.Date(2024, time.July, 2, 12, 0, 0, 0, time.Local), Power: 2000, Intensity: 50},
+	}
+	m := NewModel(measurements, 1)
+	got, ok := m.Expected(time.Date(2025, time.July, 1, 12, 0, 0, 0, time.Local), 80)
+	assert.True(t, ok)
+	assert.Equal(t, 1500.0, got)
+}
+
+func TestModel_NoIntensity(t *testing.T) {
+	measurements := makeMeasurements(100)
+	// measurements without intensity don't affect the model
+	measurements = append(measurements, repository.Measurement{Timestamp: time.Date(2024, time.August, 1, 12, 0, 0, 0, time.Local), Power: 5000, Intensity: math.NaN(), NoWeather: true})
+	got, ok := NewModel(measurements, 10).Expected(time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local), 50)
+	assert.True(t, ok)
+	assert.InDelta(t, 2000, got, 0.01)
+}
+
+func TestModel_Evaluate(t *testing.T) {
+	m := NewModel(makeMeasurements(100), 10)
+
+	measurements := repository.Measurements{
+		{Timestamp: time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local), Power: 1000, Intensity: 50, Weather: "SUN"},
+		{Timestamp: time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local), Power: 50, Intensity: 1, Weather: "SUN"},
+		{Timestamp: time.Date(2025, time.August, 1, 3, 0, 0, 0, time.Local), Power: 50, Intensity: 1, Weather: "NIGHT"},
+		{Timestamp: time.Date(2025, time.August, 1, 12, 15, 0, 0, time.Local), Power: 1000, Intensity: math.NaN(), Weather: repository.UnknownWeather, NoWeather: true},
+	}
+	want := []Performance{
+		{Timestamp: measurements[0].Timestamp, Weather: "SUN", Power: 1000, Intensity: 50, Expected: 2000, Ratio: 0.5},
+	}
+	got := m.Evaluate(measurements)
+	assert.Len(t, got, 1)
+	assert.InDelta(t, want[0].Ratio, got[0].Ratio, 0.001)
+	assert.InDelta(t, want[0].Expected, got[0].Expected, 0.01)
+	got[0].Ratio, got[0].Expected = want[0].Ratio, want[0].Expected
+	assert.Equal(t, want, got)
+}
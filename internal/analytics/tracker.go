@@ -0,0 +1,161 @@
+package analytics
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// A Tracker periodically fits a Model from the measurements in the Repository.
+//
+// Tracker also implements scraper.Store: it stores each measurement in the Repository and reports the measurement's
+// performance ratio as a Prometheus metric. If InverterPower is set, it also reports the energy lost due to clipping
+// during the current day.
+type Tracker struct {
+	Repository Repository
+	Logger     *slog.Logger
+	model      *Model
+	last       *Performance
+	// today holds the measurements stored during the current day, to estimate the energy lost due to clipping.
+	today      repository.Measurements
+	lostEnergy *float64
+	// History is the period of measurements used to fit the model. Defaults to one year.
+	History time.Duration
+	// RefitInterval determines how often the model is refitted. Defaults to one day.
+	RefitInterval time.Duration
+	// MinSamples is the minimum number of measurements needed to model an hour of the day.
+	MinSamples int
+	// InverterPower is the rated AC power of the inverter(s), in W. Used to detect clipping. Zero disables the lost energy metric.
+	InverterPower float64
+	lock          sync.RWMutex
+}
+
+type Repository interface {
+	Get(from, to time.Time) (repository.Measurements, error)
+	Store(repository.Measurement) error
+}
+
+var (
+	performanceRatioMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "", "performance_ratio"),
+		"Ratio of the last measured power vs. the power expected for the solar intensity",
+		nil,
+		nil,
+	)
+	expectedPowerMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "", "expected_power_watts"),
+		"Power expected for the solar intensity of the last measurement",
+		nil,
+		nil,
+	)
+	lostEnergyMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "", "lost_energy_watthours"),
+		"Energy lost due to clipping during the current day, estimated from the measurements stored since midnight",
+		nil,
+		nil,
+	)
+)
+
+func (t *Tracker) Run(ctx context.Context) error {
+	t.Logger.Debug("starting performance tracker")
+	defer t.Logger.Debug("stopped performance tracker")
+
+	interval := t.RefitInterval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Refit(time.Now()); err != nil {
+			t.Logger.Error("failed to fit performance model", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refit fits a new model from the measurements of the History before now.
+func (t *Tracker) Refit(now time.Time) error {
+	history := t.History
+	if history <= 0 {
+		history = 365 * 24 * time.Hour
+	}
+	measurements, err := t.Repository.Get(now.Add(-history), now)
+	if err != nil {
+		return err
+	}
+	model := NewModel(measurements, t.MinSamples)
+	t.lock.Lock()
+	t.model = model
+	t.lock.Unlock()
+	t.Logger.Debug("performance model fitted", "measurements", len(measurements))
+	return nil
+}
+
+// Model returns the current model. Returns nil if no model has been fitted yet.
+func (t *Tracker) Model() *Model {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.model
+}
+
+func (t *Tracker) Store(measurement repository.Measurement) error {
+	if err := t.Repository.Store(measurement); err != nil {
+		return err
+	}
+	model := t.Model()
+	if t.InverterPower > 0 {
+		t.trackClipping(measurement, model)
+	}
+	if model == nil || !measurement.HasIntensity() {
+		return nil
+	}
+	if performance := model.Evaluate(repository.Measurements{measurement}); len(performance) > 0 {
+		t.lock.Lock()
+		t.last = &performance[0]
+		t.lock.Unlock()
+		t.Logger.Debug("performance ratio calculated", "ratio", performance[0].Ratio, "expected", performance[0].Expected)
+	}
+	return nil
+}
+
+// trackClipping adds the measurement to the measurements of the current day and estimates the energy lost due to clipping.
+func (t *Tracker) trackClipping(measurement repository.Measurement, model *Model) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.today) > 0 && !sameDay(t.today[0].Timestamp, measurement.Timestamp) {
+		t.today = t.today[:0]
+	}
+	t.today = append(t.today, measurement)
+	if model != nil {
+		lostEnergy := Clipping(t.today, t.InverterPower, model).LostEnergy()
+		t.lostEnergy = &lostEnergy
+	}
+}
+
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- performanceRatioMetric
+	ch <- expectedPowerMetric
+	ch <- lostEnergyMetric
+}
+
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.lostEnergy != nil {
+		ch <- prometheus.MustNewConstMetric(lostEnergyMetric, prometheus.GaugeValue, *t.lostEnergy)
+	}
+	if t.last == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(performanceRatioMetric, prometheus.GaugeValue, t.last.Ratio)
+	ch <- prometheus.MustNewConstMetric(expectedPowerMetric, prometheus.GaugeValue, t.last.Expected)
+}
@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	repo := fakeRepository{measurements: makeMeasurements(100)}
+	tracker := Tracker{Repository: &repo, Logger: slog.New(slog.DiscardHandler), MinSamples: 10}
+
+	// no model yet: measurement is stored, but no metrics are reported
+	m := repository.Measurement{Timestamp: time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local), Power: 1500, Intensity: 50}
+	require.NoError(t, tracker.Store(m))
+	assert.Nil(t, tracker.Model())
+	assert.Zero(t, testutil.CollectAndCount(&tracker))
+
+	require.NoError(t, tracker.Refit(time.Now()))
+	require.NotNil(t, tracker.Model())
+	require.NoError(t, tracker.Store(m))
+	assert.Len(t, repo.stored, 2)
+
+	assert.NoError(t, testutil.CollectAndCompare(&tracker, strings.NewReader(`
+# HELP solaredge_expected_power_watts Power expected for the solar intensity of the last measurement
+# TYPE solaredge_expected_power_watts gauge
+solaredge_expected_power_watts 2000
+# HELP solaredge_performance_ratio Ratio of the last measured power vs. the power expected for the solar intensity
+# TYPE solaredge_performance_ratio gauge
+solaredge_performance_ratio 0.75
+`)))
+
+	repo.err = errors.New("db error")
+	assert.Error(t, tracker.Refit(time.Now()))
+	assert.Error(t, tracker.Store(m))
+	assert.NotNil(t, tracker.Model())
+}
+
+func TestTracker_LostEnergy(t *testing.T) {
+	repo := fakeRepository{measurements: makeMeasurements(100)}
+	tracker := Tracker{Repository: &repo, Logger: slog.New(slog.DiscardHandler), MinSamples: 10, InverterPower: 1600}
+	require.NoError(t, tracker.Refit(time.Now()))
+
+	// the model expects 2000 W at noon: two measurements at the ceiling, 5 minutes apart, lose 2 * 420 W * 5 min = 70 Wh
+	timestamp := time.Date(2025, time.August, 1, 12, 0, 0, 0, time.Local)
+	for _, m := range []repository.Measurement{
+		{Timestamp: timestamp, Power: 1580, Intensity: 50},
+		{Timestamp: timestamp.Add(5 * time.Minute), Power: 1580, Intensity: 50},
+	} {
+		require.NoError(t, tracker.Store(m))
+	}
+	assert.NoError(t, testutil.CollectAndCompare(&tracker, strings.NewReader(`
+# HELP solaredge_lost_energy_watthours Energy lost due to clipping during the current day, estimated from the measurements stored since midnight
+# TYPE solaredge_lost_energy_watthours gauge
+solaredge_lost_energy_watthours 70
+`), "solaredge_lost_energy_watthours"))
+
+	// a new day resets the lost energy
+	require.NoError(t, tracker.Store(repository.Measurement{Timestamp: timestamp.Add(24 * time.Hour), Power: 1000, Intensity: 50}))
+	assert.NoError(t, testutil.CollectAndCompare(&tracker, strings.NewReader(`
+# HELP solaredge_lost_energy_watthours Energy lost due to clipping during the current day, estimated from the measurements stored since midnight
+# TYPE solaredge_lost_energy_watthours gauge
+solaredge_lost_energy_watthours 0
+`), "solaredge_lost_energy_watthours"))
+}
+
+type fakeRepository struct {
+	err          error
+	measurements repository.Measurements
+	stored       repository.Measurements
+}
+
+func (f *fakeRepository) Get(_, _ time.Time) (repository.Measurements, error) {
+	return f.measurements, f.err
+}
+
+func (f *fakeRepository) Store(measurement repository.Measurement) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.stored = append(f.stored, measurement)
+	return nil
+}
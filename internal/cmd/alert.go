@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/alert"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/spf13/viper"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// newAlertEngine returns an alert Engine for the configured rules & notifiers. Returns nil if no notifiers are configured.
+func newAlertEngine(v *viper.Viper, solarEdge alert.Publisher[publisher.SolarEdgeUpdate], components map[string]alert.Component, logger *slog.Logger) *alert.Engine {
+	notifiers := newNotifiers(v)
+	if len(notifiers) == 0 {
+		return nil
+	}
+
+	var rules []alert.Rule
+	// without a location, daylight is only a rough guess, so the zero-power rule would fire at dawn & dusk
+	if d := v.GetDuration("alert.rules.zero-power"); d > 0 && hasLocation(v) {
+		rules = append(rules, alert.Rule{Name: "zero-power", For: d, Evaluator: alert.ZeroPower{IsDaylight: newDaylightFunc(v)}})
+	}
+	if threshold := v.GetFloat64("alert.rules.temperature"); threshold > 0 {
+		rules = append(rules, alert.Rule{Name: "inverter-temperature", For: v.GetDuration("alert.rules.temperature-for"), Evaluator: alert.InverterTemperature{Threshold: threshold}})
+	}
+	if d := v.GetDuration("alert.rules.power-limit"); d > 0 {
+		rules = append(rules, alert.Rule{Name: "power-limit", For: d, Evaluator: alert.PowerLimit{}})
+	}
+	if d := v.GetDuration("alert.rules.unhealthy"); d > 0 {
+		rules = append(rules, alert.Rule{Name: "unhealthy", For: d, Evaluator: alert.Unhealthy{Components: components}})
+	}
+
+	return &alert.Engine{
+		SolarEdge:      solarEdge,
+		Logger:         logger,
+		Rules:          rules,
+		Notifiers:      notifiers,
+		Interval:       v.GetDuration("alert.interval"),
+		ResendInterval: v.GetDuration("alert.resend-interval"),
+	}
+}
+
+func newNotifiers(v *viper.Viper) []alert.Notifier {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	var notifiers []alert.Notifier
+	if url := v.GetString("alert.webhook.url"); url != "" {
+		notifiers = append(notifiers, alert.Webhook{URL: url, HTTPClient: httpClient})
+	}
+	if url := v.GetString("alert.ntfy.url"); url != "" {
+		notifiers = append(notifiers, alert.Ntfy{URL: url, Token: v.GetString("alert.ntfy.token"), HTTPClient: httpClient})
+	}
+	if addr := v.GetString("alert.smtp.addr"); addr != "" {
+		notifiers = append(notifiers, alert.SMTP{
+			Addr:     addr,
+			From:     v.GetString("alert.smtp.from"),
+			To:       strings.Split(v.GetString("alert.smtp.to"), ","),
+			Username: v.GetString("alert.smtp.username"),
+			Password: v.GetString("alert.smtp.password"),
+			Timeout:  httpClient.Timeout,
+		})
+	}
+	return notifiers
+}
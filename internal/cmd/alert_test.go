@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/alert"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_newAlertEngine(t *testing.T) {
+	v := getViperFromViper(viper.GetViper())
+	assert.Nil(t, newAlertEngine(v, nil, nil, discardLogger))
+
+	v.Set("alert.ntfy.url", "https://ntfy.sh/solar")
+	v.Set("alert.smtp.addr", "localhost:25")
+	v.Set("alert.smtp.to", "me@example.com,you@example.com")
+	e := newAlertEngine(v, nil, nil, discardLogger)
+	require.NotNil(t, e)
+	require.Len(t, e.Notifiers, 2)
+	assert.Equal(t, []string{"me@example.com", "you@example.com"}, e.Notifiers[1].(alert.SMTP).To)
+
+	var rules []string
+	for _, rule := range e.Rules {
+		rules = append(rules, rule.Name)
+	}
+	// zero-power requires the location to determine daylight
+	assert.Equal(t, []string{"inverter-temperature", "power-limit", "unhealthy"}, rules)
+
+	v.Set("location.latitude", 50.85)
+	v.Set("location.longitude", 4.35)
+	e = newAlertEngine(v, nil, nil, discardLogger)
+	require.NotNil(t, e)
+	rules = rules[:0]
+	for _, rule := range e.Rules {
+		rules = append(rules, rule.Name)
+	}
+	assert.Equal(t, []string{"zero-power", "inverter-temperature", "power-limit", "unhealthy"}, rules)
+}
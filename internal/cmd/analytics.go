@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"github.com/clambin/solaredge-monitor/internal/forecast"
+	"github.com/spf13/viper"
+	"log/slog"
+)
+
+func newPerformanceTracker(v *viper.Viper, repo analytics.Repository, logger *slog.Logger) *analytics.Tracker {
+	return &analytics.Tracker{
+		Repository:    repo,
+		Logger:        logger,
+		History:       v.GetDuration("analytics.history"),
+		RefitInterval: v.GetDuration("analytics.refit-interval"),
+		MinSamples:    v.GetInt("analytics.min-samples"),
+		InverterPower: v.GetFloat64("clipping.inverter-power"),
+	}
+}
+
+// newForecaster returns a Forecaster for the configured location. If repo is not nil, the forecaster records
+// the forecast error of each hour. Returns nil if no forecast URL or location is configured.
+func newForecaster(v *viper.Viper, model forecast.PerformanceModel, repo forecast.Repository, logger *slog.Logger) *forecast.Forecaster {
+	url := v.GetString("forecast.url")
+	latitude, longitude := v.GetFloat64("location.latitude"), v.GetFloat64("location.longitude")
+	if url == "" || (latitude == 0 && longitude == 0) {
+		return nil
+	}
+	return &forecast.Forecaster{
+		Source:     forecast.OpenMeteo{URL: url, Latitude: latitude, Longitude: longitude},
+		Model:      model,
+		Repository: repo,
+		Logger:     logger,
+		Interval:   v.GetDuration("forecast.interval"),
+		Horizon:    v.GetDuration("forecast.horizon"),
+	}
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/forecast"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_newForecaster(t *testing.T) {
+	v := getViperFromViper(viper.GetViper())
+	assert.Nil(t, newForecaster(v, nil, nil, discardLogger))
+
+	v.Set("location.latitude", 51.2)
+	v.Set("location.longitude", 4.4)
+	f := newForecaster(v, nil, nil, discardLogger)
+	require.NotNil(t, f)
+	assert.Equal(t, forecast.OpenMeteo{URL: forecast.DefaultOpenMeteoURL, Latitude: 51.2, Longitude: 4.4}, f.Source)
+	assert.Nil(t, f.Repository)
+
+	v.Set("forecast.url", "")
+	assert.Nil(t, newForecaster(v, nil, nil, discardLogger))
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	analyzeCmd = cobra.Command{
+		Use:   "analyze",
+		Short: "analyze the measurements in the database",
+	}
+
+	degradationCmd = cobra.Command{
+		Use:   "degradation",
+		Short: "report the year-over-year degradation of the panels",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			charmer.SetTextLogger(cmd, viper.GetBool("debug"))
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			repo, err := repository.NewPostgresDB(viper.GetString("database.url"))
+			if err != nil {
+				return fmt.Errorf("database: %w", err)
+			}
+			return runDegradation(viper.GetViper(), repo, cmd.OutOrStdout())
+		},
+	}
+)
+
+type measurementRepository interface {
+	Get(from, to time.Time) (repository.Measurements, error)
+	GetDataRange() (time.Time, time.Time, error)
+}
+
+func runDegradation(v *viper.Viper, repo measurementRepository, stdout io.Writer) error {
+	start, end, err := repo.GetDataRange()
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	measurements, err := repo.Get(start, end)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	report, err := analytics.Degradation(measurements, v.GetFloat64("analytics.min-intensity"))
+	if err != nil {
+		return fmt.Errorf("degradation: %w", err)
+	}
+	return writeDegradationReport(stdout, report)
+}
+
+func writeDegradationReport(w io.Writer, report analytics.DegradationReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "Year\tSamples\tOutput (W/%)\tIndex")
+	for _, year := range report.Years {
+		index := "-"
+		if year.Index != 0 {
+			index = fmt.Sprintf("%.3f", year.Index)
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%d\t%.2f\t%s\n", year.Year, year.Samples, year.Output, index)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nDegradation: %.2f%%/year (%.0f%% confidence interval: %.2f%% to %.2f%%), based on %d months\n",
+		100*report.Rate, 100*report.Confidence, 100*report.Lower, 100*report.Upper, report.Months,
+	)
+	return err
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_runDegradation(t *testing.T) {
+	start := time.Date(2021, time.January, 1, 12, 0, 0, 0, time.Local)
+	var measurements repository.Measurements
+	for day := range 3 * 365 {
+		timestamp := start.AddDate(0, 0, day)
+		age := timestamp.Sub(start).Hours() / (365.25 * 24)
+		measurements = append(measurements, repository.Measurement{
+			Timestamp: timestamp,
+			Weather:   "SUN",
+			Power:     40 * (1 - 0.01*age) * 75,
+			Intensity: 75,
+		})
+	}
+
+	v := getViperFromViper(viper.GetViper())
+	var stdout bytes.Buffer
+	require.NoError(t, runDegradation(v, fakeMeasurementRepository{measurements: measurements}, &stdout))
+	assert.Equal(t, `Year  Samples  Output (W/%)  Index
+2021  365      39.80         1.010
+2022  365      39.40         1.000
+2023  365      39.00         0.990
+
+Degradation: -1.01%/year (95% confidence interval: -1.02% to -1.01%), based on 36 months
+`, stdout.String())
+
+	assert.ErrorContains(t, runDegradation(v, fakeMeasurementRepository{measurements: measurements[:100]}, &stdout), "degradation: insufficient data")
+	assert.ErrorContains(t, runDegradation(v, fakeMeasurementRepository{err: errors.New("db failure")}, &stdout), "database: db failure")
+}
+
+type fakeMeasurementRepository struct {
+	err          error
+	measurements repository.Measurements
+}
+
+func (f fakeMeasurementRepository) Get(_, _ time.Time) (repository.Measurements, error) {
+	return f.measurements, f.err
+}
+
+func (f fakeMeasurementRepository) GetDataRange() (time.Time, time.Time, error) {
+	if len(f.measurements) == 0 {
+		return time.Time{}, time.Time{}, f.err
+	}
+	return f.measurements[0].Timestamp, f.measurements[len(f.measurements)-1].Timestamp, f.err
+}
@@ -5,7 +5,11 @@ import (
 	"codeberg.org/clambin/go-common/httputils/roundtripper"
 	"context"
 	"fmt"
-	"github.com/clambin/solaredge-monitor/oauth2redis"
+	"github.com/clambin/solaredge-monitor/internal/emissions"
+	"github.com/clambin/solaredge-monitor/internal/exporter"
+	"github.com/clambin/solaredge-monitor/internal/inventory"
+	"github.com/clambin/solaredge-monitor/internal/mqtt"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
 	"github.com/clambin/solaredge/v2"
 	"github.com/clambin/tado/v2"
 	"github.com/clambin/tado/v2/oauth2store"
@@ -13,21 +17,39 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// siteClient provides the SolarEdge site data that isn't part of the regular SolarEdge updates.
+type siteClient interface {
+	inventory.Client
+	emissions.Client
+}
+
 func newSolarEdgeClient(subsystem string, r prometheus.Registerer, v *viper.Viper) solaredge.Client {
+	token := v.GetString("solaredge.token")
+	return *newSolarEdgeClients(subsystem, r, []string{token})[token]
+}
+
+// newSolarEdgeClients returns a client for each SolarEdge token. The clients share their request metrics.
+func newSolarEdgeClients(subsystem string, r prometheus.Registerer, tokens []string) map[string]*solaredge.Client {
 	solarEdgeMetrics := metrics.NewRequestMetrics(metrics.Options{Namespace: "solaredge", Subsystem: subsystem, ConstLabels: prometheus.Labels{"application": "solaredge"}})
 	r.MustRegister(solarEdgeMetrics)
 
-	return solaredge.Client{
-		SiteKey: v.GetString("solaredge.token"),
-		HTTPClient: &http.Client{
-			Timeout:   5 * time.Second,
-			Transport: roundtripper.New(roundtripper.WithRequestMetrics(solarEdgeMetrics)),
-		},
+	clients := make(map[string]*solaredge.Client, len(tokens))
+	for _, token := range tokens {
+		clients[token] = &solaredge.Client{
+			SiteKey: token,
+			HTTPClient: &http.Client{
+				Timeout:   5 * time.Second,
+				Transport: roundtripper.New(roundtripper.WithRequestMetrics(solarEdgeMetrics)),
+			},
+		}
 	}
+	return clients
 }
 
 func newRedisClient(v *viper.Viper) *redis.Client {
@@ -43,16 +65,16 @@ func newRedisClient(v *viper.Viper) *redis.Client {
 	return redisClient
 }
 
-func newTadoClient(ctx context.Context, r prometheus.Registerer, redisClient *redis.Client) (*tado.ClientWithResponses, error) {
-	tadoMetrics := metrics.NewRequestMetrics(metrics.Options{Namespace: "solaredge", Subsystem: "scraper", ConstLabels: prometheus.Labels{"application": "tado"}})
-	r.MustRegister(tadoMetrics)
-
-	tadoHttpClient, err := newOAuth2Client(ctx, redisClient, func(response *oauth2.DeviceAuthResponse) {
-		fmt.Printf("No token found. Visit %s and log in ...\n", response.VerificationURIComplete)
-	})
+// newTadoClient returns a Tado client that uses the stored Tado token. Returns errNoTadoToken if no token is stored.
+func newTadoClient(ctx context.Context, r prometheus.Registerer, store oauth2store.TokenStore) (*tado.ClientWithResponses, error) {
+	tadoHttpClient, err := newOAuth2Client(ctx, store)
 	if err != nil {
 		return nil, err
 	}
+
+	tadoMetrics := metrics.NewRequestMetrics(metrics.Options{Namespace: "solaredge", Subsystem: "scraper", ConstLabels: prometheus.Labels{"application": "tado"}})
+	r.MustRegister(tadoMetrics)
+
 	origTP := tadoHttpClient.Transport
 	tadoHttpClient.Transport = roundtripper.New(
 		roundtripper.WithRequestMetrics(tadoMetrics),
@@ -61,20 +83,11 @@ func newTadoClient(ctx context.Context, r prometheus.Registerer, redisClient *re
 	return tado.NewClientWithResponses(tado.ServerURL, tado.WithHTTPClient(tadoHttpClient))
 }
 
-func newOAuth2Client(ctx context.Context, redisClient *redis.Client, deviceAuthCallback func(response *oauth2.DeviceAuthResponse)) (client *http.Client, err error) {
-	// store to save our token
-	store := oauth2redis.NewRedisTokenStore(redisClient, "github.com/clambin/solaredge-monitor/oauth2redis", 30*24*time.Hour)
-	token, err := store.Load()
+// newOAuth2Client returns an HTTP client that authenticates with the stored token and saves the token when it's refreshed.
+func newOAuth2Client(ctx context.Context, store oauth2store.TokenStore) (*http.Client, error) {
+	token, err := loadTadoToken(store)
 	if err != nil {
-		// store doesn't contain a valid token. ask the user to log in
-		var devAuthResponse *oauth2.DeviceAuthResponse
-		if devAuthResponse, err = tado.Config.DeviceAuth(ctx); err != nil {
-			return nil, fmt.Errorf("DevAuth: %w", err)
-		}
-		deviceAuthCallback(devAuthResponse)
-		if token, err = tado.Config.DeviceAccessToken(ctx, devAuthResponse); err != nil {
-			return nil, fmt.Errorf("DeviceAccessToken: %w", err)
-		}
+		return nil, err
 	}
 	pts := oauth2store.TokenSource{
 		TokenSource: tado.Config.TokenSource(ctx, token),
@@ -82,3 +95,75 @@ func newOAuth2Client(ctx context.Context, redisClient *redis.Client, deviceAuthC
 	}
 	return oauth2.NewClient(ctx, &pts), nil
 }
+
+// newMQTTSink returns a Sink that publishes the updates to MQTT. Returns nil if no MQTT broker is configured.
+func newMQTTSink(v *viper.Viper, solarEdge mqtt.Publisher[publisher.SolarEdgeUpdate], weather mqtt.Publisher[*tado.Weather], logger *slog.Logger) *mqtt.Sink {
+	broker := v.GetString("mqtt.broker")
+	if broker == "" {
+		return nil
+	}
+	return &mqtt.Sink{
+		SolarEdge: solarEdge,
+		Tado:      weather,
+		Logger:    logger,
+		Config: mqtt.Config{
+			Broker:            broker,
+			ClientID:          v.GetString("mqtt.client-id"),
+			Username:          v.GetString("mqtt.username"),
+			Password:          v.GetString("mqtt.password"),
+			TopicPrefix:       v.GetString("mqtt.topic"),
+			DiscoveryPrefix:   v.GetString("mqtt.discovery"),
+			ReconnectInterval: v.GetDuration("mqtt.reconnect-interval"),
+		},
+	}
+}
+
+// newPushers returns a Pusher for each configured push target (InfluxDB and/or OTLP).
+func newPushers(v *viper.Viper, solarEdge exporter.Publisher[publisher.SolarEdgeUpdate], weather exporter.Publisher[*tado.Weather], logger *slog.Logger) []exporter.Pusher {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	var writers []exporter.PointWriter
+	if target := v.GetString("influxdb.url"); target != "" {
+		writers = append(writers, exporter.InfluxDBWriter{
+			URL:        target,
+			Org:        v.GetString("influxdb.org"),
+			Bucket:     v.GetString("influxdb.bucket"),
+			Token:      v.GetString("influxdb.token"),
+			HTTPClient: httpClient,
+		})
+	}
+	if target := v.GetString("otlp.url"); target != "" {
+		writers = append(writers, exporter.OTLPWriter{
+			URL:        target,
+			Headers:    parseHeaders(v.GetString("otlp.headers")),
+			HTTPClient: httpClient,
+		})
+	}
+
+	pushers := make([]exporter.Pusher, len(writers))
+	for i, writer := range writers {
+		pushers[i] = exporter.Pusher{
+			SolarEdge: solarEdge,
+			Tado:      weather,
+			Writer:    writer,
+			Logger:    logger.With("writer", fmt.Sprintf("%T", writer)),
+			PushConfig: exporter.PushConfig{
+				BatchSize:     v.GetInt("push.batch-size"),
+				FlushInterval: v.GetDuration("push.flush-interval"),
+				MaxRetries:    v.GetInt("push.max-retries"),
+				RetryInterval: v.GetDuration("push.retry-interval"),
+			},
+		}
+	}
+	return pushers
+}
+
+// parseHeaders parses a comma-separated list of key=value pairs.
+func parseHeaders(arg string) map[string]string {
+	headers := make(map[string]string)
+	for header := range strings.SplitSeq(arg, ",") {
+		if key, value, ok := strings.Cut(header, "="); ok {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
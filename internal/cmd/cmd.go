@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"codeberg.org/clambin/go-common/charmer"
+	"github.com/clambin/solaredge-monitor/internal/emissions"
+	"github.com/clambin/solaredge-monitor/internal/forecast"
+	"github.com/clambin/solaredge-monitor/oauth2redis"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"log/slog"
@@ -16,11 +19,47 @@ var (
 	}
 
 	commonArguments = charmer.Arguments{
-		"debug":            {Default: false, Help: "Log debug messages"},
-		"pprof":            {Default: "", Help: "Address for pprof endpoint (blank: don't run pprof"},
-		"prometheus.addr":  {Default: ":9090", Help: "Prometheus metrics endpoint"},
-		"solaredge.token":  {Default: "", Help: "SolarEdge API token"},
-		"polling.interval": {Default: 5 * time.Minute, Help: "Polling interval"},
+		"debug":              {Default: false, Help: "Log debug messages"},
+		"pprof":              {Default: "", Help: "Address for pprof endpoint (blank: don't run pprof"},
+		"prometheus.addr":    {Default: ":9090", Help: "Prometheus metrics endpoint"},
+		"solaredge.token":    {Default: "", Help: "SolarEdge API token"},
+		"solaredge.meter":    {Default: false, Help: "Sites have a meter: also collect consumption, grid import/export and self-consumption"},
+		"solaredge.storage":  {Default: false, Help: "Sites have batteries: also collect the state of the batteries"},
+		"polling.interval":   {Default: 5 * time.Minute, Help: "Polling interval"},
+		"exporter.max-age":   {Default: time.Duration(0), Help: "Remove metrics that haven't been updated for this long (0: never remove)"},
+		"location.latitude":  {Default: 0.0, Help: "Latitude of the installation (0 & 0: unknown)"},
+		"location.longitude": {Default: 0.0, Help: "Longitude of the installation (0 & 0: unknown)"},
+	}
+
+	healthArguments = charmer.Arguments{
+		"health.timeout":           {Default: 5 * time.Second, Help: "Timeout for each health check"},
+		"health.interval":          {Default: 30 * time.Second, Help: "How often the health checks are evaluated for the solaredge_component_healthy metric"},
+		"health.max-failures":      {Default: 0, Help: "Number of consecutive failed updates that marks a source as unhealthy (0: disabled)"},
+		"health.solaredge.max-age": {Default: 30 * time.Minute, Help: "Maximum time since the last SolarEdge update during the day. At night, SolarEdge is not checked"},
+		"health.tado.max-age":      {Default: time.Duration(0), Help: "Maximum time since the last Tado update (0: 5 times the polling interval)"},
+	}
+
+	forecastArguments = charmer.Arguments{
+		"forecast.url":      {Default: forecast.DefaultOpenMeteoURL, Help: "Open-Meteo compatible forecast API. Requires the location to be set (blank: no forecast)"},
+		"forecast.interval": {Default: time.Hour, Help: "How often the forecast is updated"},
+		"forecast.horizon":  {Default: 48 * time.Hour, Help: "Period covered by the forecast"},
+	}
+
+	clippingArguments = charmer.Arguments{
+		"clipping.inverter-power": {Default: 0.0, Help: "Rated AC power of the inverter(s) in Watt, used to detect clipping (0: clipping is not detected)"},
+	}
+
+	tariffArguments = charmer.Arguments{
+		"tariffs.currency":          {Default: "EUR", Help: "Currency of the tariffs. The tariffs themselves are configured in the configuration file (no tariffs: no savings metrics & reports)"},
+		"tariffs.self-consumption":  {Default: 0.0, Help: "Fraction of the production consumed on site, valued at the import price. The remainder is valued at the feed-in tariff"},
+		"tariffs.installation-cost": {Default: 0.0, Help: "Cost of the installation, used to track payback (0: unknown)"},
+	}
+
+	emissionsArguments = charmer.Arguments{
+		"emissions.country":         {Default: "", Help: "Country whose grid emission factors are used. The factors themselves are configured in the configuration file (blank: no emissions metrics & reports)"},
+		"emissions.tree-absorption": {Default: emissions.DefaultTreeAbsorption, Help: "CO2 absorbed by a tree in one year, in kg"},
+		"emissions.fossil-fuel":     {Default: emissions.DefaultFossilFuel, Help: "Fossil fuel burnt to generate one kWh, in kg"},
+		"emissions.check-interval":  {Default: 24 * time.Hour, Help: "How often the avoided emissions are cross-checked against SolarEdge's environmental benefits (0: disabled)"},
 	}
 
 	redisArguments = charmer.Arguments{
@@ -30,18 +69,95 @@ var (
 		"redis.db":       {Default: 0, Help: "Redis cache db"},
 	}
 
+	tadoTokenArguments = charmer.Arguments{
+		"tado.token.store":    {Default: "redis", Help: "Where the Tado token is stored: redis or file"},
+		"tado.token.file":     {Default: "tado-token.json", Help: "File that stores the Tado token (tado.token.store: file)"},
+		"tado.token.account":  {Default: "", Help: "Tado account whose token is stored in Redis. Scrapers using different Tado accounts need a different account to share a Redis server"},
+		"tado.token.timeout":  {Default: oauth2redis.DefaultTimeout, Help: "Timeout to save or load the Tado token in Redis"},
+		"tado.token.key-file": {Default: "", Help: "File with the base64-encoded AES key that encrypts the Tado token. If blank, the key is read from $" + tadoTokenKeyEnv + ". If neither is set, the token is not encrypted"},
+	}
+
+	gapsArguments = charmer.Arguments{
+		"gaps.interval":      {Default: time.Duration(0), Help: "Expected time between two measurements (0: the scraper's scrape.interval). Backfilled measurements are 15 minutes apart, so they only close a gap if the interval is at least 10 minutes"},
+		"gaps.min-elevation": {Default: 10.0, Help: "Elevation of the sun, in degrees, below which the site isn't expected to produce power"},
+	}
+
+	gapsCmdArguments = charmer.Arguments{
+		"gaps.days":     {Default: 30, Help: "Number of days to check for gaps"},
+		"gaps.backfill": {Default: false, Help: "Store the power measured by SolarEdge during the gaps. The measurements are stored without weather"},
+	}
+
 	dbArguments = charmer.Arguments{
 		"database.url": {Default: "", Help: "Postgres connection string (postgres://<user>:<password>@<host>:<port>/<dbname>)"},
 	}
 	webArguments = charmer.Arguments{
 		"web.addr":           {Default: ":8080", Help: "Address for web endpoint"},
+		"web.health.addr":    {Default: ":9091", Help: "Health probe address"},
 		"web.cache.rounding": {Default: 15 * time.Minute, Help: "Cache granularity rounding"},
 		"web.cache.ttl":      {Default: time.Hour, Help: "Time to cache images"},
 	}
 
+	mqttArguments = charmer.Arguments{
+		"mqtt.broker":             {Default: "", Help: "MQTT broker URL, e.g. tcp://localhost:1883 (blank: don't publish to MQTT)"},
+		"mqtt.client-id":          {Default: "solaredge-monitor", Help: "MQTT client ID"},
+		"mqtt.username":           {Default: "", Help: "MQTT username"},
+		"mqtt.password":           {Default: "", Help: "MQTT password"},
+		"mqtt.topic":              {Default: "solaredge", Help: "MQTT topic prefix"},
+		"mqtt.discovery":          {Default: "homeassistant", Help: "Home Assistant discovery prefix (blank: disable discovery)"},
+		"mqtt.reconnect-interval": {Default: 30 * time.Second, Help: "Time between two connection attempts to the MQTT broker"},
+	}
+
+	pushArguments = charmer.Arguments{
+		"influxdb.url":        {Default: "", Help: "InfluxDB server URL, e.g. http://localhost:8086 (blank: don't push to InfluxDB)"},
+		"influxdb.org":        {Default: "", Help: "InfluxDB organization"},
+		"influxdb.bucket":     {Default: "solaredge", Help: "InfluxDB bucket"},
+		"influxdb.token":      {Default: "", Help: "InfluxDB API token"},
+		"otlp.url":            {Default: "", Help: "OTLP/HTTP metrics endpoint, e.g. http://localhost:4318/v1/metrics (blank: don't push OTLP metrics)"},
+		"otlp.headers":        {Default: "", Help: "Comma-separated list of key=value headers to add to OTLP requests"},
+		"push.batch-size":     {Default: 100, Help: "Number of points that triggers a push"},
+		"push.flush-interval": {Default: 10 * time.Second, Help: "Maximum time points are buffered before they are pushed"},
+		"push.max-retries":    {Default: 3, Help: "Number of times a failed push is retried"},
+		"push.retry-interval": {Default: time.Second, Help: "Time to wait before retrying a failed push. Doubles with each attempt"},
+	}
+
+	alertArguments = charmer.Arguments{
+		"alert.interval":              {Default: time.Minute, Help: "How often alert rules are evaluated"},
+		"alert.resend-interval":       {Default: 4 * time.Hour, Help: "How often a notification is repeated while an alert is firing (0: never)"},
+		"alert.rules.zero-power":      {Default: 30 * time.Minute, Help: "Alert when a site produces no power during daylight for this long (0: disabled). Requires location.latitude and location.longitude"},
+		"alert.rules.temperature":     {Default: 75.0, Help: "Alert when an inverter's temperature exceeds this value in °C (0: disabled)"},
+		"alert.rules.temperature-for": {Default: 10 * time.Minute, Help: "Time an inverter's temperature must exceed the threshold before alerting"},
+		"alert.rules.power-limit":     {Default: 30 * time.Minute, Help: "Alert when an inverter's power is limited for this long (0: disabled)"},
+		"alert.rules.unhealthy":       {Default: 15 * time.Minute, Help: "Alert when a source is unhealthy for this long (0: disabled)"},
+		"alert.webhook.url":           {Default: "", Help: "URL to post alert notifications to (blank: disabled)"},
+		"alert.ntfy.url":              {Default: "", Help: "ntfy topic URL to publish alert notifications to, e.g. https://ntfy.sh/my-topic (blank: disabled)"},
+		"alert.ntfy.token":            {Default: "", Help: "ntfy access token"},
+		"alert.smtp.addr":             {Default: "", Help: "SMTP server to send alert notifications with, e.g. smtp.example.com:587 (blank: disabled)"},
+		"alert.smtp.from":             {Default: "", Help: "Sender address of alert emails"},
+		"alert.smtp.to":               {Default: "", Help: "Comma-separated list of recipients of alert emails"},
+		"alert.smtp.username":         {Default: "", Help: "SMTP username"},
+		"alert.smtp.password":         {Default: "", Help: "SMTP password"},
+	}
+
+	analyticsArguments = charmer.Arguments{
+		"analytics.history":        {Default: 365 * 24 * time.Hour, Help: "Period of measurements used to fit the expected-power model"},
+		"analytics.refit-interval": {Default: 24 * time.Hour, Help: "How often the expected-power model is refitted"},
+		"analytics.min-samples":    {Default: 10, Help: "Minimum number of measurements needed to model an hour of the day"},
+		"analytics.min-intensity":  {Default: 50.0, Help: "Minimum solar intensity of the clear-sky measurements used to determine panel degradation"},
+	}
+
+	exportArguments = charmer.Arguments{
+		"export.health.addr": {Default: ":9091", Help: "Health probe address"},
+	}
+
 	scrapeArguments = charmer.Arguments{
-		"scrape.interval":    {Default: 15 * time.Minute, Help: "Scraper interval"},
-		"scrape.health.addr": {Default: ":9091", Help: "Health probe address"},
+		"scrape.interval":      {Default: defaultScrapeInterval, Help: "Scraper interval"},
+		"scrape.health.addr":   {Default: ":9091", Help: "Health probe address"},
+		"tado.home":            {Default: "", Help: "Tado home that provides the weather, by ID or name (blank: the account's first home). Multiple sites are configured in the configuration file"},
+		"tado.optional":        {Default: false, Help: "Run without weather if Tado is not available, rather than failing. Log in with 'solaredge tado login'"},
+		"scrape.fill.interval": {Default: time.Hour, Help: "How often the solar intensity of measurements stored without weather is interpolated (0: disabled)"},
+		"scrape.fill.max-gap":  {Default: time.Hour, Help: "Maximum time between a measurement stored without weather and the measurements its solar intensity is interpolated from. Measurements further away from a measurement with weather keep no intensity"},
+		"scrape.fill.lookback": {Default: 7 * 24 * time.Hour, Help: "Only interpolate the solar intensity of measurements stored during this period, so measurements that can't be interpolated aren't retried forever (0: all measurements)"},
+		"inventory.interval":   {Default: time.Hour, Help: "How often the equipment inventory is checked (0: disabled)"},
 		//"scrape.health.path": {Default: "/health", Help: "Health probe path"},
 	}
 )
@@ -50,9 +166,16 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	RootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Configuration file")
 	setFlags(&RootCmd, viper.GetViper(), commonArguments)
-	setFlags(&webCmd, viper.GetViper(), dbArguments, redisArguments, webArguments)
-	setFlags(&scrapeCmd, viper.GetViper(), dbArguments, redisArguments, scrapeArguments)
-	RootCmd.AddCommand(&webCmd, &exportCmd, &scrapeCmd)
+	setFlags(&webCmd, viper.GetViper(), dbArguments, redisArguments, healthArguments, analyticsArguments, forecastArguments, clippingArguments, tariffArguments, emissionsArguments, gapsArguments, webArguments)
+	setFlags(&exportCmd, viper.GetViper(), mqttArguments, pushArguments, healthArguments, alertArguments, clippingArguments, tariffArguments, emissionsArguments, exportArguments)
+	setFlags(&scrapeCmd, viper.GetViper(), dbArguments, redisArguments, mqttArguments, pushArguments, healthArguments, alertArguments, analyticsArguments, forecastArguments, clippingArguments, tariffArguments, emissionsArguments, tadoTokenArguments, scrapeArguments)
+	setFlags(&simulateCmd, viper.GetViper(), dbArguments, simulateArguments)
+	setFlags(&analyzeCmd, viper.GetViper(), dbArguments, analyticsArguments)
+	setFlags(&tadoCmd, viper.GetViper(), redisArguments, tadoTokenArguments)
+	setFlags(&gapsCmd, viper.GetViper(), dbArguments, gapsArguments, gapsCmdArguments)
+	analyzeCmd.AddCommand(&degradationCmd)
+	tadoCmd.AddCommand(&tadoLoginCmd, &tadoStatusCmd, &tadoLogoutCmd)
+	RootCmd.AddCommand(&webCmd, &exportCmd, &scrapeCmd, &simulateCmd, &analyzeCmd, &tadoCmd, &gapsCmd)
 }
 
 func initConfig() {
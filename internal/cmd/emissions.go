@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/emissions"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/spf13/viper"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// newEmissionFactors returns the emission factors of the configured country. Returns nil if no country is configured.
+//
+// The grid emission factors (in kg CO2 per kWh) are configured per country and per year in the configuration file, e.g.:
+//
+//	emissions:
+//	  country: BE
+//	  factors:
+//	    BE:
+//	      2023: 0.16
+//	      2024: 0.14
+func newEmissionFactors(v *viper.Viper) (*emissions.Factors, error) {
+	country := v.GetString("emissions.country")
+	if country == "" {
+		return nil, nil
+	}
+	// viper keys are case-insensitive
+	var years map[string]float64
+	if err := v.UnmarshalKey("emissions.factors."+strings.ToLower(country), &years); err != nil {
+		return nil, fmt.Errorf("emissions: %w", err)
+	}
+	factors := emissions.Factors{
+		Country:        country,
+		CO2:            make(map[int]float64, len(years)),
+		TreeAbsorption: v.GetFloat64("emissions.tree-absorption"),
+		FossilFuel:     v.GetFloat64("emissions.fossil-fuel"),
+	}
+	for year, factor := range years {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return nil, fmt.Errorf("emissions: invalid year %q", year)
+		}
+		factors.CO2[y] = factor
+	}
+	if err := factors.Validate(); err != nil {
+		return nil, fmt.Errorf("emissions: %s: %w", country, err)
+	}
+	return &factors, nil
+}
+
+// newEmissionsTracker returns a Tracker that determines the emissions avoided by the production reported by SolarEdge.
+// If client is not nil, the Tracker cross-checks its estimate against SolarEdge's. Returns nil if no country is configured.
+func newEmissionsTracker(v *viper.Viper, solarEdge emissions.Publisher[publisher.SolarEdgeUpdate], client emissions.Client, logger *slog.Logger) (*emissions.Tracker, error) {
+	factors, err := newEmissionFactors(v)
+	if err != nil || factors == nil {
+		return nil, err
+	}
+	tracker := emissions.Tracker{SolarEdge: solarEdge, Factors: *factors, Interval: v.GetDuration("emissions.check-interval"), Logger: logger}
+	if client != nil && tracker.Interval > 0 {
+		tracker.Client = client
+	}
+	return &tracker, nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/clambin/solaredge-monitor/internal/emissions"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_newEmissionFactors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    *emissions.Factors
+		wantErr string
+	}{
+		{
+			name: "no country",
+		},
+		{
+			name: "valid",
+			config: `
+emissions:
+  country: BE
+  tree-absorption: 20
+  fossil-fuel: 0.3
+  factors:
+    BE:
+      2023: 0.16
+      2024: 0.14
+    NL:
+      2024: 0.3
+`,
+			want: &emissions.Factors{Country: "BE", CO2: map[int]float64{2023: 0.16, 2024: 0.14}, TreeAbsorption: 20, FossilFuel: 0.3},
+		},
+		{
+			name: "unknown country",
+			config: `
+emissions:
+  country: FR
+  tree-absorption: 20
+  factors:
+    BE:
+      2024: 0.14
+`,
+			wantErr: "emissions: FR: no emission factors",
+		},
+		{
+			name: "invalid year",
+			config: `
+emissions:
+  country: BE
+  factors:
+    BE:
+      last-year: 0.14
+`,
+			wantErr: `emissions: invalid year "last-year"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.SetConfigType("yaml")
+			require.NoError(t, v.MergeConfig(bytes.NewBufferString(tt.config)))
+
+			factors, err := newEmissionFactors(v)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, factors)
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"codeberg.org/clambin/go-common/charmer"
 	"codeberg.org/clambin/go-common/httputils"
 	"context"
+	"github.com/clambin/solaredge-monitor/internal/alert"
 	"github.com/clambin/solaredge-monitor/internal/exporter"
+	"github.com/clambin/solaredge-monitor/internal/health"
 	"github.com/clambin/solaredge-monitor/internal/publisher"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -32,7 +34,8 @@ var (
 				cmd.Root().Version,
 				viper.GetViper(),
 				prometheus.DefaultRegisterer,
-				publisher.SolarEdgeUpdater{SolarEdgeClient: &solarEdgeClient},
+				publisher.SolarEdgeUpdater{SolarEdgeClient: publisher.PhasesSolarEdgeClient{Client: &solarEdgeClient}, Meter: viper.GetBool("solaredge.meter"), Storage: viper.GetBool("solaredge.storage")},
+				&solarEdgeClient,
 				logger,
 			)
 		},
@@ -45,6 +48,7 @@ func runExport(
 	v *viper.Viper,
 	r prometheus.Registerer,
 	solarEdgeUpdater publisher.Updater[publisher.SolarEdgeUpdate],
+	siteClient siteClient,
 	logger *slog.Logger,
 ) error {
 	logger.Info("starting solaredge exporter", "version", version)
@@ -61,23 +65,75 @@ func runExport(
 	r.MustRegister(exportMetrics)
 
 	solarEdgePoller := publisher.Publisher[publisher.SolarEdgeUpdate]{
-		Updater:  solarEdgeUpdater,
-		Interval: v.GetDuration("polling.interval"),
-		Logger:   logger.With("publisher", "solaredge"),
+		Updater:      solarEdgeUpdater,
+		Name:         "SolarEdge",
+		HealthPolicy: newSolarEdgeHealthPolicy(v),
+		Interval:     v.GetDuration("polling.interval"),
+		Logger:       logger.With("publisher", "solaredge"),
 	}
 
 	exp := exporter.Exporter{
-		SolarEdge: &solarEdgePoller,
-		Metrics:   exportMetrics,
-		Logger:    logger,
+		SolarEdge:     &solarEdgePoller,
+		Sources:       map[string]exporter.Source{"solaredge": &solarEdgePoller},
+		Metrics:       exportMetrics,
+		Logger:        logger,
+		MaxAge:        v.GetDuration("exporter.max-age"),
+		InverterPower: v.GetFloat64("clipping.inverter-power"),
+	}
+
+	healthProbe := health.Health{
+		Logger:   logger.With("component", "health"),
+		Timeout:  v.GetDuration("health.timeout"),
+		Interval: v.GetDuration("health.interval"),
+		Checks: []health.Check{
+			{Name: "solaredge", Component: &solarEdgePoller},
+			{Name: "solaredge-poller", Component: health.IsHealthyFunc(solarEdgePoller.IsAlive), Live: true},
+		},
+	}
+	r.MustRegister(&healthProbe)
+
+	tariffTracker, err := newTariffTracker(v, &solarEdgePoller, logger.With("component", "tariff"))
+	if err != nil {
+		return err
+	}
+	if tariffTracker != nil {
+		r.MustRegister(tariffTracker)
+	}
+	emissionsTracker, err := newEmissionsTracker(v, &solarEdgePoller, siteClient, logger.With("component", "emissions"))
+	if err != nil {
+		return err
+	}
+	if emissionsTracker != nil {
+		r.MustRegister(emissionsTracker)
 	}
 
 	var group errgroup.Group
 	group.Go(func() error {
 		return httputils.RunServer(ctx, &http.Server{Addr: v.GetString("prometheus.addr"), Handler: promhttp.Handler()})
 	})
+	group.Go(func() error {
+		addr := v.GetString("export.health.addr")
+		logger.Debug("starting health probe", "addr", addr)
+		return httputils.RunServer(ctx, &http.Server{Addr: addr, Handler: healthProbe.Handler()})
+	})
+	group.Go(func() error { return healthProbe.Run(ctx) })
 	group.Go(func() error { return solarEdgePoller.Run(ctx) })
 	group.Go(func() error { return exp.Run(ctx) })
+	if tariffTracker != nil {
+		group.Go(func() error { return tariffTracker.Run(ctx) })
+	}
+	if emissionsTracker != nil {
+		group.Go(func() error { return emissionsTracker.Run(ctx) })
+	}
+	if sink := newMQTTSink(v, &solarEdgePoller, nil, logger.With("component", "mqtt")); sink != nil {
+		group.Go(func() error { return sink.Run(ctx) })
+	}
+	if engine := newAlertEngine(v, &solarEdgePoller, map[string]alert.Component{"solaredge": &solarEdgePoller}, logger.With("component", "alert")); engine != nil {
+		group.Go(func() error { return engine.Run(ctx) })
+	}
+	for _, pusher := range newPushers(v, &solarEdgePoller, nil, logger.With("component", "pusher")) {
+		group.Go(func() error { return pusher.Run(ctx) })
+	}
 
 	return group.Wait()
 }
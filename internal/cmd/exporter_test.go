@@ -28,10 +28,11 @@ func Test_runExport(t *testing.T) {
 	}}}
 	v := getViperFromViper(viper.GetViper())
 	v.Set("polling.interval", time.Second)
+	v.Set("health.interval", 10*time.Millisecond)
 	r := prometheus.NewPedanticRegistry()
 	ctx := t.Context()
 	go func() {
-		assert.NoError(t, runExport(ctx, "dev", v, r, &p, discardLogger))
+		assert.NoError(t, runExport(ctx, "dev", v, r, &p, nil, discardLogger))
 	}()
 
 	var metricNames = []string{
@@ -49,19 +50,29 @@ func Test_runExport(t *testing.T) {
 	assert.NoError(t, testutil.GatherAndCompare(r, strings.NewReader(`
 # HELP solaredge_current_power current power in Watt
 # TYPE solaredge_current_power gauge
-solaredge_current_power{site="my home"} 500
+solaredge_current_power{site="my home",siteid="1"} 500
 
 # HELP solaredge_day_energy Today's produced energy in WattHours
 # TYPE solaredge_day_energy gauge
-solaredge_day_energy{site="my home"} 1
+solaredge_day_energy{site="my home",siteid="1"} 1
 
 # HELP solaredge_month_energy This month's produced energy in WattHours
 # TYPE solaredge_month_energy gauge
-solaredge_month_energy{site="my home"} 10
+solaredge_month_energy{site="my home",siteid="1"} 10
 # HELP solaredge_year_energy This year's produced energy in WattHours
 # TYPE solaredge_year_energy gauge
-solaredge_year_energy{site="my home"} 100
+solaredge_year_energy{site="my home",siteid="1"} 100
 `), metricNames...))
+
+	// the health checks are evaluated in the background
+	assert.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(r, strings.NewReader(`
+# HELP solaredge_component_healthy Set to 1 if the component is healthy, 0 otherwise
+# TYPE solaredge_component_healthy gauge
+solaredge_component_healthy{component="solaredge"} 1
+solaredge_component_healthy{component="solaredge-poller"} 1
+`), "solaredge_component_healthy") == nil
+	}, time.Second, 10*time.Millisecond)
 }
 
 var _ publisher.Updater[publisher.SolarEdgeUpdate] = fakeUpdater{}
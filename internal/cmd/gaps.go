@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/gaps"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var gapsCmd = cobra.Command{
+	Use:   "gaps",
+	Short: "report the periods during daylight without measurements and optionally backfill them",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		charmer.SetTextLogger(cmd, viper.GetBool("debug"))
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		finder := newGapFinder(viper.GetViper(), nil)
+		if finder == nil {
+			return errors.New("location.latitude and location.longitude must be set to determine daylight")
+		}
+		sites, err := getSites(viper.GetViper())
+		if err != nil {
+			return err
+		}
+		var clients map[string]*solaredge.Client
+		if viper.GetBool("gaps.backfill") {
+			clients = newSolarEdgeClients("gaps", prometheus.NewRegistry(), getSiteTokens(sites))
+		}
+		gapsSites := make([]gapsSite, len(sites))
+		for i, site := range sites {
+			repo, err := repository.NewPostgresDB(site.Database.URL)
+			if err != nil {
+				return fmt.Errorf("database: %w", err)
+			}
+			gapsSites[i] = gapsSite{Name: site.Name, Repository: repo}
+			if clients == nil {
+				continue
+			}
+			if gapsSites[i].Backfiller, err = newBackfiller(ctx, clients[site.SolarEdge.Token], site.SolarEdge.Site, repo); err != nil {
+				return fmt.Errorf("solaredge: %w", err)
+			}
+		}
+		to := time.Now()
+		from := to.AddDate(0, 0, -viper.GetInt("gaps.days"))
+		return runGaps(ctx, *finder, gapsSites, from, to, cmd.OutOrStdout())
+	},
+}
+
+// defaultScrapeInterval is the scraper's default interval.
+const defaultScrapeInterval = 15 * time.Minute
+
+// newGapFinder returns a Finder for the gaps in repo. Returns nil if the location isn't configured, as the location
+// determines when the site is expected to produce power.
+//
+// If gaps.interval isn't set, the Finder expects a measurement every scrape.interval.
+func newGapFinder(v *viper.Viper, repo gaps.Repository) *gaps.Finder {
+	latitude, longitude := v.GetFloat64("location.latitude"), v.GetFloat64("location.longitude")
+	if latitude == 0 && longitude == 0 {
+		return nil
+	}
+	interval := v.GetDuration("gaps.interval")
+	if interval <= 0 {
+		interval = v.GetDuration("scrape.interval")
+	}
+	if interval <= 0 {
+		interval = defaultScrapeInterval
+	}
+	return &gaps.Finder{
+		Repository: repo,
+		Daylight:   gaps.Daylight{Latitude: latitude, Longitude: longitude, MinElevation: v.GetFloat64("gaps.min-elevation")},
+		Interval:   interval,
+	}
+}
+
+// gapsSite is a site whose gaps are reported.
+type gapsSite struct {
+	// Name is the name of the site. Blank if only one, unnamed site is configured.
+	Name       string
+	Repository gaps.Repository
+	// Backfiller fills the site's gaps. If nil, the gaps are only reported.
+	Backfiller gapBackfiller
+}
+
+type gapBackfiller interface {
+	Backfill(ctx context.Context, gap repository.Gap) (int, error)
+}
+
+// newBackfiller returns a Backfiller that stores the power that SolarEdge measured at the site in repo.
+// site is the ID or name of the site. Blank selects the account's first site.
+func newBackfiller(ctx context.Context, client *solaredge.Client, site string, repo gaps.Store) (gaps.Backfiller, error) {
+	resp, err := client.GetSites(ctx)
+	if err != nil {
+		return gaps.Backfiller{}, err
+	}
+	details, err := findSolarEdgeSite(resp.Sites.Site, site)
+	if err != nil {
+		return gaps.Backfiller{}, err
+	}
+	location, err := time.LoadLocation(details.Location.TimeZone)
+	if err != nil {
+		return gaps.Backfiller{}, fmt.Errorf("site %q: %w", details.Name, err)
+	}
+	return gaps.Backfiller{Client: client, Store: repo, SiteID: details.Id, Location: location}, nil
+}
+
+// findSolarEdgeSite returns the site with the specified ID or name. Blank selects the first site.
+func findSolarEdgeSite(sites []solaredge.SiteDetails, site string) (solaredge.SiteDetails, error) {
+	for i, details := range sites {
+		if (site == "" && i == 0) || site == strconv.Itoa(details.Id) || site == details.Name {
+			return details, nil
+		}
+	}
+	return solaredge.SiteDetails{}, fmt.Errorf("site %q not found", site)
+}
+
+// runGaps reports the gaps of each site between from and to and backfills them if the site has a Backfiller.
+func runGaps(ctx context.Context, finder gaps.Finder, sites []gapsSite, from, to time.Time, stdout io.Writer) error {
+	for i, site := range sites {
+		if i > 0 {
+			_, _ = fmt.Fprintln(stdout)
+		}
+		if site.Name != "" {
+			_, _ = fmt.Fprintf(stdout, "Site: %s\n", site.Name)
+		}
+		finder.Repository = site.Repository
+		found, err := finder.Find(from, to)
+		if err != nil {
+			return fmt.Errorf("database: %w", err)
+		}
+		if err = writeGaps(ctx, stdout, found, site.Backfiller); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeGaps(ctx context.Context, w io.Writer, found []repository.Gap, backfiller gapBackfiller) error {
+	if len(found) == 0 {
+		_, err := fmt.Fprintln(w, "No gaps found.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "From\tTo\tDuration"
+	if backfiller != nil {
+		header += "\tBackfilled"
+	}
+	_, _ = fmt.Fprintln(tw, header)
+	var missing time.Duration
+	for _, gap := range found {
+		missing += gap.Duration()
+		line := fmt.Sprintf("%s\t%s\t%s", gap.From.Local().Format("2006-01-02 15:04"), gap.To.Local().Format("2006-01-02 15:04"), gap.Duration().Round(time.Minute))
+		if backfiller != nil {
+			count, err := backfiller.Backfill(ctx, gap)
+			if err != nil {
+				return fmt.Errorf("backfill: %w", err)
+			}
+			line += "\t" + strconv.Itoa(count)
+		}
+		_, _ = fmt.Fprintln(tw, line)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d gaps, %s missing\n", len(found), missing.Round(time.Minute))
+	return err
+}
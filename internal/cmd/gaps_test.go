@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/gaps"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_newGapFinder(t *testing.T) {
+	v := viper.New()
+	assert.Nil(t, newGapFinder(v, nil))
+
+	v.Set("location.latitude", 51.0)
+	v.Set("location.longitude", 4.0)
+	v.Set("gaps.min-elevation", 5.0)
+	finder := newGapFinder(v, nil)
+	require.NotNil(t, finder)
+	assert.Equal(t, gaps.Daylight{Latitude: 51, Longitude: 4, MinElevation: 5}, finder.Daylight)
+	assert.Equal(t, defaultScrapeInterval, finder.Interval)
+
+	// defaults to the scraper's interval
+	v.Set("scrape.interval", 5*time.Minute)
+	assert.Equal(t, 5*time.Minute, newGapFinder(v, nil).Interval)
+
+	v.Set("gaps.interval", 10*time.Minute)
+	assert.Equal(t, 10*time.Minute, newGapFinder(v, nil).Interval)
+}
+
+func Test_findSolarEdgeSite(t *testing.T) {
+	sites := []solaredge.SiteDetails{{Id: 1, Name: "home"}, {Id: 2, Name: "parents"}}
+	tests := []struct {
+		name    string
+		site    string
+		wantID  int
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{name: "first", site: "", wantID: 1, wantErr: assert.NoError},
+		{name: "by id", site: "2", wantID: 2, wantErr: assert.NoError},
+		{name: "by name", site: "parents", wantID: 2, wantErr: assert.NoError},
+		{name: "not found", site: "unknown", wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := findSolarEdgeSite(sites, tt.site)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.wantID, details.Id)
+		})
+	}
+}
+
+func Test_runGaps(t *testing.T) {
+	// noon in Brussels, during the summer
+	from := time.Date(2024, time.July, 1, 10, 0, 0, 0, time.UTC)
+	finder := gaps.Finder{Daylight: gaps.Daylight{Latitude: 51, Longitude: 4}, Interval: 15 * time.Minute}
+	sites := []gapsSite{
+		{
+			Name:       "home",
+			Repository: fakeGapsRepository{{From: from, To: from.Add(time.Hour)}},
+			Backfiller: fakeBackfiller{count: 4},
+		},
+		{
+			Name:       "parents",
+			Repository: fakeGapsRepository{},
+		},
+	}
+
+	var stdout bytes.Buffer
+	require.NoError(t, runGaps(t.Context(), finder, sites, from, from.Add(24*time.Hour), &stdout))
+	assert.Equal(t, `Site: home
+From              To                Duration  Backfilled
+`+from.Local().Format("2006-01-02 15:04")+`  `+from.Add(time.Hour).Local().Format("2006-01-02 15:04")+`  1h0m0s    4
+
+1 gaps, 1h0m0s missing
+
+Site: parents
+No gaps found.
+`, stdout.String())
+
+	sites[0].Backfiller = fakeBackfiller{err: errors.New("api failure")}
+	assert.Error(t, runGaps(t.Context(), finder, sites, from, from.Add(24*time.Hour), &stdout))
+}
+
+type fakeGapsRepository []repository.Gap
+
+func (f fakeGapsRepository) GetGaps(_, _ time.Time, _ time.Duration) ([]repository.Gap, error) {
+	return f, nil
+}
+
+type fakeBackfiller struct {
+	count int
+	err   error
+}
+
+func (f fakeBackfiller) Backfill(_ context.Context, _ repository.Gap) (int, error) {
+	return f.count, f.err
+}
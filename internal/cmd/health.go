@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/health"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/solar"
+	"github.com/spf13/viper"
+	"net"
+	"time"
+)
+
+// newSolarEdgeHealthPolicy returns the health policy for the SolarEdge publisher.
+// At night, when the inverters are asleep, SolarEdge isn't expected to produce new data.
+func newSolarEdgeHealthPolicy(v *viper.Viper) publisher.StalenessPolicy {
+	isDaylight := newDaylightFunc(v)
+	return publisher.StalenessPolicy{
+		QuietHours:             func(t time.Time) bool { return !isDaylight(t) },
+		MaxAge:                 v.GetDuration("health.solaredge.max-age"),
+		MaxConsecutiveFailures: v.GetInt("health.max-failures"),
+	}
+}
+
+// newTadoHealthPolicy returns the health policy for the Tado publisher.
+func newTadoHealthPolicy(v *viper.Viper) publisher.StalenessPolicy {
+	maxAge := v.GetDuration("health.tado.max-age")
+	if maxAge <= 0 {
+		maxAge = 5 * v.GetDuration("polling.interval")
+	}
+	return publisher.StalenessPolicy{
+		MaxAge:                 maxAge,
+		MaxConsecutiveFailures: v.GetInt("health.max-failures"),
+	}
+}
+
+// newDaylightFunc returns a function that determines if it's daylight at the configured location.
+// If the location isn't configured, anything between 6:00 and 22:00 local time is considered daylight.
+func newDaylightFunc(v *viper.Viper) func(time.Time) bool {
+	if !hasLocation(v) {
+		return func(t time.Time) bool {
+			hour := t.Local().Hour()
+			return hour >= 6 && hour < 22
+		}
+	}
+	latitude, longitude := v.GetFloat64("location.latitude"), v.GetFloat64("location.longitude")
+	return func(t time.Time) bool { return solar.IsDaylight(t, latitude, longitude) }
+}
+
+// hasLocation returns true if the site's location is configured.
+func hasLocation(v *viper.Viper) bool {
+	return v.GetFloat64("location.latitude") != 0 || v.GetFloat64("location.longitude") != 0
+}
+
+// listening returns a health check that fails if no server accepts connections on addr. If addr has no host,
+// the server is expected on localhost.
+func listening(addr string) health.IsHealthyFunc {
+	return func(ctx context.Context) error {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		if host == "" {
+			host = "localhost"
+		}
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_newDaylightFunc(t *testing.T) {
+	v := viper.New()
+	isDaylight := newDaylightFunc(v)
+	assert.True(t, isDaylight(time.Date(2024, time.December, 21, 12, 0, 0, 0, time.Local)))
+	assert.False(t, isDaylight(time.Date(2024, time.December, 21, 23, 0, 0, 0, time.Local)))
+
+	v.Set("location.latitude", 51.0)
+	v.Set("location.longitude", 0.0)
+	isDaylight = newDaylightFunc(v)
+	assert.True(t, isDaylight(time.Date(2024, time.June, 21, 20, 0, 0, 0, time.UTC)))
+	assert.False(t, isDaylight(time.Date(2024, time.December, 21, 7, 0, 0, 0, time.UTC)))
+}
+
+func Test_listening(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	_, port, _ := net.SplitHostPort(addr)
+
+	assert.NoError(t, listening(addr)(context.Background()))
+	assert.NoError(t, listening(":"+port)(context.Background()))
+	require.NoError(t, l.Close())
+	assert.Error(t, listening(addr)(context.Background()))
+	assert.Error(t, listening("invalid")(context.Background()))
+}
@@ -6,13 +6,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/alert"
 	"github.com/clambin/solaredge-monitor/internal/exporter"
 	"github.com/clambin/solaredge-monitor/internal/health"
+	"github.com/clambin/solaredge-monitor/internal/inventory"
 	"github.com/clambin/solaredge-monitor/internal/publisher"
 	"github.com/clambin/solaredge-monitor/internal/repository"
 	"github.com/clambin/solaredge-monitor/internal/scraper"
 	"github.com/clambin/tado/v2"
-	"github.com/clambin/tado/v2/tools"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
@@ -22,6 +23,7 @@ import (
 	"log/slog"
 	"net/http"
 	_ "net/http/pprof"
+	"strconv"
 )
 
 var (
@@ -34,23 +36,49 @@ var (
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			logger := charmer.GetLogger(cmd)
-			solarEdgeClient := newSolarEdgeClient("scraper", prometheus.DefaultRegisterer, viper.GetViper())
-			redisClient := newRedisClient(viper.GetViper())
-			tadoClient, err := newTadoClient(ctx, prometheus.DefaultRegisterer, redisClient)
+			sites, err := getSites(viper.GetViper())
 			if err != nil {
-				return fmt.Errorf("tado: %w", err)
+				return err
+			}
+			solarEdgeClients := newSolarEdgeClients("scraper", prometheus.DefaultRegisterer, getSiteTokens(sites))
+			updaterClients := make(map[string]publisher.SolarEdgeClient, len(solarEdgeClients))
+			var siteClients accountClients
+			for token, client := range solarEdgeClients {
+				updaterClients[token] = publisher.PhasesSolarEdgeClient{Client: client}
+				siteClients.clients = append(siteClients.clients, client)
+			}
+			redisClient := newRedisClient(viper.GetViper())
+			var tadoClient *tado.ClientWithResponses
+			store, err := newTadoTokenStore(viper.GetViper(), prometheus.DefaultRegisterer)
+			if err == nil {
+				tadoClient, err = newTadoClient(ctx, prometheus.DefaultRegisterer, store)
 			}
-			homeId, err := getHomeId(ctx, tadoClient, logger)
 			if err != nil {
-				return fmt.Errorf("failed to list Tado Homes: %w", err)
+				if !viper.GetBool("tado.optional") {
+					return fmt.Errorf("tado: %w", err)
+				}
+				logger.Warn("Tado not available. Running without weather", "err", err)
+			}
+			scrapeSites := make([]scrapeSite, len(sites))
+			for i, site := range sites {
+				scrapeSites[i] = scrapeSite{Name: site.Name, DatabaseURL: site.Database.URL}
+				if tadoClient == nil {
+					continue
+				}
+				homeId, err := getHomeId(ctx, tadoClient, site.Tado.Home, logger)
+				if err != nil {
+					return fmt.Errorf("failed to list Tado Homes: %w", err)
+				}
+				scrapeSites[i].Tado = publisher.TadoUpdater{Client: tadoClient, HomeId: homeId}
 			}
 			return runScrape(
 				ctx,
 				cmd.Root().Version,
 				viper.GetViper(),
 				prometheus.DefaultRegisterer,
-				publisher.SolarEdgeUpdater{SolarEdgeClient: &solarEdgeClient},
-				publisher.TadoUpdater{Client: tadoClient, HomeId: homeId},
+				newSolarEdgeAccounts(sites, updaterClients, viper.GetBool("solaredge.meter"), viper.GetBool("solaredge.storage")),
+				scrapeSites,
+				&siteClients,
 				redisClient,
 				logger,
 			)
@@ -58,34 +86,65 @@ var (
 	}
 )
 
-func getHomeId(ctx context.Context, client tools.TadoClient, logger *slog.Logger) (tado.HomeId, error) {
-	homes, err := tools.GetHomes(ctx, client)
-	if err != nil {
-		return 0, err
+// scrapeSite is a site monitored by the scraper: the Tado home that provides its weather and the database that
+// stores its measurements.
+type scrapeSite struct {
+	// Name is the name under which the site is reported. Blank if the scraper monitors a single, unnamed site.
+	Name string
+	// Tado gets the site's weather. If nil, Tado isn't available and the site is monitored without weather.
+	Tado        publisher.Updater[*tado.Weather]
+	DatabaseURL string
+}
+
+// errNoWeather is the health status of a site that's monitored without weather. It reports the site as degraded, so it doesn't fail the readiness probe.
+var errNoWeather = fmt.Errorf("Tado not available: running without weather (see 'solaredge tado status'): %w", health.ErrDegraded)
+
+// component returns the name of the site's instance of a component in health checks and metrics.
+func (s scrapeSite) component(name string) string {
+	if s.Name == "" {
+		return name
 	}
-	if len(homes) == 0 {
-		return 0, errors.New("no Tado Homes found")
+	return name + "-" + s.Name
+}
+
+// newInventoryTracker returns a Tracker that keeps the equipment inventory up to date. Returns nil if inventory tracking is disabled.
+func newInventoryTracker(v *viper.Viper, solarEdge inventory.Publisher[publisher.SolarEdgeUpdate], client inventory.Client, repo inventory.Repository, logger *slog.Logger) *inventory.Tracker {
+	interval := v.GetDuration("inventory.interval")
+	if interval <= 0 || client == nil {
+		return nil
 	}
-	homeId := *homes[0].Id
-	if len(homes) > 1 {
-		logger.Warn("Tado account has more than one home registered. Using first one", "homeId", homeId)
+	return &inventory.Tracker{SolarEdge: solarEdge, Client: client, Repository: repo, Interval: interval, Logger: logger}
+}
+
+// newIntensityFiller returns an IntensityFiller that estimates the solar intensity of the measurements stored without
+// weather. Returns nil if filling is disabled.
+func newIntensityFiller(v *viper.Viper, repo scraper.FillerRepository, logger *slog.Logger) *scraper.IntensityFiller {
+	interval := v.GetDuration("scrape.fill.interval")
+	if interval <= 0 {
+		return nil
 	}
-	return homeId, nil
+	return &scraper.IntensityFiller{Repository: repo, Interval: interval, MaxGap: v.GetDuration("scrape.fill.max-gap"), Lookback: v.GetDuration("scrape.fill.lookback"), Logger: logger}
 }
 
+// runScrape monitors the sites. The analytics (performance model, forecast and inventory) use the first site's database.
 func runScrape(
 	ctx context.Context,
 	version string,
 	v *viper.Viper,
 	r prometheus.Registerer,
 	solarEdgeUpdater publisher.Updater[publisher.SolarEdgeUpdate],
-	tadoUpdater publisher.Updater[*tado.Weather],
+	sites []scrapeSite,
+	siteClient siteClient,
 	redisClient *redis.Client,
 	logger *slog.Logger,
 ) error {
 	logger.Info("starting solaredge scraper", "version", version)
 	defer logger.Info("stopping solaredge scraper")
 
+	if len(sites) == 0 {
+		return errors.New("no sites configured")
+	}
+
 	if pprofAddr := v.GetString("pprof"); pprofAddr != "" {
 		go func() {
 			logger.Debug("starting pprof", "addr", pprofAddr)
@@ -93,49 +152,141 @@ func runScrape(
 		}()
 	}
 
-	repo, err := repository.NewPostgresDB(v.GetString("database.url"))
-	if err != nil {
-		return fmt.Errorf("database: %w", err)
+	repos := make([]*repository.PostgresDB, len(sites))
+	for i, site := range sites {
+		repo, err := repository.NewPostgresDB(site.DatabaseURL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", site.component("database"), err)
+		}
+		repos[i] = repo
 	}
+	repo := repos[0]
 
 	logger.Debug("connected to database")
 
 	solarEdgePoller := publisher.Publisher[publisher.SolarEdgeUpdate]{
-		Updater:  solarEdgeUpdater,
-		Interval: v.GetDuration("polling.interval"),
-		Logger:   logger.With("publisher", "solaredge"),
+		Updater:      solarEdgeUpdater,
+		Name:         "SolarEdge",
+		HealthPolicy: newSolarEdgeHealthPolicy(v),
+		Interval:     v.GetDuration("polling.interval"),
+		Logger:       logger.With("publisher", "solaredge"),
 	}
 
-	tadoPoller := publisher.Publisher[*tado.Weather]{
-		Updater:  tadoUpdater,
-		Interval: v.GetDuration("polling.interval"),
-		Logger:   logger.With("publisher", "tado"),
+	tadoPollers := make([]*publisher.Publisher[*tado.Weather], len(sites))
+	for i, site := range sites {
+		if site.Tado == nil {
+			continue
+		}
+		tadoPollers[i] = &publisher.Publisher[*tado.Weather]{
+			Updater:      site.Tado,
+			Name:         "Tado",
+			HealthPolicy: newTadoHealthPolicy(v),
+			Interval:     v.GetDuration("polling.interval"),
+			Logger:       logger.With("publisher", site.component("tado")),
+		}
+	}
+	// the weather published to MQTT and the push targets is the first site's
+	var weather scraper.Publisher[*tado.Weather]
+	if tadoPollers[0] != nil {
+		weather = tadoPollers[0]
 	}
 
-	writer := scraper.Writer{
-		Store:     repo,
-		SolarEdge: &solarEdgePoller,
-		Tado:      &tadoPoller,
-		Interval:  v.GetDuration("scrape.interval"),
-		Logger:    logger.With("component", "writer"),
+	tracker := newPerformanceTracker(v, repo, logger.With("component", "analytics"))
+	r.MustRegister(tracker)
+
+	writers := make([]*scraper.Writer, len(sites))
+	for i, site := range sites {
+		writers[i] = &scraper.Writer{
+			Store:      repos[i],
+			Site:       site.Name,
+			PowerFlows: repos[i],
+			Batteries:  repos[i],
+			SolarEdge:  &solarEdgePoller,
+			Interval:   v.GetDuration("scrape.interval"),
+			Logger:     logger.With("component", site.component("writer")),
+		}
+	}
+	for i, poller := range tadoPollers {
+		if poller != nil {
+			writers[i].Tado = poller
+		}
 	}
+	// the performance tracker learns from the first site's measurements
+	writers[0].Store = tracker
 
 	exportMetrics := exporter.NewMetrics()
 	r.MustRegister(exportMetrics)
 
+	sources := map[string]exporter.Source{"solaredge": &solarEdgePoller}
+	components := map[string]alert.Component{"solaredge": &solarEdgePoller}
+	healthProbe := health.Health{
+		Logger:   logger.With("component", "health"),
+		Timeout:  v.GetDuration("health.timeout"),
+		Interval: v.GetDuration("health.interval"),
+		Checks: []health.Check{
+			{Name: "solaredge", Component: &solarEdgePoller},
+			{Name: "solaredge-poller", Component: health.IsHealthyFunc(solarEdgePoller.IsAlive), Live: true},
+		},
+	}
+	// with multiple accounts, a failing account doesn't fail the SolarEdge check: report it separately
+	if accounts, ok := solarEdgeUpdater.(*publisher.SolarEdgeAccounts); ok && len(accounts.Accounts) > 1 {
+		for i := range accounts.Accounts {
+			name := "solaredge-account-" + strconv.Itoa(i+1)
+			check := health.IsHealthyFunc(func(context.Context) error { return accounts.Err(i) })
+			components[name] = check
+			healthProbe.Checks = append(healthProbe.Checks, health.Check{Name: name, Component: check})
+		}
+	}
+	if redisClient != nil {
+		healthProbe.Checks = append(healthProbe.Checks,
+			health.Check{Name: "redis", Component: health.IsHealthyFunc(func(ctx context.Context) error { return redisClient.Ping(ctx).Err() })},
+		)
+	}
+	for i, site := range sites {
+		healthProbe.Checks = append(healthProbe.Checks,
+			health.Check{Name: site.component("database"), Component: health.IsHealthyFunc(func(ctx context.Context) error { return repos[i].DBX.PingContext(ctx) })},
+			health.Check{Name: site.component("writer"), Component: health.IsHealthyFunc(writers[i].IsAlive), Live: true},
+		)
+		if tadoPollers[i] == nil {
+			healthProbe.Checks = append(healthProbe.Checks,
+				health.Check{Name: site.component("tado"), Component: health.IsHealthyFunc(func(context.Context) error { return errNoWeather })},
+			)
+			continue
+		}
+		sources[site.component("tado")] = tadoPollers[i]
+		components[site.component("tado")] = tadoPollers[i]
+		healthProbe.Checks = append(healthProbe.Checks,
+			health.Check{Name: site.component("tado"), Component: tadoPollers[i]},
+			health.Check{Name: site.component("tado-poller"), Component: health.IsHealthyFunc(tadoPollers[i].IsAlive), Live: true},
+		)
+	}
+	r.MustRegister(&healthProbe)
+
 	exp := exporter.Exporter{
-		SolarEdge: &solarEdgePoller,
-		Metrics:   exportMetrics,
-		Logger:    logger.With("component", "exporter"),
+		SolarEdge:     &solarEdgePoller,
+		Sources:       sources,
+		Metrics:       exportMetrics,
+		Logger:        logger.With("component", "exporter"),
+		MaxAge:        v.GetDuration("exporter.max-age"),
+		InverterPower: v.GetFloat64("clipping.inverter-power"),
+	}
+
+	tariffTracker, err := newTariffTracker(v, &solarEdgePoller, logger.With("component", "tariff"))
+	if err != nil {
+		return err
+	}
+	if tariffTracker != nil {
+		r.MustRegister(tariffTracker)
+	}
+	emissionsTracker, err := newEmissionsTracker(v, &solarEdgePoller, siteClient, logger.With("component", "emissions"))
+	if err != nil {
+		return err
+	}
+	if emissionsTracker != nil {
+		r.MustRegister(emissionsTracker)
 	}
 
-	healthProbe := health.Probe(logger.With("component", "health"),
-		health.IsHealthyFunc(func(ctx context.Context) error { return repo.DBX.PingContext(ctx) }),
-		health.IsHealthyFunc(func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }),
-		&tadoPoller,
-		// TODO: add solaredge back
-		//&solarEdgePoller,
-	)
+	inventoryTracker := newInventoryTracker(v, &solarEdgePoller, siteClient, repo, logger.With("component", "inventory"))
 
 	var group errgroup.Group
 	group.Go(func() error {
@@ -144,12 +295,47 @@ func runScrape(
 	group.Go(func() error {
 		addr := v.GetString("scrape.health.addr")
 		logger.Debug("starting health probe", "addr", addr)
-		return httputils.RunServer(ctx, &http.Server{Addr: addr, Handler: healthProbe})
+		return httputils.RunServer(ctx, &http.Server{Addr: addr, Handler: healthProbe.Handler()})
 	})
-	group.Go(func() error { return writer.Run(ctx) })
+	group.Go(func() error { return healthProbe.Run(ctx) })
+	for _, writer := range writers {
+		group.Go(func() error { return writer.Run(ctx) })
+	}
+	for i, site := range sites {
+		if filler := newIntensityFiller(v, repos[i], logger.With("component", site.component("filler"))); filler != nil {
+			group.Go(func() error { return filler.Run(ctx) })
+		}
+	}
+	group.Go(func() error { return tracker.Run(ctx) })
+	if forecaster := newForecaster(v, tracker, repo, logger.With("component", "forecast")); forecaster != nil {
+		r.MustRegister(forecaster)
+		group.Go(func() error { return forecaster.Run(ctx) })
+	}
 	group.Go(func() error { return exp.Run(ctx) })
+	if tariffTracker != nil {
+		group.Go(func() error { return tariffTracker.Run(ctx) })
+	}
+	if emissionsTracker != nil {
+		group.Go(func() error { return emissionsTracker.Run(ctx) })
+	}
+	if inventoryTracker != nil {
+		group.Go(func() error { return inventoryTracker.Run(ctx) })
+	}
 	group.Go(func() error { return solarEdgePoller.Run(ctx) })
-	group.Go(func() error { return tadoPoller.Run(ctx) })
+	for _, poller := range tadoPollers {
+		if poller != nil {
+			group.Go(func() error { return poller.Run(ctx) })
+		}
+	}
+	if sink := newMQTTSink(v, &solarEdgePoller, weather, logger.With("component", "mqtt")); sink != nil {
+		group.Go(func() error { return sink.Run(ctx) })
+	}
+	if engine := newAlertEngine(v, &solarEdgePoller, components, logger.With("component", "alert")); engine != nil {
+		group.Go(func() error { return engine.Run(ctx) })
+	}
+	for _, pusher := range newPushers(v, &solarEdgePoller, weather, logger.With("component", "pusher")) {
+		group.Go(func() error { return pusher.Run(ctx) })
+	}
 
 	return group.Wait()
 }
@@ -26,7 +26,6 @@ func Test_runScrape(t *testing.T) {
 		require.NoError(t, testcontainers.TerminateContainer(store))
 	})
 	v := getViperFromViper(viper.GetViper())
-	v.Set("database.url", connString)
 	v.Set("polling.interval", time.Second)
 	v.Set("scrape.interval", 2*time.Second)
 	solarEdgeUpdater := fakeUpdater{SolarEdgeUpdate: publisher.SolarEdgeUpdate{{
@@ -45,7 +44,7 @@ func Test_runScrape(t *testing.T) {
 	r := prometheus.NewPedanticRegistry()
 
 	go func() {
-		assert.NoError(t, runScrape(ctx, "dev", v, r, &solarEdgeUpdater, &tadoUpdater, nil, discardLogger))
+		assert.NoError(t, runScrape(ctx, "dev", v, r, &solarEdgeUpdater, []scrapeSite{{Tado: &tadoUpdater, DatabaseURL: connString}}, nil, nil, discardLogger))
 	}()
 
 	dbc, err := repository.NewPostgresDB(connString)
@@ -59,6 +58,7 @@ func Test_runScrape(t *testing.T) {
 
 func Test_getHomeId(t *testing.T) {
 	type args struct {
+		home string
 		resp *tado.GetMeResponse
 		err  error
 	}
@@ -111,13 +111,46 @@ func Test_getHomeId(t *testing.T) {
 			},
 			want: want{homeId: 1, err: assert.NoError},
 		},
+		{
+			name: "select by id",
+			args: args{
+				home: "2",
+				resp: &tado.GetMeResponse{
+					HTTPResponse: &http.Response{StatusCode: http.StatusOK},
+					JSON200:      &tado.User{Homes: &[]tado.HomeBase{{Id: pointer(tado.HomeId(1)), Name: pointer("home")}, {Id: pointer(tado.HomeId(2)), Name: pointer("parents")}}},
+				},
+			},
+			want: want{homeId: 2, err: assert.NoError},
+		},
+		{
+			name: "select by name",
+			args: args{
+				home: "Parents",
+				resp: &tado.GetMeResponse{
+					HTTPResponse: &http.Response{StatusCode: http.StatusOK},
+					JSON200:      &tado.User{Homes: &[]tado.HomeBase{{Id: pointer(tado.HomeId(1)), Name: pointer("home")}, {Id: pointer(tado.HomeId(2)), Name: pointer("parents")}}},
+				},
+			},
+			want: want{homeId: 2, err: assert.NoError},
+		},
+		{
+			name: "unknown home",
+			args: args{
+				home: "cottage",
+				resp: &tado.GetMeResponse{
+					HTTPResponse: &http.Response{StatusCode: http.StatusOK},
+					JSON200:      &tado.User{Homes: &[]tado.HomeBase{{Id: pointer(tado.HomeId(1)), Name: pointer("home")}}},
+				},
+			},
+			want: want{err: assert.Error},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f := fakeMeGetter{tt.args.resp, tt.args.err}
 
-			got, err := getHomeId(context.Background(), f, discardLogger)
+			got, err := getHomeId(context.Background(), f, tt.args.home, discardLogger)
 			assert.Equal(t, tt.want.homeId, got)
 			tt.want.err(t, err)
 		})
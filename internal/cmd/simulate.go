@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/simulator"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+var (
+	simulateCmd = cobra.Command{
+		Use:   "simulate",
+		Short: "generate synthetic measurements for load and UI testing",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			charmer.SetTextLogger(cmd, viper.GetBool("debug"))
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runSimulate(viper.GetViper(), cmd.OutOrStdout(), charmer.GetLogger(cmd))
+		},
+	}
+
+	simulateArguments = charmer.Arguments{
+		"simulate.latitude":  {Default: 51.0, Help: "Latitude of the simulated site"},
+		"simulate.longitude": {Default: 4.4, Help: "Longitude of the simulated site"},
+		"simulate.capacity":  {Default: 4000.0, Help: "Peak power of the simulated panels (in Watt)"},
+		"simulate.start":     {Default: "", Help: "Start date of the simulation (blank: 30 days before end)"},
+		"simulate.end":       {Default: "", Help: "End date of the simulation (blank: now)"},
+		"simulate.interval":  {Default: 15 * time.Minute, Help: "Time between two measurements"},
+		"simulate.seed":      {Default: 0, Help: "Seed for the random generator (0: random seed)"},
+		"simulate.output":    {Default: "", Help: "CSV file to write the measurements to (blank: write to the database; -: write to stdout)"},
+	}
+)
+
+func runSimulate(v *viper.Viper, stdout io.Writer, logger *slog.Logger) error {
+	s, err := newSimulator(v)
+	if err != nil {
+		return err
+	}
+	logger.Info("generating measurements", "start", s.Start, "end", s.End, "capacity", s.PeakPower)
+
+	var count int
+	switch output := v.GetString("simulate.output"); output {
+	case "":
+		repo, err := repository.NewPostgresDB(v.GetString("database.url"))
+		if err != nil {
+			return fmt.Errorf("database: %w", err)
+		}
+		count, err = simulator.Write(s.Measurements(), repo)
+		if err != nil {
+			return fmt.Errorf("database: %w", err)
+		}
+	case "-":
+		if count, err = writeCSV(s, stdout); err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+	default:
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+		count, err = writeCSV(s, f)
+		if err2 := f.Close(); err == nil {
+			err = err2
+		}
+		if err != nil {
+			return fmt.Errorf("csv: %w", err)
+		}
+	}
+	logger.Info("measurements generated", "count", count)
+	return nil
+}
+
+func newSimulator(v *viper.Viper) (simulator.Simulator, error) {
+	end, err := parseDate(v.GetString("simulate.end"), time.Now())
+	if err != nil {
+		return simulator.Simulator{}, fmt.Errorf("invalid end date: %w", err)
+	}
+	start, err := parseDate(v.GetString("simulate.start"), end.AddDate(0, 0, -30))
+	if err != nil {
+		return simulator.Simulator{}, fmt.Errorf("invalid start date: %w", err)
+	}
+	if !start.Before(end) {
+		return simulator.Simulator{}, fmt.Errorf("start date %s must be before end date %s", start.Format(time.DateOnly), end.Format(time.DateOnly))
+	}
+	seed := uint64(v.GetInt("simulate.seed"))
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return simulator.Simulator{
+		Start:     start,
+		End:       end,
+		Latitude:  v.GetFloat64("simulate.latitude"),
+		Longitude: v.GetFloat64("simulate.longitude"),
+		PeakPower: v.GetFloat64("simulate.capacity"),
+		Interval:  v.GetDuration("simulate.interval"),
+		Seed:      seed,
+	}, nil
+}
+
+func parseDate(arg string, defaultValue time.Time) (time.Time, error) {
+	if arg == "" {
+		return defaultValue, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, arg, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", arg)
+}
+
+func writeCSV(s simulator.Simulator, w io.Writer) (int, error) {
+	csvWriter := simulator.NewCSVWriter(w)
+	count, err := simulator.Write(s.Measurements(), csvWriter)
+	if err == nil {
+		err = csvWriter.Flush()
+	}
+	return count, err
+}
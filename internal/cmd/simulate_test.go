@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_runSimulate(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "measurements.csv")
+	v := getViperFromViper(viper.GetViper())
+	v.Set("simulate.start", "2024-06-01")
+	v.Set("simulate.end", "2024-06-03")
+	v.Set("simulate.seed", 42)
+	v.Set("simulate.output", output)
+
+	require.NoError(t, runSimulate(v, nil, discardLogger))
+
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Greater(t, len(records), 1)
+	assert.Equal(t, []string{"timestamp", "power", "intensity", "weather"}, records[0])
+
+	var stdout bytes.Buffer
+	v.Set("simulate.output", "-")
+	require.NoError(t, runSimulate(v, &stdout, discardLogger))
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, string(content), stdout.String())
+}
+
+func Test_newSimulator(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		err   assert.ErrorAssertionFunc
+	}{
+		{"dates", "2024-06-01", "2024-06-03", assert.NoError},
+		{"timestamps", "2024-06-01T12:00:00Z", "2024-06-03 12:00:00", assert.NoError},
+		{"defaults", "", "", assert.NoError},
+		{"invalid start", "foo", "2024-06-03", assert.Error},
+		{"invalid end", "2024-06-01", "bar", assert.Error},
+		{"start after end", "2024-06-03", "2024-06-01", assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := getViperFromViper(viper.GetViper())
+			v.Set("simulate.start", tt.start)
+			v.Set("simulate.end", tt.end)
+			s, err := newSimulator(v)
+			tt.err(t, err)
+			if err == nil {
+				assert.True(t, s.Start.Before(s.End))
+				assert.NotZero(t, s.Seed)
+				assert.Equal(t, 15*time.Minute, s.Interval)
+			}
+		})
+	}
+}
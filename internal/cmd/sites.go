@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge/v2"
+	"github.com/clambin/tado/v2"
+	"github.com/clambin/tado/v2/tools"
+	"github.com/spf13/viper"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// siteConfig configures a site monitored by the scraper. Sites are configured in the configuration file, e.g.:
+//
+//	sites:
+//	  - name: home
+//	    solaredge:
+//	      token: <token>
+//	    tado:
+//	      home: Home
+//	  - name: parents
+//	    solaredge:
+//	      token: <token>
+//	      site: 123456
+//	    tado:
+//	      home: Parents
+//	    database:
+//	      url: postgres://...
+//
+// Blank settings default to the global solaredge.token, tado.home and database.url. If no sites are configured,
+// the scraper monitors the first site of the global SolarEdge account.
+type siteConfig struct {
+	Name      string `mapstructure:"name"`
+	SolarEdge struct {
+		Token string `mapstructure:"token"`
+		// Site is the ID or name of the site in the SolarEdge account. Blank selects the account's first site.
+		Site string `mapstructure:"site"`
+	} `mapstructure:"solaredge"`
+	Tado struct {
+		// Home is the ID or name of the Tado home that provides the site's weather. Blank selects the account's first home.
+		Home string `mapstructure:"home"`
+	} `mapstructure:"tado"`
+	Database struct {
+		URL string `mapstructure:"url"`
+	} `mapstructure:"database"`
+}
+
+// getSites returns the configured sites.
+func getSites(v *viper.Viper) ([]siteConfig, error) {
+	var sites []siteConfig
+	if err := v.UnmarshalKey("sites", &sites); err != nil {
+		return nil, fmt.Errorf("sites: %w", err)
+	}
+	if len(sites) == 0 {
+		sites = []siteConfig{{}}
+	}
+	names := make(map[string]struct{}, len(sites))
+	databases := make(map[string]string, len(sites))
+	for i := range sites {
+		site := &sites[i]
+		if len(sites) > 1 {
+			if site.Name == "" {
+				return nil, fmt.Errorf("sites: site %d has no name", i+1)
+			}
+			if _, ok := names[site.Name]; ok {
+				return nil, fmt.Errorf("sites: duplicate site %q", site.Name)
+			}
+			names[site.Name] = struct{}{}
+		}
+		if site.SolarEdge.Token == "" {
+			site.SolarEdge.Token = v.GetString("solaredge.token")
+		}
+		if site.Tado.Home == "" {
+			site.Tado.Home = v.GetString("tado.home")
+		}
+		if site.Database.URL == "" {
+			site.Database.URL = v.GetString("database.url")
+		}
+		// measurements don't record their site: each site needs its own database
+		if other, ok := databases[site.Database.URL]; ok {
+			return nil, fmt.Errorf("sites: %q and %q use the same database", other, site.Name)
+		}
+		databases[site.Database.URL] = site.Name
+	}
+	return sites, nil
+}
+
+// newSolarEdgeAccounts returns an updater that gets the configured sites from their SolarEdge accounts.
+func newSolarEdgeAccounts(sites []siteConfig, clients map[string]publisher.SolarEdgeClient, meter, storage bool) *publisher.SolarEdgeAccounts {
+	var tokens []string
+	accountSites := make(map[string]map[string]string)
+	for _, site := range sites {
+		token := site.SolarEdge.Token
+		if _, ok := accountSites[token]; !ok {
+			tokens = append(tokens, token)
+			accountSites[token] = make(map[string]string)
+		}
+		// a site without a name is reported under its SolarEdge name
+		if site.Name != "" {
+			accountSites[token][site.SolarEdge.Site] = site.Name
+		}
+	}
+	accounts := publisher.SolarEdgeAccounts{Accounts: make([]publisher.Updater[publisher.SolarEdgeUpdate], len(tokens))}
+	for i, token := range tokens {
+		updater := publisher.SolarEdgeUpdater{SolarEdgeClient: clients[token], Meter: meter, Storage: storage}
+		if len(accountSites[token]) > 0 {
+			updater.Sites = accountSites[token]
+		}
+		accounts.Accounts[i] = updater
+	}
+	return &accounts
+}
+
+// getSiteTokens returns the SolarEdge tokens of the sites, without duplicates.
+func getSiteTokens(sites []siteConfig) []string {
+	tokens := make([]string, 0, len(sites))
+	for _, site := range sites {
+		if !slices.Contains(tokens, site.SolarEdge.Token) {
+			tokens = append(tokens, site.SolarEdge.Token)
+		}
+	}
+	return tokens
+}
+
+// accountClient is a SolarEdge client for one account.
+type accountClient interface {
+	siteClient
+	GetSites(ctx context.Context) (solaredge.GetSitesResponse, error)
+}
+
+// accountClients sends site requests to the SolarEdge account that owns the site. The owner of each site is looked up
+// once.
+type accountClients struct {
+	clients []accountClient
+	owners  map[int]accountClient
+	lock    sync.Mutex
+}
+
+func (a *accountClients) GetInventory(ctx context.Context, id int) (solaredge.GetInventoryResponse, error) {
+	client, err := a.client(ctx, id)
+	if err != nil {
+		return solaredge.GetInventoryResponse{}, err
+	}
+	return client.GetInventory(ctx, id)
+}
+
+func (a *accountClients) GetEnvBenefits(ctx context.Context, id int) (solaredge.GetEnvBenefitsResponse, error) {
+	client, err := a.client(ctx, id)
+	if err != nil {
+		return solaredge.GetEnvBenefitsResponse{}, err
+	}
+	return client.GetEnvBenefits(ctx, id)
+}
+
+func (a *accountClients) client(ctx context.Context, id int) (accountClient, error) {
+	if len(a.clients) == 1 {
+		return a.clients[0], nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if client, ok := a.owners[id]; ok {
+		return client, nil
+	}
+	if a.owners == nil {
+		a.owners = make(map[int]accountClient)
+	}
+	// a failing account shouldn't prevent finding the sites of the other accounts
+	var errs []error
+	for _, client := range a.clients {
+		sites, err := client.GetSites(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, site := range sites.Sites.Site {
+			a.owners[site.Id] = client
+		}
+	}
+	if client, ok := a.owners[id]; ok {
+		return client, nil
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, fmt.Errorf("site %d not found", id)
+}
+
+// getHomeId returns the ID of the Tado home with the specified ID or name. If home is blank, it returns the first home.
+func getHomeId(ctx context.Context, client tools.TadoClient, home string, logger *slog.Logger) (tado.HomeId, error) {
+	homes, err := tools.GetHomes(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	if len(homes) == 0 {
+		return 0, errors.New("no Tado Homes found")
+	}
+	if home == "" {
+		homeId := *homes[0].Id
+		if len(homes) > 1 {
+			logger.Warn("Tado account has more than one home registered. Using first one", "homeId", homeId)
+		}
+		return homeId, nil
+	}
+	for _, h := range homes {
+		if h.Id != nil && strconv.Itoa(int(*h.Id)) == home {
+			return *h.Id, nil
+		}
+		if h.Id != nil && h.Name != nil && strings.EqualFold(*h.Name, home) {
+			return *h.Id, nil
+		}
+	}
+	return 0, fmt.Errorf("Tado Home %q not found", home)
+}
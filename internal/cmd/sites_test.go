@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_getSites(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    []siteConfig
+		wantErr string
+	}{
+		{
+			name: "no sites",
+			config: `
+solaredge:
+  token: "1234"
+tado:
+  home: Home
+database:
+  url: postgres://localhost/solaredge
+`,
+			want: []siteConfig{makeSiteConfig("", "1234", "", "Home", "postgres://localhost/solaredge")},
+		},
+		{
+			name: "multiple sites",
+			config: `
+solaredge:
+  token: "1234"
+database:
+  url: postgres://localhost/solaredge
+sites:
+  - name: home
+    tado:
+      home: Home
+  - name: parents
+    solaredge:
+      token: "5678"
+      site: 42
+    tado:
+      home: 2
+    database:
+      url: postgres://localhost/parents
+`,
+			want: []siteConfig{
+				makeSiteConfig("home", "1234", "", "Home", "postgres://localhost/solaredge"),
+				makeSiteConfig("parents", "5678", "42", "2", "postgres://localhost/parents"),
+			},
+		},
+		{
+			name: "missing name",
+			config: `
+sites:
+  - name: home
+  - database:
+      url: postgres://localhost/parents
+`,
+			wantErr: "sites: site 2 has no name",
+		},
+		{
+			name: "duplicate name",
+			config: `
+sites:
+  - name: home
+  - name: home
+    database:
+      url: postgres://localhost/parents
+`,
+			wantErr: `sites: duplicate site "home"`,
+		},
+		{
+			name: "shared database",
+			config: `
+database:
+  url: postgres://localhost/solaredge
+sites:
+  - name: home
+  - name: parents
+`,
+			wantErr: `sites: "home" and "parents" use the same database`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.SetConfigType("yaml")
+			require.NoError(t, v.MergeConfig(bytes.NewBufferString(tt.config)))
+
+			sites, err := getSites(v)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sites)
+		})
+	}
+}
+
+func makeSiteConfig(name, token, site, home, databaseURL string) siteConfig {
+	var cfg siteConfig
+	cfg.Name = name
+	cfg.SolarEdge.Token = token
+	cfg.SolarEdge.Site = site
+	cfg.Tado.Home = home
+	cfg.Database.URL = databaseURL
+	return cfg
+}
+
+func Test_newSolarEdgeAccounts(t *testing.T) {
+	clients := map[string]publisher.SolarEdgeClient{"1234": &solaredge.Client{SiteKey: "1234"}, "5678": &solaredge.Client{SiteKey: "5678"}}
+
+	accounts := newSolarEdgeAccounts([]siteConfig{makeSiteConfig("", "1234", "", "", "")}, clients, true, false)
+	require.Len(t, accounts.Accounts, 1)
+	assert.Equal(t, publisher.SolarEdgeUpdater{SolarEdgeClient: clients["1234"], Meter: true}, accounts.Accounts[0])
+
+	accounts = newSolarEdgeAccounts([]siteConfig{
+		makeSiteConfig("home", "1234", "", "", ""),
+		makeSiteConfig("parents", "5678", "42", "", ""),
+		makeSiteConfig("cottage", "1234", "cottage", "", ""),
+	}, clients, false, true)
+	require.Len(t, accounts.Accounts, 2)
+	assert.Equal(t, publisher.SolarEdgeUpdater{SolarEdgeClient: clients["1234"], Storage: true, Sites: map[string]string{"": "home", "cottage": "cottage"}}, accounts.Accounts[0])
+	assert.Equal(t, publisher.SolarEdgeUpdater{SolarEdgeClient: clients["5678"], Storage: true, Sites: map[string]string{"42": "parents"}}, accounts.Accounts[1])
+}
+
+func Test_accountClients(t *testing.T) {
+	var calls atomic.Int32
+	clients := accountClients{clients: []accountClient{fakeAccountClient{siteID: 1, calls: &calls}, fakeAccountClient{siteID: 2, calls: &calls}}}
+
+	inventory, err := clients.GetInventory(t.Context(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "2", inventory.Inventory.Inverters[0].SN)
+
+	benefits, err := clients.GetEnvBenefits(t.Context(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, benefits.EnvBenefits.TreesPlanted)
+	// the owner of the sites is only looked up once
+	assert.Equal(t, int32(2), calls.Load())
+
+	_, err = clients.GetInventory(t.Context(), 3)
+	assert.EqualError(t, err, "site 3 not found")
+
+	// a failing account doesn't prevent finding the sites of the other accounts
+	clients = accountClients{clients: []accountClient{fakeAccountClient{err: errors.New("failed")}, fakeAccountClient{siteID: 2}}}
+	_, err = clients.GetInventory(t.Context(), 2)
+	require.NoError(t, err)
+	_, err = clients.GetEnvBenefits(t.Context(), 3)
+	assert.EqualError(t, err, "failed")
+}
+
+type fakeAccountClient struct {
+	siteID int
+	err    error
+	calls  *atomic.Int32
+}
+
+func (f fakeAccountClient) GetSites(_ context.Context) (solaredge.GetSitesResponse, error) {
+	if f.calls != nil {
+		f.calls.Add(1)
+	}
+	var response solaredge.GetSitesResponse
+	response.Sites.Site = []solaredge.SiteDetails{{Id: f.siteID}}
+	return response, f.err
+}
+
+func (f fakeAccountClient) GetInventory(_ context.Context, id int) (solaredge.GetInventoryResponse, error) {
+	var response solaredge.GetInventoryResponse
+	response.Inventory.Inverters = []solaredge.InverterEquipment{{SN: strconv.Itoa(id)}}
+	return response, nil
+}
+
+func (f fakeAccountClient) GetEnvBenefits(_ context.Context, id int) (solaredge.GetEnvBenefitsResponse, error) {
+	var response solaredge.GetEnvBenefitsResponse
+	response.EnvBenefits.TreesPlanted = float64(id)
+	return response, nil
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"codeberg.org/clambin/go-common/charmer"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/oauth2crypt"
+	"github.com/clambin/solaredge-monitor/oauth2file"
+	"github.com/clambin/solaredge-monitor/oauth2redis"
+	"github.com/clambin/tado/v2"
+	"github.com/clambin/tado/v2/oauth2store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+const (
+	// tadoTokenKey is the Redis key of the Tado token.
+	tadoTokenKey = "github.com/clambin/solaredge-monitor/oauth2redis"
+	// tadoTokenTTL is how long the Tado token is kept in Redis after it was last saved.
+	tadoTokenTTL = 30 * 24 * time.Hour
+	// tadoTokenKeyEnv is the environment variable that holds the key to encrypt the Tado token, if tado.token.key-file is not set.
+	tadoTokenKeyEnv = "SOLAREDGE_TADO_TOKEN_KEY"
+)
+
+// errNoTadoToken indicates that no Tado token is stored. The user needs to log in first.
+var errNoTadoToken = errors.New("no Tado token found: run 'solaredge tado login' first")
+
+var (
+	tadoCmd = cobra.Command{
+		Use:   "tado",
+		Short: "manage the Tado token used to get the weather",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			charmer.SetTextLogger(cmd, viper.GetBool("debug"))
+		},
+	}
+
+	tadoLoginCmd = cobra.Command{
+		Use:   "login",
+		Short: "log in to Tado and store the token",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			store, err := newTadoTokenStore(viper.GetViper(), nil)
+			if err != nil {
+				return err
+			}
+			return runTadoLogin(cmd.Context(), &tado.Config, store, cmd.OutOrStdout())
+		},
+	}
+
+	tadoStatusCmd = cobra.Command{
+		Use:   "status",
+		Short: "show the state of the stored Tado token",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			store, err := newTadoTokenStore(viper.GetViper(), nil)
+			if err != nil {
+				return err
+			}
+			return runTadoStatus(store, cmd.OutOrStdout(), time.Now())
+		},
+	}
+
+	tadoLogoutCmd = cobra.Command{
+		Use:   "logout",
+		Short: "remove the stored Tado token",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			store, err := newTadoTokenStore(viper.GetViper(), nil)
+			if err != nil {
+				return err
+			}
+			if err = store.Delete(); err != nil {
+				return fmt.Errorf("failed to remove token: %w", err)
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Logged out of Tado.")
+			return nil
+		},
+	}
+)
+
+// tadoTokenStore stores the Tado token.
+type tadoTokenStore interface {
+	oauth2store.TokenStore
+	Delete() error
+}
+
+// deviceAuthenticator performs the OAuth2 device authorization flow.
+type deviceAuthenticator interface {
+	DeviceAuth(ctx context.Context, opts ...oauth2.AuthCodeOption) (*oauth2.DeviceAuthResponse, error)
+	DeviceAccessToken(ctx context.Context, da *oauth2.DeviceAuthResponse, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+}
+
+// newTadoTokenStore returns the store for the Tado token, as configured by tado.token.store. If a key is configured,
+// the token is encrypted. If r is not nil, it registers the Redis store's metrics.
+func newTadoTokenStore(v *viper.Viper, r prometheus.Registerer) (tadoTokenStore, error) {
+	var store tadoTokenStore
+	switch storeType := v.GetString("tado.token.store"); storeType {
+	case "redis":
+		redisClient := newRedisClient(v)
+		if redisClient == nil {
+			return nil, errors.New("redis.addr not set: the Tado token is stored in Redis")
+		}
+		redisStore := &oauth2redis.TokenStore{
+			RedisClient: redisClient,
+			Key:         oauth2redis.Key(tadoTokenKey, v.GetString("tado.token.account")),
+			TTL:         tadoTokenTTL,
+			Timeout:     v.GetDuration("tado.token.timeout"),
+		}
+		if r != nil {
+			redisStore.Metrics = oauth2redis.NewMetrics("solaredge", "tado")
+			r.MustRegister(redisStore.Metrics)
+		}
+		store = redisStore
+	case "file":
+		path := v.GetString("tado.token.file")
+		if path == "" {
+			return nil, errors.New("tado.token.file not set")
+		}
+		store = oauth2file.NewFileTokenStore(path)
+	default:
+		return nil, fmt.Errorf("invalid tado.token.store %q: must be redis or file", storeType)
+	}
+
+	var key []byte
+	var err error
+	if keyFile := v.GetString("tado.token.key-file"); keyFile != "" {
+		key, err = oauth2crypt.ReadKeyFile(keyFile)
+	} else if keyString := os.Getenv(tadoTokenKeyEnv); keyString != "" {
+		key, err = oauth2crypt.ParseKey(keyString)
+	}
+	if err != nil || key == nil {
+		return store, err
+	}
+	encryptedStore, err := oauth2crypt.NewEncryptedTokenStore(store, key)
+	if err != nil {
+		return nil, err
+	}
+	return encryptedStore, nil
+}
+
+// runTadoLogin performs the device authorization flow and stores the resulting token.
+func runTadoLogin(ctx context.Context, authenticator deviceAuthenticator, store tadoTokenStore, stdout io.Writer) error {
+	response, err := authenticator.DeviceAuth(ctx)
+	if err != nil {
+		return fmt.Errorf("DevAuth: %w", err)
+	}
+	_, _ = fmt.Fprintf(stdout, "Visit %s and log in. Waiting for authorization ...\n", response.VerificationURIComplete)
+	token, err := authenticator.DeviceAccessToken(ctx, response)
+	if err != nil {
+		return fmt.Errorf("DeviceAccessToken: %w", err)
+	}
+	if err = store.Save(token); err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+	_, _ = fmt.Fprintln(stdout, "Logged in to Tado.")
+	return nil
+}
+
+// runTadoStatus reports the state of the stored token. Returns errNoTadoToken if no token is stored.
+func runTadoStatus(store tadoTokenStore, stdout io.Writer, now time.Time) error {
+	token, err := loadTadoToken(store)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(stdout, "Logged in to Tado.")
+	switch {
+	case token.Expiry.IsZero():
+		_, _ = fmt.Fprintln(stdout, "Access token: does not expire")
+	case token.Expiry.After(now):
+		_, _ = fmt.Fprintf(stdout, "Access token: valid until %s (%s)\n", token.Expiry.Format(time.RFC3339), token.Expiry.Sub(now).Round(time.Second))
+	default:
+		_, _ = fmt.Fprintf(stdout, "Access token: expired at %s\n", token.Expiry.Format(time.RFC3339))
+	}
+	if token.RefreshToken != "" {
+		_, _ = fmt.Fprintln(stdout, "Refresh token: present. The access token is refreshed automatically")
+	} else {
+		_, _ = fmt.Fprintln(stdout, "Refresh token: missing. Log in again when the access token expires")
+	}
+	return nil
+}
+
+// loadTadoToken loads the token from the store. Returns errNoTadoToken if no token is stored. Other errors (e.g. the
+// store is unreachable or the token can't be decrypted) are returned as is, so they aren't mistaken for a missing login.
+func loadTadoToken(store oauth2store.TokenStore) (*oauth2.Token, error) {
+	token, err := store.Load()
+	switch {
+	case err == nil:
+		return token, nil
+	case errors.Is(err, redis.Nil) || errors.Is(err, fs.ErrNotExist):
+		return nil, errNoTadoToken
+	default:
+		return nil, fmt.Errorf("failed to load Tado token: %w", err)
+	}
+}
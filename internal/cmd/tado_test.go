@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/clambin/solaredge-monitor/oauth2redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_runTadoLogin(t *testing.T) {
+	var store fakeTokenStore
+	var stdout bytes.Buffer
+	authenticator := fakeAuthenticator{token: &oauth2.Token{AccessToken: "abc"}}
+
+	require.NoError(t, runTadoLogin(t.Context(), &authenticator, &store, &stdout))
+	assert.Equal(t, "abc", store.token.AccessToken)
+	assert.Equal(t, "Visit https://login.tado.com/device?code=1234 and log in. Waiting for authorization ...\nLogged in to Tado.\n", stdout.String())
+
+	authenticator.err = errors.New("access denied")
+	assert.EqualError(t, runTadoLogin(t.Context(), &authenticator, &store, &stdout), "DeviceAccessToken: access denied")
+}
+
+func Test_runTadoStatus(t *testing.T) {
+	now := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		token   *oauth2.Token
+		loadErr error
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "not logged in",
+			wantErr: errNoTadoToken,
+		},
+		{
+			name:    "store unavailable",
+			loadErr: context.DeadlineExceeded,
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:  "valid",
+			token: &oauth2.Token{AccessToken: "abc", RefreshToken: "def", Expiry: now.Add(time.Hour)},
+			want:  "Logged in to Tado.\nAccess token: valid until 2024-07-01T13:00:00Z (1h0m0s)\nRefresh token: present. The access token is refreshed automatically\n",
+		},
+		{
+			name:  "expired",
+			token: &oauth2.Token{AccessToken: "abc", Expiry: now.Add(-time.Hour)},
+			want:  "Logged in to Tado.\nAccess token: expired at 2024-07-01T11:00:00Z\nRefresh token: missing. Log in again when the access token expires\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fakeTokenStore{token: tt.token, err: tt.loadErr}
+			var stdout bytes.Buffer
+			err := runTadoStatus(&store, &stdout, now)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
+
+func Test_newTadoTokenStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	keyFile := filepath.Join(tmpDir, "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(key), 0o600))
+
+	tests := []struct {
+		name      string
+		config    map[string]any
+		env       string
+		wantErr   assert.ErrorAssertionFunc
+		encrypted bool
+	}{
+		{name: "redis without address", config: map[string]any{"tado.token.store": "redis"}, wantErr: assert.Error},
+		{name: "invalid store", config: map[string]any{"tado.token.store": "vault"}, wantErr: assert.Error},
+		{name: "file", config: map[string]any{"tado.token.store": "file", "tado.token.file": filepath.Join(tmpDir, "file.json")}, wantErr: assert.NoError},
+		{name: "file without path", config: map[string]any{"tado.token.store": "file"}, wantErr: assert.Error},
+		{name: "key from env", config: map[string]any{"tado.token.store": "file", "tado.token.file": filepath.Join(tmpDir, "env.json")}, env: key, wantErr: assert.NoError, encrypted: true},
+		{name: "invalid key from env", config: map[string]any{"tado.token.store": "file", "tado.token.file": filepath.Join(tmpDir, "env.json")}, env: "foo", wantErr: assert.Error},
+		{name: "key from file", config: map[string]any{"tado.token.store": "file", "tado.token.file": filepath.Join(tmpDir, "key.json"), "tado.token.key-file": keyFile}, env: "foo", wantErr: assert.NoError, encrypted: true},
+		{name: "missing key file", config: map[string]any{"tado.token.store": "file", "tado.token.file": filepath.Join(tmpDir, "key.json"), "tado.token.key-file": filepath.Join(tmpDir, "missing")}, wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tadoTokenKeyEnv, tt.env)
+			v := viper.New()
+			for key, value := range tt.config {
+				v.Set(key, value)
+			}
+			store, err := newTadoTokenStore(v, nil)
+			tt.wantErr(t, err)
+			if err != nil {
+				return
+			}
+
+			require.NoError(t, store.Save(&oauth2.Token{AccessToken: "abc", RefreshToken: "def"}))
+			content, err := os.ReadFile(v.GetString("tado.token.file"))
+			require.NoError(t, err)
+			assert.Equal(t, !tt.encrypted, strings.Contains(string(content), `"def"`))
+			token, err := store.Load()
+			require.NoError(t, err)
+			assert.Equal(t, "def", token.RefreshToken)
+			require.NoError(t, store.Delete())
+		})
+	}
+}
+
+func Test_newTadoTokenStore_Redis(t *testing.T) {
+	t.Setenv(tadoTokenKeyEnv, "")
+	v := viper.New()
+	v.Set("tado.token.store", "redis")
+	v.Set("redis.addr", "localhost:6379")
+	v.Set("tado.token.account", "foo@example.com")
+	v.Set("tado.token.timeout", time.Second)
+	r := prometheus.NewPedanticRegistry()
+
+	store, err := newTadoTokenStore(v, r)
+	require.NoError(t, err)
+	redisStore, ok := store.(*oauth2redis.TokenStore)
+	require.True(t, ok)
+	assert.Equal(t, oauth2redis.Key(tadoTokenKey, "foo@example.com"), redisStore.Key)
+	assert.Equal(t, time.Second, redisStore.Timeout)
+	assert.NotNil(t, redisStore.Metrics)
+}
+
+func Test_newOAuth2Client(t *testing.T) {
+	_, err := newOAuth2Client(t.Context(), &fakeTokenStore{})
+	assert.ErrorIs(t, err, errNoTadoToken)
+
+	// only a missing token means the user isn't logged in
+	_, err = newOAuth2Client(t.Context(), &fakeTokenStore{err: redis.Nil})
+	assert.ErrorIs(t, err, errNoTadoToken)
+	_, err = newOAuth2Client(t.Context(), &fakeTokenStore{err: context.DeadlineExceeded})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, errNoTadoToken)
+
+	client, err := newOAuth2Client(t.Context(), &fakeTokenStore{token: &oauth2.Token{AccessToken: "abc"}})
+	require.NoError(t, err)
+	assert.NotNil(t, client)
+}
+
+var _ tadoTokenStore = &fakeTokenStore{}
+
+type fakeTokenStore struct {
+	token *oauth2.Token
+	err   error
+}
+
+func (f *fakeTokenStore) Save(token *oauth2.Token) error {
+	f.token = token
+	return nil
+}
+
+func (f *fakeTokenStore) Load() (*oauth2.Token, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.token == nil {
+		return nil, fs.ErrNotExist
+	}
+	return f.token, nil
+}
+
+func (f *fakeTokenStore) Delete() error {
+	f.token = nil
+	return nil
+}
+
+var _ deviceAuthenticator = &fakeAuthenticator{}
+
+type fakeAuthenticator struct {
+	token *oauth2.Token
+	err   error
+}
+
+func (f *fakeAuthenticator) DeviceAuth(_ context.Context, _ ...oauth2.AuthCodeOption) (*oauth2.DeviceAuthResponse, error) {
+	return &oauth2.DeviceAuthResponse{VerificationURIComplete: "https://login.tado.com/device?code=1234"}, nil
+}
+
+func (f *fakeAuthenticator) DeviceAccessToken(_ context.Context, _ *oauth2.DeviceAuthResponse, _ ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return f.token, f.err
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/tariff"
+	"github.com/spf13/viper"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// tariffConfig is the configuration of a tariff in the configuration file, e.g.:
+//
+//	tariffs:
+//	  periods:
+//	    - effective: 2024-01-01
+//	      feed-in: 0.05
+//	      import:
+//	        - price: 0.35
+//	          from: "07:00"
+//	          to: "23:00"
+//	          days: [mon, tue, wed, thu, fri]
+//	        - price: 0.25
+type tariffConfig struct {
+	// Effective is a date (YYYY-MM-DD). Unquoted dates are decoded as a time.Time by the YAML parser.
+	Effective any     `mapstructure:"effective"`
+	FeedIn    float64 `mapstructure:"feed-in"`
+	Import    []struct {
+		Price float64  `mapstructure:"price"`
+		From  string   `mapstructure:"from"`
+		To    string   `mapstructure:"to"`
+		Days  []string `mapstructure:"days"`
+	} `mapstructure:"import"`
+}
+
+// newTariffSchedule returns the configured tariff schedule. Returns nil if no tariffs are configured.
+func newTariffSchedule(v *viper.Viper) (*tariff.Schedule, error) {
+	var periods []tariffConfig
+	if err := v.UnmarshalKey("tariffs.periods", &periods); err != nil {
+		return nil, fmt.Errorf("tariffs: %w", err)
+	}
+	if len(periods) == 0 {
+		return nil, nil
+	}
+
+	schedule := tariff.Schedule{
+		Currency:         v.GetString("tariffs.currency"),
+		SelfConsumption:  v.GetFloat64("tariffs.self-consumption"),
+		InstallationCost: v.GetFloat64("tariffs.installation-cost"),
+	}
+	for _, period := range periods {
+		t, err := parseTariff(period)
+		if err != nil {
+			return nil, fmt.Errorf("tariffs: %w", err)
+		}
+		schedule.Tariffs = append(schedule.Tariffs, t)
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("tariffs: %w", err)
+	}
+	return &schedule, nil
+}
+
+// newTariffTracker returns a Tracker that values the production reported by SolarEdge. Returns nil if no tariffs are configured.
+func newTariffTracker(v *viper.Viper, solarEdge tariff.Publisher[publisher.SolarEdgeUpdate], logger *slog.Logger) (*tariff.Tracker, error) {
+	schedule, err := newTariffSchedule(v)
+	if err != nil || schedule == nil {
+		return nil, err
+	}
+	return &tariff.Tracker{SolarEdge: solarEdge, Schedule: *schedule, Logger: logger}, nil
+}
+
+func parseTariff(cfg tariffConfig) (tariff.Tariff, error) {
+	effective, err := parseEffectiveDate(cfg.Effective)
+	if err != nil {
+		return tariff.Tariff{}, fmt.Errorf("invalid effective date: %w", err)
+	}
+	t := tariff.Tariff{Effective: effective, FeedIn: cfg.FeedIn}
+	for _, rate := range cfg.Import {
+		r := tariff.Rate{Price: rate.Price}
+		if r.From, err = parseTimeOfDay(rate.From); err != nil {
+			return tariff.Tariff{}, fmt.Errorf("tariff effective on %s: invalid from: %w", effective.Format(time.DateOnly), err)
+		}
+		if r.To, err = parseTimeOfDay(rate.To); err != nil {
+			return tariff.Tariff{}, fmt.Errorf("tariff effective on %s: invalid to: %w", effective.Format(time.DateOnly), err)
+		}
+		for _, day := range rate.Days {
+			weekday, err := parseWeekday(day)
+			if err != nil {
+				return tariff.Tariff{}, fmt.Errorf("tariff effective on %s: %w", effective.Format(time.DateOnly), err)
+			}
+			r.Days = append(r.Days, weekday)
+		}
+		t.Import = append(t.Import, r)
+	}
+	return t, nil
+}
+
+// parseEffectiveDate returns midnight (local time) of a date, specified as a time.Time or a string (YYYY-MM-DD).
+func parseEffectiveDate(value any) (time.Time, error) {
+	switch date := value.(type) {
+	case time.Time:
+		return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local), nil
+	case string:
+		return time.ParseInLocation(time.DateOnly, date, time.Local)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// parseTimeOfDay parses a time of day (HH:MM) into the time since midnight. Blank means midnight.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "24:00":
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseWeekday parses the (abbreviated) English name of a day of the week.
+func parseWeekday(value string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if name := strings.ToLower(day.String()); len(value) >= 3 && strings.HasPrefix(name, strings.ToLower(value)) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day %q", value)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/clambin/solaredge-monitor/internal/tariff"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_newTariffSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    *tariff.Schedule
+		wantErr string
+	}{
+		{
+			name: "no tariffs",
+		},
+		{
+			name: "valid",
+			config: `
+tariffs:
+  currency: USD
+  self-consumption: 0.4
+  installation-cost: 10000
+  periods:
+    - effective: 2024-01-01
+      feed-in: 0.05
+      import:
+        - price: 0.35
+          from: "07:00"
+          to: "23:00"
+          days: [mon, tue, wed, thu, friday]
+        - price: 0.25
+`,
+			want: &tariff.Schedule{
+				Currency: "USD",
+				Tariffs: []tariff.Tariff{{
+					Effective: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local),
+					Import: []tariff.Rate{
+						{Price: 0.35, From: 7 * time.Hour, To: 23 * time.Hour, Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}},
+						{Price: 0.25},
+					},
+					FeedIn: 0.05,
+				}},
+				SelfConsumption:  0.4,
+				InstallationCost: 10000,
+			},
+		},
+		{
+			name: "invalid date",
+			config: `
+tariffs:
+  periods:
+    - effective: "January 2024"
+`,
+			wantErr: `tariffs: invalid effective date: parsing time "January 2024" as "2006-01-02": cannot parse "January 2024" as "2006"`,
+		},
+		{
+			name: "invalid time",
+			config: `
+tariffs:
+  periods:
+    - effective: 2024-01-01
+      import:
+        - from: "7am"
+`,
+			wantErr: `tariffs: tariff effective on 2024-01-01: invalid from: parsing time "7am" as "15:04": cannot parse "am" as ":"`,
+		},
+		{
+			name: "invalid day",
+			config: `
+tariffs:
+  periods:
+    - effective: 2024-01-01
+      import:
+        - days: [mo]
+`,
+			wantErr: `tariffs: tariff effective on 2024-01-01: invalid day "mo"`,
+		},
+		{
+			name: "invalid schedule",
+			config: `
+tariffs:
+  self-consumption: 2
+  periods:
+    - effective: 2024-01-01
+`,
+			wantErr: "tariffs: invalid self-consumption 2: must be between 0 and 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.SetConfigType("yaml")
+			require.NoError(t, v.MergeConfig(bytes.NewBufferString(tt.config)))
+
+			schedule, err := newTariffSchedule(v)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, schedule)
+		})
+	}
+}
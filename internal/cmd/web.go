@@ -7,6 +7,7 @@ import (
 	"codeberg.org/clambin/go-common/httputils/middleware"
 	"context"
 	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/health"
 	"github.com/clambin/solaredge-monitor/internal/repository"
 	"github.com/clambin/solaredge-monitor/internal/web"
 	"github.com/prometheus/client_golang/prometheus"
@@ -65,7 +66,55 @@ func runWeb(ctx context.Context, version string, v *viper.Viper, r prometheus.Re
 		}
 	}
 
-	h := web.New(repo, cache, logger)
+	healthProbe := health.Health{
+		Logger:   logger.With("component", "health"),
+		Timeout:  v.GetDuration("health.timeout"),
+		Interval: v.GetDuration("health.interval"),
+		Checks: []health.Check{
+			{Name: "database", Component: health.IsHealthyFunc(func(ctx context.Context) error { return repo.DBX.PingContext(ctx) })},
+			{Name: "web-server", Component: listening(v.GetString("web.addr")), Live: true},
+		},
+	}
+	if cache != nil {
+		healthProbe.Checks = append(healthProbe.Checks, health.Check{Name: "cache", Component: cache})
+	}
+	r.MustRegister(&healthProbe)
+
+	tracker := newPerformanceTracker(v, repo, logger.With("component", "analytics"))
+
+	schedule, err := newTariffSchedule(v)
+	if err != nil {
+		return err
+	}
+
+	factors, err := newEmissionFactors(v)
+	if err != nil {
+		return err
+	}
+
+	analyticsConfig := web.AnalyticsConfig{
+		Performance:   tracker,
+		MinIntensity:  v.GetFloat64("analytics.min-intensity"),
+		InverterPower: v.GetFloat64("clipping.inverter-power"),
+		Tariffs:       schedule,
+		Emissions:     factors,
+		Inventory:     repo,
+	}
+	if v.GetBool("solaredge.storage") {
+		analyticsConfig.Battery = repo
+	}
+	if v.GetBool("solaredge.meter") {
+		analyticsConfig.PowerFlows = repo
+	}
+	if finder := newGapFinder(v, repo); finder != nil {
+		analyticsConfig.Gaps = finder
+	}
+	// the scraper records the forecast errors: the web server only serves the forecast
+	forecaster := newForecaster(v, tracker, nil, logger.With("component", "forecast"))
+	if forecaster != nil {
+		analyticsConfig.Forecast = forecaster
+	}
+	h := web.New(repo, analyticsConfig, cache, logger)
 	h = middleware.WithRequestMetrics(serverMetrics)(h)
 	h = middleware.RequestLogger(logger, slog.LevelInfo, middleware.DefaultRequestLogFormatter)(h)
 
@@ -76,5 +125,15 @@ func runWeb(ctx context.Context, version string, v *viper.Viper, r prometheus.Re
 	g.Go(func() error {
 		return httputils.RunServer(ctx, &http.Server{Addr: v.GetString("web.addr"), Handler: h})
 	})
+	g.Go(func() error {
+		addr := v.GetString("web.health.addr")
+		logger.Debug("starting health probe", "addr", addr)
+		return httputils.RunServer(ctx, &http.Server{Addr: addr, Handler: healthProbe.Handler()})
+	})
+	g.Go(func() error { return healthProbe.Run(ctx) })
+	g.Go(func() error { return tracker.Run(ctx) })
+	if forecaster != nil {
+		g.Go(func() error { return forecaster.Run(ctx) })
+	}
 	return g.Wait()
 }
@@ -0,0 +1,142 @@
+// Package emissions determines the greenhouse gas emissions avoided by the installation's production.
+package emissions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"slices"
+	"time"
+)
+
+const (
+	// DefaultTreeAbsorption is the CO2 absorbed by a mature tree in one year, in kg.
+	DefaultTreeAbsorption = 22.0
+	// DefaultFossilFuel is the fossil fuel (expressed as coal) burnt to generate one kWh in a fossil fuel power plant, in kg.
+	DefaultFossilFuel = 0.4
+)
+
+// Factors determine the emissions avoided by producing energy, rather than importing it from the grid.
+type Factors struct {
+	// Country is the country whose grid emission factors are used.
+	Country string
+	// CO2 holds the emission factor of the country's grid, in kg CO2 per kWh, per year. If a year has no factor, the
+	// factor of the most recent earlier year applies. Years before the first factor use the first factor.
+	CO2 map[int]float64
+	// TreeAbsorption is the CO2 absorbed by a tree in one year, in kg. Used to express the avoided CO2 as trees.
+	TreeAbsorption float64
+	// FossilFuel is the fossil fuel burnt to generate one kWh, in kg.
+	FossilFuel float64
+}
+
+// Validate checks that the factors are complete and consistent.
+func (f Factors) Validate() error {
+	if len(f.CO2) == 0 {
+		return errors.New("no emission factors")
+	}
+	for year, factor := range f.CO2 {
+		if factor < 0 {
+			return fmt.Errorf("invalid emission factor %v for %d: cannot be negative", factor, year)
+		}
+	}
+	if f.TreeAbsorption <= 0 {
+		return fmt.Errorf("invalid tree absorption %v: must be positive", f.TreeAbsorption)
+	}
+	if f.FossilFuel < 0 {
+		return fmt.Errorf("invalid fossil fuel %v: cannot be negative", f.FossilFuel)
+	}
+	return nil
+}
+
+// CO2Factor returns the grid emission factor, in kg CO2 per kWh, at time t.
+func (f Factors) CO2Factor(t time.Time) float64 {
+	years := make([]int, 0, len(f.CO2))
+	for year := range f.CO2 {
+		years = append(years, year)
+	}
+	if len(years) == 0 {
+		return 0
+	}
+	slices.Sort(years)
+	year := years[0]
+	for _, y := range years {
+		if y <= t.Year() {
+			year = y
+		}
+	}
+	return f.CO2[year]
+}
+
+// Benefits are the emissions avoided by producing energy.
+type Benefits struct {
+	// CO2 is the avoided CO2, in kg.
+	CO2 float64 `json:"co2"`
+	// Trees is the number of trees needed to absorb the CO2 in one year.
+	Trees float64 `json:"trees"`
+	// FossilFuel is the avoided fossil fuel, in kg.
+	FossilFuel float64 `json:"fossilFuel"`
+}
+
+func (b *Benefits) add(other Benefits) {
+	b.CO2 += other.CO2
+	b.Trees += other.Trees
+	b.FossilFuel += other.FossilFuel
+}
+
+// Benefits returns the emissions avoided by the energy (in Wh) produced at time t.
+func (f Factors) Benefits(t time.Time, energy float64) Benefits {
+	kWh := energy / 1000
+	co2 := kWh * f.CO2Factor(t)
+	var trees float64
+	if f.TreeAbsorption > 0 {
+		trees = co2 / f.TreeAbsorption
+	}
+	return Benefits{CO2: co2, Trees: trees, FossilFuel: kWh * f.FossilFuel}
+}
+
+// A Period is the production, and the emissions it avoided, during a month.
+type Period struct {
+	Start time.Time `json:"start"`
+	// Energy is the energy produced, in kWh.
+	Energy float64 `json:"energy"`
+	Benefits
+}
+
+// Monthly returns the production and the avoided emissions for each month in the (chronologically ordered) measurements.
+func (f Factors) Monthly(measurements repository.Measurements) []Period {
+	var periods []Period
+	for i, m := range measurements {
+		local := m.Timestamp.Local()
+		periodStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.Local)
+		if len(periods) == 0 || !periods[len(periods)-1].Start.Equal(periodStart) {
+			periods = append(periods, Period{Start: periodStart})
+		}
+		energy := m.Power * analytics.SampleDuration(measurements, i).Hours()
+		period := &periods[len(periods)-1]
+		period.Energy += energy / 1000
+		period.add(f.Benefits(m.Timestamp, energy))
+	}
+	return periods
+}
+
+// A Report summarises the avoided emissions over a number of periods.
+type Report struct {
+	Country string   `json:"country,omitempty"`
+	Periods []Period `json:"periods"`
+	// Total is the production and avoided emissions over all periods. Total.Start is the start of the first period.
+	Total Period `json:"total"`
+}
+
+// NewReport summarises the periods.
+func (f Factors) NewReport(periods []Period) Report {
+	report := Report{Country: f.Country, Periods: periods}
+	for i, period := range periods {
+		if i == 0 {
+			report.Total.Start = period.Start
+		}
+		report.Total.Energy += period.Energy
+		report.Total.add(period.Benefits)
+	}
+	return report
+}
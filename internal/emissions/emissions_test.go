@@ -0,0 +1,89 @@
+package emissions
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testFactors = Factors{
+	Country:        "BE",
+	CO2:            map[int]float64{2023: 0.2, 2025: 0.1},
+	TreeAbsorption: 20,
+	FossilFuel:     0.4,
+}
+
+func TestFactors_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		factors Factors
+		wantErr string
+	}{
+		{name: "valid", factors: testFactors},
+		{name: "no factors", factors: Factors{TreeAbsorption: 20}, wantErr: "no emission factors"},
+		{name: "negative factor", factors: Factors{CO2: map[int]float64{2024: -1}, TreeAbsorption: 20}, wantErr: "invalid emission factor -1 for 2024: cannot be negative"},
+		{name: "no tree absorption", factors: Factors{CO2: map[int]float64{2024: 0.1}}, wantErr: "invalid tree absorption 0: must be positive"},
+		{name: "negative fossil fuel", factors: Factors{CO2: map[int]float64{2024: 0.1}, TreeAbsorption: 20, FossilFuel: -1}, wantErr: "invalid fossil fuel -1: cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.factors.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFactors_CO2Factor(t *testing.T) {
+	tests := []struct {
+		year int
+		want float64
+	}{
+		{year: 2020, want: 0.2},
+		{year: 2023, want: 0.2},
+		{year: 2024, want: 0.2},
+		{year: 2025, want: 0.1},
+		{year: 2030, want: 0.1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, testFactors.CO2Factor(time.Date(tt.year, time.June, 1, 0, 0, 0, 0, time.Local)), tt.year)
+	}
+	assert.Zero(t, Factors{}.CO2Factor(time.Now()))
+}
+
+func TestFactors_Benefits(t *testing.T) {
+	benefits := testFactors.Benefits(time.Date(2024, time.June, 1, 12, 0, 0, 0, time.Local), 10_000)
+	assert.InDelta(t, 2, benefits.CO2, 1e-9)
+	assert.InDelta(t, 0.1, benefits.Trees, 1e-9)
+	assert.InDelta(t, 4, benefits.FossilFuel, 1e-9)
+}
+
+func TestFactors_Monthly(t *testing.T) {
+	// 1 kW during 4 hours on the first of December 2024 and January 2025
+	var measurements repository.Measurements
+	for _, day := range []time.Time{time.Date(2024, time.December, 1, 0, 0, 0, 0, time.Local), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.Local)} {
+		for hour := 10; hour < 14; hour++ {
+			measurements = append(measurements, repository.Measurement{Timestamp: day.Add(time.Duration(hour) * time.Hour), Power: 1000})
+		}
+	}
+
+	periods := testFactors.Monthly(measurements)
+	require.Len(t, periods, 2)
+	assert.Equal(t, time.Date(2024, time.December, 1, 0, 0, 0, 0, time.Local), periods[0].Start)
+	assert.InDelta(t, 4, periods[0].Energy, 1e-9)
+	assert.InDelta(t, 0.8, periods[0].CO2, 1e-9)
+	assert.InDelta(t, 0.4, periods[1].CO2, 1e-9)
+
+	report := testFactors.NewReport(periods)
+	assert.Equal(t, "BE", report.Country)
+	assert.Equal(t, periods[0].Start, report.Total.Start)
+	assert.InDelta(t, 8, report.Total.Energy, 1e-9)
+	assert.InDelta(t, 1.2, report.Total.CO2, 1e-9)
+	assert.InDelta(t, 0.06, report.Total.Trees, 1e-9)
+	assert.InDelta(t, 3.2, report.Total.FossilFuel, 1e-9)
+}
@@ -0,0 +1,204 @@
+package emissions
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Publisher[T any] interface {
+	Subscribe() <-chan T
+	Unsubscribe(<-chan T)
+}
+
+type Client interface {
+	GetEnvBenefits(ctx context.Context, id int) (solaredge.GetEnvBenefitsResponse, error)
+}
+
+// maxDeviation is the relative difference between the avoided CO2 and SolarEdge's estimate above which the Tracker logs a warning.
+const maxDeviation = 0.25
+
+// poundsToKg converts the CO2 reported by SolarEdge if the site uses imperial units.
+const poundsToKg = 0.45359237
+
+// A Tracker determines the emissions avoided by the production reported by SolarEdge.
+//
+// The Tracker applies the current emission factor to each site's day, month, year and lifetime energy. For the
+// lifetime energy, this is an approximation, as the grid's emission factor changes over time.
+//
+// If Client is set, the Tracker also gets SolarEdge's own estimate of the site's environmental benefits every Interval
+// and logs a warning if it differs significantly from the Tracker's lifetime estimate.
+type Tracker struct {
+	SolarEdge Publisher[publisher.SolarEdgeUpdate]
+	Client    Client
+	Factors   Factors
+	Interval  time.Duration
+	Logger    *slog.Logger
+	sites     map[int]*siteBenefits
+	lock      sync.Mutex
+}
+
+type siteBenefits struct {
+	name      string
+	periods   map[string]Benefits
+	reported  *solaredge.EnvBenefits
+	lastCheck time.Time
+}
+
+var (
+	co2AvoidedMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "emissions", "co2_avoided_kg"),
+		"CO2 emissions avoided by the energy produced during the current day, month, year or lifetime",
+		[]string{"site", "siteid", "period"},
+		nil,
+	)
+	treesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "emissions", "trees"),
+		"Number of trees needed to absorb the avoided CO2 in one year",
+		[]string{"site", "siteid", "period"},
+		nil,
+	)
+	fossilFuelAvoidedMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "emissions", "fossil_fuel_avoided_kg"),
+		"Fossil fuel saved by the energy produced during the current day, month, year or lifetime",
+		[]string{"site", "siteid", "period"},
+		nil,
+	)
+	co2FactorMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "emissions", "co2_factor"),
+		"Current grid emission factor, in kg CO2 per kWh",
+		[]string{"country"},
+		nil,
+	)
+	reportedCO2AvoidedMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "emissions", "reported_co2_avoided_kg"),
+		"Lifetime CO2 emissions avoided, as reported by SolarEdge",
+		[]string{"site", "siteid"},
+		nil,
+	)
+	reportedTreesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "emissions", "reported_trees"),
+		"Equivalent trees planted, as reported by SolarEdge",
+		[]string{"site", "siteid"},
+		nil,
+	)
+)
+
+func (t *Tracker) Run(ctx context.Context) error {
+	ch := t.SolarEdge.Subscribe()
+	defer t.SolarEdge.Unsubscribe(ch)
+
+	t.Logger.Debug("starting emissions tracker")
+	defer t.Logger.Debug("stopped emissions tracker")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update := <-ch:
+			t.update(time.Now(), update)
+			t.crossCheck(ctx, time.Now())
+		}
+	}
+}
+
+func (t *Tracker) update(now time.Time, update publisher.SolarEdgeUpdate) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.sites == nil {
+		t.sites = make(map[int]*siteBenefits)
+	}
+	for _, siteUpdate := range update {
+		site, ok := t.sites[siteUpdate.ID]
+		if !ok {
+			site = &siteBenefits{}
+			t.sites[siteUpdate.ID] = site
+		}
+		site.name = siteUpdate.Name
+		site.periods = map[string]Benefits{
+			"day":      t.Factors.Benefits(now, siteUpdate.PowerOverview.LastDayData.Energy),
+			"month":    t.Factors.Benefits(now, siteUpdate.PowerOverview.LastMonthData.Energy),
+			"year":     t.Factors.Benefits(now, siteUpdate.PowerOverview.LastYearData.Energy),
+			"lifetime": t.Factors.Benefits(now, siteUpdate.PowerOverview.LifeTimeData.Energy),
+		}
+	}
+}
+
+// crossCheck gets SolarEdge's environmental benefits for each site that wasn't checked during the last Interval.
+func (t *Tracker) crossCheck(ctx context.Context, now time.Time) {
+	if t.Client == nil {
+		return
+	}
+	for id, site := range t.dueForCheck(now) {
+		resp, err := t.Client.GetEnvBenefits(ctx, id)
+		if err != nil {
+			t.Logger.Warn("failed to get environmental benefits", "site", site, "err", err)
+			continue
+		}
+		t.setReported(id, now, resp.EnvBenefits)
+	}
+}
+
+func (t *Tracker) dueForCheck(now time.Time) map[int]string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	due := make(map[int]string)
+	for id, site := range t.sites {
+		if site.lastCheck.IsZero() || now.Sub(site.lastCheck) >= t.Interval {
+			due[id] = site.name
+		}
+	}
+	return due
+}
+
+func (t *Tracker) setReported(id int, now time.Time, benefits solaredge.EnvBenefits) {
+	if strings.HasPrefix(strings.ToLower(benefits.GasEmissionSaved.Units), "lb") {
+		benefits.GasEmissionSaved.Co2 *= poundsToKg
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	site := t.sites[id]
+	site.reported = &benefits
+	site.lastCheck = now
+
+	reported, estimated := benefits.GasEmissionSaved.Co2, site.periods["lifetime"].CO2
+	if reported > 0 && math.Abs(estimated-reported)/reported > maxDeviation {
+		t.Logger.Warn("avoided CO2 differs from SolarEdge's estimate", "site", site.name, "estimated", estimated, "reported", reported)
+	}
+}
+
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- co2AvoidedMetric
+	ch <- treesMetric
+	ch <- fossilFuelAvoidedMetric
+	ch <- co2FactorMetric
+	ch <- reportedCO2AvoidedMetric
+	ch <- reportedTreesMetric
+}
+
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(co2FactorMetric, prometheus.GaugeValue, t.Factors.CO2Factor(time.Now()), t.Factors.Country)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for id, site := range t.sites {
+		siteID := strconv.Itoa(id)
+		for period, benefits := range site.periods {
+			ch <- prometheus.MustNewConstMetric(co2AvoidedMetric, prometheus.GaugeValue, benefits.CO2, site.name, siteID, period)
+			ch <- prometheus.MustNewConstMetric(treesMetric, prometheus.GaugeValue, benefits.Trees, site.name, siteID, period)
+			ch <- prometheus.MustNewConstMetric(fossilFuelAvoidedMetric, prometheus.GaugeValue, benefits.FossilFuel, site.name, siteID, period)
+		}
+		if site.reported != nil {
+			ch <- prometheus.MustNewConstMetric(reportedCO2AvoidedMetric, prometheus.GaugeValue, site.reported.GasEmissionSaved.Co2, site.name, siteID)
+			ch <- prometheus.MustNewConstMetric(reportedTreesMetric, prometheus.GaugeValue, site.reported.TreesPlanted, site.name, siteID)
+		}
+	}
+}
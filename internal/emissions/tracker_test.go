@@ -0,0 +1,125 @@
+package emissions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/solaredge/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker_update(t *testing.T) {
+	tracker := Tracker{Factors: testFactors, Logger: slog.New(slog.DiscardHandler)}
+	tracker.update(time.Date(2024, time.June, 1, 12, 0, 0, 0, time.Local), publisher.SolarEdgeUpdate{{
+		ID:   1,
+		Name: "foo",
+		PowerOverview: solaredge.PowerOverview{
+			LifeTimeData:  solaredge.EnergyOverview{Energy: 1_000_000},
+			LastYearData:  solaredge.EnergyOverview{Energy: 100_000},
+			LastMonthData: solaredge.EnergyOverview{Energy: 10_000},
+			LastDayData:   solaredge.EnergyOverview{Energy: 1_000},
+		},
+	}})
+
+	want := map[string]float64{"day": 0.2, "month": 2, "year": 20, "lifetime": 200}
+	for period, co2 := range want {
+		assert.InDelta(t, co2, tracker.sites[1].periods[period].CO2, 1e-9, period)
+	}
+}
+
+func TestTracker_crossCheck(t *testing.T) {
+	var logOutput bytes.Buffer
+	client := fakeClient{}
+	tracker := Tracker{
+		Client:   &client,
+		Factors:  testFactors,
+		Interval: time.Hour,
+		Logger:   slog.New(slog.NewTextHandler(&logOutput, nil)),
+	}
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.Local)
+	tracker.update(now, publisher.SolarEdgeUpdate{{ID: 1, Name: "foo", PowerOverview: solaredge.PowerOverview{LifeTimeData: solaredge.EnergyOverview{Energy: 1_000_000}}}})
+
+	// estimate is 200 kg: SolarEdge's 400 lb is close enough
+	client.benefits.GasEmissionSaved.Units = "lb"
+	client.benefits.GasEmissionSaved.Co2 = 400
+	tracker.crossCheck(t.Context(), now)
+	assert.InDelta(t, 181.4, tracker.sites[1].reported.GasEmissionSaved.Co2, 0.1)
+	assert.Empty(t, logOutput.String())
+
+	// not checked again during the interval
+	client.benefits.GasEmissionSaved.Units = "kg"
+	client.benefits.GasEmissionSaved.Co2 = 400
+	tracker.crossCheck(t.Context(), now.Add(time.Minute))
+	assert.InDelta(t, 181.4, tracker.sites[1].reported.GasEmissionSaved.Co2, 0.1)
+
+	// large deviation is logged
+	tracker.crossCheck(t.Context(), now.Add(time.Hour))
+	assert.Equal(t, 400.0, tracker.sites[1].reported.GasEmissionSaved.Co2)
+	assert.Contains(t, logOutput.String(), "avoided CO2 differs from SolarEdge's estimate")
+
+	// failures are logged
+	logOutput.Reset()
+	client.err = errors.New("fail")
+	tracker.crossCheck(t.Context(), now.Add(2*time.Hour))
+	assert.Contains(t, logOutput.String(), "failed to get environmental benefits")
+}
+
+func TestTracker_Run(t *testing.T) {
+	ch := make(chan publisher.SolarEdgeUpdate)
+	client := fakeClient{}
+	client.benefits.GasEmissionSaved.Units = "kg"
+	client.benefits.GasEmissionSaved.Co2 = 1
+	client.benefits.TreesPlanted = 0.1
+	tracker := Tracker{
+		SolarEdge: testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: ch},
+		Client:    &client,
+		Factors:   Factors{Country: "BE", CO2: map[int]float64{2020: 0.5}, TreeAbsorption: 20},
+		Interval:  time.Hour,
+		Logger:    slog.New(slog.DiscardHandler),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- tracker.Run(ctx) }()
+	ch <- testutils.TestUpdate
+
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCount(&tracker, "solaredge_emissions_reported_co2_avoided_kg") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, testutil.CollectAndCompare(&tracker, strings.NewReader(`
+# HELP solaredge_emissions_co2_avoided_kg CO2 emissions avoided by the energy produced during the current day, month, year or lifetime
+# TYPE solaredge_emissions_co2_avoided_kg gauge
+solaredge_emissions_co2_avoided_kg{period="day",site="foo",siteid="1"} 0.005
+solaredge_emissions_co2_avoided_kg{period="lifetime",site="foo",siteid="1"} 0
+solaredge_emissions_co2_avoided_kg{period="month",site="foo",siteid="1"} 0.05
+solaredge_emissions_co2_avoided_kg{period="year",site="foo",siteid="1"} 0.5
+# HELP solaredge_emissions_co2_factor Current grid emission factor, in kg CO2 per kWh
+# TYPE solaredge_emissions_co2_factor gauge
+solaredge_emissions_co2_factor{country="BE"} 0.5
+# HELP solaredge_emissions_reported_co2_avoided_kg Lifetime CO2 emissions avoided, as reported by SolarEdge
+# TYPE solaredge_emissions_reported_co2_avoided_kg gauge
+solaredge_emissions_reported_co2_avoided_kg{site="foo",siteid="1"} 1
+# HELP solaredge_emissions_reported_trees Equivalent trees planted, as reported by SolarEdge
+# TYPE solaredge_emissions_reported_trees gauge
+solaredge_emissions_reported_trees{site="foo",siteid="1"} 0.1
+`), "solaredge_emissions_co2_avoided_kg", "solaredge_emissions_co2_factor", "solaredge_emissions_reported_co2_avoided_kg", "solaredge_emissions_reported_trees"))
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+type fakeClient struct {
+	benefits solaredge.EnvBenefits
+	err      error
+}
+
+func (f *fakeClient) GetEnvBenefits(_ context.Context, _ int) (solaredge.GetEnvBenefitsResponse, error) {
+	return solaredge.GetEnvBenefitsResponse{EnvBenefits: f.benefits}, f.err
+}
@@ -0,0 +1,27 @@
+// Package energy determines how much of a site's production is consumed on site.
+package energy
+
+// Balance is the energy produced, consumed and self-consumed by a site over a period. Any unit works, as long as
+// all fields use the same one.
+type Balance struct {
+	Production  float64
+	Consumption float64
+	// SelfConsumption is the part of the production that was consumed on site.
+	SelfConsumption float64
+}
+
+// SelfConsumptionRatio returns the fraction of the production that was consumed on site. Returns false if nothing was produced.
+func (b Balance) SelfConsumptionRatio() (float64, bool) {
+	if b.Production <= 0 {
+		return 0, false
+	}
+	return min(1, b.SelfConsumption/b.Production), true
+}
+
+// SelfSufficiencyRatio returns the fraction of the consumption that was covered by the production. Returns false if nothing was consumed.
+func (b Balance) SelfSufficiencyRatio() (float64, bool) {
+	if b.Consumption <= 0 {
+		return 0, false
+	}
+	return min(1, b.SelfConsumption/b.Consumption), true
+}
@@ -0,0 +1,49 @@
+package energy_test
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/energy"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBalance(t *testing.T) {
+	tests := []struct {
+		name            string
+		balance         energy.Balance
+		selfConsumption float64
+		selfConsumed    bool
+		selfSufficiency float64
+		selfSufficient  bool
+	}{
+		{
+			name:            "production and consumption",
+			balance:         energy.Balance{Production: 10000, Consumption: 5000, SelfConsumption: 4000},
+			selfConsumption: 0.4, selfConsumed: true,
+			selfSufficiency: 0.8, selfSufficient: true,
+		},
+		{
+			name:            "rounding errors are capped",
+			balance:         energy.Balance{Production: 1000, Consumption: 1000, SelfConsumption: 1001},
+			selfConsumption: 1, selfConsumed: true,
+			selfSufficiency: 1, selfSufficient: true,
+		},
+		{
+			name:            "no production",
+			balance:         energy.Balance{Consumption: 1000},
+			selfSufficiency: 0, selfSufficient: true,
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ratio, ok := tt.balance.SelfConsumptionRatio()
+			assert.Equal(t, tt.selfConsumed, ok)
+			assert.Equal(t, tt.selfConsumption, ratio)
+			ratio, ok = tt.balance.SelfSufficiencyRatio()
+			assert.Equal(t, tt.selfSufficient, ok)
+			assert.Equal(t, tt.selfSufficiency, ratio)
+		})
+	}
+}
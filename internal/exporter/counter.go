@@ -0,0 +1,71 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"sync"
+)
+
+var _ prometheus.Collector = &counterVec{}
+
+// counterVec exports values as a counter. Unlike a prometheus.CounterVec, the value is set directly.
+// This allows us to export lifetime counters, as reported by SolarEdge, with the correct metric type.
+type counterVec struct {
+	desc       *prometheus.Desc
+	values     map[string]counterValue
+	labelNames []string
+	lock       sync.RWMutex
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+func newCounterVec(name, help string, labelNames []string) *counterVec {
+	return &counterVec{
+		desc:       prometheus.NewDesc(name, help, labelNames, nil),
+		values:     make(map[string]counterValue),
+		labelNames: labelNames,
+	}
+}
+
+func (c *counterVec) Set(value float64, labelValues ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[strings.Join(labelValues, "|")] = counterValue{labelValues: labelValues, value: value}
+}
+
+func (c *counterVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *counterVec) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, v := range c.values {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, v.value, v.labelValues...)
+	}
+}
+
+func (c *counterVec) DeletePartialMatch(labels prometheus.Labels) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var count int
+	for key, v := range c.values {
+		if c.matches(v.labelValues, labels) {
+			delete(c.values, key)
+			count++
+		}
+	}
+	return count
+}
+
+func (c *counterVec) matches(labelValues []string, labels prometheus.Labels) bool {
+	for i, name := range c.labelNames {
+		if value, ok := labels[name]; ok && labelValues[i] != value {
+			return false
+		}
+	}
+	return true
+}
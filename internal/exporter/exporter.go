@@ -2,15 +2,31 @@ package exporter
 
 import (
 	"context"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
 	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge/v2"
 	"github.com/prometheus/client_golang/prometheus"
+	"iter"
 	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type Exporter struct {
 	SolarEdge Publisher[publisher.SolarEdgeUpdate]
-	Metrics   *Metrics
-	Logger    *slog.Logger
+	// Sources are checked every Interval to determine the value of the solaredge_up metric for each source.
+	Sources map[string]Source
+	Metrics *Metrics
+	Logger  *slog.Logger
+	// MaxAge drops the metrics of any site or inverter that hasn't been updated in MaxAge. Zero means metrics are never dropped.
+	MaxAge time.Duration
+	// Interval determines how often Sources and MaxAge are checked. Defaults to one minute.
+	Interval time.Duration
+	// InverterPower is the rated AC power of the inverter(s), in W. Used to detect clipping. Zero disables clipping detection.
+	InverterPower float64
 }
 
 type Publisher[T any] interface {
@@ -18,6 +34,10 @@ type Publisher[T any] interface {
 	Unsubscribe(<-chan T)
 }
 
+type Source interface {
+	IsHealthy(context.Context) error
+}
+
 func (e Exporter) Run(ctx context.Context) error {
 	ch := e.SolarEdge.Subscribe()
 	defer e.SolarEdge.Unsubscribe(ch)
@@ -25,110 +45,603 @@ func (e Exporter) Run(ctx context.Context) error {
 	e.Logger.Debug("starting exporter")
 	defer e.Logger.Debug("stopped exporter")
 
+	interval := e.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	e.check(ctx)
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case update := <-ch:
 			e.export(update)
+		case <-ticker.C:
+			e.check(ctx)
 		}
 	}
 }
 
 func (e Exporter) export(update publisher.SolarEdgeUpdate) {
-	for site := range update {
-		e.Metrics.currentPower.WithLabelValues(update[site].Name).Set(update[site].PowerOverview.CurrentPower.Power)
-		e.Metrics.dayEnergy.WithLabelValues(update[site].Name).Set(update[site].PowerOverview.LastDayData.Energy)
-		e.Metrics.monthEnergy.WithLabelValues(update[site].Name).Set(update[site].PowerOverview.LastMonthData.Energy)
-		e.Metrics.yearEnergy.WithLabelValues(update[site].Name).Set(update[site].PowerOverview.LastYearData.Energy)
-
-		for inverter := range update[site].InverterUpdates {
-			e.Metrics.inverterTemperature.WithLabelValues(update[site].Name, update[site].InverterUpdates[inverter].Name).Set(update[site].InverterUpdates[inverter].Telemetry.Temperature)
-			e.Metrics.inverterACVoltage.WithLabelValues(update[site].Name, update[site].InverterUpdates[inverter].Name).Set(update[site].InverterUpdates[inverter].Telemetry.L1Data.AcVoltage)
-			e.Metrics.inverterACCurrent.WithLabelValues(update[site].Name, update[site].InverterUpdates[inverter].Name).Set(update[site].InverterUpdates[inverter].Telemetry.L1Data.AcCurrent)
-			e.Metrics.inverterDCVoltage.WithLabelValues(update[site].Name, update[site].InverterUpdates[inverter].Name).Set(update[site].InverterUpdates[inverter].Telemetry.DcVoltage)
-			e.Metrics.inverterPowerLimit.WithLabelValues(update[site].Name, update[site].InverterUpdates[inverter].Name).Set(update[site].InverterUpdates[inverter].Telemetry.PowerLimit)
+	now := time.Now()
+	sites := make(map[siteKey]struct{}, len(update))
+	inverters := make(map[inverterKey]struct{})
+	batteries := make(map[batteryKey]struct{})
+
+	for _, siteUpdate := range update {
+		site := siteKey{id: strconv.Itoa(siteUpdate.ID), name: siteUpdate.Name}
+		sites[site] = struct{}{}
+		labels := site.labelValues()
+		e.Metrics.currentPower.WithLabelValues(labels...).Set(siteUpdate.PowerOverview.CurrentPower.Power)
+		e.Metrics.dayEnergy.WithLabelValues(labels...).Set(siteUpdate.PowerOverview.LastDayData.Energy)
+		e.Metrics.monthEnergy.WithLabelValues(labels...).Set(siteUpdate.PowerOverview.LastMonthData.Energy)
+		e.Metrics.yearEnergy.WithLabelValues(labels...).Set(siteUpdate.PowerOverview.LastYearData.Energy)
+		e.Metrics.setSiteInfo(site, siteUpdate.Details)
+		e.Metrics.setPowerFlow(site, siteUpdate.PowerFlow, siteUpdate.EnergyDetails)
+		e.Metrics.setStorage(site, siteUpdate.Storage)
+		e.Metrics.siteUpdated(site, now)
+		if siteUpdate.Storage != nil {
+			for _, batteryUpdate := range siteUpdate.Storage.Batteries {
+				battery := batteryKey{site: site, serial: batteryUpdate.SerialNumber}
+				batteries[battery] = struct{}{}
+				e.Metrics.batteryUpdated(battery, now)
+			}
+		}
+
+		for _, inverterUpdate := range siteUpdate.InverterUpdates {
+			inverter := inverterKey{site: site, serial: inverterUpdate.SerialNumber, name: inverterUpdate.Name}
+			inverters[inverter] = struct{}{}
+			labels = inverter.labelValues()
+			telemetry := inverterUpdate.Telemetry
+			e.Metrics.inverterTemperature.WithLabelValues(labels...).Set(telemetry.Temperature)
+			e.Metrics.inverterDCVoltage.WithLabelValues(labels...).Set(telemetry.DcVoltage)
+			e.Metrics.inverterPowerLimit.WithLabelValues(labels...).Set(telemetry.PowerLimit)
+			e.Metrics.inverterActivePowerTotal.WithLabelValues(labels...).Set(telemetry.TotalActivePower)
+			e.Metrics.inverterEnergy.Set(telemetry.TotalEnergy, labels...)
+			if e.InverterPower > 0 {
+				var clipping float64
+				if isClipping(telemetry, e.InverterPower) {
+					clipping = 1
+				}
+				e.Metrics.inverterClipping.WithLabelValues(labels...).Set(clipping)
+			}
+
+			for phase, data := range phases(inverterUpdate) {
+				phaseLabels := append(inverter.labelValues(), phase)
+				e.Metrics.inverterACVoltage.WithLabelValues(phaseLabels...).Set(data.AcVoltage)
+				e.Metrics.inverterACCurrent.WithLabelValues(phaseLabels...).Set(data.AcCurrent)
+				e.Metrics.inverterACFrequency.WithLabelValues(phaseLabels...).Set(data.AcFrequency)
+				e.Metrics.inverterActivePower.WithLabelValues(phaseLabels...).Set(data.ActivePower)
+				e.Metrics.inverterApparentPower.WithLabelValues(phaseLabels...).Set(data.ApparentPower)
+				e.Metrics.inverterReactivePower.WithLabelValues(phaseLabels...).Set(data.ReactivePower)
+				e.Metrics.inverterCosPhi.WithLabelValues(phaseLabels...).Set(data.CosPhi)
+			}
+			e.Metrics.setInverterInfo(inverter, inverterUpdate.Manufacturer, inverterUpdate.Model)
+			e.Metrics.inverterUpdated(inverter, now)
+		}
+	}
+
+	// remove any sites, inverters & batteries that are no longer reported
+	for _, site := range e.Metrics.removeSites(func(site siteKey, _ time.Time) bool { _, ok := sites[site]; return !ok }) {
+		e.Logger.Info("site no longer reported. removing metrics", "site", site.name, "siteid", site.id)
+	}
+	for _, inverter := range e.Metrics.removeInverters(func(inverter inverterKey, _ time.Time) bool { _, ok := inverters[inverter]; return !ok }) {
+		e.Logger.Info("inverter no longer reported. removing metrics", "site", inverter.site.name, "inverter", inverter.name, "serial", inverter.serial)
+	}
+	for _, battery := range e.Metrics.removeBatteries(func(battery batteryKey, _ time.Time) bool { _, ok := batteries[battery]; return !ok }) {
+		e.Logger.Info("battery no longer reported. removing metrics", "site", battery.site.name, "battery", battery.serial)
+	}
+}
+
+func (e Exporter) check(ctx context.Context) {
+	for name, source := range e.Sources {
+		var up float64
+		if err := source.IsHealthy(ctx); err == nil {
+			up = 1
+		} else {
+			e.Logger.Debug("source not healthy", "source", name, "err", err)
+		}
+		e.Metrics.up.WithLabelValues(name).Set(up)
+	}
+
+	if e.MaxAge <= 0 {
+		return
+	}
+	for _, site := range e.Metrics.removeSites(func(_ siteKey, lastUpdate time.Time) bool { return time.Since(lastUpdate) > e.MaxAge }) {
+		e.Logger.Warn("no recent data for site. removing metrics", "site", site.name, "siteid", site.id, "maxAge", e.MaxAge)
+	}
+	for _, inverter := range e.Metrics.removeInverters(func(_ inverterKey, lastUpdate time.Time) bool { return time.Since(lastUpdate) > e.MaxAge }) {
+		e.Logger.Warn("no recent data for inverter. removing metrics", "site", inverter.site.name, "inverter", inverter.name, "serial", inverter.serial, "maxAge", e.MaxAge)
+	}
+	for _, battery := range e.Metrics.removeBatteries(func(_ batteryKey, lastUpdate time.Time) bool { return time.Since(lastUpdate) > e.MaxAge }) {
+		e.Logger.Warn("no recent data for battery. removing metrics", "site", battery.site.name, "battery", battery.serial, "maxAge", e.MaxAge)
+	}
+}
+
+// isClipping returns true if the inverter's output is at its ceiling, i.e. its rated power, reduced by its power limit.
+func isClipping(telemetry solaredge.InverterTelemetry, ratedPower float64) bool {
+	limit := telemetry.PowerLimit
+	if limit <= 0 || limit > 1 {
+		limit = 1
+	}
+	return telemetry.TotalActivePower > 0 && telemetry.TotalActivePower >= analytics.ClippingTolerance*limit*ratedPower
+}
+
+// phases returns the telemetry data for each of the inverter's phases, in phase order. If the update has no per-phase
+// data, only L1 of the inverter's telemetry is returned.
+func phases(inverterUpdate publisher.InverterUpdate) iter.Seq2[string, solaredge.InverterTelemetryL1Data] {
+	return func(yield func(string, solaredge.InverterTelemetryL1Data) bool) {
+		if len(inverterUpdate.Phases) == 0 {
+			_ = yield("L1", inverterUpdate.Telemetry.L1Data)
+			return
+		}
+		for _, phase := range slices.Sorted(maps.Keys(inverterUpdate.Phases)) {
+			if !yield(phase, inverterUpdate.Phases[phase]) {
+				return
+			}
 		}
 	}
 }
 
 //////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-var _ prometheus.Collector = Metrics{}
+var _ prometheus.Collector = &Metrics{}
 
 type Metrics struct {
-	currentPower        *prometheus.GaugeVec
-	dayEnergy           *prometheus.GaugeVec
-	monthEnergy         *prometheus.GaugeVec
-	yearEnergy          *prometheus.GaugeVec
-	inverterTemperature *prometheus.GaugeVec
-	inverterACVoltage   *prometheus.GaugeVec
-	inverterACCurrent   *prometheus.GaugeVec
-	inverterDCVoltage   *prometheus.GaugeVec
-	inverterPowerLimit  *prometheus.GaugeVec
+	currentPower             *prometheus.GaugeVec
+	dayEnergy                *prometheus.GaugeVec
+	monthEnergy              *prometheus.GaugeVec
+	yearEnergy               *prometheus.GaugeVec
+	siteLastUpdate           *prometheus.GaugeVec
+	sitePeakPower            *prometheus.GaugeVec
+	siteInfo                 *prometheus.GaugeVec
+	siteConsumption          *prometheus.GaugeVec
+	siteGridPower            *prometheus.GaugeVec
+	siteMeterEnergy          *prometheus.GaugeVec
+	siteSelfConsumption      *prometheus.GaugeVec
+	siteSelfSufficiency      *prometheus.GaugeVec
+	batteryChargeLevel       *prometheus.GaugeVec
+	batteryPower             *prometheus.GaugeVec
+	batteryCapacity          *prometheus.GaugeVec
+	batteryEnergyAvailable   *prometheus.GaugeVec
+	batteryTemperature       *prometheus.GaugeVec
+	batteryCharged           *counterVec
+	batteryDischarged        *counterVec
+	inverterTemperature      *prometheus.GaugeVec
+	inverterDCVoltage        *prometheus.GaugeVec
+	inverterPowerLimit       *prometheus.GaugeVec
+	inverterActivePowerTotal *prometheus.GaugeVec
+	inverterClipping         *prometheus.GaugeVec
+	inverterEnergy           *counterVec
+	inverterACVoltage        *prometheus.GaugeVec
+	inverterACCurrent        *prometheus.GaugeVec
+	inverterACFrequency      *prometheus.GaugeVec
+	inverterActivePower      *prometheus.GaugeVec
+	inverterApparentPower    *prometheus.GaugeVec
+	inverterReactivePower    *prometheus.GaugeVec
+	inverterCosPhi           *prometheus.GaugeVec
+	inverterLastUpdate       *prometheus.GaugeVec
+	inverterInfo             *prometheus.GaugeVec
+	up                       *prometheus.GaugeVec
+	sites                    map[siteKey]time.Time
+	inverters                map[inverterKey]time.Time
+	batteries                map[batteryKey]time.Time
+	lock                     sync.Mutex
+}
+
+var (
+	siteLabels     = []string{"site", "siteid"}
+	inverterLabels = []string{"site", "siteid", "inverter", "serial"}
+	phaseLabels    = []string{"site", "siteid", "inverter", "serial", "phase"}
+	batteryLabels  = []string{"site", "siteid", "battery"}
+)
+
+type siteKey struct {
+	id   string
+	name string
+}
+
+func (s siteKey) labelValues() []string {
+	return []string{s.name, s.id}
+}
+
+type inverterKey struct {
+	site   siteKey
+	serial string
+	name   string
+}
+
+func (i inverterKey) labelValues() []string {
+	return []string{i.site.name, i.site.id, i.name, i.serial}
+}
+
+type batteryKey struct {
+	site   siteKey
+	serial string
 }
 
 func NewMetrics() *Metrics {
 	return &Metrics{
+		sites:     make(map[siteKey]time.Time),
+		inverters: make(map[inverterKey]time.Time),
+		batteries: make(map[batteryKey]time.Time),
 		currentPower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: prometheus.BuildFQName("solaredge", "", "current_power"),
 			Help: "current power in Watt",
-		}, []string{"site"}),
+		}, siteLabels),
 		dayEnergy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: prometheus.BuildFQName("solaredge", "", "day_energy"),
 			Help: "Today's produced energy in WattHours",
-		}, []string{"site"}),
+		}, siteLabels),
 		monthEnergy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: prometheus.BuildFQName("solaredge", "", "month_energy"),
 			Help: "This month's produced energy in WattHours",
-		}, []string{"site"}),
+		}, siteLabels),
 		yearEnergy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: prometheus.BuildFQName("solaredge", "", "year_energy"),
 			Help: "This year's produced energy in WattHours",
-		}, []string{"site"}),
+		}, siteLabels),
+		siteLastUpdate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "", "last_update_timestamp_seconds"),
+			Help: "Timestamp of the last update received for the site",
+		}, siteLabels),
+		sitePeakPower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "site", "peak_power"),
+			Help: "Peak power of the site in kW",
+		}, siteLabels),
+		siteInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "site", "info"),
+			Help: "Site details",
+		}, append(slices.Clone(siteLabels), "country", "city", "timezone", "installation_date", "peak_power")),
+		siteConsumption: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "site", "consumption_power"),
+			Help: "Current consumption in Watt",
+		}, siteLabels),
+		siteGridPower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "site", "grid_power"),
+			Help: "Current power imported from the grid in Watt. Negative if power is exported to the grid",
+		}, siteLabels),
+		siteMeterEnergy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "site", "day_meter_energy"),
+			Help: "Today's energy measured by the site's meters in WattHours",
+		}, append(slices.Clone(siteLabels), "meter")),
+		siteSelfConsumption: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "site", "self_consumption_ratio"),
+			Help: "Fraction of today's production that was consumed on site",
+		}, siteLabels),
+		siteSelfSufficiency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "site", "self_sufficiency_ratio"),
+			Help: "Fraction of today's consumption that was covered by the production",
+		}, siteLabels),
+		batteryChargeLevel: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "battery", "charge_level"),
+			Help: "State of charge of the site's batteries in %",
+		}, siteLabels),
+		batteryPower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "battery", "power"),
+			Help: "Power charging the site's batteries in Watt. Negative if the batteries are discharging",
+		}, siteLabels),
+		batteryCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "battery", "capacity"),
+			Help: "Nameplate capacity of the battery in WattHours",
+		}, batteryLabels),
+		batteryEnergyAvailable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "battery", "energy_available"),
+			Help: "Energy the battery can store when fully charged in WattHours",
+		}, batteryLabels),
+		batteryTemperature: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "battery", "temperature"),
+			Help: "Internal temperature reported by the battery",
+		}, batteryLabels),
+		batteryCharged: newCounterVec(
+			prometheus.BuildFQName("solaredge", "battery", "charged_energy_total"),
+			"Lifetime energy charged into the battery in WattHours",
+			batteryLabels,
+		),
+		batteryDischarged: newCounterVec(
+			prometheus.BuildFQName("solaredge", "battery", "discharged_energy_total"),
+			"Lifetime energy discharged from the battery in WattHours",
+			batteryLabels,
+		),
 		inverterTemperature: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: prometheus.BuildFQName("solaredge", "inverter", "temperature"),
 			Help: "Temperature reported by the inverter(s)",
-		}, []string{"site", "inverter"}),
-		inverterACVoltage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: prometheus.BuildFQName("solaredge", "inverter", "ac_voltage"),
-			Help: "AC voltage reported by the inverter(s)",
-		}, []string{"site", "inverter"}),
-		inverterACCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: prometheus.BuildFQName("solaredge", "inverter", "ac_current"),
-			Help: "AC current reported by the inverter(s)",
-		}, []string{"site", "inverter"}),
+		}, inverterLabels),
 		inverterDCVoltage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: prometheus.BuildFQName("solaredge", "inverter", "dc_voltage"),
 			Help: "DC voltage reported by the inverter(s)",
-		}, []string{"site", "inverter"}),
+		}, inverterLabels),
 		inverterPowerLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: prometheus.BuildFQName("solaredge", "inverter", "power_limit"),
 			Help: "Power limit reported by the inverter(s)",
-		}, []string{"site", "inverter"}),
-	}
-}
-
-func (m Metrics) Describe(ch chan<- *prometheus.Desc) {
-	m.currentPower.Describe(ch)
-	m.dayEnergy.Describe(ch)
-	m.monthEnergy.Describe(ch)
-	m.yearEnergy.Describe(ch)
-	m.inverterTemperature.Describe(ch)
-	m.inverterACVoltage.Describe(ch)
-	m.inverterACCurrent.Describe(ch)
-	m.inverterDCVoltage.Describe(ch)
-	m.inverterPowerLimit.Describe(ch)
-}
-
-func (m Metrics) Collect(ch chan<- prometheus.Metric) {
-	m.currentPower.Collect(ch)
-	m.dayEnergy.Collect(ch)
-	m.monthEnergy.Collect(ch)
-	m.yearEnergy.Collect(ch)
-	m.inverterTemperature.Collect(ch)
-	m.inverterACVoltage.Collect(ch)
-	m.inverterACCurrent.Collect(ch)
-	m.inverterDCVoltage.Collect(ch)
-	m.inverterPowerLimit.Collect(ch)
+		}, inverterLabels),
+		inverterActivePowerTotal: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "total_active_power"),
+			Help: "Total active power over all phases reported by the inverter(s)",
+		}, inverterLabels),
+		inverterClipping: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "clipping"),
+			Help: "Set to 1 if the inverter(s) output is capped at its rated power, 0 otherwise",
+		}, inverterLabels),
+		inverterEnergy: newCounterVec(
+			prometheus.BuildFQName("solaredge", "inverter", "energy_total"),
+			"Lifetime energy produced by the inverter(s) in WattHours",
+			inverterLabels,
+		),
+		inverterACVoltage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "ac_voltage"),
+			Help: "AC voltage reported by the inverter(s)",
+		}, phaseLabels),
+		inverterACCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "ac_current"),
+			Help: "AC current reported by the inverter(s)",
+		}, phaseLabels),
+		inverterACFrequency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "ac_frequency"),
+			Help: "AC frequency reported by the inverter(s)",
+		}, phaseLabels),
+		inverterActivePower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "active_power"),
+			Help: "Active power reported by the inverter(s)",
+		}, phaseLabels),
+		inverterApparentPower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "apparent_power"),
+			Help: "Apparent power reported by the inverter(s)",
+		}, phaseLabels),
+		inverterReactivePower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "reactive_power"),
+			Help: "Reactive power reported by the inverter(s)",
+		}, phaseLabels),
+		inverterCosPhi: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "cos_phi"),
+			Help: "Power factor (cos phi) reported by the inverter(s)",
+		}, phaseLabels),
+		inverterLastUpdate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "last_update_timestamp_seconds"),
+			Help: "Timestamp of the last update received for the inverter(s)",
+		}, inverterLabels),
+		inverterInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "inverter", "info"),
+			Help: "Inverter details",
+		}, append(slices.Clone(inverterLabels), "manufacturer", "model")),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName("solaredge", "", "up"),
+			Help: "Set to 1 if the source is healthy, 0 otherwise",
+		}, []string{"source"}),
+	}
+}
+
+func (m *Metrics) siteUpdated(site siteKey, timestamp time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sites[site] = timestamp
+	m.siteLastUpdate.WithLabelValues(site.labelValues()...).Set(float64(timestamp.Unix()))
+}
+
+func (m *Metrics) inverterUpdated(inverter inverterKey, timestamp time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.inverters[inverter] = timestamp
+	m.inverterLastUpdate.WithLabelValues(inverter.labelValues()...).Set(float64(timestamp.Unix()))
+}
+
+func (m *Metrics) batteryUpdated(battery batteryKey, timestamp time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.batteries[battery] = timestamp
+}
+
+func (m *Metrics) setSiteInfo(site siteKey, details solaredge.SiteDetails) {
+	m.sitePeakPower.WithLabelValues(site.labelValues()...).Set(details.PeakPower)
+	// site details may change: remove the old info before setting the new one.
+	m.siteInfo.DeletePartialMatch(prometheus.Labels{"siteid": site.id})
+	var installationDate string
+	if date := time.Time(details.InstallationDate); !date.IsZero() {
+		installationDate = date.Format(time.DateOnly)
+	}
+	m.siteInfo.WithLabelValues(append(site.labelValues(),
+		details.Location.Country,
+		details.Location.City,
+		details.Location.TimeZone,
+		installationDate,
+		strconv.FormatFloat(details.PeakPower, 'f', -1, 64),
+	)...).Set(1)
+}
+
+// setPowerFlow sets the metrics of a site with a meter. Nothing is set if the site's power flow isn't known.
+func (m *Metrics) setPowerFlow(site siteKey, powerFlow *publisher.PowerFlow, energyDetails *publisher.EnergyDetails) {
+	labels := site.labelValues()
+	if powerFlow != nil {
+		m.siteConsumption.WithLabelValues(labels...).Set(powerFlow.Consumption)
+		m.siteGridPower.WithLabelValues(labels...).Set(powerFlow.Grid)
+	}
+	if energyDetails == nil {
+		return
+	}
+	for meter, energy := range map[string]float64{
+		"production":       energyDetails.Production,
+		"consumption":      energyDetails.Consumption,
+		"self_consumption": energyDetails.SelfConsumption,
+		"feed_in":          energyDetails.FeedIn,
+		"purchased":        energyDetails.Purchased,
+	} {
+		m.siteMeterEnergy.WithLabelValues(append(labels, meter)...).Set(energy)
+	}
+	balance := energyDetails.Balance()
+	if ratio, ok := balance.SelfConsumptionRatio(); ok {
+		m.siteSelfConsumption.WithLabelValues(labels...).Set(ratio)
+	}
+	if ratio, ok := balance.SelfSufficiencyRatio(); ok {
+		m.siteSelfSufficiency.WithLabelValues(labels...).Set(ratio)
+	}
+}
+
+// setStorage sets the battery metrics of a site. Nothing is set if the site's storage isn't known.
+func (m *Metrics) setStorage(site siteKey, storage *publisher.StorageUpdate) {
+	if storage == nil {
+		return
+	}
+	m.batteryChargeLevel.WithLabelValues(site.labelValues()...).Set(storage.ChargeLevel)
+	m.batteryPower.WithLabelValues(site.labelValues()...).Set(storage.Power)
+	for _, battery := range storage.Batteries {
+		labels := append(site.labelValues(), battery.SerialNumber)
+		m.batteryCapacity.WithLabelValues(labels...).Set(battery.Nameplate)
+		// batteries without recent telemetry only report their capacity
+		if battery.Telemetry == (solaredge.BatteryTelemetry{}) {
+			continue
+		}
+		m.batteryEnergyAvailable.WithLabelValues(labels...).Set(battery.Telemetry.FullPackEnergyAvailable)
+		m.batteryTemperature.WithLabelValues(labels...).Set(battery.Telemetry.InternalTemp)
+		m.batteryCharged.Set(battery.Telemetry.LifeTimeEnergyCharged, labels...)
+		m.batteryDischarged.Set(battery.Telemetry.LifeTimeEnergyDischarged, labels...)
+	}
+}
+
+func (m *Metrics) setInverterInfo(inverter inverterKey, manufacturer, model string) {
+	m.inverterInfo.DeletePartialMatch(prometheus.Labels{"siteid": inverter.site.id, "serial": inverter.serial})
+	m.inverterInfo.WithLabelValues(append(inverter.labelValues(), manufacturer, model)...).Set(1)
+}
+
+// removeSites deletes the metrics of all sites (and their inverters and batteries) for which remove returns true. It returns the removed sites.
+func (m *Metrics) removeSites(remove func(siteKey, time.Time) bool) []siteKey {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var removed []siteKey
+	for site, lastUpdate := range m.sites {
+		if !remove(site, lastUpdate) {
+			continue
+		}
+		labels := prometheus.Labels{"siteid": site.id}
+		for _, c := range m.collectors() {
+			c.DeletePartialMatch(labels)
+		}
+		for inverter := range m.inverters {
+			if inverter.site == site {
+				delete(m.inverters, inverter)
+			}
+		}
+		for battery := range m.batteries {
+			if battery.site == site {
+				delete(m.batteries, battery)
+			}
+		}
+		delete(m.sites, site)
+		removed = append(removed, site)
+	}
+	return removed
+}
+
+// removeInverters deletes the metrics of all inverters for which remove returns true. It returns the removed inverters.
+func (m *Metrics) removeInverters(remove func(inverterKey, time.Time) bool) []inverterKey {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var removed []inverterKey
+	for inverter, lastUpdate := range m.inverters {
+		if !remove(inverter, lastUpdate) {
+			continue
+		}
+		labels := prometheus.Labels{"siteid": inverter.site.id, "serial": inverter.serial}
+		for _, c := range m.inverterCollectors() {
+			c.DeletePartialMatch(labels)
+		}
+		delete(m.inverters, inverter)
+		removed = append(removed, inverter)
+	}
+	return removed
+}
+
+// removeBatteries deletes the metrics of all batteries for which remove returns true. It returns the removed batteries.
+func (m *Metrics) removeBatteries(remove func(batteryKey, time.Time) bool) []batteryKey {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var removed []batteryKey
+	for battery, lastUpdate := range m.batteries {
+		if !remove(battery, lastUpdate) {
+			continue
+		}
+		labels := prometheus.Labels{"siteid": battery.site.id, "battery": battery.serial}
+		for _, c := range m.batteryCollectors() {
+			c.DeletePartialMatch(labels)
+		}
+		delete(m.batteries, battery)
+		removed = append(removed, battery)
+	}
+	return removed
+}
+
+type deletableCollector interface {
+	prometheus.Collector
+	DeletePartialMatch(prometheus.Labels) int
+}
+
+func (m *Metrics) siteCollectors() []deletableCollector {
+	return []deletableCollector{
+		m.currentPower,
+		m.dayEnergy,
+		m.monthEnergy,
+		m.yearEnergy,
+		m.siteLastUpdate,
+		m.sitePeakPower,
+		m.siteInfo,
+		m.siteConsumption,
+		m.siteGridPower,
+		m.siteMeterEnergy,
+		m.siteSelfConsumption,
+		m.siteSelfSufficiency,
+		m.batteryChargeLevel,
+		m.batteryPower,
+	}
+}
+
+// batteryCollectors returns the collectors of the metrics of individual batteries.
+func (m *Metrics) batteryCollectors() []deletableCollector {
+	return []deletableCollector{
+		m.batteryCapacity,
+		m.batteryEnergyAvailable,
+		m.batteryTemperature,
+		m.batteryCharged,
+		m.batteryDischarged,
+	}
+}
+
+func (m *Metrics) inverterCollectors() []deletableCollector {
+	return []deletableCollector{
+		m.inverterTemperature,
+		m.inverterDCVoltage,
+		m.inverterPowerLimit,
+		m.inverterActivePowerTotal,
+		m.inverterClipping,
+		m.inverterEnergy,
+		m.inverterACVoltage,
+		m.inverterACCurrent,
+		m.inverterACFrequency,
+		m.inverterActivePower,
+		m.inverterApparentPower,
+		m.inverterReactivePower,
+		m.inverterCosPhi,
+		m.inverterLastUpdate,
+		m.inverterInfo,
+	}
+}
+
+// collectors returns all collectors of site, battery and inverter metrics.
+func (m *Metrics) collectors() []deletableCollector {
+	return slices.Concat(m.siteCollectors(), m.batteryCollectors(), m.inverterCollectors())
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+	m.up.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+	m.up.Collect(ch)
 }
@@ -1,12 +1,16 @@
 package exporter
 
 import (
+	"context"
+	"errors"
 	"github.com/clambin/solaredge-monitor/internal/publisher"
 	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/solaredge/v2"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"log/slog"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -26,44 +30,341 @@ func TestExporter(t *testing.T) {
 	p.Ch <- testutils.TestUpdate
 
 	require.Eventually(t, func() bool {
-		return testutil.CollectAndCount(metrics) >= 9
+		return testutil.CollectAndCount(metrics) >= 21
 	}, 10*time.Second, time.Millisecond)
 
 	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
 # HELP solaredge_current_power current power in Watt
 # TYPE solaredge_current_power gauge
-solaredge_current_power{site="foo"} 3000
+solaredge_current_power{site="foo",siteid="1"} 3000
 
 # HELP solaredge_day_energy Today's produced energy in WattHours
 # TYPE solaredge_day_energy gauge
-solaredge_day_energy{site="foo"} 10
+solaredge_day_energy{site="foo",siteid="1"} 10
 
 # HELP solaredge_inverter_ac_current AC current reported by the inverter(s)
 # TYPE solaredge_inverter_ac_current gauge
-solaredge_inverter_ac_current{inverter="inv1",site="foo"} 10
+solaredge_inverter_ac_current{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 10
+
+# HELP solaredge_inverter_ac_frequency AC frequency reported by the inverter(s)
+# TYPE solaredge_inverter_ac_frequency gauge
+solaredge_inverter_ac_frequency{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 50
 
 # HELP solaredge_inverter_ac_voltage AC voltage reported by the inverter(s)
 # TYPE solaredge_inverter_ac_voltage gauge
-solaredge_inverter_ac_voltage{inverter="inv1",site="foo"} 240
+solaredge_inverter_ac_voltage{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 240
+
+# HELP solaredge_inverter_active_power Active power reported by the inverter(s)
+# TYPE solaredge_inverter_active_power gauge
+solaredge_inverter_active_power{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 2400
+
+# HELP solaredge_inverter_apparent_power Apparent power reported by the inverter(s)
+# TYPE solaredge_inverter_apparent_power gauge
+solaredge_inverter_apparent_power{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 2500
+
+# HELP solaredge_inverter_cos_phi Power factor (cos phi) reported by the inverter(s)
+# TYPE solaredge_inverter_cos_phi gauge
+solaredge_inverter_cos_phi{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 0.96
 
 # HELP solaredge_inverter_dc_voltage DC voltage reported by the inverter(s)
 # TYPE solaredge_inverter_dc_voltage gauge
-solaredge_inverter_dc_voltage{inverter="inv1",site="foo"} 400
+solaredge_inverter_dc_voltage{inverter="inv1",serial="1234",site="foo",siteid="1"} 400
+
+# HELP solaredge_inverter_energy_total Lifetime energy produced by the inverter(s) in WattHours
+# TYPE solaredge_inverter_energy_total counter
+solaredge_inverter_energy_total{inverter="inv1",serial="1234",site="foo",siteid="1"} 8888
 
 # HELP solaredge_inverter_power_limit Power limit reported by the inverter(s)
 # TYPE solaredge_inverter_power_limit gauge
-solaredge_inverter_power_limit{inverter="inv1",site="foo"} 1
+solaredge_inverter_power_limit{inverter="inv1",serial="1234",site="foo",siteid="1"} 1
+
+# HELP solaredge_inverter_reactive_power Reactive power reported by the inverter(s)
+# TYPE solaredge_inverter_reactive_power gauge
+solaredge_inverter_reactive_power{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 700
 
 # HELP solaredge_inverter_temperature Temperature reported by the inverter(s)
 # TYPE solaredge_inverter_temperature gauge
-solaredge_inverter_temperature{inverter="inv1",site="foo"} 40
+solaredge_inverter_temperature{inverter="inv1",serial="1234",site="foo",siteid="1"} 40
+
+# HELP solaredge_inverter_total_active_power Total active power over all phases reported by the inverter(s)
+# TYPE solaredge_inverter_total_active_power gauge
+solaredge_inverter_total_active_power{inverter="inv1",serial="1234",site="foo",siteid="1"} 9999
+
+# HELP solaredge_inverter_info Inverter details
+# TYPE solaredge_inverter_info gauge
+solaredge_inverter_info{inverter="inv1",manufacturer="SolarEdge",model="SE4000H",serial="1234",site="foo",siteid="1"} 1
 
 # HELP solaredge_month_energy This month's produced energy in WattHours
 # TYPE solaredge_month_energy gauge
-solaredge_month_energy{site="foo"} 100
+solaredge_month_energy{site="foo",siteid="1"} 100
+
+# HELP solaredge_site_info Site details
+# TYPE solaredge_site_info gauge
+solaredge_site_info{city="Brussels",country="Belgium",installation_date="2020-06-01",peak_power="4.5",site="foo",siteid="1",timezone="Europe/Brussels"} 1
+
+# HELP solaredge_site_peak_power Peak power of the site in kW
+# TYPE solaredge_site_peak_power gauge
+solaredge_site_peak_power{site="foo",siteid="1"} 4.5
 
 # HELP solaredge_year_energy This year's produced energy in WattHours
 # TYPE solaredge_year_energy gauge
-solaredge_year_energy{site="foo"} 1000
-`)))
+solaredge_year_energy{site="foo",siteid="1"} 1000
+`), valueMetrics...))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_last_update_timestamp_seconds"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_inverter_last_update_timestamp_seconds"))
+}
+
+var valueMetrics = []string{
+	"solaredge_current_power",
+	"solaredge_day_energy",
+	"solaredge_month_energy",
+	"solaredge_year_energy",
+	"solaredge_inverter_ac_current",
+	"solaredge_inverter_ac_frequency",
+	"solaredge_inverter_ac_voltage",
+	"solaredge_inverter_active_power",
+	"solaredge_inverter_apparent_power",
+	"solaredge_inverter_cos_phi",
+	"solaredge_inverter_dc_voltage",
+	"solaredge_inverter_energy_total",
+	"solaredge_inverter_power_limit",
+	"solaredge_inverter_reactive_power",
+	"solaredge_inverter_temperature",
+	"solaredge_inverter_total_active_power",
+	"solaredge_inverter_info",
+	"solaredge_site_info",
+	"solaredge_site_peak_power",
+}
+
+func TestExporter_RemovedSources(t *testing.T) {
+	metrics := NewMetrics()
+	e := Exporter{Metrics: metrics, Logger: slog.New(slog.DiscardHandler)}
+
+	e.export(testutils.TestUpdate)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_current_power"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_inverter_temperature"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_inverter_energy_total"))
+
+	// inverter is no longer reported
+	e.export(testutils.EmptyUpdate)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_current_power"))
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_inverter_temperature"))
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_inverter_energy_total"))
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_inverter_last_update_timestamp_seconds"))
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_inverter_info"))
+
+	// site is no longer reported
+	e.export(publisher.SolarEdgeUpdate{})
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_current_power"))
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_last_update_timestamp_seconds"))
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_site_info"))
+}
+
+func TestExporter_Clipping(t *testing.T) {
+	metrics := NewMetrics()
+	e := Exporter{Metrics: metrics, Logger: slog.New(slog.DiscardHandler)}
+
+	// clipping detection is disabled by default
+	e.export(testutils.TestUpdate)
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_inverter_clipping"))
+
+	// TestUpdate reports a total active power of 9999 W at a power limit of 1
+	e.InverterPower = 10000
+	e.export(testutils.TestUpdate)
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP solaredge_inverter_clipping Set to 1 if the inverter(s) output is capped at its rated power, 0 otherwise
+# TYPE solaredge_inverter_clipping gauge
+solaredge_inverter_clipping{inverter="inv1",serial="1234",site="foo",siteid="1"} 1
+`), "solaredge_inverter_clipping"))
+
+	e.InverterPower = 12000
+	e.export(testutils.TestUpdate)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.inverterClipping.WithLabelValues("foo", "1", "inv1", "1234")))
+}
+
+func TestExporter_Phases(t *testing.T) {
+	metrics := NewMetrics()
+	e := Exporter{Metrics: metrics, Logger: slog.New(slog.DiscardHandler)}
+
+	update := slices.Clone(testutils.TestUpdate)
+	update[0].InverterUpdates = slices.Clone(update[0].InverterUpdates)
+	update[0].InverterUpdates[0].Phases = map[string]solaredge.InverterTelemetryL1Data{
+		"L1": {AcVoltage: 230}, "L2": {AcVoltage: 231}, "L3": {AcVoltage: 229},
+	}
+	e.export(update)
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP solaredge_inverter_ac_voltage AC voltage reported by the inverter(s)
+# TYPE solaredge_inverter_ac_voltage gauge
+solaredge_inverter_ac_voltage{inverter="inv1",phase="L1",serial="1234",site="foo",siteid="1"} 230
+solaredge_inverter_ac_voltage{inverter="inv1",phase="L2",serial="1234",site="foo",siteid="1"} 231
+solaredge_inverter_ac_voltage{inverter="inv1",phase="L3",serial="1234",site="foo",siteid="1"} 229
+`), "solaredge_inverter_ac_voltage"))
+}
+
+func TestExporter_PowerFlow(t *testing.T) {
+	metrics := NewMetrics()
+	e := Exporter{Metrics: metrics, Logger: slog.New(slog.DiscardHandler)}
+
+	// sites without a meter don't report power flow metrics
+	e.export(testutils.TestUpdate)
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_site_consumption_power", "solaredge_site_self_consumption_ratio"))
+
+	update := slices.Clone(testutils.TestUpdate)
+	update[0].PowerFlow = &publisher.PowerFlow{Production: 3000, Consumption: 1000, Grid: -2000}
+	update[0].EnergyDetails = &publisher.EnergyDetails{Production: 10000, Consumption: 5000, SelfConsumption: 4000, FeedIn: 6000, Purchased: 1000}
+	e.export(update)
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP solaredge_site_consumption_power Current consumption in Watt
+# TYPE solaredge_site_consumption_power gauge
+solaredge_site_consumption_power{site="foo",siteid="1"} 1000
+# HELP solaredge_site_day_meter_energy Today's energy measured by the site's meters in WattHours
+# TYPE solaredge_site_day_meter_energy gauge
+solaredge_site_day_meter_energy{meter="consumption",site="foo",siteid="1"} 5000
+solaredge_site_day_meter_energy{meter="feed_in",site="foo",siteid="1"} 6000
+solaredge_site_day_meter_energy{meter="production",site="foo",siteid="1"} 10000
+solaredge_site_day_meter_energy{meter="purchased",site="foo",siteid="1"} 1000
+solaredge_site_day_meter_energy{meter="self_consumption",site="foo",siteid="1"} 4000
+# HELP solaredge_site_grid_power Current power imported from the grid in Watt. Negative if power is exported to the grid
+# TYPE solaredge_site_grid_power gauge
+solaredge_site_grid_power{site="foo",siteid="1"} -2000
+# HELP solaredge_site_self_consumption_ratio Fraction of today's production that was consumed on site
+# TYPE solaredge_site_self_consumption_ratio gauge
+solaredge_site_self_consumption_ratio{site="foo",siteid="1"} 0.4
+# HELP solaredge_site_self_sufficiency_ratio Fraction of today's consumption that was covered by the production
+# TYPE solaredge_site_self_sufficiency_ratio gauge
+solaredge_site_self_sufficiency_ratio{site="foo",siteid="1"} 0.8
+`), "solaredge_site_consumption_power", "solaredge_site_day_meter_energy", "solaredge_site_grid_power", "solaredge_site_self_consumption_ratio", "solaredge_site_self_sufficiency_ratio"))
+}
+
+func TestExporter_Storage(t *testing.T) {
+	metrics := NewMetrics()
+	e := Exporter{Metrics: metrics, Logger: slog.New(slog.DiscardHandler)}
+
+	// sites without batteries don't report battery metrics
+	e.export(testutils.TestUpdate)
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_battery_charge_level", "solaredge_battery_capacity"))
+
+	update := slices.Clone(testutils.TestUpdate)
+	update[0].Storage = &publisher.StorageUpdate{
+		Status:      "Discharging",
+		ChargeLevel: 60,
+		Power:       -500,
+		Batteries: []publisher.BatteryUpdate{
+			{SerialNumber: "B1", Nameplate: 10000, Telemetry: solaredge.BatteryTelemetry{FullPackEnergyAvailable: 9500, InternalTemp: 25, LifeTimeEnergyCharged: 1100, LifeTimeEnergyDischarged: 900}},
+			{SerialNumber: "B2", Nameplate: 5000},
+		},
+	}
+	e.export(update)
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP solaredge_battery_capacity Nameplate capacity of the battery in WattHours
+# TYPE solaredge_battery_capacity gauge
+solaredge_battery_capacity{battery="B1",site="foo",siteid="1"} 10000
+solaredge_battery_capacity{battery="B2",site="foo",siteid="1"} 5000
+# HELP solaredge_battery_charge_level State of charge of the site's batteries in %
+# TYPE solaredge_battery_charge_level gauge
+solaredge_battery_charge_level{site="foo",siteid="1"} 60
+# HELP solaredge_battery_charged_energy_total Lifetime energy charged into the battery in WattHours
+# TYPE solaredge_battery_charged_energy_total counter
+solaredge_battery_charged_energy_total{battery="B1",site="foo",siteid="1"} 1100
+# HELP solaredge_battery_discharged_energy_total Lifetime energy discharged from the battery in WattHours
+# TYPE solaredge_battery_discharged_energy_total counter
+solaredge_battery_discharged_energy_total{battery="B1",site="foo",siteid="1"} 900
+# HELP solaredge_battery_energy_available Energy the battery can store when fully charged in WattHours
+# TYPE solaredge_battery_energy_available gauge
+solaredge_battery_energy_available{battery="B1",site="foo",siteid="1"} 9500
+# HELP solaredge_battery_power Power charging the site's batteries in Watt. Negative if the batteries are discharging
+# TYPE solaredge_battery_power gauge
+solaredge_battery_power{site="foo",siteid="1"} -500
+# HELP solaredge_battery_temperature Internal temperature reported by the battery
+# TYPE solaredge_battery_temperature gauge
+solaredge_battery_temperature{battery="B1",site="foo",siteid="1"} 25
+`), "solaredge_battery_capacity", "solaredge_battery_charge_level", "solaredge_battery_charged_energy_total", "solaredge_battery_discharged_energy_total",
+		"solaredge_battery_energy_available", "solaredge_battery_power", "solaredge_battery_temperature"))
+
+	// a battery that's no longer reported is removed
+	update[0].Storage = &publisher.StorageUpdate{
+		ChargeLevel: 60,
+		Batteries:   []publisher.BatteryUpdate{{SerialNumber: "B1", Nameplate: 10000, Telemetry: solaredge.BatteryTelemetry{InternalTemp: 25}}},
+	}
+	e.export(update)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_battery_capacity"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_battery_temperature"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_battery_charge_level"))
+
+	// removing the site removes its battery metrics
+	e.export(publisher.SolarEdgeUpdate{})
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_battery_charge_level", "solaredge_battery_capacity"))
+}
+
+func Test_isClipping(t *testing.T) {
+	tests := []struct {
+		name      string
+		telemetry solaredge.InverterTelemetry
+		want      bool
+	}{
+		{name: "at rated power", telemetry: solaredge.InverterTelemetry{TotalActivePower: 5000, PowerLimit: 1}, want: true},
+		{name: "below rated power", telemetry: solaredge.InverterTelemetry{TotalActivePower: 4000, PowerLimit: 1}},
+		{name: "at power limit", telemetry: solaredge.InverterTelemetry{TotalActivePower: 3500, PowerLimit: 0.7}, want: true},
+		{name: "below power limit", telemetry: solaredge.InverterTelemetry{TotalActivePower: 3000, PowerLimit: 0.7}},
+		{name: "no power limit reported", telemetry: solaredge.InverterTelemetry{TotalActivePower: 5000}, want: true},
+		{name: "no power", telemetry: solaredge.InverterTelemetry{PowerLimit: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isClipping(tt.telemetry, 5000))
+		})
+	}
+}
+
+func TestExporter_check(t *testing.T) {
+	metrics := NewMetrics()
+	e := Exporter{
+		Sources: map[string]Source{
+			"up":   fakeSource{},
+			"down": fakeSource{err: errors.New("failed")},
+		},
+		Metrics: metrics,
+		Logger:  slog.New(slog.DiscardHandler),
+		MaxAge:  time.Hour,
+	}
+
+	e.export(testutils.TestUpdate)
+	e.check(t.Context())
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_current_power"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_inverter_temperature"))
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP solaredge_up Set to 1 if the source is healthy, 0 otherwise
+# TYPE solaredge_up gauge
+solaredge_up{source="down"} 0
+solaredge_up{source="up"} 1
+`), "solaredge_up"))
+
+	// age a battery
+	site := siteKey{id: "1", name: "foo"}
+	update := slices.Clone(testutils.TestUpdate)
+	update[0].Storage = &publisher.StorageUpdate{Batteries: []publisher.BatteryUpdate{{SerialNumber: "B1", Nameplate: 10000}}}
+	e.export(update)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_battery_capacity"))
+	metrics.batteryUpdated(batteryKey{site: site, serial: "B1"}, time.Now().Add(-2*time.Hour))
+	e.check(t.Context())
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_battery_capacity"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "solaredge_current_power"))
+
+	// age the data
+	metrics.siteUpdated(site, time.Now().Add(-2*time.Hour))
+	metrics.inverterUpdated(inverterKey{site: site, serial: "1234", name: "inv1"}, time.Now().Add(-2*time.Hour))
+	e.check(t.Context())
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_current_power"))
+	assert.Zero(t, testutil.CollectAndCount(metrics, "solaredge_inverter_temperature"))
+}
+
+var _ Source = fakeSource{}
+
+type fakeSource struct {
+	err error
+}
+
+func (f fakeSource) IsHealthy(_ context.Context) error {
+	return f.err
 }
@@ -0,0 +1,92 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var _ PointWriter = InfluxDBWriter{}
+
+// InfluxDBWriter writes points to InfluxDB, using the v2 HTTP write API and line protocol.
+type InfluxDBWriter struct {
+	// URL of the InfluxDB server, e.g. http://localhost:8086
+	URL        string
+	Org        string
+	Bucket     string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func (w InfluxDBWriter) WritePoints(ctx context.Context, points []Point) error {
+	target, err := url.JoinPath(w.URL, "/api/v2/write")
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("invalid url: %w", err)}
+	}
+	values := url.Values{"org": {w.Org}, "bucket": {w.Bucket}, "precision": {"s"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target+"?"+values.Encode(), bytes.NewReader(encodeLineProtocol(points)))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Token "+w.Token)
+	}
+
+	httpClient := w.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("influxdb: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("influxdb: %w", httpError(resp))
+	}
+	return nil
+}
+
+// encodeLineProtocol encodes the points as InfluxDB line protocol. Tags and fields are sorted, so the output is stable.
+func encodeLineProtocol(points []Point) []byte {
+	var b bytes.Buffer
+	for _, p := range points {
+		// line protocol doesn't support NaN or infinite values
+		fields := slices.DeleteFunc(slices.Sorted(maps.Keys(p.Fields)), func(key string) bool {
+			return math.IsNaN(p.Fields[key]) || math.IsInf(p.Fields[key], 0)
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		b.WriteString(measurementEscaper.Replace(p.Name))
+		for _, key := range slices.Sorted(maps.Keys(p.Tags)) {
+			// line protocol doesn't support empty tag values
+			if value := p.Tags[key]; value != "" {
+				b.WriteString("," + tagEscaper.Replace(key) + "=" + tagEscaper.Replace(value))
+			}
+		}
+		for i, key := range fields {
+			if i == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteByte(',')
+			}
+			b.WriteString(tagEscaper.Replace(key) + "=" + strconv.FormatFloat(p.Fields[key], 'f', -1, 64))
+		}
+		b.WriteString(" " + strconv.FormatInt(p.Time.Unix(), 10) + "\n")
+	}
+	return b.Bytes()
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
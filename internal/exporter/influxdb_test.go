@@ -0,0 +1,87 @@
+package exporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInfluxDBWriter_WritePoints(t *testing.T) {
+	var body string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" || r.URL.Query().Get("bucket") != "solaredge" || r.URL.Query().Get("org") != "home" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(s.Close)
+
+	points := []Point{{
+		Name:   "solaredge_site",
+		Time:   time.Unix(1700000000, 0),
+		Tags:   map[string]string{"site": "my home", "siteid": "1"},
+		Fields: map[string]float64{"current_power": 3000, "day_energy": 10.5},
+	}}
+
+	w := InfluxDBWriter{URL: s.URL, Org: "home", Bucket: "solaredge", Token: "secret"}
+	assert.NoError(t, w.WritePoints(t.Context(), points))
+	assert.Equal(t, "solaredge_site,site=my\\ home,siteid=1 current_power=3000,day_energy=10.5 1700000000\n", body)
+
+	w.Token = "invalid"
+	err := w.WritePoints(t.Context(), points)
+	var permanent *PermanentError
+	assert.ErrorAs(t, err, &permanent)
+}
+
+func Test_encodeLineProtocol(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   string
+	}{
+		{
+			name: "escaping",
+			points: []Point{{
+				Name:   "my measurement,1",
+				Tags:   map[string]string{"a=b": "c,d e"},
+				Fields: map[string]float64{"value": 1},
+			}},
+			want: "my\\ measurement\\,1,a\\=b=c\\,d\\ e value=1 -62135596800\n",
+		},
+		{
+			name: "empty tags are skipped",
+			points: []Point{{
+				Name:   "foo",
+				Time:   time.Unix(1, 0),
+				Tags:   map[string]string{"a": "", "b": "1"},
+				Fields: map[string]float64{"value": 1},
+			}},
+			want: "foo,b=1 value=1 1\n",
+		},
+		{
+			name: "points without valid fields are skipped",
+			points: []Point{
+				{Name: "foo", Fields: map[string]float64{}},
+				{Name: "bar", Time: time.Unix(1, 0), Fields: map[string]float64{"nan": math.NaN(), "value": 2}},
+			},
+			want: "bar value=2 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, string(encodeLineProtocol(tt.points)))
+		})
+	}
+}
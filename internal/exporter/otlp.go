@@ -0,0 +1,103 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+var _ PointWriter = OTLPWriter{}
+
+// OTLPWriter writes points as OTLP metrics to an OpenTelemetry collector, using OTLP/HTTP with protobuf encoding.
+// Each field of a point is sent as a gauge named <point name>_<field name>, with the point's tags as attributes.
+type OTLPWriter struct {
+	// URL of the collector's metrics endpoint, e.g. http://localhost:4318/v1/metrics
+	URL string
+	// Headers are added to each request, e.g. to authenticate with the collector
+	Headers     map[string]string
+	ServiceName string
+	HTTPClient  *http.Client
+}
+
+func (w OTLPWriter) WritePoints(ctx context.Context, points []Point) error {
+	body, err := proto.Marshal(w.request(points))
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("otlp: %w", err)}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+
+	httpClient := w.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("otlp: %w", httpError(resp))
+	}
+	return nil
+}
+
+func (w OTLPWriter) request(points []Point) *collectorpb.ExportMetricsServiceRequest {
+	gauges := make(map[string]*metricspb.Gauge)
+	for _, p := range points {
+		attributes := make([]*commonpb.KeyValue, 0, len(p.Tags))
+		for _, key := range slices.Sorted(maps.Keys(p.Tags)) {
+			attributes = append(attributes, stringAttribute(key, p.Tags[key]))
+		}
+		for field, value := range p.Fields {
+			name := p.Name + "_" + field
+			gauge, ok := gauges[name]
+			if !ok {
+				gauge = &metricspb.Gauge{}
+				gauges[name] = gauge
+			}
+			gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+				Attributes:   attributes,
+				TimeUnixNano: uint64(p.Time.UnixNano()),
+				Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+			})
+		}
+	}
+
+	metrics := make([]*metricspb.Metric, 0, len(gauges))
+	for _, name := range slices.Sorted(maps.Keys(gauges)) {
+		metrics = append(metrics, &metricspb.Metric{Name: name, Data: &metricspb.Metric_Gauge{Gauge: gauges[name]}})
+	}
+
+	serviceName := w.ServiceName
+	if serviceName == "" {
+		serviceName = "solaredge-monitor"
+	}
+	return &collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttribute("service.name", serviceName)}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: "github.com/clambin/solaredge-monitor/internal/exporter"},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
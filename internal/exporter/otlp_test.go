@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPWriter_WritePoints(t *testing.T) {
+	var req collectorpb.ExportMetricsServiceRequest
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := proto.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+
+	timestamp := time.Unix(1700000000, 0)
+	w := OTLPWriter{URL: s.URL + "/v1/metrics", Headers: map[string]string{"X-Api-Key": "secret"}}
+	require.NoError(t, w.WritePoints(t.Context(), []Point{
+		{Name: "solaredge_site", Time: timestamp, Tags: map[string]string{"siteid": "1"}, Fields: map[string]float64{"current_power": 3000, "day_energy": 10}},
+		{Name: "solaredge_site", Time: timestamp, Tags: map[string]string{"siteid": "2"}, Fields: map[string]float64{"current_power": 1000}},
+	}))
+
+	require.Len(t, req.ResourceMetrics, 1)
+	assert.Equal(t, "solaredge-monitor", req.ResourceMetrics[0].Resource.Attributes[0].Value.GetStringValue())
+	require.Len(t, req.ResourceMetrics[0].ScopeMetrics, 1)
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "solaredge_site_current_power", metrics[0].Name)
+	dataPoints := metrics[0].GetGauge().DataPoints
+	require.Len(t, dataPoints, 2)
+	assert.Equal(t, 3000.0, dataPoints[0].GetAsDouble())
+	assert.Equal(t, "siteid", dataPoints[0].Attributes[0].Key)
+	assert.Equal(t, "1", dataPoints[0].Attributes[0].Value.GetStringValue())
+	assert.Equal(t, uint64(timestamp.UnixNano()), dataPoints[0].TimeUnixNano)
+	assert.Equal(t, "solaredge_site_day_energy", metrics[1].Name)
+
+	w.Headers = nil
+	var permanent *PermanentError
+	assert.ErrorAs(t, w.WritePoints(t.Context(), []Point{{Name: "foo"}}), &permanent)
+}
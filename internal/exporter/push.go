@@ -0,0 +1,290 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/tado/v2"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Pusher pushes SolarEdge and Tado updates to a remote metrics backend (e.g. InfluxDB or an OpenTelemetry collector).
+// Updates are converted to Points and written in batches. Failed writes are retried. Batches are written in the
+// background: if the backend can't keep up, the oldest batches are dropped.
+type Pusher struct {
+	SolarEdge Publisher[publisher.SolarEdgeUpdate]
+	Tado      Publisher[*tado.Weather]
+	Writer    PointWriter
+	Logger    *slog.Logger
+	PushConfig
+}
+
+type PushConfig struct {
+	// BatchSize is the number of points that triggers a write. Defaults to 100.
+	BatchSize int
+	// FlushInterval is the maximum time points are buffered before they are written. Defaults to 10 seconds.
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed write is retried before the batch is dropped.
+	MaxRetries int
+	// RetryInterval is the time to wait before the first retry. It doubles with each attempt. Defaults to one second.
+	RetryInterval time.Duration
+}
+
+// A PointWriter writes a batch of points to a metrics backend.
+type PointWriter interface {
+	WritePoints(ctx context.Context, points []Point) error
+}
+
+// A Point is a set of related measurements, taken at the same time, for an entity identified by its Tags.
+type Point struct {
+	Time   time.Time
+	Tags   map[string]string
+	Fields map[string]float64
+	Name   string
+}
+
+func (p Pusher) Run(ctx context.Context) error {
+	p.Logger.Debug("starting pusher")
+	defer p.Logger.Debug("stopped pusher")
+
+	var solarEdgeUpdates <-chan publisher.SolarEdgeUpdate
+	if p.SolarEdge != nil {
+		solarEdgeUpdates = p.SolarEdge.Subscribe()
+		defer p.SolarEdge.Unsubscribe(solarEdgeUpdates)
+	}
+	var tadoUpdates <-chan *tado.Weather
+	if p.Tado != nil {
+		tadoUpdates = p.Tado.Subscribe()
+		defer p.Tado.Unsubscribe(tadoUpdates)
+	}
+
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	flushInterval := p.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = 10 * time.Second
+	}
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	// batches are written by a separate goroutine, so a slow or unavailable backend doesn't block the publishers.
+	// the writes outlive ctx, so the last batches can be written on shutdown.
+	batches := make(chan []Point, maxPendingBatches)
+	writeCtx, cancelWrites := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWrites()
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for batch := range batches {
+			p.flush(writeCtx, batch)
+		}
+	}()
+
+	var batch []Point
+	for {
+		select {
+		case <-ctx.Done():
+			// write what we have before we go, but don't wait for the backend forever.
+			p.queue(batches, batch)
+			close(batches)
+			select {
+			case <-written:
+			case <-time.After(shutdownTimeout):
+				cancelWrites()
+				<-written
+			}
+			return nil
+		case update := <-solarEdgeUpdates:
+			batch = append(batch, solarEdgePoints(update, time.Now())...)
+		case update := <-tadoUpdates:
+			batch = append(batch, weatherPoints(update, time.Now())...)
+		case <-ticker.C:
+			p.queue(batches, batch)
+			batch = nil
+		}
+		if len(batch) >= batchSize {
+			p.queue(batches, batch)
+			batch = nil
+		}
+	}
+}
+
+const (
+	// maxPendingBatches is the number of batches waiting to be written. If more batches are waiting, the oldest one is dropped.
+	maxPendingBatches = 10
+	// shutdownTimeout is the time the Pusher waits for the pending batches to be written when it shuts down.
+	shutdownTimeout = 5 * time.Second
+)
+
+// queue queues a batch to be written. If too many batches are waiting, the oldest batch is dropped.
+func (p Pusher) queue(batches chan []Point, batch []Point) {
+	if len(batch) == 0 {
+		return
+	}
+	for {
+		select {
+		case batches <- batch:
+			return
+		default:
+		}
+		select {
+		case dropped := <-batches:
+			p.Logger.Warn("too many batches waiting to be pushed. dropping oldest batch", "points", len(dropped))
+		default:
+		}
+	}
+}
+
+func (p Pusher) flush(ctx context.Context, batch []Point) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := p.write(ctx, batch); err != nil {
+		p.Logger.Error("failed to push metrics. dropping batch", "points", len(batch), "err", err)
+		return
+	}
+	p.Logger.Debug("metrics pushed", "points", len(batch))
+}
+
+func (p Pusher) write(ctx context.Context, batch []Point) error {
+	retryInterval := p.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = time.Second
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = p.Writer.WritePoints(ctx, batch); err == nil || attempt >= p.MaxRetries {
+			return err
+		}
+		var permanent *PermanentError
+		if errors.As(err, &permanent) {
+			return err
+		}
+		p.Logger.Debug("failed to push metrics. retrying", "attempt", attempt+1, "err", err)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(retryInterval << attempt):
+		}
+	}
+}
+
+// PermanentError indicates that a write failed in a way that retrying won't fix (e.g. the backend rejected the data).
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// solarEdgePoints converts a SolarEdgeUpdate to Points. The field names match the names of the Prometheus metrics.
+func solarEdgePoints(update publisher.SolarEdgeUpdate, timestamp time.Time) []Point {
+	var points []Point
+	for _, siteUpdate := range update {
+		siteTags := map[string]string{"site": siteUpdate.Name, "siteid": strconv.Itoa(siteUpdate.ID)}
+		points = append(points, Point{
+			Name: "solaredge_site",
+			Time: timestamp,
+			Tags: siteTags,
+			Fields: map[string]float64{
+				"current_power":   siteUpdate.PowerOverview.CurrentPower.Power,
+				"day_energy":      siteUpdate.PowerOverview.LastDayData.Energy,
+				"month_energy":    siteUpdate.PowerOverview.LastMonthData.Energy,
+				"year_energy":     siteUpdate.PowerOverview.LastYearData.Energy,
+				"lifetime_energy": siteUpdate.PowerOverview.LifeTimeData.Energy,
+			},
+		})
+		for _, inverterUpdate := range siteUpdate.InverterUpdates {
+			inverterTags := map[string]string{
+				"site":     siteUpdate.Name,
+				"siteid":   strconv.Itoa(siteUpdate.ID),
+				"inverter": inverterUpdate.Name,
+				"serial":   inverterUpdate.SerialNumber,
+			}
+			telemetry := inverterUpdate.Telemetry
+			points = append(points, Point{
+				Name: "solaredge_inverter",
+				Time: timestamp,
+				Tags: inverterTags,
+				Fields: map[string]float64{
+					"temperature":        telemetry.Temperature,
+					"dc_voltage":         telemetry.DcVoltage,
+					"power_limit":        telemetry.PowerLimit,
+					"total_active_power": telemetry.TotalActivePower,
+					"energy_total":       telemetry.TotalEnergy,
+				},
+			})
+			for phase, data := range phases(inverterUpdate) {
+				phaseTags := maps.Clone(inverterTags)
+				phaseTags["phase"] = phase
+				points = append(points, Point{
+					Name: "solaredge_inverter_phase",
+					Time: timestamp,
+					Tags: phaseTags,
+					Fields: map[string]float64{
+						"ac_voltage":     data.AcVoltage,
+						"ac_current":     data.AcCurrent,
+						"ac_frequency":   data.AcFrequency,
+						"active_power":   data.ActivePower,
+						"apparent_power": data.ApparentPower,
+						"reactive_power": data.ReactivePower,
+						"cos_phi":        data.CosPhi,
+					},
+				})
+			}
+		}
+	}
+	return points
+}
+
+// weatherPoints converts a Tado weather update to Points. Measurements that Tado didn't report are left out.
+func weatherPoints(update *tado.Weather, timestamp time.Time) []Point {
+	if update == nil {
+		return nil
+	}
+	point := Point{
+		Name:   "solaredge_weather",
+		Time:   timestamp,
+		Tags:   make(map[string]string),
+		Fields: make(map[string]float64),
+	}
+	if update.SolarIntensity != nil && update.SolarIntensity.Percentage != nil {
+		point.Fields["solar_intensity"] = float64(*update.SolarIntensity.Percentage)
+	}
+	if update.OutsideTemperature != nil && update.OutsideTemperature.Celsius != nil {
+		point.Fields["outside_temperature"] = float64(*update.OutsideTemperature.Celsius)
+	}
+	if update.WeatherState != nil && update.WeatherState.Value != nil {
+		point.Tags["weather"] = string(*update.WeatherState.Value)
+	}
+	if len(point.Fields) == 0 {
+		return nil
+	}
+	return []Point{point}
+}
+
+// httpError converts an unexpected HTTP response into an error.
+func httpError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	statusCode := resp.StatusCode
+	// 4xx errors (other than throttling) won't go away by retrying
+	if statusCode >= 400 && statusCode < 500 && statusCode != 429 {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
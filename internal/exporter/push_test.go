@@ -0,0 +1,202 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/tado/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPusher(t *testing.T) {
+	solarEdge := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+	weather := testutils.FakePublisher[*tado.Weather]{Ch: make(chan *tado.Weather)}
+	var w fakeWriter
+	p := Pusher{
+		SolarEdge:  solarEdge,
+		Tado:       weather,
+		Writer:     &w,
+		Logger:     slog.New(slog.DiscardHandler),
+		PushConfig: PushConfig{BatchSize: 4, FlushInterval: time.Hour},
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errCh := make(chan error)
+	go func() { errCh <- p.Run(ctx) }()
+
+	// one update creates three points: site, inverter & phase. The batch isn't full yet.
+	solarEdge.Ch <- testutils.TestUpdate
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, w.get())
+
+	// the weather point fills the batch
+	weather.Ch <- &tado.Weather{SolarIntensity: &tado.PercentageDataPoint{Percentage: pointer(float32(75))}}
+	require.Eventually(t, func() bool { return len(w.get()) == 1 }, time.Second, 10*time.Millisecond)
+	batch := w.get()[0]
+	require.Len(t, batch, 4)
+	assert.Equal(t, "solaredge_site", batch[0].Name)
+	assert.Equal(t, map[string]string{"site": "foo", "siteid": "1"}, batch[0].Tags)
+	assert.Equal(t, 3000.0, batch[0].Fields["current_power"])
+	assert.Equal(t, "solaredge_inverter", batch[1].Name)
+	assert.Equal(t, 8888.0, batch[1].Fields["energy_total"])
+	assert.Equal(t, "solaredge_inverter_phase", batch[2].Name)
+	assert.Equal(t, "L1", batch[2].Tags["phase"])
+	assert.Equal(t, "solaredge_weather", batch[3].Name)
+	assert.Equal(t, map[string]float64{"solar_intensity": 75}, batch[3].Fields)
+
+	// remaining points are written on shutdown
+	solarEdge.Ch <- testutils.TestUpdate
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.Len(t, w.get(), 2)
+}
+
+func TestPusher_Flush(t *testing.T) {
+	solarEdge := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+	var w fakeWriter
+	p := Pusher{
+		SolarEdge:  solarEdge,
+		Writer:     &w,
+		Logger:     slog.New(slog.DiscardHandler),
+		PushConfig: PushConfig{FlushInterval: 100 * time.Millisecond},
+	}
+	go func() { _ = p.Run(t.Context()) }()
+
+	solarEdge.Ch <- testutils.TestUpdate
+	assert.Eventually(t, func() bool { return len(w.get()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestPusher_SlowWriter(t *testing.T) {
+	solarEdge := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+	w := fakeWriter{errs: []error{errors.New("fail")}}
+	p := Pusher{
+		SolarEdge:  solarEdge,
+		Writer:     &w,
+		Logger:     slog.New(slog.DiscardHandler),
+		PushConfig: PushConfig{BatchSize: 1, FlushInterval: time.Hour, MaxRetries: 1, RetryInterval: time.Hour},
+	}
+	go func() { _ = p.Run(t.Context()) }()
+
+	// the first write waits an hour to be retried: updates are still received
+	for range 2 * maxPendingBatches {
+		select {
+		case solarEdge.Ch <- testutils.TestUpdate:
+		case <-time.After(time.Second):
+			t.Fatal("pusher blocks the publisher")
+		}
+	}
+}
+
+func TestPusher_queue(t *testing.T) {
+	p := Pusher{Logger: slog.New(slog.DiscardHandler)}
+	batches := make(chan []Point, 2)
+
+	p.queue(batches, nil)
+	assert.Empty(t, batches)
+
+	// queueing never blocks: the oldest batch is dropped
+	for _, name := range []string{"a", "b", "c"} {
+		p.queue(batches, []Point{{Name: name}})
+	}
+	require.Len(t, batches, 2)
+	assert.Equal(t, "b", (<-batches)[0].Name)
+	assert.Equal(t, "c", (<-batches)[0].Name)
+}
+
+func TestPusher_write(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   assert.ErrorAssertionFunc
+		wantCalls int
+	}{
+		{
+			name:      "success",
+			wantErr:   assert.NoError,
+			wantCalls: 1,
+		},
+		{
+			name:      "retry",
+			errs:      []error{errors.New("fail"), errors.New("fail")},
+			wantErr:   assert.NoError,
+			wantCalls: 3,
+		},
+		{
+			name:      "too many failures",
+			errs:      []error{errors.New("fail"), errors.New("fail"), errors.New("fail"), errors.New("fail")},
+			wantErr:   assert.Error,
+			wantCalls: 3,
+		},
+		{
+			name:      "permanent error",
+			errs:      []error{&PermanentError{Err: errors.New("fail")}},
+			wantErr:   assert.Error,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			w := fakeWriter{errs: tt.errs}
+			p := Pusher{
+				Writer:     &w,
+				Logger:     slog.New(slog.DiscardHandler),
+				PushConfig: PushConfig{MaxRetries: 2, RetryInterval: time.Millisecond},
+			}
+			tt.wantErr(t, p.write(t.Context(), []Point{{Name: "foo"}}))
+			assert.Equal(t, tt.wantCalls, w.calls)
+		})
+	}
+}
+
+func Test_weatherPoints(t *testing.T) {
+	assert.Empty(t, weatherPoints(nil, time.Time{}))
+	assert.Empty(t, weatherPoints(&tado.Weather{}, time.Time{}))
+
+	points := weatherPoints(&tado.Weather{
+		SolarIntensity:     &tado.PercentageDataPoint{Percentage: pointer(float32(50))},
+		OutsideTemperature: &tado.TemperatureDataPoint{Celsius: pointer(float32(20))},
+		WeatherState:       &tado.WeatherStateDataPoint{Value: pointer(tado.CLOUDY)},
+	}, time.Time{})
+	require.Len(t, points, 1)
+	assert.Equal(t, map[string]string{"weather": "CLOUDY"}, points[0].Tags)
+	assert.Equal(t, map[string]float64{"solar_intensity": 50, "outside_temperature": 20}, points[0].Fields)
+}
+
+var _ PointWriter = &fakeWriter{}
+
+type fakeWriter struct {
+	batches [][]Point
+	errs    []error
+	calls   int
+	lock    sync.Mutex
+}
+
+func (f *fakeWriter) WritePoints(_ context.Context, points []Point) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	f.batches = append(f.batches, points)
+	return nil
+}
+
+func (f *fakeWriter) get() [][]Point {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.batches
+}
+
+func pointer[T any](t T) *T {
+	return &t
+}
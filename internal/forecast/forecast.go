@@ -0,0 +1,252 @@
+// Package forecast forecasts the production of the installation from a weather forecast and the expected-power model.
+package forecast
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Source provides the forecasted solar radiation.
+type Source interface {
+	// Radiation returns the hourly forecasted radiation for the next number of days, starting today.
+	Radiation(ctx context.Context, days int) ([]Radiation, error)
+}
+
+// Radiation is the average solar radiation, in W/m², for the hour starting at Time.
+type Radiation struct {
+	Time      time.Time
+	Radiation float64
+}
+
+// An Hour is the forecasted production for the hour starting at Time.
+type Hour struct {
+	Time time.Time `json:"time"`
+	// Intensity is the expected solar intensity, in %.
+	Intensity float64 `json:"intensity"`
+	// Power is the expected average power, in W.
+	Power float64 `json:"power"`
+}
+
+// PerformanceModel provides the model of the installation's expected output.
+type PerformanceModel interface {
+	Model() *analytics.Model
+}
+
+type Repository interface {
+	Get(from, to time.Time) (repository.Measurements, error)
+	StoreForecastError(repository.ForecastError) error
+}
+
+// fullSunRadiation is the radiation (in W/m²) that corresponds to a solar intensity of 100%.
+const fullSunRadiation = 1000.0
+
+// A Forecaster periodically forecasts the production for the next Horizon.
+//
+// If a Repository is set, the Forecaster also compares each forecasted hour against the measurements for that hour,
+// once it has passed, and records the error.
+type Forecaster struct {
+	Source     Source
+	Model      PerformanceModel
+	Repository Repository
+	Logger     *slog.Logger
+	// pending holds the last forecast made for an hour, before that hour started.
+	pending  map[time.Time]float64
+	errors   prometheus.Histogram
+	forecast []Hour
+	// Interval determines how often the forecast is updated. Defaults to one hour.
+	Interval time.Duration
+	// Horizon is the period covered by the forecast. Defaults to 48 hours.
+	Horizon time.Duration
+	lock    sync.RWMutex
+}
+
+var (
+	forecastPowerMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "forecast", "power_watts"),
+		"Forecasted average power for the hour starting hours_ahead hours from now",
+		[]string{"hours_ahead"},
+		nil,
+	)
+	forecastEnergyMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "forecast", "energy_watthours"),
+		"Forecasted energy for the rest of today and for tomorrow",
+		[]string{"day"},
+		nil,
+	)
+)
+
+func (f *Forecaster) Run(ctx context.Context) error {
+	f.Logger.Debug("starting forecaster")
+	defer f.Logger.Debug("stopped forecaster")
+
+	interval := f.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		f.update(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Forecaster) update(ctx context.Context, now time.Time) {
+	if f.Repository != nil {
+		f.evaluate(now)
+	}
+	if err := f.refresh(ctx, now); err != nil {
+		f.Logger.Error("failed to update forecast", "err", err)
+	}
+}
+
+func (f *Forecaster) refresh(ctx context.Context, now time.Time) error {
+	model := f.Model.Model()
+	if model == nil {
+		f.Logger.Debug("no performance model available yet. skipping forecast")
+		return nil
+	}
+
+	horizon := f.Horizon
+	if horizon <= 0 {
+		horizon = 48 * time.Hour
+	}
+	// radiation is returned per day, starting today
+	days := int(horizon.Hours()/24) + 1
+	radiation, err := f.Source.Radiation(ctx, days)
+	if err != nil {
+		return err
+	}
+
+	start := now.Truncate(time.Hour)
+	forecast := make([]Hour, 0, len(radiation))
+	for _, r := range radiation {
+		if r.Time.Before(start) || !r.Time.Before(start.Add(horizon)) {
+			continue
+		}
+		hour := Hour{Time: r.Time, Intensity: min(100, 100*r.Radiation/fullSunRadiation)}
+		// at night, the model has no data: no production is expected
+		hour.Power, _ = model.Expected(r.Time.Add(30*time.Minute), hour.Intensity)
+		forecast = append(forecast, hour)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.forecast = forecast
+	// pending hours are only evaluated if there's a Repository to get the measurements from
+	if f.Repository != nil {
+		if f.pending == nil {
+			f.pending = make(map[time.Time]float64)
+		}
+		for _, hour := range forecast {
+			if hour.Time.After(now) {
+				f.pending[hour.Time] = hour.Power
+			}
+		}
+	}
+	f.Logger.Debug("forecast updated", "hours", len(forecast))
+	return nil
+}
+
+// evaluate records the forecast error of each pending hour that has passed.
+func (f *Forecaster) evaluate(now time.Time) {
+	// don't hold the lock while querying the database
+	f.lock.Lock()
+	passed := make(map[time.Time]float64)
+	for hour, forecast := range f.pending {
+		if !hour.Add(time.Hour).After(now) {
+			passed[hour] = forecast
+			delete(f.pending, hour)
+		}
+	}
+	errorHistogram := f.errorHistogram()
+	f.lock.Unlock()
+
+	for hour, forecast := range passed {
+		measurements, err := f.Repository.Get(hour, hour.Add(time.Hour-time.Second))
+		if err != nil {
+			f.Logger.Error("failed to get measurements", "err", err)
+			continue
+		}
+		// measurements without power aren't stored: without measurements, we can't tell if there was no power, or no data
+		if len(measurements) == 0 {
+			continue
+		}
+		var actual float64
+		for _, m := range measurements {
+			actual += m.Power
+		}
+		actual /= float64(len(measurements))
+		errorHistogram.Observe(actual - forecast)
+		if err = f.Repository.StoreForecastError(repository.ForecastError{Timestamp: hour, Forecast: forecast, Actual: actual}); err != nil {
+			f.Logger.Error("failed to store forecast error", "err", err)
+		}
+	}
+}
+
+// Forecast returns the current forecast.
+func (f *Forecaster) Forecast() []Hour {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.forecast
+}
+
+func (f *Forecaster) errorHistogram() prometheus.Histogram {
+	if f.errors == nil {
+		f.errors = prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    prometheus.BuildFQName("solaredge", "forecast", "error_watts"),
+			Help:    "Difference between the measured and the forecasted average power of each hour",
+			Buckets: []float64{-2000, -1000, -500, -250, 0, 250, 500, 1000, 2000},
+		})
+	}
+	return f.errors
+}
+
+func (f *Forecaster) Describe(ch chan<- *prometheus.Desc) {
+	ch <- forecastPowerMetric
+	ch <- forecastEnergyMetric
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.errorHistogram().Describe(ch)
+}
+
+func (f *Forecaster) Collect(ch chan<- prometheus.Metric) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.errorHistogram().Collect(ch)
+	if f.forecast == nil {
+		return
+	}
+
+	now := time.Now()
+	start := now.Truncate(time.Hour)
+	today := now.Local()
+	tomorrow := today.AddDate(0, 0, 1)
+	var energyToday, energyTomorrow float64
+	for _, hour := range f.forecast {
+		if hour.Time.Before(start) {
+			continue
+		}
+		hoursAhead := int(hour.Time.Sub(start).Hours())
+		ch <- prometheus.MustNewConstMetric(forecastPowerMetric, prometheus.GaugeValue, hour.Power, strconv.Itoa(hoursAhead))
+		switch t := hour.Time.Local(); {
+		case t.YearDay() == today.YearDay() && t.Year() == today.Year():
+			energyToday += hour.Power
+		case t.YearDay() == tomorrow.YearDay() && t.Year() == tomorrow.Year():
+			energyTomorrow += hour.Power
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(forecastEnergyMetric, prometheus.GaugeValue, energyToday, "today")
+	ch <- prometheus.MustNewConstMetric(forecastEnergyMetric, prometheus.GaugeValue, energyTomorrow, "tomorrow")
+}
@@ -0,0 +1,164 @@
+package forecast
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForecaster(t *testing.T) {
+	now := time.Now().Truncate(time.Hour)
+	source := fakeSource{radiation: []Radiation{
+		{Time: now.Add(-time.Hour), Radiation: 800},
+		{Time: now, Radiation: 500},
+		{Time: now.Add(time.Hour), Radiation: 1200},
+		{Time: now.Add(49 * time.Hour), Radiation: 500},
+	}}
+	repo := fakeRepository{}
+	f := Forecaster{
+		Source:     &source,
+		Model:      fakeModel{model: constantModel()},
+		Repository: &repo,
+		Logger:     slog.New(slog.DiscardHandler),
+	}
+
+	f.update(t.Context(), now.Add(time.Minute))
+	assert.Equal(t, []Hour{
+		{Time: now, Intensity: 50, Power: 2000},
+		{Time: now.Add(time.Hour), Intensity: 100, Power: 4000},
+	}, f.Forecast())
+	assert.Equal(t, 3, source.days)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(&f, "solaredge_forecast_power_watts"))
+	assert.Equal(t, 2, testutil.CollectAndCount(&f, "solaredge_forecast_energy_watthours"))
+	assert.Equal(t, 2000.0+4000.0, energy(t, &f))
+
+	// the next hour has passed: its forecast error is recorded
+	repo.measurements = repository.Measurements{{Power: 3000}, {Power: 3400}}
+	f.update(t.Context(), now.Add(2*time.Hour))
+	require.Len(t, repo.stored, 1)
+	assert.Equal(t, repository.ForecastError{Timestamp: now.Add(time.Hour), Forecast: 4000, Actual: 3200}, repo.stored[0])
+	assert.NotContains(t, f.pending, now.Add(time.Hour))
+	assert.NoError(t, testutil.CollectAndCompare(&f, strings.NewReader(`
+# HELP solaredge_forecast_error_watts Difference between the measured and the forecasted average power of each hour
+# TYPE solaredge_forecast_error_watts histogram
+solaredge_forecast_error_watts_bucket{le="-2000"} 0
+solaredge_forecast_error_watts_bucket{le="-1000"} 0
+solaredge_forecast_error_watts_bucket{le="-500"} 1
+solaredge_forecast_error_watts_bucket{le="-250"} 1
+solaredge_forecast_error_watts_bucket{le="0"} 1
+solaredge_forecast_error_watts_bucket{le="250"} 1
+solaredge_forecast_error_watts_bucket{le="500"} 1
+solaredge_forecast_error_watts_bucket{le="1000"} 1
+solaredge_forecast_error_watts_bucket{le="2000"} 1
+solaredge_forecast_error_watts_bucket{le="+Inf"} 1
+solaredge_forecast_error_watts_sum -800
+solaredge_forecast_error_watts_count 1
+`), "solaredge_forecast_error_watts"))
+
+	// forecast source fails: the previous forecast is kept
+	forecast := f.Forecast()
+	require.Len(t, forecast, 1)
+	source.err = errors.New("failed")
+	f.update(t.Context(), now.Add(3*time.Hour))
+	assert.Equal(t, forecast, f.Forecast())
+}
+
+func TestForecaster_NoRepository(t *testing.T) {
+	now := time.Now().Truncate(time.Hour)
+	f := Forecaster{
+		Source: &fakeSource{radiation: []Radiation{{Time: now.Add(time.Hour), Radiation: 500}}},
+		Model:  fakeModel{model: constantModel()},
+		Logger: slog.New(slog.DiscardHandler),
+	}
+	f.update(t.Context(), now)
+	assert.Len(t, f.Forecast(), 1)
+	// without a Repository, the forecast can't be evaluated: nothing is kept for evaluation
+	assert.Empty(t, f.pending)
+}
+
+func TestForecaster_NoModel(t *testing.T) {
+	f := Forecaster{
+		Source: &fakeSource{radiation: []Radiation{{Time: time.Now(), Radiation: 500}}},
+		Model:  fakeModel{},
+		Logger: slog.New(slog.DiscardHandler),
+	}
+	f.update(t.Context(), time.Now())
+	assert.Nil(t, f.Forecast())
+	assert.Zero(t, testutil.CollectAndCount(&f, "solaredge_forecast_power_watts"))
+}
+
+// energy returns the total forecasted energy for today and tomorrow.
+func energy(t *testing.T, f *Forecaster) float64 {
+	t.Helper()
+	r := prometheus.NewPedanticRegistry()
+	require.NoError(t, r.Register(f))
+	families, err := r.Gather()
+	require.NoError(t, err)
+	var total float64
+	for _, family := range families {
+		if family.GetName() == "solaredge_forecast_energy_watthours" {
+			for _, m := range family.GetMetric() {
+				total += m.GetGauge().GetValue()
+			}
+		}
+	}
+	return total
+}
+
+// constantModel returns a model where power is 40 times the intensity, at any time of day.
+func constantModel() *analytics.Model {
+	var measurements repository.Measurements
+	for hour := range 24 {
+		for intensity := range 20 {
+			measurements = append(measurements, repository.Measurement{
+				Timestamp: time.Date(2024, time.July, 1, hour, 30, 0, 0, time.Local),
+				Power:     40 * float64(intensity),
+				Intensity: float64(intensity),
+			})
+		}
+	}
+	return analytics.NewModel(measurements, 10)
+}
+
+type fakeSource struct {
+	err       error
+	radiation []Radiation
+	days      int
+}
+
+func (f *fakeSource) Radiation(_ context.Context, days int) ([]Radiation, error) {
+	f.days = days
+	return f.radiation, f.err
+}
+
+type fakeModel struct {
+	model *analytics.Model
+}
+
+func (f fakeModel) Model() *analytics.Model {
+	return f.model
+}
+
+type fakeRepository struct {
+	measurements repository.Measurements
+	stored       []repository.ForecastError
+}
+
+func (f *fakeRepository) Get(_, _ time.Time) (repository.Measurements, error) {
+	return f.measurements, nil
+}
+
+func (f *fakeRepository) StoreForecastError(forecastError repository.ForecastError) error {
+	f.stored = append(f.stored, forecastError)
+	return nil
+}
@@ -0,0 +1,85 @@
+package forecast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultOpenMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+var _ Source = OpenMeteo{}
+
+// OpenMeteo gets the forecasted solar radiation from an Open-Meteo compatible forecast API.
+type OpenMeteo struct {
+	HTTPClient *http.Client
+	// URL of the forecast API. Defaults to DefaultOpenMeteoURL.
+	URL       string
+	Latitude  float64
+	Longitude float64
+}
+
+func (o OpenMeteo) Radiation(ctx context.Context, days int) ([]Radiation, error) {
+	target := o.URL
+	if target == "" {
+		target = DefaultOpenMeteoURL
+	}
+	args := url.Values{
+		"latitude":      []string{strconv.FormatFloat(o.Latitude, 'f', -1, 64)},
+		"longitude":     []string{strconv.FormatFloat(o.Longitude, 'f', -1, 64)},
+		"hourly":        []string{"shortwave_radiation"},
+		"forecast_days": []string{strconv.Itoa(days)},
+		"timeformat":    []string{"unixtime"},
+		"timezone":      []string{"UTC"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target+"?"+args.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := o.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var response struct {
+		Hourly struct {
+			Time               []int64    `json:"time"`
+			ShortwaveRadiation []*float64 `json:"shortwave_radiation"`
+		} `json:"hourly"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	if len(response.Hourly.Time) != len(response.Hourly.ShortwaveRadiation) {
+		return nil, errors.New("decode: mismatched hourly data")
+	}
+
+	radiation := make([]Radiation, 0, len(response.Hourly.Time))
+	for i, timestamp := range response.Hourly.Time {
+		if response.Hourly.ShortwaveRadiation[i] == nil {
+			continue
+		}
+		// Open-Meteo reports the average radiation of the preceding hour
+		radiation = append(radiation, Radiation{
+			Time:      time.Unix(timestamp, 0).Add(-time.Hour),
+			Radiation: *response.Hourly.ShortwaveRadiation[i],
+		})
+	}
+	return radiation, nil
+}
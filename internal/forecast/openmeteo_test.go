@@ -0,0 +1,48 @@
+package forecast
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenMeteo_Radiation(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("latitude") != "51.2" || q.Get("longitude") != "4.4" || q.Get("hourly") != "shortwave_radiation" || q.Get("forecast_days") != "3" {
+			http.Error(w, "invalid arguments", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"hourly":{"time":[1719831600,1719835200,1719838800],"shortwave_radiation":[500.0,null,750.5]}}`))
+	}))
+	t.Cleanup(s.Close)
+
+	o := OpenMeteo{URL: s.URL, Latitude: 51.2, Longitude: 4.4}
+	radiation, err := o.Radiation(t.Context(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []Radiation{
+		{Time: time.Unix(1719831600, 0).Add(-time.Hour), Radiation: 500},
+		{Time: time.Unix(1719838800, 0).Add(-time.Hour), Radiation: 750.5},
+	}, radiation)
+
+	o.Latitude = 0
+	_, err = o.Radiation(t.Context(), 3)
+	assert.ErrorContains(t, err, "400 Bad Request: invalid arguments")
+}
+
+func TestOpenMeteo_Radiation_InvalidResponse(t *testing.T) {
+	for _, body := range []string{
+		`{"hourly":`,
+		`{"hourly":{"time":[1719831600],"shortwave_radiation":[]}}`,
+	} {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+		_, err := OpenMeteo{URL: s.URL}.Radiation(t.Context(), 1)
+		assert.ErrorContains(t, err, "decode")
+		s.Close()
+	}
+}
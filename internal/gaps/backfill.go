@@ -0,0 +1,58 @@
+package gaps
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge/v2"
+	"math"
+	"time"
+)
+
+type Client interface {
+	GetPowerMeasurements(ctx context.Context, id int, startTime, endTime time.Time) (solaredge.GetPowerMeasurementsResponse, error)
+}
+
+type Store interface {
+	Store(repository.Measurement) error
+}
+
+// A Backfiller fills gaps with the power that SolarEdge measured during the gap.
+//
+// SolarEdge doesn't know the weather, so the measurements are stored without weather info. If the scraper fills
+// missing intensities, their intensity is interpolated later.
+type Backfiller struct {
+	Client Client
+	Store  Store
+	SiteID int
+	// Location is the site's time zone. SolarEdge reports the power measurements in the site's local time.
+	Location *time.Location
+}
+
+// Backfill stores the power measurements inside the gap and returns the number of stored measurements.
+// Measurements without power are not stored.
+func (b Backfiller) Backfill(ctx context.Context, gap repository.Gap) (int, error) {
+	resp, err := b.Client.GetPowerMeasurements(ctx, b.SiteID, gap.From.In(b.Location), gap.To.In(b.Location))
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, value := range resp.Power.Values {
+		// the timestamp is the site's local time, but parsed as UTC
+		local := time.Time(value.Date)
+		timestamp := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, b.Location)
+		if !timestamp.After(gap.From) || !timestamp.Before(gap.To) || value.Value <= 0 {
+			continue
+		}
+		if err = b.Store.Store(repository.Measurement{
+			Timestamp: timestamp,
+			Power:     value.Value,
+			Intensity: math.NaN(),
+			Weather:   repository.UnknownWeather,
+			NoWeather: true,
+		}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
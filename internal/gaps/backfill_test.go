@@ -0,0 +1,120 @@
+package gaps
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/solaredge/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"testing"
+	"time"
+)
+
+func TestBackfiller_Backfill(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Brussels")
+	require.NoError(t, err)
+	var client fakeClient
+	var store fakeStore
+	b := Backfiller{Client: &client, Store: &store, SiteID: 1, Location: location}
+
+	gap := repository.Gap{
+		From: time.Date(2024, time.July, 1, 10, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, time.July, 1, 11, 0, 0, 0, time.UTC),
+	}
+	count, err := b.Backfill(t.Context(), gap)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// the request uses the site's local time
+	assert.Equal(t, "2024-07-01 12:00:00", client.start.Format(time.DateTime))
+	require.Len(t, store.measurements, 2)
+	assert.Equal(t, gap.From.Add(15*time.Minute), store.measurements[0].Timestamp.UTC())
+	assert.Equal(t, 1000.0, store.measurements[0].Power)
+	assert.False(t, store.measurements[0].HasIntensity())
+	assert.True(t, store.measurements[0].NoWeather)
+	assert.Equal(t, repository.UnknownWeather, store.measurements[0].Weather)
+	assert.Equal(t, gap.From.Add(45*time.Minute), store.measurements[1].Timestamp.UTC())
+}
+
+func TestBackfiller_Backfill_Postgres(t *testing.T) {
+	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(c))
+	})
+
+	db, err := repository.NewPostgresDB(connString)
+	require.NoError(t, err)
+
+	// noon in Brussels, during the summer
+	start := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Store(repository.Measurement{Timestamp: start, Power: 1000, Intensity: 50, Weather: "SUN"}))
+	require.NoError(t, db.Store(repository.Measurement{Timestamp: start.Add(time.Hour), Power: 1000, Intensity: 50, Weather: "SUN"}))
+
+	f := Finder{Repository: db, Daylight: Daylight{Latitude: 51, Longitude: 4}, Interval: 15 * time.Minute}
+	found, err := f.Find(start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	b := Backfiller{Client: quarterHourClient{}, Store: db, SiteID: 1, Location: time.UTC}
+	count, err := b.Backfill(t.Context(), found[0])
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// backfilled measurements are returned, without intensity
+	measurements, err := db.Get(start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, measurements, 5)
+	assert.Equal(t, start.Add(15*time.Minute), measurements[1].Timestamp.UTC())
+	assert.Equal(t, 1500.0, measurements[1].Power)
+	assert.True(t, measurements[1].NoWeather)
+	assert.False(t, measurements[1].HasIntensity())
+
+	// the gap is filled
+	found, err = f.Find(start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+type fakeClient struct {
+	start time.Time
+}
+
+func (f *fakeClient) GetPowerMeasurements(_ context.Context, _ int, startTime, _ time.Time) (solaredge.GetPowerMeasurementsResponse, error) {
+	f.start = startTime
+	var resp solaredge.GetPowerMeasurementsResponse
+	// local time, parsed as UTC
+	date := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	resp.Power.Values = []solaredge.Value{
+		// boundary of the gap: already stored
+		{Date: solaredge.Time(date), Value: 500},
+		{Date: solaredge.Time(date.Add(15 * time.Minute)), Value: 1000},
+		// no power
+		{Date: solaredge.Time(date.Add(30 * time.Minute)), Value: 0},
+		{Date: solaredge.Time(date.Add(45 * time.Minute)), Value: 1500},
+		{Date: solaredge.Time(date.Add(time.Hour)), Value: 2000},
+	}
+	return resp, nil
+}
+
+type fakeStore struct {
+	measurements repository.Measurements
+}
+
+func (f *fakeStore) Store(measurement repository.Measurement) error {
+	f.measurements = append(f.measurements, measurement)
+	return nil
+}
+
+// quarterHourClient reports a power of 1500 W every 15 minutes.
+type quarterHourClient struct{}
+
+func (quarterHourClient) GetPowerMeasurements(_ context.Context, _ int, startTime, endTime time.Time) (solaredge.GetPowerMeasurementsResponse, error) {
+	var resp solaredge.GetPowerMeasurementsResponse
+	for t := startTime; !t.After(endTime); t = t.Add(15 * time.Minute) {
+		resp.Power.Values = append(resp.Power.Values, solaredge.Value{Date: solaredge.Time(t), Value: 1500})
+	}
+	return resp, nil
+}
@@ -0,0 +1,147 @@
+// Package gaps finds the periods during daylight for which no measurements were stored.
+package gaps
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/solar"
+	"math"
+	"time"
+)
+
+// step is the resolution at which gaps are clipped to daylight.
+const step = 5 * time.Minute
+
+// Daylight determines whether the sun is high enough for a site to produce power.
+type Daylight struct {
+	Latitude  float64
+	Longitude float64
+	// MinElevation is the elevation of the sun above the horizon, in degrees, below which the site isn't expected to
+	// produce any power. Measurements without power aren't stored, so a gap at dawn or dusk is normal.
+	MinElevation float64
+}
+
+// IsDaylight returns true if the sun is above MinElevation.
+func (d Daylight) IsDaylight(t time.Time) bool {
+	return solar.Elevation(t, d.Latitude, d.Longitude) > d.MinElevation*math.Pi/180
+}
+
+type Repository interface {
+	GetGaps(from, to time.Time, minGap time.Duration) ([]repository.Gap, error)
+}
+
+// A Finder finds the gaps in the measurements during daylight.
+type Finder struct {
+	Repository Repository
+	Daylight   Daylight
+	// Interval is the expected time between two measurements, i.e. the scraper's interval. Gaps of up to one and a half
+	// Interval are normal. As SolarEdge reports the power every 15 minutes, a Backfiller only closes a gap if Interval
+	// is at least 10 minutes.
+	Interval time.Duration
+	// Location determines the days of the completeness report. If nil, local time is used.
+	Location *time.Location
+}
+
+func (f Finder) minGap() time.Duration {
+	return f.Interval * 3 / 2
+}
+
+// Find returns the daylight part of the gaps between from and to.
+func (f Finder) Find(from, to time.Time) ([]repository.Gap, error) {
+	gaps, err := f.Repository.GetGaps(from, to, f.minGap())
+	if err != nil {
+		return nil, err
+	}
+	daylightGaps := make([]repository.Gap, 0, len(gaps))
+	for _, gap := range gaps {
+		for _, daylightGap := range f.clip(gap) {
+			if daylightGap.Duration() > f.minGap() {
+				daylightGaps = append(daylightGaps, daylightGap)
+			}
+		}
+	}
+	return daylightGaps, nil
+}
+
+// clip returns the parts of the gap during daylight.
+func (f Finder) clip(gap repository.Gap) []repository.Gap {
+	var clipped []repository.Gap
+	var current *repository.Gap
+	for t := gap.From; t.Before(gap.To); t = t.Add(step) {
+		daylight := f.Daylight.IsDaylight(t)
+		switch {
+		case daylight && current == nil:
+			current = &repository.Gap{From: t}
+		case !daylight && current != nil:
+			current.To = t
+			clipped = append(clipped, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		current.To = gap.To
+		clipped = append(clipped, *current)
+	}
+	return clipped
+}
+
+// Day is the completeness of the measurements of one day.
+type Day struct {
+	Date time.Time
+	// Daylight is the time the site is expected to produce power.
+	Daylight time.Duration
+	// Missing is the part of Daylight without measurements.
+	Missing time.Duration
+}
+
+// Completeness returns the fraction of the day's daylight for which measurements were stored.
+// Returns 1 for a day without daylight.
+func (d Day) Completeness() float64 {
+	if d.Daylight <= 0 {
+		return 1
+	}
+	return max(0, 1-float64(d.Missing)/float64(d.Daylight))
+}
+
+// Completeness returns the completeness of each day between from and to.
+func (f Finder) Completeness(from, to time.Time) ([]Day, error) {
+	gaps, err := f.Find(from, to)
+	if err != nil {
+		return nil, err
+	}
+	location := f.Location
+	if location == nil {
+		location = time.Local
+	}
+	from = from.In(location)
+	var days []Day
+	for date := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location); date.Before(to); date = date.AddDate(0, 0, 1) {
+		day := Day{Date: date}
+		dayStart, dayEnd := later(date, from), earlier(date.AddDate(0, 0, 1), to)
+		for t := dayStart; t.Before(dayEnd); t = t.Add(step) {
+			if f.Daylight.IsDaylight(t) {
+				day.Daylight += step
+			}
+		}
+		for _, gap := range gaps {
+			if overlap := earlier(gap.To, dayEnd).Sub(later(gap.From, dayStart)); overlap > 0 {
+				day.Missing += overlap
+			}
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
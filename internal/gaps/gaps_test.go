@@ -0,0 +1,82 @@
+package gaps
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var (
+	testDate     = time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+	testDaylight = Daylight{Latitude: 51, Longitude: 0}
+)
+
+func TestDaylight_IsDaylight(t *testing.T) {
+	assert.True(t, testDaylight.IsDaylight(testDate.Add(12*time.Hour)))
+	assert.False(t, testDaylight.IsDaylight(testDate))
+	// the sun is above the horizon, but not high enough
+	d := Daylight{Latitude: 51, Longitude: 0, MinElevation: 20}
+	assert.True(t, testDaylight.IsDaylight(testDate.Add(5*time.Hour)))
+	assert.False(t, d.IsDaylight(testDate.Add(5*time.Hour)))
+}
+
+func TestFinder_Find(t *testing.T) {
+	f := Finder{
+		Repository: fakeRepository{
+			{From: testDate, To: testDate.Add(2 * time.Hour)},
+			{From: testDate.Add(11 * time.Hour), To: testDate.Add(12 * time.Hour)},
+			{From: testDate.Add(18 * time.Hour), To: testDate.Add(24 * time.Hour)},
+		},
+		Daylight: testDaylight,
+		Interval: 15 * time.Minute,
+	}
+
+	gaps, err := f.Find(testDate, testDate.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, gaps, 2)
+	assert.Equal(t, repository.Gap{From: testDate.Add(11 * time.Hour), To: testDate.Add(12 * time.Hour)}, gaps[0])
+	// clipped at sunset
+	assert.Equal(t, testDate.Add(18*time.Hour), gaps[1].From)
+	assert.True(t, gaps[1].To.After(testDate.Add(20*time.Hour)))
+	assert.True(t, gaps[1].To.Before(testDate.Add(21*time.Hour)))
+}
+
+func TestFinder_Completeness(t *testing.T) {
+	f := Finder{
+		Repository: fakeRepository{
+			{From: testDate.Add(11 * time.Hour), To: testDate.Add(12 * time.Hour)},
+			{From: testDate.Add(35 * time.Hour), To: testDate.Add(36 * time.Hour)},
+		},
+		Daylight: testDaylight,
+		Interval: 15 * time.Minute,
+		Location: time.UTC,
+	}
+
+	days, err := f.Completeness(testDate.Add(6*time.Hour), testDate.Add(48*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+	assert.Equal(t, testDate, days[0].Date)
+	assert.Equal(t, time.Hour, days[0].Missing)
+	// daylight starts at 6:00
+	assert.Less(t, days[0].Daylight, 15*time.Hour)
+	assert.Equal(t, testDate.AddDate(0, 0, 1), days[1].Date)
+	assert.Equal(t, time.Hour, days[1].Missing)
+	assert.Greater(t, days[1].Daylight, 16*time.Hour)
+	assert.InDelta(t, 0.94, days[1].Completeness(), 0.01)
+
+	assert.Equal(t, 1.0, Day{}.Completeness())
+}
+
+type fakeRepository []repository.Gap
+
+func (f fakeRepository) GetGaps(from, to time.Time, _ time.Duration) ([]repository.Gap, error) {
+	var gaps []repository.Gap
+	for _, gap := range f {
+		if !gap.From.Before(from) && !gap.To.After(to) {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps, nil
+}
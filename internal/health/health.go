@@ -2,8 +2,13 @@ package health
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 )
 
 type Component interface {
@@ -16,15 +21,226 @@ func (c IsHealthyFunc) IsHealthy(ctx context.Context) error {
 	return c(ctx)
 }
 
-func Probe(logger *slog.Logger, components ...Component) http.Handler {
+// A Detailer provides details on a component's status. If a Component implements Detailer, the details are added to its report.
+type Detailer interface {
+	HealthDetails() map[string]any
+}
+
+// A Check is a named Component.
+type Check struct {
+	Component
+	Name string
+	// Timeout limits the time the check may take. Defaults to the Health's Timeout.
+	Timeout time.Duration
+	// Live marks the check as part of the liveness probe. All checks are part of the readiness probe.
+	Live bool
+}
+
+// Health evaluates a set of Checks and reports the result over HTTP and as Prometheus metrics.
+//
+// The metrics report the most recent result of each check, so scraping the metrics doesn't run the checks.
+// Run evaluates the checks periodically, to keep the metrics up to date.
+type Health struct {
+	Logger *slog.Logger
+	Checks []Check
+	// Timeout is the default timeout for each check. Defaults to 5 seconds.
+	Timeout time.Duration
+	// Interval determines how often Run evaluates the checks. Defaults to 30 seconds.
+	Interval   time.Duration
+	lastErrors map[string]lastError
+	results    map[string]ComponentReport
+	lock       sync.Mutex
+}
+
+type lastError struct {
+	timestamp time.Time
+	err       string
+}
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+// ErrDegraded marks a component as degraded: it reports an error, but the service is still usable.
+// A check that returns an error wrapping ErrDegraded gets status StatusDegraded and doesn't fail the probe.
+var ErrDegraded = errors.New("degraded")
+
+// Report is the result of evaluating a set of checks.
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentReport `json:"components"`
+}
+
+// ComponentReport is the result of a single check. LastError holds the most recent error of the check, even if it has since recovered.
+type ComponentReport struct {
+	LastErrorTime *time.Time     `json:"last_error_time,omitempty"`
+	Details       map[string]any `json:"details,omitempty"`
+	Status        string         `json:"status"`
+	Latency       string         `json:"latency"`
+	Error         string         `json:"error,omitempty"`
+	LastError     string         `json:"last_error,omitempty"`
+}
+
+// Handler returns an http.Handler that serves the liveness probe on /livez and the readiness probe on /readyz.
+// All other paths serve the readiness probe.
+func (h *Health) Handler() http.Handler {
+	m := http.NewServeMux()
+	ready := h.probe(func(Check) bool { return true })
+	m.Handle("/livez", h.probe(func(c Check) bool { return c.Live }))
+	m.Handle("/readyz", ready)
+	m.Handle("/", ready)
+	return m
+}
+
+func (h *Health) probe(filter func(Check) bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for _, component := range components {
-			if err := component.IsHealthy(r.Context()); err != nil {
-				logger.Warn("health check failed", "err", err)
-				w.WriteHeader(http.StatusServiceUnavailable)
-				return
-			}
+		report := h.Evaluate(r.Context(), filter)
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status == StatusFail {
+			w.WriteHeader(http.StatusServiceUnavailable)
 		}
-		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(report)
 	})
 }
+
+// Run evaluates all checks every Interval, until ctx is done.
+func (h *Health) Run(ctx context.Context) error {
+	interval := h.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.Evaluate(ctx, func(Check) bool { return true })
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate runs all checks for which filter returns true concurrently and returns the result.
+func (h *Health) Evaluate(ctx context.Context, filter func(Check) bool) Report {
+	report := Report{Status: StatusOK, Components: make(map[string]ComponentReport)}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for _, check := range h.Checks {
+		if !filter(check) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := h.evaluate(ctx, check)
+			lock.Lock()
+			defer lock.Unlock()
+			report.Components[check.Name] = result
+			switch {
+			case result.Status == StatusFail:
+				report.Status = StatusFail
+			case result.Status == StatusDegraded && report.Status == StatusOK:
+				report.Status = StatusDegraded
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (h *Health) evaluate(ctx context.Context, check Check) ComponentReport {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = h.Timeout
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runCheck(ctx, check)
+	result := ComponentReport{Status: StatusOK, Latency: time.Since(start).String()}
+	switch {
+	case errors.Is(err, ErrDegraded):
+		result.Status = StatusDegraded
+		result.Error = err.Error()
+	case err != nil:
+		h.Logger.Warn("health check failed", "component", check.Name, "err", err)
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	if detailer, ok := check.Component.(Detailer); ok {
+		result.Details = detailer.HealthDetails()
+	}
+	if last, ok := h.recordError(check.Name, err, start); ok {
+		result.LastError = last.err
+		result.LastErrorTime = &last.timestamp
+	}
+	h.recordResult(check.Name, result)
+	return result
+}
+
+// runCheck runs the check, but returns when the context expires, even if the component doesn't honour it.
+func runCheck(ctx context.Context, check Check) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- check.IsHealthy(ctx) }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return errors.New("timeout")
+	}
+}
+
+func (h *Health) recordError(name string, err error, timestamp time.Time) (lastError, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.lastErrors == nil {
+		h.lastErrors = make(map[string]lastError)
+	}
+	if err != nil {
+		h.lastErrors[name] = lastError{timestamp: timestamp, err: err.Error()}
+	}
+	last, ok := h.lastErrors[name]
+	return last, ok
+}
+
+func (h *Health) recordResult(name string, result ComponentReport) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.results == nil {
+		h.results = make(map[string]ComponentReport)
+	}
+	h.results[name] = result
+}
+
+var _ prometheus.Collector = &Health{}
+
+var componentHealthy = prometheus.NewDesc(
+	prometheus.BuildFQName("solaredge", "component", "healthy"),
+	"Set to 1 if the component is healthy, 0 otherwise",
+	[]string{"component"},
+	nil,
+)
+
+func (h *Health) Describe(ch chan<- *prometheus.Desc) {
+	ch <- componentHealthy
+}
+
+// Collect exports the most recent status of each component. Components that haven't been evaluated yet aren't reported.
+func (h *Health) Collect(ch chan<- prometheus.Metric) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for name, component := range h.results {
+		var value float64
+		if component.Status == StatusOK {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(componentHealthy, prometheus.GaugeValue, value, name)
+	}
+}
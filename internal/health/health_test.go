@@ -2,39 +2,160 @@ package health
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
-func TestHealthProbe(t *testing.T) {
+func TestHealth_Handler(t *testing.T) {
 	up := IsHealthyFunc(func(_ context.Context) error { return nil })
 	down := IsHealthyFunc(func(_ context.Context) error { return errors.New("error") })
+	degraded := IsHealthyFunc(func(_ context.Context) error { return fmt.Errorf("no data: %w", ErrDegraded) })
 
 	tests := []struct {
 		name       string
-		components []Component
+		checks     []Check
+		path       string
 		want       int
+		wantStatus map[string]string
 	}{
-		{"no probes", nil, http.StatusOK},
-		{"up", []Component{up}, http.StatusOK},
-		{"down", []Component{down}, http.StatusServiceUnavailable},
-		{"partial", []Component{up, down, down}, http.StatusServiceUnavailable},
+		{"no probes", nil, "/readyz", http.StatusOK, map[string]string{}},
+		{"up", []Check{{Name: "a", Component: up}}, "/readyz", http.StatusOK, map[string]string{"a": StatusOK}},
+		{"down", []Check{{Name: "a", Component: down}}, "/readyz", http.StatusServiceUnavailable, map[string]string{"a": StatusFail}},
+		{"partial", []Check{{Name: "a", Component: up}, {Name: "b", Component: down}, {Name: "c", Component: down}}, "/readyz", http.StatusServiceUnavailable, map[string]string{"a": StatusOK, "b": StatusFail, "c": StatusFail}},
+		{"degraded", []Check{{Name: "a", Component: up}, {Name: "b", Component: degraded}}, "/readyz", http.StatusOK, map[string]string{"a": StatusOK, "b": StatusDegraded}},
+		{"degraded and down", []Check{{Name: "a", Component: down}, {Name: "b", Component: degraded}}, "/readyz", http.StatusServiceUnavailable, map[string]string{"a": StatusFail, "b": StatusDegraded}},
+		{"default path", []Check{{Name: "a", Component: down}}, "/health", http.StatusServiceUnavailable, map[string]string{"a": StatusFail}},
+		{"liveness", []Check{{Name: "a", Component: up, Live: true}, {Name: "b", Component: down}}, "/livez", http.StatusOK, map[string]string{"a": StatusOK}},
+		{"liveness failed", []Check{{Name: "a", Component: down, Live: true}, {Name: "b", Component: up}}, "/livez", http.StatusServiceUnavailable, map[string]string{"a": StatusFail}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			l := slog.New(slog.DiscardHandler) //slog.New(slog.NewTextHandler(os.Stdout, nil))
-			h := Probe(l, tt.components...)
+			t.Parallel()
+			h := Health{Logger: slog.New(slog.DiscardHandler), Checks: tt.checks}
 
-			r, _ := http.NewRequest("GET", "/health", nil)
+			r, _ := http.NewRequest(http.MethodGet, tt.path, nil)
 			w := httptest.NewRecorder()
-
-			h.ServeHTTP(w, r)
+			h.Handler().ServeHTTP(w, r)
 			assert.Equal(t, tt.want, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var report Report
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+			status := make(map[string]string)
+			for name, component := range report.Components {
+				status[name] = component.Status
+			}
+			assert.Equal(t, tt.wantStatus, status)
 		})
 	}
 }
+
+func TestHealth_Evaluate(t *testing.T) {
+	var fail bool
+	flaky := IsHealthyFunc(func(_ context.Context) error {
+		if fail {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	slow := IsHealthyFunc(func(_ context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	h := Health{
+		Logger: slog.New(slog.DiscardHandler),
+		Checks: []Check{
+			{Name: "flaky", Component: flaky},
+			{Name: "slow", Component: slow, Timeout: 10 * time.Millisecond},
+		},
+	}
+	all := func(Check) bool { return true }
+
+	start := time.Now()
+	report := h.Evaluate(t.Context(), all)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, ComponentReport{Status: StatusOK, Latency: report.Components["flaky"].Latency}, report.Components["flaky"])
+	assert.Equal(t, "timeout", report.Components["slow"].Error)
+
+	fail = true
+	report = h.Evaluate(t.Context(), all)
+	assert.Equal(t, "flaky", report.Components["flaky"].Error)
+
+	// last error is remembered after the component recovers
+	fail = false
+	report = h.Evaluate(t.Context(), all)
+	assert.Equal(t, StatusOK, report.Components["flaky"].Status)
+	assert.Empty(t, report.Components["flaky"].Error)
+	assert.Equal(t, "flaky", report.Components["flaky"].LastError)
+	assert.NotNil(t, report.Components["flaky"].LastErrorTime)
+}
+
+func TestHealth_Evaluate_Details(t *testing.T) {
+	h := Health{
+		Logger: slog.New(slog.DiscardHandler),
+		Checks: []Check{{Name: "detailed", Component: detailedComponent{}}},
+	}
+	report := h.Evaluate(t.Context(), func(Check) bool { return true })
+	assert.Equal(t, map[string]any{"foo": "bar"}, report.Components["detailed"].Details)
+}
+
+var _ Detailer = detailedComponent{}
+
+type detailedComponent struct{}
+
+func (detailedComponent) IsHealthy(_ context.Context) error { return nil }
+
+func (detailedComponent) HealthDetails() map[string]any { return map[string]any{"foo": "bar"} }
+
+func TestHealth_Collect(t *testing.T) {
+	var calls atomic.Int32
+	h := Health{
+		Logger: slog.New(slog.DiscardHandler),
+		Checks: []Check{
+			{Name: "up", Component: IsHealthyFunc(func(_ context.Context) error { calls.Add(1); return nil })},
+			{Name: "down", Component: IsHealthyFunc(func(_ context.Context) error { return errors.New("error") })},
+		},
+	}
+	// nothing evaluated yet
+	assert.Zero(t, testutil.CollectAndCount(&h))
+
+	h.Evaluate(t.Context(), func(Check) bool { return true })
+	assert.NoError(t, testutil.CollectAndCompare(&h, strings.NewReader(`
+# HELP solaredge_component_healthy Set to 1 if the component is healthy, 0 otherwise
+# TYPE solaredge_component_healthy gauge
+solaredge_component_healthy{component="down"} 0
+solaredge_component_healthy{component="up"} 1
+`)))
+	// collecting reports the last result: it doesn't evaluate the checks
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHealth_Run(t *testing.T) {
+	var calls atomic.Int32
+	h := Health{
+		Logger:   slog.New(slog.DiscardHandler),
+		Checks:   []Check{{Name: "up", Component: IsHealthyFunc(func(_ context.Context) error { calls.Add(1); return nil })}},
+		Interval: 10 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	errCh := make(chan error)
+	go func() { errCh <- h.Run(ctx) }()
+
+	assert.Eventually(t, func() bool { return calls.Load() > 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, testutil.CollectAndCount(&h))
+	cancel()
+	assert.NoError(t, <-errCh)
+}
@@ -0,0 +1,111 @@
+// Package inventory keeps track of the equipment installed at each site.
+package inventory
+
+import (
+	"cmp"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge/v2"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Equipment types
+const (
+	Inverter = "inverter"
+	Battery  = "battery"
+	Meter    = "meter"
+	Gateway  = "gateway"
+	Sensor   = "sensor"
+)
+
+// Changes recorded in an EquipmentEvent
+const (
+	Added      = "added"
+	Removed    = "removed"
+	Firmware   = "firmware"
+	Optimizers = "optimizers"
+)
+
+// FromSolarEdge converts a site's inventory, as reported by SolarEdge, to a list of equipment, seen at the specified time.
+//
+// SolarEdge doesn't report individual optimizers, only the number of optimizers connected to each inverter. Meters don't
+// have a serial number: they are identified by their name.
+func FromSolarEdge(siteID int, inventory solaredge.Inventory, now time.Time) []repository.Equipment {
+	equipment := make([]repository.Equipment, 0, len(inventory.Inverters)+len(inventory.Batteries)+len(inventory.Meters)+len(inventory.Gateways)+len(inventory.Sensors))
+	add := func(e repository.Equipment) {
+		e.SiteID = siteID
+		e.FirstSeen = now
+		e.LastSeen = now
+		equipment = append(equipment, e)
+	}
+	for _, inverter := range inventory.Inverters {
+		add(repository.Equipment{Type: Inverter, SerialNumber: inverter.SN, Name: inverter.Name, Manufacturer: inverter.Manufacturer, Model: inverter.Model, Firmware: inverter.CPUVersion, Optimizers: inverter.ConnectedOptimizers})
+	}
+	for _, battery := range inventory.Batteries {
+		add(repository.Equipment{Type: Battery, SerialNumber: battery.SN, Name: battery.Name, Manufacturer: battery.Manufacturer, Model: battery.Model, Firmware: battery.FirmwareVersion})
+	}
+	for _, meter := range inventory.Meters {
+		add(repository.Equipment{Type: Meter, Name: meter.Name, Manufacturer: meter.Manufacturer, Model: meter.Model, Firmware: meter.FirmwareVersion})
+	}
+	for _, gateway := range inventory.Gateways {
+		add(repository.Equipment{Type: Gateway, SerialNumber: gateway.SN, Name: gateway.Name, Firmware: gateway.FirmwareVersion})
+	}
+	for _, sensor := range inventory.Sensors {
+		add(repository.Equipment{Type: Sensor, SerialNumber: sensor.ID, Name: sensor.Type, Model: sensor.Category})
+	}
+	return equipment
+}
+
+// Diff compares the current equipment of a site with the previous one and returns the events that explain the differences.
+// It also carries over the time each device was first seen from the previous inventory.
+func Diff(previous, current []repository.Equipment, now time.Time) []repository.EquipmentEvent {
+	type key struct{ kind, serialNumber, name string }
+	keyOf := func(e repository.Equipment) key { return key{kind: e.Type, serialNumber: e.SerialNumber, name: e.Name} }
+	event := func(e repository.Equipment, change, oldValue, newValue string) repository.EquipmentEvent {
+		return repository.EquipmentEvent{
+			Timestamp:    now,
+			SiteID:       e.SiteID,
+			Type:         e.Type,
+			SerialNumber: e.SerialNumber,
+			Name:         e.Name,
+			Change:       change,
+			Old:          oldValue,
+			New:          newValue,
+		}
+	}
+
+	known := make(map[key]repository.Equipment, len(previous))
+	for _, e := range previous {
+		known[keyOf(e)] = e
+	}
+
+	var events []repository.EquipmentEvent
+	for i, e := range current {
+		old, ok := known[keyOf(e)]
+		if !ok {
+			events = append(events, event(e, Added, "", ""))
+			continue
+		}
+		delete(known, keyOf(e))
+		current[i].FirstSeen = old.FirstSeen
+		if old.Firmware != e.Firmware {
+			events = append(events, event(e, Firmware, old.Firmware, e.Firmware))
+		}
+		if old.Optimizers != e.Optimizers {
+			events = append(events, event(e, Optimizers, strconv.Itoa(old.Optimizers), strconv.Itoa(e.Optimizers)))
+		}
+	}
+	// sort removed equipment to get a deterministic order
+	removed := make([]repository.Equipment, 0, len(known))
+	for _, e := range known {
+		removed = append(removed, e)
+	}
+	slices.SortFunc(removed, func(a, b repository.Equipment) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.Name, b.Name), cmp.Compare(a.SerialNumber, b.SerialNumber))
+	})
+	for _, e := range removed {
+		events = append(events, event(e, Removed, "", ""))
+	}
+	return events
+}
@@ -0,0 +1,69 @@
+package inventory
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testInventory = solaredge.Inventory{
+	Inverters: []solaredge.InverterEquipment{{SN: "INV1", Name: "Inverter 1", Manufacturer: "SolarEdge", Model: "SE5000", CPUVersion: "4.1.1", ConnectedOptimizers: 16}},
+	Batteries: []solaredge.BatteryEquipment{{SN: "BAT1", Name: "Battery 1", Manufacturer: "SolarEdge", Model: "Home Battery", FirmwareVersion: "1.0"}},
+	Meters:    []solaredge.MeterEquipment{{Name: "Production Meter", Manufacturer: "WattNode", Model: "WNC", FirmwareVersion: "2.0"}},
+	Gateways:  []solaredge.GatewayEquipment{{SN: "GW1", Name: "Gateway 1", FirmwareVersion: "3.0"}},
+	Sensors:   []solaredge.SensorEquipment{{ID: "S1", Type: "Irradiance", Category: "IRRADIANCE"}},
+}
+
+func TestFromSolarEdge(t *testing.T) {
+	now := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	equipment := FromSolarEdge(1, testInventory, now)
+	require.Len(t, equipment, 5)
+	assert.Equal(t, repository.Equipment{
+		SiteID:       1,
+		Type:         Inverter,
+		SerialNumber: "INV1",
+		Name:         "Inverter 1",
+		Manufacturer: "SolarEdge",
+		Model:        "SE5000",
+		Firmware:     "4.1.1",
+		Optimizers:   16,
+		FirstSeen:    now,
+		LastSeen:     now,
+	}, equipment[0])
+	for i, kind := range []string{Inverter, Battery, Meter, Gateway, Sensor} {
+		assert.Equal(t, kind, equipment[i].Type)
+	}
+	assert.Equal(t, "S1", equipment[4].SerialNumber)
+}
+
+func TestDiff(t *testing.T) {
+	firstSeen := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	previous := []repository.Equipment{
+		{SiteID: 1, Type: Inverter, SerialNumber: "INV1", Name: "Inverter 1", Firmware: "4.1.0", Optimizers: 15, FirstSeen: firstSeen},
+		{SiteID: 1, Type: Battery, SerialNumber: "BAT0", Name: "Battery 0", FirstSeen: firstSeen},
+		{SiteID: 1, Type: Meter, Name: "Production Meter", Firmware: "2.0", FirstSeen: firstSeen},
+	}
+	current := []repository.Equipment{
+		{SiteID: 1, Type: Inverter, SerialNumber: "INV1", Name: "Inverter 1", Firmware: "4.1.1", Optimizers: 16, FirstSeen: now},
+		{SiteID: 1, Type: Battery, SerialNumber: "BAT1", Name: "Battery 1", FirstSeen: now},
+		{SiteID: 1, Type: Meter, Name: "Production Meter", Firmware: "2.0", FirstSeen: now},
+	}
+
+	events := Diff(previous, current, now)
+	want := []repository.EquipmentEvent{
+		{Timestamp: now, SiteID: 1, Type: Inverter, SerialNumber: "INV1", Name: "Inverter 1", Change: Firmware, Old: "4.1.0", New: "4.1.1"},
+		{Timestamp: now, SiteID: 1, Type: Inverter, SerialNumber: "INV1", Name: "Inverter 1", Change: Optimizers, Old: "15", New: "16"},
+		{Timestamp: now, SiteID: 1, Type: Battery, SerialNumber: "BAT1", Name: "Battery 1", Change: Added},
+		{Timestamp: now, SiteID: 1, Type: Battery, SerialNumber: "BAT0", Name: "Battery 0", Change: Removed},
+	}
+	assert.Equal(t, want, events)
+	assert.Equal(t, firstSeen, current[0].FirstSeen)
+	assert.Equal(t, now, current[1].FirstSeen)
+	assert.Equal(t, firstSeen, current[2].FirstSeen)
+
+	assert.Empty(t, Diff(current, current, now))
+}
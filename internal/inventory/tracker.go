@@ -0,0 +1,105 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge/v2"
+	"log/slog"
+	"time"
+)
+
+type Publisher[T any] interface {
+	Subscribe() <-chan T
+	Unsubscribe(<-chan T)
+}
+
+type Client interface {
+	GetInventory(ctx context.Context, id int) (solaredge.GetInventoryResponse, error)
+}
+
+type Repository interface {
+	GetInventory() ([]repository.Equipment, error)
+	UpdateInventory(siteID int, inventory []repository.Equipment, events []repository.EquipmentEvent) error
+}
+
+var _ Repository = &repository.PostgresDB{}
+
+// A Tracker keeps the inventory of each site in the repository up to date and records any changes as events.
+//
+// The Tracker uses the SolarEdge updates to determine the sites to check, but checks each site at most once per
+// Interval, as the inventory rarely changes. The first inventory of a site is stored without recording any events.
+type Tracker struct {
+	SolarEdge  Publisher[publisher.SolarEdgeUpdate]
+	Client     Client
+	Repository Repository
+	Interval   time.Duration
+	Logger     *slog.Logger
+	lastCheck  map[int]time.Time
+}
+
+func (t *Tracker) Run(ctx context.Context) error {
+	ch := t.SolarEdge.Subscribe()
+	defer t.SolarEdge.Unsubscribe(ch)
+
+	t.Logger.Debug("starting inventory tracker")
+	defer t.Logger.Debug("stopped inventory tracker")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update := <-ch:
+			t.update(ctx, time.Now(), update)
+		}
+	}
+}
+
+func (t *Tracker) update(ctx context.Context, now time.Time, update publisher.SolarEdgeUpdate) {
+	if t.lastCheck == nil {
+		t.lastCheck = make(map[int]time.Time)
+	}
+	for _, site := range update {
+		if last, ok := t.lastCheck[site.ID]; ok && now.Sub(last) < t.Interval {
+			continue
+		}
+		events, err := t.check(ctx, site.ID, now)
+		if err != nil {
+			t.Logger.Warn("failed to update inventory", "site", site.Name, "err", err)
+			continue
+		}
+		t.lastCheck[site.ID] = now
+		for _, event := range events {
+			t.Logger.Info("equipment changed", "site", site.Name, "type", event.Type, "name", event.Name, "serialNumber", event.SerialNumber, "change", event.Change, "old", event.Old, "new", event.New)
+		}
+	}
+}
+
+func (t *Tracker) check(ctx context.Context, siteID int, now time.Time) ([]repository.EquipmentEvent, error) {
+	resp, err := t.Client.GetInventory(ctx, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("solaredge: %w", err)
+	}
+	stored, err := t.Repository.GetInventory()
+	if err != nil {
+		return nil, fmt.Errorf("database: %w", err)
+	}
+	var previous []repository.Equipment
+	for _, equipment := range stored {
+		if equipment.SiteID == siteID {
+			previous = append(previous, equipment)
+		}
+	}
+
+	current := FromSolarEdge(siteID, resp.Inventory, now)
+	events := Diff(previous, current, now)
+	if len(previous) == 0 {
+		// first inventory of the site: nothing changed
+		events = nil
+	}
+	if err = t.Repository.UpdateInventory(siteID, current, events); err != nil {
+		return nil, fmt.Errorf("database: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,117 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/solaredge/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTracker_update(t *testing.T) {
+	client := fakeClient{inventory: testInventory}
+	client.inventory.Inverters = slices.Clone(testInventory.Inverters)
+	repo := fakeRepository{}
+	tracker := Tracker{Client: &client, Repository: &repo, Interval: time.Hour, Logger: slog.New(slog.DiscardHandler)}
+	update := publisher.SolarEdgeUpdate{{ID: 1, Name: "foo"}}
+	now := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+
+	// first inventory: no events
+	tracker.update(t.Context(), now, update)
+	assert.Len(t, repo.inventory[1], 5)
+	assert.Empty(t, repo.events)
+
+	// within the interval: inventory isn't checked
+	client.inventory.Inverters[0].CPUVersion = "4.2.0"
+	tracker.update(t.Context(), now.Add(time.Minute), update)
+	assert.Empty(t, repo.events)
+
+	// after the interval: firmware change is recorded
+	tracker.update(t.Context(), now.Add(time.Hour), update)
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, Firmware, repo.events[0].Change)
+	assert.Equal(t, "4.2.0", repo.events[0].New)
+	assert.Equal(t, now, repo.inventory[1][0].FirstSeen)
+	assert.Equal(t, now.Add(time.Hour), repo.inventory[1][0].LastSeen)
+
+	// failure: site is checked again on the next update
+	client.err = errors.New("fail")
+	tracker.update(t.Context(), now.Add(2*time.Hour), update)
+	client.err = nil
+	client.inventory.Batteries = nil
+	tracker.update(t.Context(), now.Add(2*time.Hour+time.Minute), update)
+	require.Len(t, repo.events, 2)
+	assert.Equal(t, Removed, repo.events[1].Change)
+}
+
+func TestTracker_Run(t *testing.T) {
+	ch := make(chan publisher.SolarEdgeUpdate)
+	repo := fakeRepository{}
+	tracker := Tracker{
+		SolarEdge:  testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: ch},
+		Client:     &fakeClient{inventory: testInventory},
+		Repository: &repo,
+		Interval:   time.Hour,
+		Logger:     slog.New(slog.DiscardHandler),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- tracker.Run(ctx) }()
+	ch <- testutils.TestUpdate
+
+	assert.Eventually(t, func() bool {
+		inventory, _ := repo.GetInventory()
+		return len(inventory) == 5
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+var _ Client = &fakeClient{}
+
+type fakeClient struct {
+	inventory solaredge.Inventory
+	err       error
+}
+
+func (f *fakeClient) GetInventory(_ context.Context, _ int) (solaredge.GetInventoryResponse, error) {
+	return solaredge.GetInventoryResponse{Inventory: f.inventory}, f.err
+}
+
+var _ Repository = &fakeRepository{}
+
+type fakeRepository struct {
+	inventory map[int][]repository.Equipment
+	events    []repository.EquipmentEvent
+	lock      sync.Mutex
+}
+
+func (f *fakeRepository) GetInventory() ([]repository.Equipment, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var inventory []repository.Equipment
+	for _, equipment := range f.inventory {
+		inventory = append(inventory, equipment...)
+	}
+	return inventory, nil
+}
+
+func (f *fakeRepository) UpdateInventory(siteID int, inventory []repository.Equipment, events []repository.EquipmentEvent) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.inventory == nil {
+		f.inventory = make(map[int][]repository.Equipment)
+	}
+	f.inventory[siteID] = inventory
+	f.events = append(f.events, events...)
+	return nil
+}
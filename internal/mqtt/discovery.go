@@ -0,0 +1,140 @@
+package mqtt
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"regexp"
+	"strconv"
+)
+
+// discoveryConfig is the Home Assistant MQTT discovery payload for a sensor.
+// See https://www.home-assistant.io/integrations/sensor.mqtt/
+type discoveryConfig struct {
+	Name              string `json:"name"`
+	UniqueID          string `json:"unique_id"`
+	StateTopic        string `json:"state_topic"`
+	ValueTemplate     string `json:"value_template"`
+	AvailabilityTopic string `json:"availability_topic"`
+	UnitOfMeasurement string `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string `json:"device_class,omitempty"`
+	StateClass        string `json:"state_class,omitempty"`
+	Device            device `json:"device"`
+}
+
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	ViaDevice    string   `json:"via_device,omitempty"`
+}
+
+type sensor struct {
+	key         string
+	name        string
+	unit        string
+	deviceClass string
+	stateClass  string
+}
+
+var (
+	siteSensors = []sensor{
+		{key: "current_power", name: "Power", unit: "W", deviceClass: "power", stateClass: "measurement"},
+		{key: "day_energy", name: "Energy today", unit: "Wh", deviceClass: "energy", stateClass: "total_increasing"},
+		{key: "month_energy", name: "Energy this month", unit: "Wh", deviceClass: "energy", stateClass: "total_increasing"},
+		{key: "year_energy", name: "Energy this year", unit: "Wh", deviceClass: "energy", stateClass: "total_increasing"},
+		{key: "lifetime_energy", name: "Lifetime energy", unit: "Wh", deviceClass: "energy", stateClass: "total_increasing"},
+	}
+	inverterSensors = []sensor{
+		{key: "temperature", name: "Temperature", unit: "°C", deviceClass: "temperature", stateClass: "measurement"},
+		{key: "dc_voltage", name: "DC voltage", unit: "V", deviceClass: "voltage", stateClass: "measurement"},
+		{key: "ac_voltage", name: "AC voltage", unit: "V", deviceClass: "voltage", stateClass: "measurement"},
+		{key: "ac_current", name: "AC current", unit: "A", deviceClass: "current", stateClass: "measurement"},
+		{key: "ac_frequency", name: "AC frequency", unit: "Hz", deviceClass: "frequency", stateClass: "measurement"},
+		{key: "active_power", name: "Active power", unit: "W", deviceClass: "power", stateClass: "measurement"},
+		{key: "apparent_power", name: "Apparent power", unit: "VA", deviceClass: "apparent_power", stateClass: "measurement"},
+		{key: "reactive_power", name: "Reactive power", unit: "var", deviceClass: "reactive_power", stateClass: "measurement"},
+		{key: "cos_phi", name: "Power factor", deviceClass: "power_factor", stateClass: "measurement"},
+		{key: "total_energy", name: "Lifetime energy", unit: "Wh", deviceClass: "energy", stateClass: "total_increasing"},
+		{key: "power_limit", name: "Power limit", stateClass: "measurement"},
+	}
+	weatherSensors = []sensor{
+		{key: "solar_intensity", name: "Solar intensity", unit: "%", stateClass: "measurement"},
+		{key: "outside_temperature", name: "Outside temperature", unit: "°C", deviceClass: "temperature", stateClass: "measurement"},
+		{key: "weather", name: "Weather"},
+	}
+)
+
+func (s *Sink) discoverSite(site publisher.SiteUpdate) {
+	d := device{
+		Identifiers:  []string{siteDeviceID(site.ID)},
+		Name:         site.Name,
+		Manufacturer: "SolarEdge",
+		Model:        "site",
+	}
+	s.discover(siteDeviceID(site.ID), s.siteTopic(site.ID)+"/state", d, siteSensors)
+}
+
+func (s *Sink) discoverInverter(site publisher.SiteUpdate, inverter publisher.InverterUpdate) {
+	id := "solaredge_inverter_" + sanitize(inverter.SerialNumber)
+	d := device{
+		Identifiers:  []string{id},
+		Name:         inverter.Name,
+		Manufacturer: inverter.Manufacturer,
+		Model:        inverter.Model,
+		ViaDevice:    siteDeviceID(site.ID),
+	}
+	s.discover(id, s.inverterTopic(site.ID, inverter.SerialNumber)+"/state", d, inverterSensors)
+}
+
+func (s *Sink) discoverWeather() {
+	const id = "solaredge_weather"
+	d := device{
+		Identifiers:  []string{id},
+		Name:         "Weather",
+		Manufacturer: "Tado",
+	}
+	s.discover(id, s.weatherTopic()+"/state", d, weatherSensors)
+}
+
+// discover publishes the discovery messages for a device's sensors, unless they were already sent since we last connected.
+func (s *Sink) discover(id string, stateTopic string, d device, sensors []sensor) {
+	if s.DiscoveryPrefix == "" {
+		return
+	}
+	s.lock.Lock()
+	if s.discovered == nil {
+		s.discovered = make(map[string]struct{})
+	}
+	_, ok := s.discovered[id]
+	s.discovered[id] = struct{}{}
+	s.lock.Unlock()
+	if ok {
+		return
+	}
+
+	for _, sn := range sensors {
+		objectID := id + "_" + sn.key
+		s.publishJSON(s.DiscoveryPrefix+"/sensor/"+objectID+"/config", discoveryConfig{
+			Name:              sn.name,
+			UniqueID:          objectID,
+			StateTopic:        stateTopic,
+			ValueTemplate:     "{{ value_json." + sn.key + " }}",
+			AvailabilityTopic: s.availabilityTopic(),
+			UnitOfMeasurement: sn.unit,
+			DeviceClass:       sn.deviceClass,
+			StateClass:        sn.stateClass,
+			Device:            d,
+		})
+	}
+}
+
+func siteDeviceID(siteID int) string {
+	return "solaredge_site_" + strconv.Itoa(siteID)
+}
+
+var invalidTopicChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// sanitize makes a string safe to use in a topic & as a Home Assistant object ID.
+func sanitize(s string) string {
+	return invalidTopicChars.ReplaceAllString(s, "_")
+}
@@ -0,0 +1,289 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/tado/v2"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Sink publishes SolarEdge and Tado updates to an MQTT broker. All state is published as retained JSON messages.
+// For each site, inverter and the weather, a Home Assistant discovery message is published, so the entities
+// are created automatically in Home Assistant.
+type Sink struct {
+	SolarEdge Publisher[publisher.SolarEdgeUpdate]
+	Tado      Publisher[*tado.Weather]
+	Logger    *slog.Logger
+	Config
+	client     paho.Client
+	queue      chan message
+	discovered map[string]struct{}
+	lock       sync.Mutex
+}
+
+// A message is queued until the sink sends it to the broker.
+type message struct {
+	topic   string
+	payload any
+}
+
+type Config struct {
+	// Broker is the URL of the MQTT broker, e.g. tcp://localhost:1883
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// TopicPrefix is the root of all state topics
+	TopicPrefix string
+	// DiscoveryPrefix is the root of the Home Assistant discovery topics. Blank disables discovery.
+	DiscoveryPrefix string
+	// ReconnectInterval is the time between two connection attempts
+	ReconnectInterval time.Duration
+}
+
+type Publisher[T any] interface {
+	Subscribe() <-chan T
+	Unsubscribe(<-chan T)
+}
+
+const (
+	online         = "online"
+	offline        = "offline"
+	qos            = 1
+	publishTimeout = 5 * time.Second
+	// queueSize is the number of messages waiting to be sent. If the queue is full, the oldest message is dropped.
+	queueSize = 100
+)
+
+func (s *Sink) Run(ctx context.Context) error {
+	s.Logger.Debug("starting mqtt sink", "broker", s.Broker)
+	defer s.Logger.Debug("stopped mqtt sink")
+
+	// messages are sent by a separate goroutine, so a slow or unavailable broker doesn't block the publishers
+	s.queue = make(chan message, queueSize)
+	senderCtx, cancelSender := context.WithCancel(ctx)
+	senderDone := make(chan struct{})
+	go func() {
+		defer close(senderDone)
+		s.send(senderCtx)
+	}()
+
+	s.client = paho.NewClient(s.clientOptions())
+	// with ConnectRetry set, Connect keeps trying in the background. Messages published in the meantime are queued.
+	s.client.Connect()
+	defer s.client.Disconnect(uint(publishTimeout.Milliseconds()))
+
+	var solarEdgeUpdates <-chan publisher.SolarEdgeUpdate
+	if s.SolarEdge != nil {
+		solarEdgeUpdates = s.SolarEdge.Subscribe()
+		defer s.SolarEdge.Unsubscribe(solarEdgeUpdates)
+	}
+	var tadoUpdates <-chan *tado.Weather
+	if s.Tado != nil {
+		tadoUpdates = s.Tado.Subscribe()
+		defer s.Tado.Unsubscribe(tadoUpdates)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			cancelSender()
+			<-senderDone
+			// the broker only publishes the will on an unexpected disconnect, so we mark ourselves offline here.
+			if s.client.IsConnectionOpen() {
+				s.sendMessage(message{topic: s.availabilityTopic(), payload: offline})
+			}
+			return nil
+		case update := <-solarEdgeUpdates:
+			s.processSolarEdgeUpdate(update)
+		case update := <-tadoUpdates:
+			s.processTadoUpdate(update)
+		}
+	}
+}
+
+func (s *Sink) clientOptions() *paho.ClientOptions {
+	reconnectInterval := s.ReconnectInterval
+	if reconnectInterval <= 0 {
+		reconnectInterval = 30 * time.Second
+	}
+	return paho.NewClientOptions().
+		AddBroker(s.Broker).
+		SetClientID(s.ClientID).
+		SetUsername(s.Username).
+		SetPassword(s.Password).
+		SetWill(s.availabilityTopic(), offline, qos, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(reconnectInterval).
+		SetMaxReconnectInterval(reconnectInterval).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			s.Logger.Warn("lost connection to mqtt broker", "err", err)
+		})
+}
+
+func (s *Sink) onConnect(_ paho.Client) {
+	s.Logger.Info("connected to mqtt broker", "broker", s.Broker)
+	// the broker may have lost its retained messages: resend the discovery messages with the next update.
+	s.lock.Lock()
+	s.discovered = make(map[string]struct{})
+	s.lock.Unlock()
+	s.publish(s.availabilityTopic(), online)
+}
+
+func (s *Sink) availabilityTopic() string {
+	return s.TopicPrefix + "/status"
+}
+
+func (s *Sink) siteTopic(siteID int) string {
+	return s.TopicPrefix + "/site/" + strconv.Itoa(siteID)
+}
+
+func (s *Sink) inverterTopic(siteID int, serialNr string) string {
+	return s.siteTopic(siteID) + "/inverter/" + sanitize(serialNr)
+}
+
+func (s *Sink) weatherTopic() string {
+	return s.TopicPrefix + "/weather"
+}
+
+type siteState struct {
+	LastUpdate     time.Time `json:"last_update"`
+	CurrentPower   float64   `json:"current_power"`
+	DayEnergy      float64   `json:"day_energy"`
+	MonthEnergy    float64   `json:"month_energy"`
+	YearEnergy     float64   `json:"year_energy"`
+	LifetimeEnergy float64   `json:"lifetime_energy"`
+}
+
+type inverterState struct {
+	Temperature      float64 `json:"temperature"`
+	DCVoltage        float64 `json:"dc_voltage"`
+	ACVoltage        float64 `json:"ac_voltage"`
+	ACCurrent        float64 `json:"ac_current"`
+	ACFrequency      float64 `json:"ac_frequency"`
+	ActivePower      float64 `json:"active_power"`
+	ApparentPower    float64 `json:"apparent_power"`
+	ReactivePower    float64 `json:"reactive_power"`
+	CosPhi           float64 `json:"cos_phi"`
+	TotalActivePower float64 `json:"total_active_power"`
+	TotalEnergy      float64 `json:"total_energy"`
+	PowerLimit       float64 `json:"power_limit"`
+}
+
+type weatherState struct {
+	SolarIntensity     *float32 `json:"solar_intensity,omitempty"`
+	OutsideTemperature *float32 `json:"outside_temperature,omitempty"`
+	Weather            *string  `json:"weather,omitempty"`
+}
+
+func (s *Sink) processSolarEdgeUpdate(update publisher.SolarEdgeUpdate) {
+	for _, site := range update {
+		s.discoverSite(site)
+		s.publishJSON(s.siteTopic(site.ID)+"/state", siteState{
+			LastUpdate:     time.Time(site.PowerOverview.LastUpdateTime),
+			CurrentPower:   site.PowerOverview.CurrentPower.Power,
+			DayEnergy:      site.PowerOverview.LastDayData.Energy,
+			MonthEnergy:    site.PowerOverview.LastMonthData.Energy,
+			YearEnergy:     site.PowerOverview.LastYearData.Energy,
+			LifetimeEnergy: site.PowerOverview.LifeTimeData.Energy,
+		})
+		for _, inverter := range site.InverterUpdates {
+			s.discoverInverter(site, inverter)
+			s.publishJSON(s.inverterTopic(site.ID, inverter.SerialNumber)+"/state", inverterState{
+				Temperature:      inverter.Telemetry.Temperature,
+				DCVoltage:        inverter.Telemetry.DcVoltage,
+				ACVoltage:        inverter.Telemetry.L1Data.AcVoltage,
+				ACCurrent:        inverter.Telemetry.L1Data.AcCurrent,
+				ACFrequency:      inverter.Telemetry.L1Data.AcFrequency,
+				ActivePower:      inverter.Telemetry.L1Data.ActivePower,
+				ApparentPower:    inverter.Telemetry.L1Data.ApparentPower,
+				ReactivePower:    inverter.Telemetry.L1Data.ReactivePower,
+				CosPhi:           inverter.Telemetry.L1Data.CosPhi,
+				TotalActivePower: inverter.Telemetry.TotalActivePower,
+				TotalEnergy:      inverter.Telemetry.TotalEnergy,
+				PowerLimit:       inverter.Telemetry.PowerLimit,
+			})
+		}
+	}
+}
+
+func (s *Sink) processTadoUpdate(update *tado.Weather) {
+	if update == nil {
+		return
+	}
+	var state weatherState
+	if update.SolarIntensity != nil {
+		state.SolarIntensity = update.SolarIntensity.Percentage
+	}
+	if update.OutsideTemperature != nil {
+		state.OutsideTemperature = update.OutsideTemperature.Celsius
+	}
+	if update.WeatherState != nil && update.WeatherState.Value != nil {
+		weather := string(*update.WeatherState.Value)
+		state.Weather = &weather
+	}
+	s.discoverWeather()
+	s.publishJSON(s.weatherTopic()+"/state", state)
+}
+
+func (s *Sink) publishJSON(topic string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.Logger.Error("failed to encode mqtt message", "topic", topic, "err", err)
+		return
+	}
+	s.publish(topic, body)
+}
+
+// publish queues a message to be sent to the broker. If the queue is full, the oldest message is dropped.
+func (s *Sink) publish(topic string, payload any) {
+	msg := message{topic: topic, payload: payload}
+	for {
+		select {
+		case s.queue <- msg:
+			return
+		default:
+		}
+		select {
+		case dropped := <-s.queue:
+			s.Logger.Warn("mqtt queue full. dropping oldest message", "topic", dropped.topic)
+		default:
+		}
+	}
+}
+
+// send sends the queued messages to the broker until ctx is done.
+func (s *Sink) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-s.queue:
+			s.sendMessage(msg)
+		}
+	}
+}
+
+func (s *Sink) sendMessage(msg message) {
+	token := s.client.Publish(msg.topic, qos, true, msg.payload)
+	if !s.client.IsConnectionOpen() {
+		// not connected: message is queued until we reconnect
+		s.Logger.Debug("mqtt broker not connected. message queued", "topic", msg.topic)
+		return
+	}
+	if !token.WaitTimeout(publishTimeout) {
+		s.Logger.Warn("timeout publishing mqtt message", "topic", msg.topic)
+		return
+	}
+	if err := token.Error(); err != nil {
+		s.Logger.Warn("failed to publish mqtt message", "topic", msg.topic, "err", fmt.Errorf("mqtt: %w", err))
+	}
+}
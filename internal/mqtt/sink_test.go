@@ -0,0 +1,168 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/tado/v2"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSink(t *testing.T) {
+	broker, addr, _ := startBroker(t, "127.0.0.1:0")
+	messages := subscribe(t, broker)
+
+	solarEdge := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+	tadoUpdates := testutils.FakePublisher[*tado.Weather]{Ch: make(chan *tado.Weather)}
+	s := Sink{
+		SolarEdge: solarEdge,
+		Tado:      tadoUpdates,
+		Logger:    slog.New(slog.DiscardHandler),
+		Config: Config{
+			Broker:            "tcp://" + addr,
+			ClientID:          "test",
+			TopicPrefix:       "solaredge",
+			DiscoveryPrefix:   "homeassistant",
+			ReconnectInterval: 100 * time.Millisecond,
+		},
+	}
+
+	errCh := make(chan error)
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() { errCh <- s.Run(ctx) }()
+
+	assert.Eventually(t, func() bool { return messages.get("solaredge/status") == online }, 5*time.Second, 10*time.Millisecond)
+
+	solarEdge.Ch <- testutils.TestUpdate
+	tadoUpdates.Ch <- &tado.Weather{
+		SolarIntensity: &tado.PercentageDataPoint{Percentage: varP(float32(75))},
+		WeatherState:   &tado.WeatherStateDataPoint{Value: varP(tado.SUN)},
+	}
+
+	assert.Eventually(t, func() bool { return messages.get("solaredge/weather/state") != "" }, 5*time.Second, 10*time.Millisecond)
+
+	var site siteState
+	require.NoError(t, json.Unmarshal([]byte(messages.get("solaredge/site/1/state")), &site))
+	assert.Equal(t, 3000.0, site.CurrentPower)
+	assert.Equal(t, 10.0, site.DayEnergy)
+
+	var inverter inverterState
+	require.NoError(t, json.Unmarshal([]byte(messages.get("solaredge/site/1/inverter/1234/state")), &inverter))
+	assert.Equal(t, 40.0, inverter.Temperature)
+	assert.Equal(t, 8888.0, inverter.TotalEnergy)
+
+	assert.JSONEq(t, `{"solar_intensity":75,"weather":"SUN"}`, messages.get("solaredge/weather/state"))
+
+	var config discoveryConfig
+	require.NoError(t, json.Unmarshal([]byte(messages.get("homeassistant/sensor/solaredge_site_1_current_power/config")), &config))
+	assert.Equal(t, discoveryConfig{
+		Name:              "Power",
+		UniqueID:          "solaredge_site_1_current_power",
+		StateTopic:        "solaredge/site/1/state",
+		ValueTemplate:     "{{ value_json.current_power }}",
+		AvailabilityTopic: "solaredge/status",
+		UnitOfMeasurement: "W",
+		DeviceClass:       "power",
+		StateClass:        "measurement",
+		Device:            device{Identifiers: []string{"solaredge_site_1"}, Name: "foo", Manufacturer: "SolarEdge", Model: "site"},
+	}, config)
+	require.NoError(t, json.Unmarshal([]byte(messages.get("homeassistant/sensor/solaredge_inverter_1234_temperature/config")), &config))
+	assert.Equal(t, "solaredge_site_1", config.Device.ViaDevice)
+	assert.Equal(t, "SE4000H", config.Device.Model)
+	assert.NotEmpty(t, messages.get("homeassistant/sensor/solaredge_weather_solar_intensity/config"))
+
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, offline, messages.get("solaredge/status"))
+}
+
+func TestSink_Reconnect(t *testing.T) {
+	broker, addr, stop := startBroker(t, "127.0.0.1:0")
+	messages := subscribe(t, broker)
+
+	solarEdge := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+	s := Sink{
+		SolarEdge: solarEdge,
+		Logger:    slog.New(slog.DiscardHandler),
+		Config: Config{
+			Broker:            "tcp://" + addr,
+			ClientID:          "test",
+			TopicPrefix:       "solaredge",
+			DiscoveryPrefix:   "homeassistant",
+			ReconnectInterval: 100 * time.Millisecond,
+		},
+	}
+	go func() { _ = s.Run(t.Context()) }()
+	assert.Eventually(t, func() bool { return messages.get("solaredge/status") == online }, 5*time.Second, 10*time.Millisecond)
+
+	// restart the broker. it loses all retained messages.
+	stop()
+	broker, _, _ = startBroker(t, addr)
+	messages = subscribe(t, broker)
+
+	// sink reconnects, marks itself available & resends the discovery messages
+	assert.Eventually(t, func() bool { return messages.get("solaredge/status") == online }, 5*time.Second, 10*time.Millisecond)
+	solarEdge.Ch <- testutils.TestUpdate
+	assert.Eventually(t, func() bool {
+		return messages.get("homeassistant/sensor/solaredge_site_1_current_power/config") != ""
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSink_publish(t *testing.T) {
+	s := Sink{Logger: slog.New(slog.DiscardHandler), queue: make(chan message, 2)}
+
+	// publishing never blocks: the oldest message is dropped
+	for _, topic := range []string{"a", "b", "c"} {
+		s.publish(topic, "payload")
+	}
+	require.Len(t, s.queue, 2)
+	assert.Equal(t, "b", (<-s.queue).topic)
+	assert.Equal(t, "c", (<-s.queue).topic)
+}
+
+func startBroker(t *testing.T, addr string) (*mochi.Server, string, func()) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{InlineClient: true, Logger: slog.New(slog.DiscardHandler)})
+	require.NoError(t, server.AddHook(new(auth.AllowHook), nil))
+	l := listeners.NewTCP(listeners.Config{ID: "test", Address: addr})
+	require.NoError(t, server.AddListener(l))
+	require.NoError(t, server.Serve())
+	// closing a mochi server twice panics
+	stop := sync.OnceFunc(func() { _ = server.Close() })
+	t.Cleanup(stop)
+	return server, l.Address(), stop
+}
+
+type receivedMessages struct {
+	messages map[string]string
+	lock     sync.Mutex
+}
+
+func (r *receivedMessages) get(topic string) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.messages[topic]
+}
+
+func subscribe(t *testing.T, broker *mochi.Server) *receivedMessages {
+	t.Helper()
+	r := receivedMessages{messages: make(map[string]string)}
+	require.NoError(t, broker.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.messages[pk.TopicName] = string(pk.Payload)
+	}))
+	return &r
+}
+
+func varP[T any](t T) *T { return &t }
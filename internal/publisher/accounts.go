@@ -0,0 +1,56 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// SolarEdgeAccounts combines the sites of multiple SolarEdge accounts in a single SolarEdgeUpdate.
+//
+// If some accounts fail, GetUpdate returns the sites of the other accounts, with a PartialUpdateError. Err reports
+// the outcome of each account's last update, so a failing account can be flagged without failing the others.
+type SolarEdgeAccounts struct {
+	Accounts []Updater[SolarEdgeUpdate]
+	errs     []error
+	lock     sync.RWMutex
+}
+
+func (a *SolarEdgeAccounts) GetUpdate(ctx context.Context) (SolarEdgeUpdate, error) {
+	var update SolarEdgeUpdate
+	errs := make([]error, len(a.Accounts))
+	var failed int
+	for i, account := range a.Accounts {
+		accountUpdate, err := account.GetUpdate(ctx)
+		if err != nil {
+			errs[i] = fmt.Errorf("account %d: %w", i+1, err)
+			failed++
+			continue
+		}
+		update = append(update, accountUpdate...)
+	}
+	a.lock.Lock()
+	a.errs = errs
+	a.lock.Unlock()
+
+	switch failed {
+	case 0:
+		return update, nil
+	case len(a.Accounts):
+		return nil, errors.Join(errs...)
+	default:
+		return update, &PartialUpdateError{Err: errors.Join(errs...)}
+	}
+}
+
+// Err returns the error of the last update of the i-th account. Returns nil if the update succeeded, or no update has
+// been attempted yet.
+func (a *SolarEdgeAccounts) Err(i int) error {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if i >= len(a.errs) {
+		return nil
+	}
+	return a.errs[i]
+}
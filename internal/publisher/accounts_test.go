@@ -0,0 +1,46 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSolarEdgeAccounts_GetUpdate(t *testing.T) {
+	accounts := SolarEdgeAccounts{Accounts: []Updater[SolarEdgeUpdate]{
+		SolarEdgeUpdater{SolarEdgeClient: fakeSolarEdgeClient{}, Sites: map[string]string{"1": "home"}},
+		SolarEdgeUpdater{SolarEdgeClient: fakeMultiSiteClient{}, Sites: map[string]string{"other home": "parents"}},
+	}}
+	update, err := accounts.GetUpdate(t.Context())
+	require.NoError(t, err)
+	require.Len(t, update, 2)
+	assert.Equal(t, "home", update[0].Name)
+	assert.Equal(t, "parents", update[1].Name)
+	assert.Equal(t, 2, update[1].ID)
+
+	// a failing account doesn't fail the other accounts
+	accounts.Accounts = append(accounts.Accounts, failingUpdater{})
+	update, err = accounts.GetUpdate(t.Context())
+	var partial *PartialUpdateError
+	require.ErrorAs(t, err, &partial)
+	assert.EqualError(t, err, "partial update: account 3: failed")
+	assert.Len(t, update, 2)
+	assert.NoError(t, accounts.Err(0))
+	assert.NoError(t, accounts.Err(1))
+	assert.EqualError(t, accounts.Err(2), "account 3: failed")
+
+	// all accounts fail
+	accounts.Accounts = []Updater[SolarEdgeUpdate]{failingUpdater{}, failingUpdater{}}
+	update, err = accounts.GetUpdate(t.Context())
+	assert.EqualError(t, err, "account 1: failed\naccount 2: failed")
+	assert.NotErrorAs(t, err, &partial)
+	assert.Empty(t, update)
+}
+
+type failingUpdater struct{}
+
+func (failingUpdater) GetUpdate(_ context.Context) (SolarEdgeUpdate, error) {
+	return nil, errors.New("failed")
+}
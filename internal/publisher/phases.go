@@ -0,0 +1,107 @@
+package publisher
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A PhasesClient gets the technical data of an inverter, including the data of each of its phases.
+//
+// The SolarEdge client only decodes phase L1 of an inverter's technical data. If the SolarEdgeUpdater's SolarEdgeClient
+// also implements PhasesClient, the updater uses it instead of GetInverterTechnicalData, so the updates also contain
+// phases L2 and L3 of three-phase inverters.
+type PhasesClient interface {
+	GetInverterPhases(ctx context.Context, id int, serialNr string, startTime, endTime time.Time) ([]InverterTelemetry, error)
+}
+
+// InverterTelemetry is the technical data of an inverter, with the data of each of its phases.
+type InverterTelemetry struct {
+	solaredge.InverterTelemetry
+	// Phases holds the data of each phase reported by the inverter, keyed by phase (L1, L2, L3).
+	Phases map[string]solaredge.InverterTelemetryL1Data
+}
+
+// phaseNames are the phases of an inverter, in the order they are reported.
+var phaseNames = []string{"L1", "L2", "L3"}
+
+var _ PhasesClient = PhasesSolarEdgeClient{}
+
+// PhasesSolarEdgeClient is a SolarEdge client that also implements PhasesClient.
+type PhasesSolarEdgeClient struct {
+	*solaredge.Client
+	// URL is the URL of the SolarEdge API. If blank, the public SolarEdge API is used.
+	URL string
+}
+
+const solarEdgeURL = "https://monitoringapi.solaredge.com"
+
+// GetInverterPhases returns the technical data of an inverter for a given timeframe.
+func (c PhasesSolarEdgeClient) GetInverterPhases(ctx context.Context, id int, serialNr string, startTime, endTime time.Time) ([]InverterTelemetry, error) {
+	args := url.Values{
+		"api_key":   []string{c.SiteKey},
+		"version":   []string{"1.0.0"},
+		"startTime": []string{startTime.Format(time.DateTime)},
+		"endTime":   []string{endTime.Format(time.DateTime)},
+	}
+	target := cmp.Or(c.URL, solarEdgeURL) + "/equipment/" + strconv.Itoa(id) + "/" + url.PathEscape(serialNr) + "/data?" + args.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := cmp.Or(c.HTTPClient, http.DefaultClient).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("solaredge: %s", resp.Status)
+	}
+	var response struct {
+		Data struct {
+			Telemetries []json.RawMessage `json:"telemetries"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	telemetries := make([]InverterTelemetry, len(response.Data.Telemetries))
+	for i, raw := range response.Data.Telemetries {
+		if telemetries[i], err = decodeInverterTelemetry(raw); err != nil {
+			return nil, err
+		}
+	}
+	return telemetries, nil
+}
+
+// decodeInverterTelemetry decodes an inverter's telemetry and the data of each phase present in the telemetry.
+func decodeInverterTelemetry(raw json.RawMessage) (InverterTelemetry, error) {
+	var telemetry InverterTelemetry
+	if err := json.Unmarshal(raw, &telemetry.InverterTelemetry); err != nil {
+		return InverterTelemetry{}, err
+	}
+	var phases map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &phases); err != nil {
+		return InverterTelemetry{}, err
+	}
+	telemetry.Phases = make(map[string]solaredge.InverterTelemetryL1Data)
+	for _, phase := range phaseNames {
+		data, ok := phases[phase+"Data"]
+		if !ok {
+			continue
+		}
+		var phaseData solaredge.InverterTelemetryL1Data
+		if err := json.Unmarshal(data, &phaseData); err != nil {
+			return InverterTelemetry{}, fmt.Errorf("%s: %w", phase, err)
+		}
+		telemetry.Phases[phase] = phaseData
+	}
+	return telemetry, nil
+}
@@ -0,0 +1,67 @@
+package publisher
+
+import (
+	"context"
+	"github.com/clambin/solaredge/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPhasesSolarEdgeClient_GetInverterPhases(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/equipment/1/1234/data" || r.URL.Query().Get("api_key") != "key" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"count":1,"telemetries":[{
+"date":"2024-07-01 12:00:00","totalActivePower":3000,"dcVoltage":750,
+"L1Data":{"acCurrent":4.3,"acVoltage":230,"activePower":1000},
+"L2Data":{"acCurrent":4.4,"acVoltage":231,"activePower":1010},
+"L3Data":{"acCurrent":4.2,"acVoltage":229,"activePower":990}
+}]}}`))
+	}))
+	t.Cleanup(s.Close)
+
+	c := PhasesSolarEdgeClient{Client: &solaredge.Client{SiteKey: "key"}, URL: s.URL}
+	telemetry, err := c.GetInverterPhases(context.Background(), 1, "1234", time.Now().Add(-10*time.Minute), time.Now())
+	require.NoError(t, err)
+	require.Len(t, telemetry, 1)
+	assert.Equal(t, 3000.0, telemetry[0].TotalActivePower)
+	assert.Equal(t, 230.0, telemetry[0].L1Data.AcVoltage)
+	assert.Equal(t, map[string]solaredge.InverterTelemetryL1Data{
+		"L1": {AcCurrent: 4.3, AcVoltage: 230, ActivePower: 1000},
+		"L2": {AcCurrent: 4.4, AcVoltage: 231, ActivePower: 1010},
+		"L3": {AcCurrent: 4.2, AcVoltage: 229, ActivePower: 990},
+	}, telemetry[0].Phases)
+
+	_, err = c.GetInverterPhases(context.Background(), 1, "5678", time.Now().Add(-10*time.Minute), time.Now())
+	assert.Error(t, err)
+}
+
+func TestSolarEdgeUpdater_GetUpdate_Phases(t *testing.T) {
+	u := SolarEdgeUpdater{SolarEdgeClient: fakePhasesClient{}}
+
+	update, err := u.GetUpdate(context.Background())
+	require.NoError(t, err)
+	require.Len(t, update, 1)
+	require.Len(t, update[0].InverterUpdates, 1)
+	assert.Equal(t, 750.0, update[0].InverterUpdates[0].Telemetry.DcVoltage)
+	assert.Len(t, update[0].InverterUpdates[0].Phases, 3)
+}
+
+var _ PhasesClient = fakePhasesClient{}
+
+type fakePhasesClient struct {
+	fakeSolarEdgeClient
+}
+
+func (f fakePhasesClient) GetInverterPhases(_ context.Context, _ int, _ string, _, _ time.Time) ([]InverterTelemetry, error) {
+	return []InverterTelemetry{{
+		InverterTelemetry: solaredge.InverterTelemetry{DcVoltage: 750},
+		Phases:            map[string]solaredge.InverterTelemetryL1Data{"L1": {AcVoltage: 230}, "L2": {AcVoltage: 231}, "L3": {AcVoltage: 229}},
+	}}, nil
+}
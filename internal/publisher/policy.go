@@ -0,0 +1,56 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A HealthPolicy determines whether a publisher is healthy, based on the outcome of its recent updates.
+type HealthPolicy interface {
+	Evaluate(now time.Time, status Status) error
+}
+
+// HealthPolicyFunc is a function that implements HealthPolicy.
+type HealthPolicyFunc func(time.Time, Status) error
+
+func (f HealthPolicyFunc) Evaluate(now time.Time, status Status) error {
+	return f(now, status)
+}
+
+var _ HealthPolicy = StalenessPolicy{}
+
+// StalenessPolicy considers a publisher unhealthy if it hasn't received an update in MaxAge,
+// or if the last MaxConsecutiveFailures updates failed.
+type StalenessPolicy struct {
+	// QuietHours returns true when the source isn't expected to produce updates (e.g. SolarEdge at night).
+	// During quiet hours, the publisher is always considered healthy, provided it received at least one update.
+	QuietHours func(time.Time) bool
+	// MaxAge is the maximum time since the last successful update. Zero disables the check.
+	MaxAge time.Duration
+	// MaxConsecutiveFailures is the number of consecutive failed updates that makes the publisher unhealthy. Zero disables the check.
+	MaxConsecutiveFailures int
+}
+
+func (s StalenessPolicy) Evaluate(now time.Time, status Status) error {
+	if status.LastUpdate.IsZero() {
+		return withLastError(errors.New("no data received"), status)
+	}
+	if s.QuietHours != nil && s.QuietHours(now) {
+		return nil
+	}
+	if s.MaxConsecutiveFailures > 0 && status.ConsecutiveFailures >= s.MaxConsecutiveFailures {
+		return withLastError(fmt.Errorf("%d consecutive failed updates", status.ConsecutiveFailures), status)
+	}
+	if age := now.Sub(status.LastUpdate); s.MaxAge > 0 && age > s.MaxAge {
+		return withLastError(fmt.Errorf("no data received since %v", age.Round(time.Second)), status)
+	}
+	return nil
+}
+
+func withLastError(err error, status Status) error {
+	if status.LastError != nil {
+		err = fmt.Errorf("%w (last error: %w)", err, status.LastError)
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package publisher
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStalenessPolicy_Evaluate(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	night := func(t time.Time) bool { return t.Hour() < 6 || t.Hour() >= 22 }
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		policy  StalenessPolicy
+		now     time.Time
+		status  Status
+		wantErr string
+	}{
+		{
+			name:    "no data",
+			policy:  StalenessPolicy{MaxAge: time.Hour},
+			now:     now,
+			wantErr: "no data received",
+		},
+		{
+			name:    "no data, with error",
+			policy:  StalenessPolicy{MaxAge: time.Hour},
+			now:     now,
+			status:  Status{LastError: errFailed, ConsecutiveFailures: 1},
+			wantErr: "no data received (last error: failed)",
+		},
+		{
+			name:   "recent data",
+			policy: StalenessPolicy{MaxAge: time.Hour},
+			now:    now,
+			status: Status{LastUpdate: now.Add(-time.Minute)},
+		},
+		{
+			name:    "stale data",
+			policy:  StalenessPolicy{MaxAge: time.Hour},
+			now:     now,
+			status:  Status{LastUpdate: now.Add(-2 * time.Hour)},
+			wantErr: "no data received since 2h0m0s",
+		},
+		{
+			name:   "stale data during quiet hours",
+			policy: StalenessPolicy{MaxAge: time.Hour, QuietHours: night},
+			now:    now.Add(11 * time.Hour),
+			status: Status{LastUpdate: now},
+		},
+		{
+			name:    "too many failures",
+			policy:  StalenessPolicy{MaxAge: time.Hour, MaxConsecutiveFailures: 3},
+			now:     now,
+			status:  Status{LastUpdate: now.Add(-time.Minute), ConsecutiveFailures: 3, LastError: errFailed},
+			wantErr: "3 consecutive failed updates (last error: failed)",
+		},
+		{
+			name:   "failures below threshold",
+			policy: StalenessPolicy{MaxAge: time.Hour, MaxConsecutiveFailures: 3},
+			now:    now,
+			status: Status{LastUpdate: now.Add(-time.Minute), ConsecutiveFailures: 2, LastError: errFailed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.policy.Evaluate(tt.now, tt.status)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package publisher
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/energy"
+	"github.com/clambin/solaredge/v2"
+	"strings"
+	"time"
+)
+
+// PowerFlow is the current power flow of a site with a meter, in W.
+type PowerFlow struct {
+	Production  float64
+	Consumption float64
+	// Grid is the power imported from the grid. Negative if power is exported to the grid.
+	Grid float64
+}
+
+// EnergyDetails is the energy measured by the site's meters today, in Wh.
+type EnergyDetails struct {
+	Production      float64
+	Consumption     float64
+	SelfConsumption float64
+	FeedIn          float64
+	Purchased       float64
+}
+
+// Balance returns the site's production, consumption and self-consumption.
+func (e EnergyDetails) Balance() energy.Balance {
+	return energy.Balance{Production: e.Production, Consumption: e.Consumption, SelfConsumption: e.SelfConsumption}
+}
+
+func newPowerFlow(flow solaredge.PowerFlow) *PowerFlow {
+	scale := powerFlowScale(flow)
+	powerFlow := PowerFlow{
+		Production:  scale * flow.PV.CurrentPower,
+		Consumption: scale * flow.Load.CurrentPower,
+		Grid:        scale * flow.Grid.CurrentPower,
+	}
+	// the grid power is always positive: the connections determine whether power is imported or exported
+	for _, connection := range flow.Connections {
+		if strings.EqualFold(connection.To, "grid") {
+			powerFlow.Grid = -powerFlow.Grid
+			break
+		}
+	}
+	return &powerFlow
+}
+
+// powerFlowScale returns the factor that converts the power flow's unit to W. SolarEdge typically reports the power flow in kW.
+func powerFlowScale(flow solaredge.PowerFlow) float64 {
+	if strings.EqualFold(flow.Unit, "W") {
+		return 1
+	}
+	return 1000
+}
+
+func (c SolarEdgeUpdater) getEnergyDetails(ctx context.Context, id int) (*EnergyDetails, error) {
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	resp, err := c.GetEnergyDetails(ctx, id, solaredge.TimeUnitDay, midnight, now)
+	if err != nil {
+		return nil, err
+	}
+	var details EnergyDetails
+	for _, meter := range resp.EnergyDetails.Meters {
+		var energy float64
+		for _, value := range meter.Values {
+			energy += value.Value
+		}
+		switch meter.Type {
+		case "Production":
+			details.Production = energy
+		case "Consumption":
+			details.Consumption = energy
+		case "SelfConsumption":
+			details.SelfConsumption = energy
+		case "FeedIn":
+			details.FeedIn = energy
+		case "Purchased":
+			details.Purchased = energy
+		}
+	}
+	return &details, nil
+}
@@ -0,0 +1,24 @@
+package publisher
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/energy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSolarEdgeUpdater_GetUpdate_Meter(t *testing.T) {
+	u := SolarEdgeUpdater{SolarEdgeClient: fakeSolarEdgeClient{}, Meter: true}
+
+	update, err := u.GetUpdate(context.Background())
+	require.NoError(t, err)
+	require.Len(t, update, 1)
+	assert.Equal(t, &PowerFlow{Production: 3000, Consumption: 1000, Grid: -2000}, update[0].PowerFlow)
+	assert.Equal(t, &EnergyDetails{Production: 10000, Consumption: 5000, SelfConsumption: 4000, FeedIn: 6000, Purchased: 1000}, update[0].EnergyDetails)
+}
+
+func TestEnergyDetails_Balance(t *testing.T) {
+	details := EnergyDetails{Production: 10000, Consumption: 5000, SelfConsumption: 4000, FeedIn: 6000, Purchased: 1000}
+	assert.Equal(t, energy.Balance{Production: 10000, Consumption: 5000, SelfConsumption: 4000}, details.Balance())
+}
@@ -3,36 +3,74 @@ package publisher
 import (
 	"codeberg.org/clambin/go-common/pubsub"
 	"context"
+	"errors"
 	"fmt"
-	"github.com/clambin/tado/v2"
 	"log/slog"
-	"sync/atomic"
+	"sync"
 	"time"
 )
 
 type Publisher[T any] struct {
 	Updater[T]
-	lastUpdate atomic.Value
-	Logger     *slog.Logger
+	// HealthPolicy determines if the publisher is healthy. Defaults to a StalenessPolicy with a MaxAge of 5 times the Interval.
+	HealthPolicy HealthPolicy
+	Logger       *slog.Logger
 	pubsub.Publisher[T]
+	// Name identifies the publisher's source in health reports
+	Name   string
+	status Status
+	// lastPoll is the time the publisher last tried to get an update. It is set when the publisher starts.
+	lastPoll time.Time
 	Interval time.Duration
+	lock     sync.RWMutex
 }
 
 type Updater[T any] interface {
 	GetUpdate(context.Context) (T, error)
 }
 
+// A PartialUpdateError indicates that an Updater could only get part of the update. The Publisher still publishes
+// the update and doesn't count it as a failure: the Updater reports the failing part.
+type PartialUpdateError struct {
+	Err error
+}
+
+func (e *PartialUpdateError) Error() string {
+	return "partial update: " + e.Err.Error()
+}
+
+func (e *PartialUpdateError) Unwrap() error {
+	return e.Err
+}
+
+// Status records the outcome of a publisher's recent updates.
+type Status struct {
+	LastUpdate          time.Time
+	LastErrorTime       time.Time
+	LastError           error
+	ConsecutiveFailures int
+}
+
 func (p *Publisher[T]) Run(ctx context.Context) error {
 	p.Logger.Debug("starting publisher", "interval", p.Interval)
 	defer p.Logger.Debug("stopped publisher")
 
+	p.polled()
 	for {
 		start := time.Now()
-		if update, err := p.GetUpdate(ctx); err == nil {
-			p.lastUpdate.Store(time.Now())
+		update, err := p.GetUpdate(ctx)
+		p.polled()
+		var partial *PartialUpdateError
+		if errors.As(err, &partial) {
+			p.Logger.Warn("update incomplete", "err", err)
+			err = nil
+		}
+		if err == nil {
+			p.updateStatus(nil)
 			p.Publish(update)
 			p.Logger.Debug("poll done", "duration", time.Since(start))
 		} else {
+			p.updateStatus(err)
 			p.Logger.Error("failed to get update", "err", err)
 		}
 		select {
@@ -43,26 +81,83 @@ func (p *Publisher[T]) Run(ctx context.Context) error {
 	}
 }
 
+func (p *Publisher[T]) polled() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.lastPoll = time.Now()
+}
+
+// maxMissedPolls is the number of intervals without an update attempt after which the publisher is no longer alive.
+const maxMissedPolls = 3
+
+// IsAlive returns an error if the publisher isn't running, or hasn't tried to get an update in 3 intervals, i.e. it is stuck.
+// Unlike IsHealthy, it doesn't matter whether the updates succeed: restarting the publisher doesn't fix a failing source.
+func (p *Publisher[T]) IsAlive(_ context.Context) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.lastPoll.IsZero() {
+		return fmt.Errorf("%s: publisher not running", p.name())
+	}
+	if since := time.Since(p.lastPoll); since > maxMissedPolls*p.Interval {
+		return fmt.Errorf("%s: no update attempted in %s", p.name(), since.Round(time.Second))
+	}
+	return nil
+}
+
+func (p *Publisher[T]) updateStatus(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := time.Now()
+	if err == nil {
+		p.status.LastUpdate = now
+		p.status.ConsecutiveFailures = 0
+		return
+	}
+	p.status.LastError = err
+	p.status.LastErrorTime = now
+	p.status.ConsecutiveFailures++
+}
+
+// Status returns the outcome of the publisher's recent updates.
+func (p *Publisher[T]) Status() Status {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.status
+}
+
+// LastUpdate returns the time of the last successful update. Returns zero if no update has been received yet.
+func (p *Publisher[T]) LastUpdate() time.Time {
+	return p.Status().LastUpdate
+}
+
 func (p *Publisher[T]) IsHealthy(_ context.Context) error {
-	lastUpdate := p.lastUpdate.Load()
-	if lastUpdate == nil {
-		return fmt.Errorf("no data received from %s", p.getSource())
+	policy := p.HealthPolicy
+	if policy == nil {
+		policy = StalenessPolicy{MaxAge: 5 * p.Interval}
 	}
-	if noData := time.Since(lastUpdate.(time.Time)); noData > 5*p.Interval {
-		return fmt.Errorf("no data received from %s since %v", p.getSource(), noData)
+	if err := policy.Evaluate(time.Now(), p.Status()); err != nil {
+		return fmt.Errorf("%s: %w", p.name(), err)
 	}
 	return nil
 }
 
-func (p *Publisher[T]) getSource() string {
-	var t T
-	var ptr any = t
-	switch ptr.(type) {
-	case SolarEdgeUpdate:
-		return "SolarEdge"
-	case *tado.Weather:
-		return "Tado"
-	default:
+// HealthDetails returns the publisher's status, for inclusion in the health report.
+func (p *Publisher[T]) HealthDetails() map[string]any {
+	status := p.Status()
+	details := map[string]any{"consecutive_failures": status.ConsecutiveFailures}
+	if !status.LastUpdate.IsZero() {
+		details["last_update"] = status.LastUpdate
+	}
+	if status.LastError != nil {
+		details["last_error"] = status.LastError.Error()
+		details["last_error_time"] = status.LastErrorTime
+	}
+	return details
+}
+
+func (p *Publisher[T]) name() string {
+	if p.Name == "" {
 		return "unknown source"
 	}
+	return p.Name
 }
@@ -3,9 +3,11 @@ package publisher
 import (
 	"codeberg.org/clambin/go-common/pubsub"
 	"context"
+	"errors"
 	"github.com/clambin/solaredge/v2"
 	"github.com/clambin/tado/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io"
 	"log/slog"
 	"testing"
@@ -26,8 +28,9 @@ func TestPublisher_SolarEdge(t *testing.T) {
 	go func() { assert.NoError(t, p.Run(t.Context())) }()
 
 	assert.Equal(t, SolarEdgeUpdate{{
-		ID:   1,
-		Name: "my home",
+		ID:      1,
+		Name:    "my home",
+		Details: solaredge.SiteDetails{Id: 1, Name: "my home", PeakPower: 4.5},
 		PowerOverview: solaredge.PowerOverview{
 			LifeTimeData:  solaredge.EnergyOverview{Energy: 1000},
 			LastYearData:  solaredge.EnergyOverview{Energy: 100},
@@ -38,6 +41,8 @@ func TestPublisher_SolarEdge(t *testing.T) {
 		InverterUpdates: []InverterUpdate{{
 			Name:         "foo",
 			SerialNumber: "1234",
+			Manufacturer: "SolarEdge",
+			Model:        "SE4000H",
 			Telemetry: solaredge.InverterTelemetry{
 				L1Data:    solaredge.InverterTelemetryL1Data{AcCurrent: 1, AcVoltage: 220},
 				DcVoltage: 380,
@@ -48,6 +53,28 @@ func TestPublisher_SolarEdge(t *testing.T) {
 	<-ch
 }
 
+func TestPublisher_PartialUpdate(t *testing.T) {
+	p := Publisher[SolarEdgeUpdate]{
+		Updater: &SolarEdgeAccounts{Accounts: []Updater[SolarEdgeUpdate]{
+			SolarEdgeUpdater{SolarEdgeClient: fakeSolarEdgeClient{}},
+			failingUpdater{},
+		}},
+		Interval:  100 * time.Millisecond,
+		Logger:    discardLogger,
+		Publisher: pubsub.Publisher[SolarEdgeUpdate]{},
+	}
+	ch := p.Subscribe()
+
+	go func() { assert.NoError(t, p.Run(t.Context())) }()
+
+	// the update of the working account is published and the publisher remains healthy
+	update := <-ch
+	require.Len(t, update, 1)
+	assert.Equal(t, "my home", update[0].Name)
+	assert.NoError(t, p.IsHealthy(t.Context()))
+	assert.Zero(t, p.Status().ConsecutiveFailures)
+}
+
 func TestPublisher_Tado(t *testing.T) {
 	p := Publisher[*tado.Weather]{
 		Updater:   fakeTadoClient{},
@@ -67,27 +94,54 @@ func TestPublisher_Tado(t *testing.T) {
 }
 
 func TestPublisher_IsHealthy(t *testing.T) {
-	p := Publisher[*tado.Weather]{Interval: 10 * time.Millisecond}
-	assert.Error(t, p.IsHealthy(context.TODO()))
-	p.lastUpdate.Store(time.Now())
+	p := Publisher[*tado.Weather]{Name: "Tado", Interval: 10 * time.Millisecond}
+	assert.ErrorContains(t, p.IsHealthy(context.TODO()), "Tado: no data received")
+	assert.True(t, p.LastUpdate().IsZero())
+	p.updateStatus(nil)
+	assert.False(t, p.LastUpdate().IsZero())
 	assert.NoError(t, p.IsHealthy(context.TODO()))
 	assert.Eventually(t, func() bool { return p.IsHealthy(context.TODO()) != nil }, time.Second, p.Interval)
 }
 
-func TestPublisher_getSource(t *testing.T) {
-	var p Publisher[*tado.Weather]
-	assert.Equal(t, "Tado", p.getSource())
-	var q Publisher[SolarEdgeUpdate]
-	assert.Equal(t, "SolarEdge", q.getSource())
-	var r Publisher[any]
-	assert.Equal(t, "unknown source", r.getSource())
+func TestPublisher_IsAlive(t *testing.T) {
+	p := Publisher[*tado.Weather]{Name: "Tado", Interval: 10 * time.Millisecond}
+	assert.ErrorContains(t, p.IsAlive(context.TODO()), "Tado: publisher not running")
+	p.polled()
+	assert.NoError(t, p.IsAlive(context.TODO()))
+	assert.Eventually(t, func() bool { return p.IsAlive(context.TODO()) != nil }, time.Second, p.Interval)
+}
+
+func TestPublisher_Status(t *testing.T) {
+	p := Publisher[*tado.Weather]{
+		Updater:   fakeTadoClient{err: errors.New("tado unavailable")},
+		Interval:  10 * time.Millisecond,
+		Logger:    discardLogger,
+		Publisher: pubsub.Publisher[*tado.Weather]{},
+	}
+	go func() { assert.NoError(t, p.Run(t.Context())) }()
+
+	assert.Eventually(t, func() bool { return p.Status().ConsecutiveFailures >= 2 }, time.Second, time.Millisecond)
+	status := p.Status()
+	assert.ErrorContains(t, status.LastError, "tado unavailable")
+	assert.True(t, status.LastUpdate.IsZero())
+
+	details := p.HealthDetails()
+	assert.Equal(t, "tado unavailable", details["last_error"])
+	assert.Contains(t, details, "consecutive_failures")
+	assert.NotContains(t, details, "last_update")
+	assert.ErrorContains(t, p.IsHealthy(t.Context()), "unknown source: no data received (last error: tado unavailable)")
 }
 
 var _ Updater[*tado.Weather] = fakeTadoClient{}
 
-type fakeTadoClient struct{}
+type fakeTadoClient struct {
+	err error
+}
 
 func (f fakeTadoClient) GetUpdate(_ context.Context) (*tado.Weather, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
 	return &tado.Weather{
 		OutsideTemperature: &tado.TemperatureDataPoint{Celsius: varP(float32(18))},
 		SolarIntensity:     &tado.PercentageDataPoint{Percentage: varP(float32(75))},
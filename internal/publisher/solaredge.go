@@ -4,11 +4,19 @@ import (
 	"context"
 	"fmt"
 	"github.com/clambin/solaredge/v2"
+	"strconv"
 	"time"
 )
 
 type SolarEdgeUpdater struct {
 	SolarEdgeClient
+	// Meter indicates the sites have a meter. If set, the updater also gets the power flow and today's energy details.
+	Meter bool
+	// Storage indicates the sites have batteries. If set, the updater also gets the state of the batteries.
+	Storage bool
+	// Sites selects the sites to monitor, keyed by the site's ID or name, and the name under which each site is reported.
+	// A blank key selects the account's first site. If empty, all sites are monitored under their SolarEdge name.
+	Sites map[string]string
 }
 
 type SolarEdgeClient interface {
@@ -16,6 +24,9 @@ type SolarEdgeClient interface {
 	GetPowerOverview(ctx context.Context, id int) (solaredge.GetPowerOverviewResponse, error)
 	GetComponents(ctx context.Context, id int) (solaredge.GetComponentsResponse, error)
 	GetInverterTechnicalData(ctx context.Context, id int, serialNr string, startTime time.Time, endTime time.Time) (solaredge.GetInverterTechnicalDataResponse, error)
+	GetPowerFlow(ctx context.Context, id int) (solaredge.GetPowerFlowResponse, error)
+	GetEnergyDetails(ctx context.Context, id int, timeUnit solaredge.TimeUnit, startTime, endTime time.Time) (solaredge.GetEnergyDetailsResponse, error)
+	GetStorageData(ctx context.Context, id int, startTime, endTime time.Time) (solaredge.GetStorageDataResponse, error)
 }
 
 type SolarEdgeUpdate []SiteUpdate
@@ -24,13 +35,24 @@ type SiteUpdate struct {
 	Name            string
 	InverterUpdates []InverterUpdate
 	PowerOverview   solaredge.PowerOverview
-	ID              int
+	Details         solaredge.SiteDetails
+	// PowerFlow and EnergyDetails are only set if the SolarEdgeUpdater is configured for sites with a meter.
+	PowerFlow     *PowerFlow
+	EnergyDetails *EnergyDetails
+	// Storage is only set if the SolarEdgeUpdater is configured for sites with batteries.
+	Storage *StorageUpdate
+	ID      int
 }
 
 type InverterUpdate struct {
 	Name         string
 	SerialNumber string
+	Manufacturer string
+	Model        string
 	Telemetry    solaredge.InverterTelemetry
+	// Phases holds the data of each of the inverter's phases, keyed by phase (L1, L2, L3). Only set if the
+	// SolarEdgeClient is a PhasesClient. Otherwise, only L1 is available, in Telemetry.
+	Phases map[string]solaredge.InverterTelemetryL1Data
 }
 
 func (c SolarEdgeUpdater) GetUpdate(ctx context.Context) (SolarEdgeUpdate, error) {
@@ -39,21 +61,80 @@ func (c SolarEdgeUpdater) GetUpdate(ctx context.Context) (SolarEdgeUpdate, error
 		return SolarEdgeUpdate{}, err
 	}
 
-	update := make(SolarEdgeUpdate, len(sites.Sites.Site))
-	for i, site := range sites.Sites.Site {
+	selected, err := c.selectSites(sites.Sites.Site)
+	if err != nil {
+		return SolarEdgeUpdate{}, err
+	}
+
+	update := make(SolarEdgeUpdate, len(selected))
+	for i, site := range selected {
 		siteUpdate := SiteUpdate{
-			ID:   site.Id,
-			Name: site.Name,
+			ID:      site.details.Id,
+			Name:    site.name,
+			Details: site.details,
 		}
-		if siteUpdate.PowerOverview, siteUpdate.InverterUpdates, err = c.getSiteUpdate(ctx, site.Id); err != nil {
+		if siteUpdate.PowerOverview, siteUpdate.InverterUpdates, err = c.getSiteUpdate(ctx, siteUpdate.ID); err != nil {
 			return SolarEdgeUpdate{}, err
 		}
+		if c.Meter || c.Storage {
+			powerFlow, err := c.GetPowerFlow(ctx, siteUpdate.ID)
+			if err != nil {
+				return SolarEdgeUpdate{}, fmt.Errorf("unable to get power flow: %w", err)
+			}
+			if c.Meter {
+				siteUpdate.PowerFlow = newPowerFlow(powerFlow.CurrentPowerFlow)
+				if siteUpdate.EnergyDetails, err = c.getEnergyDetails(ctx, siteUpdate.ID); err != nil {
+					return SolarEdgeUpdate{}, fmt.Errorf("unable to get energy details: %w", err)
+				}
+			}
+			if c.Storage {
+				if siteUpdate.Storage, err = c.getStorage(ctx, siteUpdate.ID, powerFlow.CurrentPowerFlow); err != nil {
+					return SolarEdgeUpdate{}, fmt.Errorf("unable to get storage data: %w", err)
+				}
+			}
+		}
 		update[i] = siteUpdate
 	}
 
 	return update, nil
 }
 
+type selectedSite struct {
+	name    string
+	details solaredge.SiteDetails
+}
+
+// selectSites returns the sites selected by Sites, in the order returned by SolarEdge.
+func (c SolarEdgeUpdater) selectSites(sites []solaredge.SiteDetails) ([]selectedSite, error) {
+	selected := make([]selectedSite, 0, len(sites))
+	if len(c.Sites) == 0 {
+		for _, site := range sites {
+			selected = append(selected, selectedSite{name: site.Name, details: site})
+		}
+		return selected, nil
+	}
+	found := make(map[string]bool, len(c.Sites))
+	for i, site := range sites {
+		keys := []string{strconv.Itoa(site.Id), site.Name}
+		if i == 0 {
+			keys = append(keys, "")
+		}
+		for _, key := range keys {
+			if name, ok := c.Sites[key]; ok {
+				selected = append(selected, selectedSite{name: name, details: site})
+				found[key] = true
+				break
+			}
+		}
+	}
+	for key := range c.Sites {
+		if !found[key] {
+			return nil, fmt.Errorf("site %q not found", key)
+		}
+	}
+	return selected, nil
+}
+
 func (c SolarEdgeUpdater) getSiteUpdate(ctx context.Context, id int) (solaredge.PowerOverview, []InverterUpdate, error) {
 	powerOverview, err := c.GetPowerOverview(ctx, id)
 	if err != nil {
@@ -73,15 +154,34 @@ func (c SolarEdgeUpdater) getSiteUpdate(ctx context.Context, id int) (solaredge.
 		inverterUpdates[i] = InverterUpdate{
 			Name:         inverter.Name,
 			SerialNumber: inverter.SerialNumber,
+			Manufacturer: inverter.Manufacturer,
+			Model:        inverter.Model,
 		}
 
-		telemetry, err := c.GetInverterTechnicalData(ctx, id, inverter.SerialNumber, startTime, endTime)
+		telemetry, err := c.getInverterTelemetry(ctx, id, inverter.SerialNumber, startTime, endTime)
 		if err != nil {
 			return solaredge.PowerOverview{}, nil, fmt.Errorf("unable to get telemetry for inverter %q: %w", inverter.Name, err)
 		}
-		if n := len(telemetry.Data.Telemetries); n > 0 {
-			inverterUpdates[i].Telemetry = telemetry.Data.Telemetries[n-1]
+		if n := len(telemetry); n > 0 {
+			inverterUpdates[i].Telemetry = telemetry[n-1].InverterTelemetry
+			inverterUpdates[i].Phases = telemetry[n-1].Phases
 		}
 	}
 	return powerOverview.Overview, inverterUpdates, nil
 }
+
+// getInverterTelemetry returns the inverter's telemetry. If the client isn't a PhasesClient, the telemetry has no Phases.
+func (c SolarEdgeUpdater) getInverterTelemetry(ctx context.Context, id int, serialNr string, startTime, endTime time.Time) ([]InverterTelemetry, error) {
+	if client, ok := c.SolarEdgeClient.(PhasesClient); ok {
+		return client.GetInverterPhases(ctx, id, serialNr, startTime, endTime)
+	}
+	resp, err := c.GetInverterTechnicalData(ctx, id, serialNr, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	telemetry := make([]InverterTelemetry, len(resp.Data.Telemetries))
+	for i, t := range resp.Data.Telemetries {
+		telemetry[i] = InverterTelemetry{InverterTelemetry: t}
+	}
+	return telemetry, nil
+}
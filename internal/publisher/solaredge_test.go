@@ -16,8 +16,9 @@ func TestSolarEdgeUpdater_GetUpdate(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, update, 1)
 	want := SiteUpdate{
-		ID:   1,
-		Name: "my home",
+		ID:      1,
+		Name:    "my home",
+		Details: solaredge.SiteDetails{Id: 1, Name: "my home", PeakPower: 4.5},
 		PowerOverview: solaredge.PowerOverview{
 			LastUpdateTime: solaredge.Time{},
 			LifeTimeData:   solaredge.EnergyOverview{Energy: 1000, Revenue: 0},
@@ -30,6 +31,8 @@ func TestSolarEdgeUpdater_GetUpdate(t *testing.T) {
 			{
 				Name:         "foo",
 				SerialNumber: "1234",
+				Manufacturer: "SolarEdge",
+				Model:        "SE4000H",
 				Telemetry: solaredge.InverterTelemetry{
 					L1Data:    solaredge.InverterTelemetryL1Data{AcCurrent: 1, AcVoltage: 220},
 					DcVoltage: 380,
@@ -46,7 +49,7 @@ type fakeSolarEdgeClient struct{}
 
 func (f fakeSolarEdgeClient) GetSites(_ context.Context) (solaredge.GetSitesResponse, error) {
 	var response solaredge.GetSitesResponse
-	response.Sites.Site = []solaredge.SiteDetails{{Id: 1, Name: "my home"}}
+	response.Sites.Site = []solaredge.SiteDetails{{Id: 1, Name: "my home", PeakPower: 4.5}}
 	response.Sites.Count = len(response.Sites.Site)
 	return response, nil
 }
@@ -65,7 +68,7 @@ func (f fakeSolarEdgeClient) GetPowerOverview(_ context.Context, _ int) (solared
 
 func (f fakeSolarEdgeClient) GetComponents(_ context.Context, id int) (solaredge.GetComponentsResponse, error) {
 	var responses = map[int][]solaredge.Inverter{
-		1: {{Name: "foo", SerialNumber: "1234"}},
+		1: {{Name: "foo", SerialNumber: "1234", Manufacturer: "SolarEdge", Model: "SE4000H"}},
 	}
 	var response solaredge.GetComponentsResponse
 	response.Reporters.List = responses[id]
@@ -87,3 +90,84 @@ func (f fakeSolarEdgeClient) GetInverterTechnicalData(_ context.Context, _ int,
 	response.Data.Count = len(response.Data.Telemetries)
 	return response, nil
 }
+
+func (f fakeSolarEdgeClient) GetPowerFlow(_ context.Context, _ int) (solaredge.GetPowerFlowResponse, error) {
+	var response solaredge.GetPowerFlowResponse
+	response.CurrentPowerFlow.Unit = "kW"
+	response.CurrentPowerFlow.PV.CurrentPower = 3
+	response.CurrentPowerFlow.Load.CurrentPower = 1
+	response.CurrentPowerFlow.Grid.CurrentPower = 2
+	response.CurrentPowerFlow.Storage.Status = "Charging"
+	response.CurrentPowerFlow.Storage.CurrentPower = 0.2
+	response.CurrentPowerFlow.Storage.ChargeLevel = 60
+	response.CurrentPowerFlow.Connections = []struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}{{From: "PV", To: "Load"}, {From: "LOAD", To: "Grid"}}
+	return response, nil
+}
+
+func (f fakeSolarEdgeClient) GetEnergyDetails(_ context.Context, _ int, _ solaredge.TimeUnit, _, _ time.Time) (solaredge.GetEnergyDetailsResponse, error) {
+	var response solaredge.GetEnergyDetailsResponse
+	for meter, energy := range map[string]float64{"Production": 10000, "Consumption": 5000, "SelfConsumption": 4000, "FeedIn": 6000, "Purchased": 1000} {
+		response.EnergyDetails.Meters = append(response.EnergyDetails.Meters, solaredge.MeterReadings{Type: meter, Values: []solaredge.Value{{Value: energy}}})
+	}
+	return response, nil
+}
+
+func (f fakeSolarEdgeClient) GetStorageData(_ context.Context, _ int, _, _ time.Time) (solaredge.GetStorageDataResponse, error) {
+	var response solaredge.GetStorageDataResponse
+	response.StorageData.Batteries = []solaredge.Battery{{
+		SerialNumber: "B1",
+		ModelNumber:  "BAT-10K",
+		Nameplate:    10000,
+		Telemetries: []solaredge.BatteryTelemetry{
+			{Power: 100, LifeTimeEnergyCharged: 1000},
+			{Power: 200, LifeTimeEnergyCharged: 1100, LifeTimeEnergyDischarged: 900, FullPackEnergyAvailable: 9500, InternalTemp: 25},
+		},
+	}}
+	response.StorageData.BatteryCount = len(response.StorageData.Batteries)
+	return response, nil
+}
+
+func TestSolarEdgeUpdater_Sites(t *testing.T) {
+	tests := []struct {
+		name      string
+		sites     map[string]string
+		wantNames []string
+		wantErr   string
+	}{
+		{name: "all sites", wantNames: []string{"my home", "other home"}},
+		{name: "by id", sites: map[string]string{"2": "parents"}, wantNames: []string{"parents"}},
+		{name: "by name", sites: map[string]string{"my home": "home", "other home": "parents"}, wantNames: []string{"home", "parents"}},
+		{name: "first site", sites: map[string]string{"": "home"}, wantNames: []string{"home"}},
+		{name: "unknown site", sites: map[string]string{"3": "home"}, wantErr: `site "3" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := SolarEdgeUpdater{SolarEdgeClient: fakeMultiSiteClient{}, Sites: tt.sites}
+			update, err := u.GetUpdate(t.Context())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			names := make([]string, len(update))
+			for i, site := range update {
+				names[i] = site.Name
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
+
+type fakeMultiSiteClient struct {
+	fakeSolarEdgeClient
+}
+
+func (f fakeMultiSiteClient) GetSites(_ context.Context) (solaredge.GetSitesResponse, error) {
+	var response solaredge.GetSitesResponse
+	response.Sites.Site = []solaredge.SiteDetails{{Id: 1, Name: "my home"}, {Id: 2, Name: "other home"}}
+	response.Sites.Count = len(response.Sites.Site)
+	return response, nil
+}
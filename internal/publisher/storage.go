@@ -0,0 +1,58 @@
+package publisher
+
+import (
+	"context"
+	"github.com/clambin/solaredge/v2"
+	"strings"
+	"time"
+)
+
+// StorageUpdate is the state of a site's batteries.
+type StorageUpdate struct {
+	// Status is the status reported by SolarEdge, e.g. "Charging", "Discharging" or "Idle".
+	Status string
+	// ChargeLevel is the state of charge, in %.
+	ChargeLevel float64
+	// Power is the power charging the batteries, in W. Negative if the batteries are discharging.
+	Power     float64
+	Batteries []BatteryUpdate
+}
+
+type BatteryUpdate struct {
+	SerialNumber string
+	Model        string
+	// Nameplate is the capacity of the battery, in Wh.
+	Nameplate float64
+	// Telemetry is the battery's most recent telemetry. Zero if the battery didn't report any recently.
+	Telemetry solaredge.BatteryTelemetry
+}
+
+func (c SolarEdgeUpdater) getStorage(ctx context.Context, id int, flow solaredge.PowerFlow) (*StorageUpdate, error) {
+	storage := StorageUpdate{
+		Status:      flow.Storage.Status,
+		ChargeLevel: flow.Storage.ChargeLevel,
+		Power:       powerFlowScale(flow) * flow.Storage.CurrentPower,
+	}
+	if strings.EqualFold(storage.Status, "discharging") {
+		storage.Power = -storage.Power
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-10 * time.Minute)
+	resp, err := c.GetStorageData(ctx, id, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	storage.Batteries = make([]BatteryUpdate, len(resp.StorageData.Batteries))
+	for i, battery := range resp.StorageData.Batteries {
+		storage.Batteries[i] = BatteryUpdate{
+			SerialNumber: battery.SerialNumber,
+			Model:        battery.ModelNumber,
+			Nameplate:    float64(battery.Nameplate),
+		}
+		if n := len(battery.Telemetries); n > 0 {
+			storage.Batteries[i].Telemetry = battery.Telemetries[n-1]
+		}
+	}
+	return &storage, nil
+}
@@ -0,0 +1,43 @@
+package publisher
+
+import (
+	"context"
+	"github.com/clambin/solaredge/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSolarEdgeUpdater_GetUpdate_Storage(t *testing.T) {
+	u := SolarEdgeUpdater{SolarEdgeClient: fakeSolarEdgeClient{}, Storage: true}
+
+	update, err := u.GetUpdate(context.Background())
+	require.NoError(t, err)
+	require.Len(t, update, 1)
+	assert.Nil(t, update[0].PowerFlow)
+	want := StorageUpdate{
+		Status:      "Charging",
+		ChargeLevel: 60,
+		Power:       200,
+		Batteries: []BatteryUpdate{{
+			SerialNumber: "B1",
+			Model:        "BAT-10K",
+			Nameplate:    10000,
+			Telemetry:    solaredge.BatteryTelemetry{Power: 200, LifeTimeEnergyCharged: 1100, LifeTimeEnergyDischarged: 900, FullPackEnergyAvailable: 9500, InternalTemp: 25},
+		}},
+	}
+	assert.Equal(t, &want, update[0].Storage)
+}
+
+func TestSolarEdgeUpdater_getStorage_Discharging(t *testing.T) {
+	var flow solaredge.PowerFlow
+	flow.Unit = "W"
+	flow.Storage.Status = "Discharging"
+	flow.Storage.CurrentPower = 500
+	flow.Storage.ChargeLevel = 40
+
+	storage, err := SolarEdgeUpdater{SolarEdgeClient: fakeSolarEdgeClient{}}.getStorage(context.Background(), 1, flow)
+	require.NoError(t, err)
+	assert.Equal(t, -500.0, storage.Power)
+	assert.Equal(t, 40.0, storage.ChargeLevel)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"gonum.org/v1/plot/plotter"
+	"time"
+)
+
+// BatteryState is the average state of a site's batteries during the interval ending at Timestamp.
+type BatteryState struct {
+	Timestamp time.Time `db:"timestamp" json:"timestamp"`
+	// ChargeLevel is the state of charge, in %.
+	ChargeLevel float64 `db:"charge_level" json:"chargeLevel"`
+	// Power is the power charging the batteries, in W. Negative if the batteries were discharging.
+	Power float64 `db:"power" json:"power"`
+}
+
+var _ plotter.XYer = BatteryHistory{}
+
+// BatteryHistory plots the battery's power over time.
+type BatteryHistory []BatteryState
+
+// Fold moves all states to the same day, so they can be plotted by time of day.
+func (b BatteryHistory) Fold() BatteryHistory {
+	if len(b) == 0 {
+		return BatteryHistory{}
+	}
+	folded := make(BatteryHistory, len(b))
+	copy(folded, b)
+	baseDate := time.Date(folded[0].Timestamp.Year(), time.January, 1, 0, 0, 0, 0, folded[0].Timestamp.Location())
+	for i := range folded {
+		hh, mm, ss := folded[i].Timestamp.Clock()
+		folded[i].Timestamp = baseDate.Add(time.Duration(hh)*time.Hour + time.Duration(mm)*time.Minute + time.Duration(ss)*time.Second)
+	}
+	return folded
+}
+
+func (b BatteryHistory) Len() int {
+	return len(b)
+}
+
+func (b BatteryHistory) XY(i int) (float64, float64) {
+	return float64(b[i].Timestamp.Unix()), b[i].Power
+}
+
+func (db *PostgresDB) StoreBatteryState(state BatteryState) error {
+	_, err := db.DBX.Exec(`INSERT INTO battery_history (timestamp, charge_level, power) VALUES ($1, $2, $3)`,
+		state.Timestamp, state.ChargeLevel, state.Power,
+	)
+	return err
+}
+
+func (db *PostgresDB) GetBatteryHistory(from, to time.Time) (BatteryHistory, error) {
+	stmt := "SELECT timestamp, charge_level, power FROM battery_history"
+	if timeClause := getTimeClause(from, to); timeClause != "" {
+		stmt += " WHERE " + timeClause
+	}
+	stmt += " ORDER BY timestamp"
+	var history BatteryHistory
+	err := db.DBX.Select(&history, stmt)
+	return history, err
+}
@@ -0,0 +1,52 @@
+package repository_test
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"testing"
+	"time"
+)
+
+func TestBatteryHistory(t *testing.T) {
+	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(c))
+	})
+
+	db, err := repository.NewPostgresDB(connString)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.StoreBatteryState(repository.BatteryState{Timestamp: timestamp, ChargeLevel: 50, Power: 1500}))
+	require.NoError(t, db.StoreBatteryState(repository.BatteryState{Timestamp: timestamp.Add(15 * time.Minute), ChargeLevel: 55, Power: -500}))
+
+	history, err := db.GetBatteryHistory(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, timestamp, history[0].Timestamp.UTC())
+	x, y := history.XY(1)
+	assert.Equal(t, float64(timestamp.Add(15*time.Minute).Unix()), x)
+	assert.Equal(t, -500.0, y)
+
+	history, err = db.GetBatteryHistory(timestamp.Add(time.Minute), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, 55.0, history[0].ChargeLevel)
+}
+
+func TestBatteryHistory_Fold(t *testing.T) {
+	history := repository.BatteryHistory{
+		{Timestamp: time.Date(2024, time.July, 1, 12, 15, 0, 0, time.UTC), Power: 100},
+		{Timestamp: time.Date(2024, time.July, 2, 13, 30, 0, 0, time.UTC), Power: -100},
+	}
+	folded := history.Fold()
+	require.Len(t, folded, 2)
+	assert.Equal(t, time.Date(2024, time.January, 1, 12, 15, 0, 0, time.UTC), folded[0].Timestamp)
+	assert.Equal(t, time.Date(2024, time.January, 1, 13, 30, 0, 0, time.UTC), folded[1].Timestamp)
+	assert.Equal(t, -100.0, folded[1].Power)
+	assert.Empty(t, repository.BatteryHistory{}.Fold())
+}
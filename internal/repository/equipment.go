@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+)
+
+// Equipment is a device installed at a site.
+type Equipment struct {
+	SiteID int `db:"site_id" json:"siteId"`
+	// Type is the type of device: inverter, battery, meter, gateway or sensor.
+	Type         string `db:"type" json:"type"`
+	SerialNumber string `db:"serial_number" json:"serialNumber"`
+	Name         string `db:"name" json:"name"`
+	Manufacturer string `db:"manufacturer" json:"manufacturer,omitempty"`
+	Model        string `db:"model" json:"model,omitempty"`
+	Firmware     string `db:"firmware" json:"firmware,omitempty"`
+	// Optimizers is the number of optimizers connected to an inverter.
+	Optimizers int       `db:"optimizers" json:"optimizers,omitempty"`
+	FirstSeen  time.Time `db:"first_seen" json:"firstSeen"`
+	LastSeen   time.Time `db:"last_seen" json:"lastSeen"`
+}
+
+// An EquipmentEvent records a change in a site's equipment.
+type EquipmentEvent struct {
+	Timestamp    time.Time `db:"timestamp" json:"timestamp"`
+	SiteID       int       `db:"site_id" json:"siteId"`
+	Type         string    `db:"type" json:"type"`
+	SerialNumber string    `db:"serial_number" json:"serialNumber"`
+	Name         string    `db:"name" json:"name"`
+	// Change is the type of change: added, removed, firmware or optimizers.
+	Change string `db:"change" json:"change"`
+	// Old and New are the values before and after the change. Blank for added and removed equipment.
+	Old string `db:"old" json:"old,omitempty"`
+	New string `db:"new" json:"new,omitempty"`
+}
+
+// UpdateInventory replaces the inventory of a site and records the events that led to it.
+// The first time a device is stored determines its FirstSeen timestamp.
+func (db *PostgresDB) UpdateInventory(siteID int, inventory []Equipment, events []EquipmentEvent) error {
+	tx, err := db.DBX.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.Exec(`DELETE FROM equipment WHERE site_id = $1`, siteID); err != nil {
+		return err
+	}
+	for _, equipment := range inventory {
+		if _, err = tx.NamedExec(`INSERT INTO equipment (site_id, type, serial_number, name, manufacturer, model, firmware, optimizers, first_seen, last_seen)
+			VALUES (:site_id, :type, :serial_number, :name, :manufacturer, :model, :firmware, :optimizers, :first_seen, :last_seen)`,
+			equipment,
+		); err != nil {
+			return err
+		}
+	}
+	for _, event := range events {
+		if _, err = tx.NamedExec(`INSERT INTO equipment_events (timestamp, site_id, type, serial_number, name, change, old, new)
+			VALUES (:timestamp, :site_id, :type, :serial_number, :name, :change, :old, :new)`,
+			event,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetInventory returns the equipment of all sites.
+func (db *PostgresDB) GetInventory() ([]Equipment, error) {
+	var inventory []Equipment
+	err := db.DBX.Select(&inventory, `SELECT site_id, type, serial_number, name, manufacturer, model, firmware, optimizers, first_seen, last_seen
+		FROM equipment ORDER BY site_id, type, name, serial_number`)
+	return inventory, err
+}
+
+// GetEquipmentEvents returns the equipment changes recorded between from and to.
+func (db *PostgresDB) GetEquipmentEvents(from, to time.Time) ([]EquipmentEvent, error) {
+	stmt := "SELECT timestamp, site_id, type, serial_number, name, change, old, new FROM equipment_events"
+	if timeClause := getTimeClause(from, to); timeClause != "" {
+		stmt += " WHERE " + timeClause
+	}
+	stmt += " ORDER BY timestamp, id"
+	var events []EquipmentEvent
+	err := db.DBX.Select(&events, stmt)
+	return events, err
+}
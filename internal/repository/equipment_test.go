@@ -0,0 +1,58 @@
+package repository_test
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"testing"
+	"time"
+)
+
+func TestInventory(t *testing.T) {
+	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(c))
+	})
+
+	db, err := repository.NewPostgresDB(connString)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	inverter := repository.Equipment{SiteID: 1, Type: "inverter", SerialNumber: "INV1", Name: "Inverter 1", Firmware: "4.1.0", Optimizers: 16, FirstSeen: timestamp, LastSeen: timestamp}
+	battery := repository.Equipment{SiteID: 1, Type: "battery", SerialNumber: "BAT1", Name: "Battery 1", FirstSeen: timestamp, LastSeen: timestamp}
+	require.NoError(t, db.UpdateInventory(1, []repository.Equipment{inverter, battery}, nil))
+
+	inventory, err := db.GetInventory()
+	require.NoError(t, err)
+	require.Len(t, inventory, 2)
+	assert.Equal(t, "BAT1", inventory[0].SerialNumber)
+	assert.Equal(t, 16, inventory[1].Optimizers)
+
+	// battery removed, inverter firmware upgraded
+	inverter.Firmware = "4.1.1"
+	inverter.LastSeen = timestamp.Add(time.Hour)
+	events := []repository.EquipmentEvent{
+		{Timestamp: timestamp.Add(time.Hour), SiteID: 1, Type: "inverter", SerialNumber: "INV1", Name: "Inverter 1", Change: "firmware", Old: "4.1.0", New: "4.1.1"},
+		{Timestamp: timestamp.Add(time.Hour), SiteID: 1, Type: "battery", SerialNumber: "BAT1", Name: "Battery 1", Change: "removed"},
+	}
+	require.NoError(t, db.UpdateInventory(1, []repository.Equipment{inverter}, events))
+
+	inventory, err = db.GetInventory()
+	require.NoError(t, err)
+	require.Len(t, inventory, 1)
+	assert.Equal(t, "4.1.1", inventory[0].Firmware)
+	assert.Equal(t, timestamp, inventory[0].FirstSeen.UTC())
+
+	stored, err := db.GetEquipmentEvents(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "firmware", stored[0].Change)
+	assert.Equal(t, "removed", stored[1].Change)
+
+	stored, err = db.GetEquipmentEvents(timestamp.Add(2*time.Hour), time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
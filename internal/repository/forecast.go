@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"time"
+)
+
+// ForecastError compares the forecasted power for an hour against the power measured during that hour.
+type ForecastError struct {
+	Timestamp time.Time `db:"timestamp" json:"timestamp"`
+	Forecast  float64   `db:"forecast" json:"forecast"`
+	Actual    float64   `db:"actual" json:"actual"`
+}
+
+// StoreForecastError stores the forecast error for an hour. Any previous error for that hour is replaced.
+func (db *PostgresDB) StoreForecastError(forecastError ForecastError) error {
+	_, err := db.DBX.Exec(`INSERT INTO forecast_errors (timestamp, forecast, actual) VALUES ($1, $2, $3)
+		ON CONFLICT (timestamp) DO UPDATE SET forecast = EXCLUDED.forecast, actual = EXCLUDED.actual`,
+		forecastError.Timestamp, forecastError.Forecast, forecastError.Actual,
+	)
+	return err
+}
+
+func (db *PostgresDB) GetForecastErrors(from, to time.Time) ([]ForecastError, error) {
+	stmt := "SELECT timestamp, forecast, actual FROM forecast_errors"
+	if timeClause := getTimeClause(from, to); timeClause != "" {
+		stmt += " WHERE " + timeClause
+	}
+	stmt += " ORDER BY timestamp"
+	var forecastErrors []ForecastError
+	err := db.DBX.Select(&forecastErrors, stmt)
+	return forecastErrors, err
+}
@@ -0,0 +1,39 @@
+package repository_test
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"testing"
+	"time"
+)
+
+func TestForecastErrors(t *testing.T) {
+	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(c))
+	})
+
+	db, err := repository.NewPostgresDB(connString)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.StoreForecastError(repository.ForecastError{Timestamp: timestamp, Forecast: 2000, Actual: 1500}))
+	require.NoError(t, db.StoreForecastError(repository.ForecastError{Timestamp: timestamp.Add(time.Hour), Forecast: 2500, Actual: 2600}))
+	// replaces the previous error
+	require.NoError(t, db.StoreForecastError(repository.ForecastError{Timestamp: timestamp, Forecast: 2000, Actual: 1800}))
+
+	forecastErrors, err := db.GetForecastErrors(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, forecastErrors, 2)
+	assert.Equal(t, timestamp, forecastErrors[0].Timestamp.UTC())
+	assert.Equal(t, 1800.0, forecastErrors[0].Actual)
+
+	forecastErrors, err = db.GetForecastErrors(timestamp.Add(30*time.Minute), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, forecastErrors, 1)
+	assert.Equal(t, 2500.0, forecastErrors[0].Forecast)
+}
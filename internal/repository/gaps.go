@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"time"
+)
+
+// Gap is a period without measurements.
+type Gap struct {
+	From time.Time `db:"from" json:"from"`
+	To   time.Time `db:"to" json:"to"`
+}
+
+func (g Gap) Duration() time.Duration {
+	return g.To.Sub(g.From)
+}
+
+// GetGaps returns the periods between from and to that are longer than minGap and contain no measurements.
+// The period before the first and after the last measurement count as gaps too.
+func (db *PostgresDB) GetGaps(from, to time.Time, minGap time.Duration) ([]Gap, error) {
+	var gaps []Gap
+	err := db.DBX.Select(&gaps, `
+SELECT "from", "to" FROM (
+	SELECT LAG(timestamp) OVER (ORDER BY timestamp) AS "from", timestamp AS "to" FROM (
+		SELECT timestamp FROM solar WHERE timestamp > $1 AND timestamp < $2
+		UNION ALL SELECT $1::timestamptz
+		UNION ALL SELECT $2::timestamptz
+	) AS timestamps
+) AS intervals
+WHERE "from" IS NOT NULL AND EXTRACT(EPOCH FROM "to" - "from") > $3
+ORDER BY "from"`,
+		from, to, minGap.Seconds(),
+	)
+	return gaps, err
+}
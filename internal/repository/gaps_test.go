@@ -0,0 +1,38 @@
+package repository_test
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"testing"
+	"time"
+)
+
+func TestPostgresDB_GetGaps(t *testing.T) {
+	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(c))
+	})
+
+	db, err := repository.NewPostgresDB(connString)
+	require.NoError(t, err)
+
+	start := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, 15 * time.Minute, 30 * time.Minute, 2 * time.Hour, 135 * time.Minute} {
+		require.NoError(t, db.Store(repository.Measurement{Timestamp: start.Add(offset), Power: 1000, Intensity: 50, Weather: "SUN"}))
+	}
+
+	gaps, err := db.GetGaps(start.Add(-time.Hour), start.Add(3*time.Hour), 20*time.Minute)
+	require.NoError(t, err)
+	require.Len(t, gaps, 3)
+	assert.Equal(t, start.Add(-time.Hour), gaps[0].From.UTC())
+	assert.Equal(t, start, gaps[0].To.UTC())
+	assert.Equal(t, start.Add(30*time.Minute), gaps[1].From.UTC())
+	assert.Equal(t, start.Add(2*time.Hour), gaps[1].To.UTC())
+	assert.Equal(t, 90*time.Minute, gaps[1].Duration())
+	assert.Equal(t, start.Add(135*time.Minute), gaps[2].From.UTC())
+	assert.Equal(t, start.Add(3*time.Hour), gaps[2].To.UTC())
+}
@@ -3,16 +3,30 @@ package repository
 import (
 	"gonum.org/v1/plot/plotter"
 	"log/slog"
+	"math"
 	"time"
 )
 
+// UnknownWeather is the weather of a measurement that was stored without weather info.
+const UnknownWeather = "UNKNOWN"
+
 var _ slog.LogValuer = Measurement{}
 
 type Measurement struct {
 	Timestamp time.Time `db:"timestamp"`
 	Weather   string    `db:"weather"`
 	Power     float64   `db:"power"`
-	Intensity float64   `db:"intensity"`
+	// Intensity is the solar intensity. NaN if the measurement was stored without weather info and its intensity
+	// hasn't been estimated yet.
+	Intensity float64 `db:"intensity"`
+	// NoWeather flags a measurement that was stored without weather info. Its weather is UnknownWeather and its
+	// intensity is either missing or estimated.
+	NoWeather bool `db:"no_weather"`
+}
+
+// HasIntensity returns true if the measurement has a (possibly estimated) solar intensity.
+func (m Measurement) HasIntensity() bool {
+	return !math.IsNaN(m.Intensity)
 }
 
 func (m Measurement) LogValue() slog.Value {
@@ -46,6 +60,17 @@ func (m Measurements) Fold() Measurements {
 	return folded
 }
 
+// WithIntensity returns the measurements that have a solar intensity.
+func (m Measurements) WithIntensity() Measurements {
+	filtered := make(Measurements, 0, len(m))
+	for _, measurement := range m {
+		if measurement.HasIntensity() {
+			filtered = append(filtered, measurement)
+		}
+	}
+	return filtered
+}
+
 func (m Measurements) Len() int {
 	return len(m)
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/clambin/solaredge-monitor/internal/repository"
 	"github.com/clambin/tado/v2"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 	"time"
 )
@@ -58,4 +59,18 @@ func TestMeasurement_LogValue(t *testing.T) {
 	assert.Equal(t, `[power=3000 intensity=80.5 weather=SUN]`, m.LogValue().String())
 }
 
+func TestMeasurement_HasIntensity(t *testing.T) {
+	assert.True(t, repository.Measurement{Intensity: 0}.HasIntensity())
+	assert.False(t, repository.Measurement{Intensity: math.NaN(), NoWeather: true}.HasIntensity())
+}
+
+func TestMeasurements_WithIntensity(t *testing.T) {
+	measurements := repository.Measurements{
+		{Power: 1000, Intensity: 50},
+		{Power: 2000, Intensity: math.NaN(), NoWeather: true},
+		{Power: 3000, Intensity: 0},
+	}
+	assert.Equal(t, repository.Measurements{{Power: 1000, Intensity: 50}, {Power: 3000, Intensity: 0}}, measurements.WithIntensity())
+}
+
 // TODO: XYZer interface
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS forecast_errors (
+    timestamp TIMESTAMP WITH TIME ZONE PRIMARY KEY,
+    forecast NUMERIC,
+    actual NUMERIC
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS power_flows;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS power_flows (
+    timestamp TIMESTAMP WITH TIME ZONE PRIMARY KEY,
+    production NUMERIC,
+    consumption NUMERIC,
+    grid NUMERIC
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS battery_history (
+    timestamp TIMESTAMP WITH TIME ZONE PRIMARY KEY,
+    charge_level NUMERIC,
+    power NUMERIC
+);
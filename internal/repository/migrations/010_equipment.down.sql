@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS equipment_events;
+DROP TABLE IF EXISTS equipment;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS equipment (
+    site_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    serial_number TEXT NOT NULL,
+    name TEXT NOT NULL,
+    manufacturer TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    firmware TEXT NOT NULL DEFAULT '',
+    optimizers INTEGER NOT NULL DEFAULT 0,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (site_id, type, serial_number, name)
+);
+CREATE TABLE IF NOT EXISTS equipment_events (
+    id SERIAL PRIMARY KEY,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    site_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    serial_number TEXT NOT NULL,
+    name TEXT NOT NULL,
+    change TEXT NOT NULL,
+    old TEXT NOT NULL DEFAULT '',
+    new TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS equipment_events_timestamp ON equipment_events (timestamp);
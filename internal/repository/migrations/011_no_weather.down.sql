@@ -0,0 +1 @@
+ALTER TABLE solar DROP COLUMN IF EXISTS no_weather;
@@ -0,0 +1 @@
+ALTER TABLE solar ADD COLUMN IF NOT EXISTS no_weather BOOLEAN NOT NULL DEFAULT FALSE;
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"database/sql"
 	"embed"
 	"errors"
 	"fmt"
@@ -56,18 +57,22 @@ func getDBName(connectionString string) (string, error) {
 	return u.Path[1:], nil
 }
 
+// Store stores the measurement. A measurement without intensity is stored with a NULL intensity.
 func (db *PostgresDB) Store(measurement Measurement) error {
+	intensity := sql.NullFloat64{Float64: measurement.Intensity, Valid: measurement.HasIntensity()}
 	weatherID, err := db.GetWeatherID(measurement.Weather)
 	if err == nil {
-		_, err = db.DBX.Exec(`INSERT INTO solar (timestamp, intensity, power, weatherid) VALUES ($1, $2, $3, $4)`,
-			measurement.Timestamp, measurement.Intensity, measurement.Power, weatherID,
+		_, err = db.DBX.Exec(`INSERT INTO solar (timestamp, intensity, power, weatherid, no_weather) VALUES ($1, $2, $3, $4, $5)`,
+			measurement.Timestamp, intensity, measurement.Power, weatherID, measurement.NoWeather,
 		)
 	}
 	return err
 }
 
+// Get returns the measurements between from and to. Measurements stored without weather info whose intensity hasn't
+// been estimated yet have a NaN intensity: callers that need the intensity should use Measurements.WithIntensity.
 func (db *PostgresDB) Get(from, to time.Time) (Measurements, error) {
-	stmt := "SELECT timestamp, intensity, power, weather FROM solar, weatherids WHERE solar.weatherid = weatherids.id"
+	stmt := "SELECT timestamp, COALESCE(intensity, 'NaN'::float) AS intensity, power, weather, no_weather FROM solar, weatherids WHERE solar.weatherid = weatherids.id"
 	if timeClause := getTimeClause(from, to); timeClause != "" {
 		stmt += " AND " + timeClause
 	}
@@ -77,6 +82,25 @@ func (db *PostgresDB) Get(from, to time.Time) (Measurements, error) {
 	return measurements, err
 }
 
+// GetMissingIntensity returns the measurements between from and to that were stored without a solar intensity.
+// Their intensity is NaN.
+func (db *PostgresDB) GetMissingIntensity(from, to time.Time) (Measurements, error) {
+	stmt := "SELECT timestamp, 'NaN'::float AS intensity, power, weather, no_weather FROM solar, weatherids WHERE solar.weatherid = weatherids.id AND intensity IS NULL"
+	if timeClause := getTimeClause(from, to); timeClause != "" {
+		stmt += " AND " + timeClause
+	}
+	stmt += " ORDER BY timestamp"
+	var measurements Measurements
+	err := db.DBX.Select(&measurements, stmt)
+	return measurements, err
+}
+
+// FillIntensity sets the solar intensity of the measurement at timestamp, if it was stored without intensity.
+func (db *PostgresDB) FillIntensity(timestamp time.Time, intensity float64) error {
+	_, err := db.DBX.Exec(`UPDATE solar SET intensity = $2 WHERE timestamp = $1 AND intensity IS NULL`, timestamp, intensity)
+	return err
+}
+
 func getTimeClause(from, to time.Time) string {
 	conditions := make([]string, 0, 2)
 	if !from.IsZero() {
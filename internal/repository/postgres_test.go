@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
+	"math"
 	"testing"
 	"time"
 )
@@ -82,6 +83,49 @@ func TestStore(t *testing.T) {
 	assert.Equal(t, 4, id)
 }
 
+func TestStore_NoWeather(t *testing.T) {
+	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(c))
+	})
+
+	db, err := repository.NewPostgresDB(connString)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2021, 7, 4, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Store(repository.Measurement{Timestamp: timestamp, Power: 1000, Intensity: 50, Weather: "SUN"}))
+	require.NoError(t, db.Store(repository.Measurement{Timestamp: timestamp.Add(15 * time.Minute), Power: 2000, Intensity: math.NaN(), Weather: repository.UnknownWeather, NoWeather: true}))
+
+	// measurements without intensity are returned with a NaN intensity
+	measurements, err := db.Get(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, measurements, 2)
+	assert.False(t, measurements[0].NoWeather)
+	assert.False(t, measurements[1].HasIntensity())
+	assert.Len(t, measurements.WithIntensity(), 1)
+
+	missing, err := db.GetMissingIntensity(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, missing, 1)
+	assert.Equal(t, 2000.0, missing[0].Power)
+	assert.False(t, missing[0].HasIntensity())
+	assert.True(t, missing[0].NoWeather)
+	assert.Equal(t, repository.UnknownWeather, missing[0].Weather)
+
+	require.NoError(t, db.FillIntensity(missing[0].Timestamp, 60))
+
+	missing, err = db.GetMissingIntensity(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	measurements, err = db.Get(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, measurements, 2)
+	assert.Equal(t, 60.0, measurements[1].Intensity)
+	assert.True(t, measurements[1].NoWeather)
+}
+
 func TestNewPostgresDB_ConnectionString(t *testing.T) {
 	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
 	require.NoError(t, err)
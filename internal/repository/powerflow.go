@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/energy"
+	"time"
+)
+
+// PowerFlow is the average power flow of a site with a meter, in W, during the interval ending at Timestamp.
+type PowerFlow struct {
+	Timestamp   time.Time `db:"timestamp" json:"timestamp"`
+	Production  float64   `db:"production" json:"production"`
+	Consumption float64   `db:"consumption" json:"consumption"`
+	// Grid is the power imported from the grid. Negative if power was exported to the grid.
+	Grid float64 `db:"grid" json:"grid"`
+}
+
+// SelfConsumption returns the produced power that was consumed on site.
+func (p PowerFlow) SelfConsumption() float64 {
+	return max(0, p.Production-max(0, -p.Grid))
+}
+
+type PowerFlows []PowerFlow
+
+// Balance returns the total production, consumption and self-consumption of the power flows.
+//
+// The totals are sums of power, so the power flows are expected to be evenly spaced.
+func (p PowerFlows) Balance() energy.Balance {
+	var balance energy.Balance
+	for _, flow := range p {
+		balance.Production += flow.Production
+		balance.Consumption += flow.Consumption
+		balance.SelfConsumption += flow.SelfConsumption()
+	}
+	return balance
+}
+
+func (db *PostgresDB) StorePowerFlow(powerFlow PowerFlow) error {
+	_, err := db.DBX.Exec(`INSERT INTO power_flows (timestamp, production, consumption, grid) VALUES ($1, $2, $3, $4)`,
+		powerFlow.Timestamp, powerFlow.Production, powerFlow.Consumption, powerFlow.Grid,
+	)
+	return err
+}
+
+func (db *PostgresDB) GetPowerFlows(from, to time.Time) (PowerFlows, error) {
+	stmt := "SELECT timestamp, production, consumption, grid FROM power_flows"
+	if timeClause := getTimeClause(from, to); timeClause != "" {
+		stmt += " WHERE " + timeClause
+	}
+	stmt += " ORDER BY timestamp"
+	var powerFlows PowerFlows
+	err := db.DBX.Select(&powerFlows, stmt)
+	return powerFlows, err
+}
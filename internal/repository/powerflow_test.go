@@ -0,0 +1,49 @@
+package repository_test
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/energy"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"testing"
+	"time"
+)
+
+func TestPowerFlows_Balance(t *testing.T) {
+	flows := repository.PowerFlows{
+		// exporting 2000 of 3000 W
+		{Production: 3000, Consumption: 1000, Grid: -2000},
+		// importing 1000 W
+		{Production: 1000, Consumption: 2000, Grid: 1000},
+	}
+	assert.Equal(t, energy.Balance{Production: 4000, Consumption: 3000, SelfConsumption: 2000}, flows.Balance())
+	assert.Equal(t, energy.Balance{}, repository.PowerFlows{}.Balance())
+}
+
+func TestPowerFlows(t *testing.T) {
+	c, connString, err := testutils.NewTestPostgresDB(t.Context(), "solaredge", "username", "password")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(c))
+	})
+
+	db, err := repository.NewPostgresDB(connString)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.StorePowerFlow(repository.PowerFlow{Timestamp: timestamp, Production: 3000, Consumption: 1000, Grid: -2000}))
+	require.NoError(t, db.StorePowerFlow(repository.PowerFlow{Timestamp: timestamp.Add(15 * time.Minute), Production: 1000, Consumption: 2000, Grid: 1000}))
+
+	powerFlows, err := db.GetPowerFlows(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, powerFlows, 2)
+	assert.Equal(t, timestamp, powerFlows[0].Timestamp.UTC())
+	assert.Equal(t, -2000.0, powerFlows[0].Grid)
+
+	powerFlows, err = db.GetPowerFlows(timestamp.Add(time.Minute), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, powerFlows, 1)
+	assert.Equal(t, 2000.0, powerFlows[0].Consumption)
+}
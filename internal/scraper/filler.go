@@ -0,0 +1,132 @@
+package scraper
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// An IntensityFiller estimates the solar intensity of measurements that were stored without weather info.
+//
+// The intensity is interpolated from the nearest measurements before and after the measurement that do have an intensity.
+// A measurement is only filled once both neighbours are available, i.e. after the weather info is available again.
+type IntensityFiller struct {
+	Repository FillerRepository
+	Logger     *slog.Logger
+	// Interval determines how often missing intensities are filled.
+	Interval time.Duration
+	// MaxGap is the maximum time between a measurement and the measurements its intensity is interpolated from.
+	// Measurements with no neighbours within MaxGap remain without intensity.
+	MaxGap time.Duration
+	// Lookback limits the measurements that are filled to those of the last Lookback, so measurements that can't be
+	// filled aren't retried forever. Zero fills all measurements.
+	Lookback time.Duration
+}
+
+type FillerRepository interface {
+	Get(from, to time.Time) (repository.Measurements, error)
+	GetMissingIntensity(from, to time.Time) (repository.Measurements, error)
+	FillIntensity(timestamp time.Time, intensity float64) error
+}
+
+func (f *IntensityFiller) Run(ctx context.Context) error {
+	f.Logger.Debug("starting intensity filler", "interval", f.Interval)
+	defer f.Logger.Debug("stopped intensity filler")
+
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.Fill(); err != nil {
+			f.Logger.Error("failed to fill missing solar intensity", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fill estimates the intensity of the measurements without intensity stored during the last Lookback.
+func (f *IntensityFiller) Fill() error {
+	var from time.Time
+	if f.Lookback > 0 {
+		from = time.Now().Add(-f.Lookback)
+	}
+	missing, err := f.Repository.GetMissingIntensity(from, time.Time{})
+	if err != nil {
+		return err
+	}
+	var filled int
+	for _, chunk := range f.chunks(missing) {
+		n, err := f.fillChunk(chunk)
+		filled += n
+		if err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		f.Logger.Debug("filled missing solar intensity", "missing", len(missing), "filled", filled)
+	}
+	return nil
+}
+
+// chunks splits the (sorted) missing measurements into chunks that share their neighbours: a new chunk starts when
+// the next measurement is more than twice MaxGap after the previous one.
+func (f *IntensityFiller) chunks(missing repository.Measurements) []repository.Measurements {
+	var chunks []repository.Measurements
+	var first int
+	for i := 1; i <= len(missing); i++ {
+		if i == len(missing) || missing[i].Timestamp.Sub(missing[i-1].Timestamp) > 2*f.MaxGap {
+			chunks = append(chunks, missing[first:i])
+			first = i
+		}
+	}
+	return chunks
+}
+
+// fillChunk estimates the intensity of a chunk of missing measurements from the measurements within MaxGap of the
+// chunk. Returns the number of measurements that were filled.
+func (f *IntensityFiller) fillChunk(missing repository.Measurements) (int, error) {
+	measured, err := f.Repository.Get(missing[0].Timestamp.Add(-f.MaxGap), missing[len(missing)-1].Timestamp.Add(f.MaxGap))
+	if err != nil {
+		return 0, err
+	}
+	measured = measured.WithIntensity()
+	var filled int
+	for _, measurement := range missing {
+		intensity, ok := interpolate(measured, measurement.Timestamp, f.MaxGap)
+		if !ok {
+			continue
+		}
+		if err = f.Repository.FillIntensity(measurement.Timestamp, intensity); err != nil {
+			return filled, err
+		}
+		filled++
+	}
+	return filled, nil
+}
+
+// interpolate returns the intensity at timestamp, interpolated from the sorted measurements immediately before and after
+// timestamp. Returns false if there is no measurement within maxGap on either side.
+func interpolate(measurements repository.Measurements, timestamp time.Time, maxGap time.Duration) (float64, bool) {
+	i, _ := slices.BinarySearchFunc(measurements, timestamp, func(m repository.Measurement, t time.Time) int {
+		return m.Timestamp.Compare(t)
+	})
+	if i == 0 || i == len(measurements) {
+		return 0, false
+	}
+	before, after := measurements[i-1], measurements[i]
+	if timestamp.Sub(before.Timestamp) > maxGap || after.Timestamp.Sub(timestamp) > maxGap {
+		return 0, false
+	}
+	span := after.Timestamp.Sub(before.Timestamp)
+	if span == 0 {
+		return before.Intensity, true
+	}
+	weight := float64(timestamp.Sub(before.Timestamp)) / float64(span)
+	return before.Intensity + weight*(after.Intensity-before.Intensity), true
+}
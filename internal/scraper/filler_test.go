@@ -0,0 +1,126 @@
+package scraper
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestIntensityFiller_Fill(t *testing.T) {
+	start := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	r := fakeFillerRepository{
+		measured: repository.Measurements{
+			{Timestamp: start, Intensity: 40},
+			{Timestamp: start.Add(time.Hour), Intensity: 80},
+			{Timestamp: start.Add(4 * time.Hour), Intensity: 20},
+		},
+		missing: repository.Measurements{
+			{Timestamp: start.Add(15 * time.Minute), Intensity: math.NaN(), NoWeather: true},
+			{Timestamp: start.Add(45 * time.Minute), Intensity: math.NaN(), NoWeather: true},
+			// too far from the nearest measurements
+			{Timestamp: start.Add(150 * time.Minute), Intensity: math.NaN(), NoWeather: true},
+			// no measurement after
+			{Timestamp: start.Add(5 * time.Hour), Intensity: math.NaN(), NoWeather: true},
+		},
+		filled: make(map[time.Time]float64),
+	}
+	f := IntensityFiller{Repository: &r, MaxGap: time.Hour, Logger: discardLogger}
+
+	require.NoError(t, f.Fill())
+	assert.Equal(t, map[time.Time]float64{
+		start.Add(15 * time.Minute): 50,
+		start.Add(45 * time.Minute): 70,
+	}, r.filled)
+	// the measurements are read per chunk
+	assert.Equal(t, 2, r.gets)
+}
+
+func TestIntensityFiller_Fill_Lookback(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	r := fakeFillerRepository{
+		measured: repository.Measurements{
+			{Timestamp: now.Add(-50 * time.Hour), Intensity: 40},
+			{Timestamp: now.Add(-49 * time.Hour), Intensity: 80},
+			{Timestamp: now.Add(-2 * time.Hour), Intensity: 40},
+			{Timestamp: now.Add(-time.Hour), Intensity: 80},
+		},
+		missing: repository.Measurements{
+			// before the lookback window
+			{Timestamp: now.Add(-49*time.Hour - 30*time.Minute), Intensity: math.NaN(), NoWeather: true},
+			{Timestamp: now.Add(-90 * time.Minute), Intensity: math.NaN(), NoWeather: true},
+		},
+		filled: make(map[time.Time]float64),
+	}
+	f := IntensityFiller{Repository: &r, MaxGap: time.Hour, Lookback: 24 * time.Hour, Logger: discardLogger}
+
+	require.NoError(t, f.Fill())
+	assert.Equal(t, map[time.Time]float64{now.Add(-90 * time.Minute): 60}, r.filled)
+	assert.Equal(t, 1, r.gets)
+}
+
+func Test_interpolate(t *testing.T) {
+	start := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	measurements := repository.Measurements{
+		{Timestamp: start, Intensity: 40},
+		{Timestamp: start.Add(time.Hour), Intensity: 80},
+	}
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      float64
+		wantOK    assert.BoolAssertionFunc
+	}{
+		{name: "before first", timestamp: start.Add(-time.Minute), wantOK: assert.False},
+		{name: "after last", timestamp: start.Add(61 * time.Minute), wantOK: assert.False},
+		{name: "between", timestamp: start.Add(30 * time.Minute), want: 60, wantOK: assert.True},
+		{name: "exact", timestamp: start.Add(time.Hour), want: 80, wantOK: assert.True},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := interpolate(measurements, tt.timestamp, time.Hour)
+			tt.wantOK(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+var _ FillerRepository = &fakeFillerRepository{}
+
+type fakeFillerRepository struct {
+	measured repository.Measurements
+	missing  repository.Measurements
+	filled   map[time.Time]float64
+	gets     int
+}
+
+func (f *fakeFillerRepository) Get(from, to time.Time) (repository.Measurements, error) {
+	f.gets++
+	// like the database, Get also returns the measurements without intensity
+	var measurements repository.Measurements
+	for _, m := range slices.Concat(f.measured, f.missing) {
+		if !m.Timestamp.Before(from) && !m.Timestamp.After(to) {
+			measurements = append(measurements, m)
+		}
+	}
+	slices.SortFunc(measurements, func(a, b repository.Measurement) int { return a.Timestamp.Compare(b.Timestamp) })
+	return measurements, nil
+}
+
+func (f *fakeFillerRepository) GetMissingIntensity(from, _ time.Time) (repository.Measurements, error) {
+	var missing repository.Measurements
+	for _, m := range f.missing {
+		if !m.Timestamp.Before(from) {
+			missing = append(missing, m)
+		}
+	}
+	return missing, nil
+}
+
+func (f *fakeFillerRepository) FillIntensity(timestamp time.Time, intensity float64) error {
+	f.filled[timestamp] = intensity
+	return nil
+}
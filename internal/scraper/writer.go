@@ -2,23 +2,39 @@ package scraper
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/clambin/solaredge-monitor/internal/publisher"
 	"github.com/clambin/solaredge-monitor/internal/repository"
 	"github.com/clambin/solaredge-monitor/internal/web/plotters"
 	"github.com/clambin/tado/v2"
 	"log/slog"
+	"math"
+	"sync"
 	"time"
 )
 
 type Writer struct {
 	Store
-	SolarEdge      Publisher[publisher.SolarEdgeUpdate]
+	// Site is the name of the site whose updates are stored. If blank, the first site of each update is stored.
+	Site string
+	// PowerFlows stores the average power flow of each interval, for sites with a meter. If nil, power flows aren't stored.
+	PowerFlows PowerFlowStore
+	// Batteries stores the average battery state of each interval, for sites with batteries. If nil, battery states aren't stored.
+	Batteries BatteryStore
+	SolarEdge Publisher[publisher.SolarEdgeUpdate]
+	// Tado publishes the weather. If nil, no weather is available and measurements are stored without weather.
 	Tado           Publisher[*tado.Weather]
 	Logger         *slog.Logger
 	power          plotters.Sampler
 	solarIntensity plotters.Sampler
 	weatherStates  weatherStates
-	Interval       time.Duration
+	powerFlow      powerFlowSamplers
+	battery        batterySamplers
+	// lastStore is the time the writer last stored its measurements. It is set when the writer starts.
+	lastStore time.Time
+	lock      sync.Mutex
+	Interval  time.Duration
 }
 
 type Publisher[T any] interface {
@@ -30,6 +46,25 @@ type Store interface {
 	Store(repository.Measurement) error
 }
 
+type PowerFlowStore interface {
+	StorePowerFlow(repository.PowerFlow) error
+}
+
+type BatteryStore interface {
+	StoreBatteryState(repository.BatteryState) error
+}
+
+type batterySamplers struct {
+	chargeLevel plotters.Sampler
+	power       plotters.Sampler
+}
+
+type powerFlowSamplers struct {
+	production  plotters.Sampler
+	consumption plotters.Sampler
+	grid        plotters.Sampler
+}
+
 func (w *Writer) Run(ctx context.Context) error {
 	w.Logger.Debug("starting writer", "interval", w.Interval)
 	defer w.Logger.Debug("stopped writer")
@@ -37,12 +72,17 @@ func (w *Writer) Run(ctx context.Context) error {
 	solarEdgeUpdate := w.SolarEdge.Subscribe()
 	defer w.SolarEdge.Unsubscribe(solarEdgeUpdate)
 
-	tadoUpdate := w.Tado.Subscribe()
-	defer w.Tado.Unsubscribe(tadoUpdate)
+	// without Tado, tadoUpdate is nil and never receives an update
+	var tadoUpdate <-chan *tado.Weather
+	if w.Tado != nil {
+		tadoUpdate = w.Tado.Subscribe()
+		defer w.Tado.Unsubscribe(tadoUpdate)
+	}
 
 	ticker := time.NewTicker(w.Interval)
 	defer ticker.Stop()
 
+	w.stored()
 	for {
 		select {
 		case update := <-solarEdgeUpdate:
@@ -50,27 +90,67 @@ func (w *Writer) Run(ctx context.Context) error {
 		case update := <-tadoUpdate:
 			w.processTadoUpdate(update)
 		case <-ticker.C:
+			w.stored()
 			if err := w.store(); err != nil {
 				w.Logger.Error("failed to store update", "err", err)
 			}
+			if err := w.storePowerFlow(); err != nil {
+				w.Logger.Error("failed to store power flow", "err", err)
+			}
+			if err := w.storeBatteryState(); err != nil {
+				w.Logger.Error("failed to store battery state", "err", err)
+			}
 		case <-ctx.Done():
 			w.Logger.Debug("shutting down. saving partial data")
 			if err := w.store(); err != nil {
 				w.Logger.Error("failed to store update", "err", err)
 			}
+			if err := w.storePowerFlow(); err != nil {
+				w.Logger.Error("failed to store power flow", "err", err)
+			}
+			if err := w.storeBatteryState(); err != nil {
+				w.Logger.Error("failed to store battery state", "err", err)
+			}
 			return nil
 		}
 	}
 }
 
 func (w *Writer) processSolarEdgeUpdate(update publisher.SolarEdgeUpdate) {
-	if len(update) > 0 {
-		w.power.Add(update[0].PowerOverview.CurrentPower.Power)
-		w.Logger.Debug("update received", "site", update[0].Name, "count", w.power.Len())
+	site, ok := w.selectSite(update)
+	if !ok {
+		return
+	}
+	w.power.Add(site.PowerOverview.CurrentPower.Power)
+	if powerFlow := site.PowerFlow; powerFlow != nil {
+		w.powerFlow.production.Add(powerFlow.Production)
+		w.powerFlow.consumption.Add(powerFlow.Consumption)
+		w.powerFlow.grid.Add(powerFlow.Grid)
 	}
-	if len(update) > 1 {
-		w.Logger.Debug("only one site is supported. ignoring remaining sites")
+	if storage := site.Storage; storage != nil {
+		w.battery.chargeLevel.Add(storage.ChargeLevel)
+		w.battery.power.Add(storage.Power)
 	}
+	w.Logger.Debug("update received", "site", site.Name, "count", w.power.Len())
+}
+
+// selectSite returns the update of the writer's site.
+func (w *Writer) selectSite(update publisher.SolarEdgeUpdate) (publisher.SiteUpdate, bool) {
+	if w.Site == "" {
+		if len(update) == 0 {
+			return publisher.SiteUpdate{}, false
+		}
+		if len(update) > 1 {
+			w.Logger.Debug("only one site is supported. ignoring remaining sites")
+		}
+		return update[0], true
+	}
+	for _, site := range update {
+		if site.Name == w.Site {
+			return site, true
+		}
+	}
+	return publisher.SiteUpdate{}, false
 }
 
 func (w *Writer) processTadoUpdate(update *tado.Weather) {
@@ -78,11 +158,9 @@ func (w *Writer) processTadoUpdate(update *tado.Weather) {
 	w.weatherStates = append(w.weatherStates, string(*update.WeatherState.Value))
 }
 
+// store stores the median power since the previous call. If no weather info was received, the measurement is stored
+// without solar intensity and flagged, so its intensity can be estimated later.
 func (w *Writer) store() error {
-	if w.solarIntensity.Len() == 0 {
-		w.Logger.Debug("no weather info to store")
-		return nil
-	}
 	if w.power.Len() == 0 {
 		w.Logger.Debug("no power data to store")
 		return nil
@@ -102,10 +180,74 @@ func (w *Writer) store() error {
 	m := repository.Measurement{
 		Timestamp: time.Now(),
 		Power:     power,
-		Intensity: w.solarIntensity.Median(),
-		Weather:   w.weatherStates.mostFrequent(),
+	}
+	if w.solarIntensity.Len() > 0 {
+		m.Intensity = w.solarIntensity.Median()
+		m.Weather = w.weatherStates.mostFrequent()
+	} else {
+		w.Logger.Warn("no weather info. storing measurement without solar intensity")
+		m.Intensity = math.NaN()
+		m.Weather = repository.UnknownWeather
+		m.NoWeather = true
 	}
 
 	w.Logger.Info("storing", "measurement", m)
 	return w.Store.Store(m)
 }
+
+// storePowerFlow stores the average power flow since the previous call. Unlike measurements, power flows don't need
+// weather info and are also stored when there's no production.
+func (w *Writer) storePowerFlow() error {
+	if w.PowerFlows == nil || w.powerFlow.production.Len() == 0 {
+		return nil
+	}
+	defer func() {
+		w.powerFlow.production.Reset()
+		w.powerFlow.consumption.Reset()
+		w.powerFlow.grid.Reset()
+	}()
+	return w.PowerFlows.StorePowerFlow(repository.PowerFlow{
+		Timestamp:   time.Now(),
+		Production:  w.powerFlow.production.Average(),
+		Consumption: w.powerFlow.consumption.Average(),
+		Grid:        w.powerFlow.grid.Average(),
+	})
+}
+
+// storeBatteryState stores the average battery state since the previous call.
+func (w *Writer) storeBatteryState() error {
+	if w.Batteries == nil || w.battery.power.Len() == 0 {
+		return nil
+	}
+	defer func() {
+		w.battery.chargeLevel.Reset()
+		w.battery.power.Reset()
+	}()
+	return w.Batteries.StoreBatteryState(repository.BatteryState{
+		Timestamp:   time.Now(),
+		ChargeLevel: w.battery.chargeLevel.Average(),
+		Power:       w.battery.power.Average(),
+	})
+}
+
+func (w *Writer) stored() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.lastStore = time.Now()
+}
+
+// maxMissedStores is the number of intervals without storing the measurements after which the writer is no longer alive.
+const maxMissedStores = 3
+
+// IsAlive returns an error if the writer isn't running, or hasn't stored its measurements in 3 intervals, i.e. it is stuck.
+func (w *Writer) IsAlive(_ context.Context) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.lastStore.IsZero() {
+		return errors.New("writer not running")
+	}
+	if since := time.Since(w.lastStore); since > maxMissedStores*w.Interval {
+		return fmt.Errorf("writer stored no measurements in %s", since.Round(time.Second))
+	}
+	return nil
+}
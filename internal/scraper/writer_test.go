@@ -5,9 +5,12 @@ import (
 	"github.com/clambin/solaredge-monitor/internal/publisher"
 	"github.com/clambin/solaredge-monitor/internal/repository"
 	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/solaredge/v2"
 	"github.com/clambin/tado/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"log/slog"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -52,6 +55,54 @@ func TestWriter(t *testing.T) {
 	assert.Equal(t, 3000.0, s.measurement.Power)
 }
 
+func TestWriter_NoWeather(t *testing.T) {
+	s := store{}
+	solarUpdate := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+
+	w := Writer{
+		Store:     &s,
+		SolarEdge: solarUpdate,
+		Interval:  10 * time.Millisecond,
+		Logger:    discardLogger,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- w.Run(ctx) }()
+
+	solarUpdate.Ch <- testutils.TestUpdate
+
+	assert.Eventually(t, s.hasData.Load, time.Second, time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, repository.UnknownWeather, s.measurement.Weather)
+	assert.False(t, s.measurement.HasIntensity())
+	assert.True(t, s.measurement.NoWeather)
+	assert.Equal(t, 3000.0, s.measurement.Power)
+}
+
+func TestWriter_IsAlive(t *testing.T) {
+	solarUpdate := testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: make(chan publisher.SolarEdgeUpdate)}
+	w := Writer{
+		Store:     &store{},
+		SolarEdge: solarUpdate,
+		Interval:  10 * time.Millisecond,
+		Logger:    discardLogger,
+	}
+	assert.ErrorContains(t, w.IsAlive(context.Background()), "writer not running")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- w.Run(ctx) }()
+	assert.Eventually(t, func() bool { return w.IsAlive(context.Background()) == nil }, time.Second, time.Millisecond)
+
+	// once the writer stops, it no longer stores measurements
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.Eventually(t, func() bool { return w.IsAlive(context.Background()) != nil }, time.Second, w.Interval)
+}
+
 func TestWriter_store(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -78,10 +129,10 @@ func TestWriter_store(t *testing.T) {
 			hasData: assert.False,
 		},
 		{
-			name:    "no tado update: no update",
+			name:    "no tado update: update without weather",
 			solar:   []publisher.SolarEdgeUpdate{testutils.TestUpdate},
 			tado:    []*tado.Weather{},
-			hasData: assert.False,
+			hasData: assert.True,
 		},
 		{
 			name:  "power: update",
@@ -118,12 +169,67 @@ func TestWriter_store(t *testing.T) {
 	}
 }
 
+func TestWriter_storePowerFlow(t *testing.T) {
+	s := store{}
+	w := Writer{Store: &s, Logger: discardLogger}
+
+	update := slices.Clone(testutils.TestUpdate)
+	update[0].PowerFlow = &publisher.PowerFlow{Production: 3000, Consumption: 1000, Grid: -2000}
+	w.processSolarEdgeUpdate(update)
+	update[0].PowerFlow = &publisher.PowerFlow{Production: 1000, Consumption: 1000, Grid: 0}
+	w.processSolarEdgeUpdate(update)
+
+	// no power flow store: nothing is stored
+	assert.NoError(t, w.storePowerFlow())
+	assert.Empty(t, s.powerFlows)
+
+	w.PowerFlows = &s
+	assert.NoError(t, w.storePowerFlow())
+	require.Len(t, s.powerFlows, 1)
+	assert.Equal(t, 2000.0, s.powerFlows[0].Production)
+	assert.Equal(t, 1000.0, s.powerFlows[0].Consumption)
+	assert.Equal(t, -1000.0, s.powerFlows[0].Grid)
+
+	// samples are reset after storing
+	assert.NoError(t, w.storePowerFlow())
+	assert.Len(t, s.powerFlows, 1)
+}
+
+func TestWriter_storeBatteryState(t *testing.T) {
+	s := store{}
+	w := Writer{Store: &s, Logger: discardLogger}
+
+	update := slices.Clone(testutils.TestUpdate)
+	update[0].Storage = &publisher.StorageUpdate{ChargeLevel: 50, Power: 1000}
+	w.processSolarEdgeUpdate(update)
+	update[0].Storage = &publisher.StorageUpdate{ChargeLevel: 60, Power: -500}
+	w.processSolarEdgeUpdate(update)
+
+	// no battery store: nothing is stored
+	assert.NoError(t, w.storeBatteryState())
+	assert.Empty(t, s.batteryStates)
+
+	w.Batteries = &s
+	assert.NoError(t, w.storeBatteryState())
+	require.Len(t, s.batteryStates, 1)
+	assert.Equal(t, 55.0, s.batteryStates[0].ChargeLevel)
+	assert.Equal(t, 250.0, s.batteryStates[0].Power)
+
+	// samples are reset after storing
+	assert.NoError(t, w.storeBatteryState())
+	assert.Len(t, s.batteryStates, 1)
+}
+
 var _ Store = &store{}
+var _ PowerFlowStore = &store{}
+var _ BatteryStore = &store{}
 
 type store struct {
-	hasData     atomic.Bool
-	lock        sync.Mutex
-	measurement repository.Measurement
+	hasData       atomic.Bool
+	lock          sync.Mutex
+	measurement   repository.Measurement
+	powerFlows    []repository.PowerFlow
+	batteryStates []repository.BatteryState
 }
 
 func (s *store) Store(measurement repository.Measurement) error {
@@ -133,3 +239,37 @@ func (s *store) Store(measurement repository.Measurement) error {
 	s.hasData.Store(true)
 	return nil
 }
+
+func (s *store) StorePowerFlow(powerFlow repository.PowerFlow) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.powerFlows = append(s.powerFlows, powerFlow)
+	return nil
+}
+
+func (s *store) StoreBatteryState(state repository.BatteryState) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.batteryStates = append(s.batteryStates, state)
+	return nil
+}
+
+func TestWriter_processSolarEdgeUpdate_Site(t *testing.T) {
+	update := publisher.SolarEdgeUpdate{
+		{Name: "home", PowerOverview: solaredge.PowerOverview{CurrentPower: solaredge.CurrentPower{Power: 1000}}},
+		{Name: "parents", PowerOverview: solaredge.PowerOverview{CurrentPower: solaredge.CurrentPower{Power: 2000}}},
+	}
+
+	w := Writer{Logger: discardLogger}
+	w.processSolarEdgeUpdate(update)
+	assert.Equal(t, 1000.0, w.power.Median())
+
+	w = Writer{Site: "parents", Logger: discardLogger}
+	w.processSolarEdgeUpdate(update)
+	assert.Equal(t, 2000.0, w.power.Median())
+
+	w = Writer{Site: "unknown", Logger: discardLogger}
+	w.processSolarEdgeUpdate(update)
+	w.processSolarEdgeUpdate(publisher.SolarEdgeUpdate{})
+	assert.Zero(t, w.power.Len())
+}
@@ -0,0 +1,119 @@
+package simulator
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/solar"
+	"github.com/clambin/tado/v2"
+	"iter"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// A Simulator generates synthetic measurements for a site, using a clear-sky irradiance model,
+// combined with randomly changing cloud cover and weather states.
+type Simulator struct {
+	Start     time.Time
+	End       time.Time
+	Latitude  float64
+	Longitude float64
+	// PeakPower is the capacity of the panels in Watt
+	PeakPower float64
+	Interval  time.Duration
+	Seed      uint64
+}
+
+const (
+	// clear-sky irradiance at sea level for a sun in the zenith, in W/m²
+	maxIrradiance = 1000
+	// ratio of the produced power versus the theoretical power of the panels (cable losses, inverter efficiency, etc.)
+	performanceRatio = 0.85
+)
+
+// Measurements returns the generated measurements. Measurements with no power (i.e. at night) are skipped.
+func (s Simulator) Measurements() iter.Seq[repository.Measurement] {
+	return func(yield func(repository.Measurement) bool) {
+		interval := s.Interval
+		if interval <= 0 {
+			interval = 15 * time.Minute
+		}
+		w := weather{rand: rand.New(rand.NewPCG(s.Seed, s.Seed))}
+		for timestamp := s.Start; !timestamp.After(s.End); timestamp = timestamp.Add(interval) {
+			w.advance(timestamp, interval)
+			irradiance := clearSkyIrradiance(timestamp, s.Latitude, s.Longitude) * w.transmittance()
+			power := math.Round(min(s.PeakPower*irradiance/maxIrradiance*performanceRatio*w.noise(), s.PeakPower))
+			if power <= 0 {
+				continue
+			}
+			m := repository.Measurement{
+				Timestamp: timestamp,
+				Power:     power,
+				Intensity: math.Round(min(100*irradiance/maxIrradiance, 100)),
+				Weather:   string(w.state()),
+			}
+			if !yield(m) {
+				return
+			}
+		}
+	}
+}
+
+// clearSkyIrradiance returns the global horizontal irradiance (in W/m²) for a cloudless sky, using the Haurwitz model.
+func clearSkyIrradiance(timestamp time.Time, latitude, longitude float64) float64 {
+	cosZenith := math.Sin(solar.Elevation(timestamp, latitude, longitude))
+	if cosZenith <= 0 {
+		return 0
+	}
+	return 1098 * cosZenith * math.Exp(-0.059/cosZenith)
+}
+
+// weather models the cloud cover as a random walk around a daily target, plus the occasional rain shower.
+type weather struct {
+	rand       *rand.Rand
+	day        time.Time
+	target     float64
+	cloudCover float64
+	raining    bool
+}
+
+func (w *weather) advance(timestamp time.Time, interval time.Duration) {
+	if day := timestamp.Truncate(24 * time.Hour); !day.Equal(w.day) {
+		w.day = day
+		w.target = w.rand.Float64()
+	}
+	steps := interval.Hours() * 4
+	w.cloudCover += 0.3*(w.target-w.cloudCover)*min(steps, 1) + w.rand.NormFloat64()*0.1*math.Sqrt(steps)
+	w.cloudCover = min(max(w.cloudCover, 0), 1)
+	w.raining = w.cloudCover > 0.7 && w.rand.Float64() < w.cloudCover-0.5
+}
+
+// transmittance returns the fraction of the clear-sky irradiance that passes through the clouds (Kasten & Czeplak).
+func (w *weather) transmittance() float64 {
+	t := 1 - 0.75*math.Pow(w.cloudCover, 3.4)
+	if w.raining {
+		t *= 0.6
+	}
+	return t
+}
+
+// noise adds some small random variation to the produced power.
+func (w *weather) noise() float64 {
+	return 1 + w.rand.NormFloat64()*0.02
+}
+
+func (w *weather) state() tado.WeatherState {
+	switch {
+	case w.raining && w.cloudCover > 0.9:
+		return tado.RAIN
+	case w.raining:
+		return tado.DRIZZLE
+	case w.cloudCover < 0.2:
+		return tado.SUN
+	case w.cloudCover < 0.5:
+		return tado.CLOUDYPARTLY
+	case w.cloudCover < 0.8:
+		return tado.CLOUDYMOSTLY
+	default:
+		return tado.CLOUDY
+	}
+}
@@ -0,0 +1,97 @@
+package simulator
+
+import (
+	"bytes"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulator_Measurements(t *testing.T) {
+	s := Simulator{
+		Start:     time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		End:       time.Date(2024, time.June, 7, 23, 59, 0, 0, time.UTC),
+		Latitude:  51,
+		Longitude: 4.4,
+		PeakPower: 4000,
+		Interval:  15 * time.Minute,
+		Seed:      42,
+	}
+
+	var count int
+	for m := range s.Measurements() {
+		count++
+		assert.Positive(t, m.Power)
+		assert.LessOrEqual(t, m.Power, s.PeakPower)
+		assert.GreaterOrEqual(t, m.Intensity, 0.0)
+		assert.LessOrEqual(t, m.Intensity, 100.0)
+		assert.NotEmpty(t, m.Weather)
+		// no power produced at night
+		hour := m.Timestamp.Hour()
+		assert.True(t, hour > 2 && hour < 21, m.Timestamp)
+	}
+	// roughly 16 hours of daylight per day
+	assert.InDelta(t, 7*16*4, count, 7*2*4)
+
+	// same seed generates the same measurements
+	first := collect(s)
+	assert.Equal(t, first, collect(s))
+	s.Seed++
+	assert.NotEqual(t, first, collect(s))
+}
+
+func collect(s Simulator) repository.Measurements {
+	var measurements repository.Measurements
+	for m := range s.Measurements() {
+		measurements = append(measurements, m)
+	}
+	return measurements
+}
+
+func Test_clearSkyIrradiance(t *testing.T) {
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      float64
+	}{
+		{"summer noon", time.Date(2024, time.June, 21, 12, 0, 0, 0, time.UTC), 910},
+		{"winter noon", time.Date(2024, time.December, 21, 12, 0, 0, 0, time.UTC), 260},
+		{"midnight", time.Date(2024, time.June, 21, 0, 0, 0, 0, time.UTC), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, clearSkyIrradiance(tt.timestamp, 51, 0), 50)
+		})
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf)
+	measurements := func(yield func(repository.Measurement) bool) {
+		for i := range 2 {
+			if !yield(repository.Measurement{
+				Timestamp: time.Date(2024, time.June, 1, 12, 15*i, 0, 0, time.UTC),
+				Power:     1000.5,
+				Intensity: 50,
+				Weather:   "SUN",
+			}) {
+				return
+			}
+		}
+	}
+	count, err := Write(measurements, w)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, strings.Join([]string{
+		"timestamp,power,intensity,weather",
+		"2024-06-01T12:00:00Z,1000.5,50,SUN",
+		"2024-06-01T12:15:00Z,1000.5,50,SUN",
+		"",
+	}, "\n"), buf.String())
+}
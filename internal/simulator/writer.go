@@ -0,0 +1,58 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"io"
+	"iter"
+	"strconv"
+	"time"
+)
+
+type Store interface {
+	Store(repository.Measurement) error
+}
+
+// Write stores all measurements in the Store and returns the number of measurements written.
+func Write(measurements iter.Seq[repository.Measurement], store Store) (int, error) {
+	var count int
+	for m := range measurements {
+		if err := store.Store(m); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+var _ Store = &CSVWriter{}
+
+// CSVWriter writes measurements as CSV records. Call Flush to write any buffered data to the underlying io.Writer.
+type CSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+func (c *CSVWriter) Store(m repository.Measurement) error {
+	if !c.headerWritten {
+		if err := c.w.Write([]string{"timestamp", "power", "intensity", "weather"}); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+	return c.w.Write([]string{
+		m.Timestamp.Format(time.RFC3339),
+		strconv.FormatFloat(m.Power, 'f', -1, 64),
+		strconv.FormatFloat(m.Intensity, 'f', -1, 64),
+		m.Weather,
+	})
+}
+
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,34 @@
+// Package solar calculates the position of the sun.
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// Elevation returns the angle of the sun above the horizon, in radians, for a location on earth.
+func Elevation(timestamp time.Time, latitude, longitude float64) float64 {
+	timestamp = timestamp.UTC()
+	day := float64(timestamp.YearDay())
+	declination := radians(23.45) * math.Sin(radians(360.0/365*(284+day)))
+
+	// equation of time, in minutes
+	b := radians(360.0 / 365 * (day - 81))
+	eot := 9.87*math.Sin(2*b) - 7.53*math.Cos(b) - 1.5*math.Sin(b)
+
+	hh, mm, ss := timestamp.Clock()
+	solarTime := float64(hh*60+mm) + float64(ss)/60 + 4*longitude + eot
+	hourAngle := radians((solarTime/60 - 12) * 15)
+
+	lat := radians(latitude)
+	return math.Asin(math.Sin(lat)*math.Sin(declination) + math.Cos(lat)*math.Cos(declination)*math.Cos(hourAngle))
+}
+
+// IsDaylight returns true if the sun is above the horizon.
+func IsDaylight(timestamp time.Time, latitude, longitude float64) bool {
+	return Elevation(timestamp, latitude, longitude) > 0
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
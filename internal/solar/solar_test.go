@@ -0,0 +1,34 @@
+package solar
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestElevation(t *testing.T) {
+	// at the equinox, the sun's elevation at solar noon is 90° minus the latitude
+	elevation := Elevation(time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC), 51, 0)
+	assert.InDelta(t, 39, elevation*180/math.Pi, 1.5)
+}
+
+func TestIsDaylight(t *testing.T) {
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      bool
+	}{
+		{"summer noon", time.Date(2024, time.June, 21, 12, 0, 0, 0, time.UTC), true},
+		{"summer evening", time.Date(2024, time.June, 21, 20, 0, 0, 0, time.UTC), true},
+		{"summer night", time.Date(2024, time.June, 21, 23, 0, 0, 0, time.UTC), false},
+		{"winter morning", time.Date(2024, time.December, 21, 7, 0, 0, 0, time.UTC), false},
+		{"winter noon", time.Date(2024, time.December, 21, 12, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, IsDaylight(tt.timestamp, 51, 0))
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Package tariff determines the financial value of the installation's production from the electricity tariffs.
+package tariff
+
+import (
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"slices"
+	"time"
+)
+
+// A Schedule holds the tariffs that applied over time.
+//
+// Production is valued as follows: the SelfConsumption fraction of the energy is consumed on site and saves the import
+// price. The remainder is exported and earns the feed-in tariff.
+type Schedule struct {
+	Currency string
+	Tariffs  []Tariff
+	// SelfConsumption is the fraction of the production that's consumed on site, between 0 and 1.
+	SelfConsumption float64
+	// InstallationCost is the cost of the installation, in Currency. Zero if unknown.
+	InstallationCost float64
+}
+
+// A Tariff holds the prices, per kWh, that apply from Effective until the next Tariff becomes effective.
+type Tariff struct {
+	Effective time.Time
+	// Import holds the time-of-use prices of imported energy. The first Rate that applies at a given time determines the price.
+	Import []Rate
+	// FeedIn is the price paid for exported energy.
+	FeedIn float64
+}
+
+// A Rate is the price of imported energy during part of the day.
+type Rate struct {
+	Price float64
+	// From and To are the start and end of the rate, as the time since midnight. If To isn't after From, the rate
+	// runs past midnight. If both are zero, the rate applies all day.
+	From time.Duration
+	To   time.Duration
+	// Days on which the rate applies. If empty, the rate applies every day.
+	Days []time.Weekday
+}
+
+// Validate checks that the schedule is complete and consistent.
+func (s Schedule) Validate() error {
+	if len(s.Tariffs) == 0 {
+		return errors.New("no tariffs")
+	}
+	if s.SelfConsumption < 0 || s.SelfConsumption > 1 {
+		return fmt.Errorf("invalid self-consumption %v: must be between 0 and 1", s.SelfConsumption)
+	}
+	effective := make(map[time.Time]struct{}, len(s.Tariffs))
+	for _, tariff := range s.Tariffs {
+		if tariff.Effective.IsZero() {
+			return errors.New("tariff has no effective date")
+		}
+		if _, ok := effective[tariff.Effective]; ok {
+			return fmt.Errorf("multiple tariffs effective on %s", tariff.Effective.Format(time.DateOnly))
+		}
+		effective[tariff.Effective] = struct{}{}
+		for _, rate := range tariff.Import {
+			if rate.From < 0 || rate.From >= 24*time.Hour || rate.To < 0 || rate.To > 24*time.Hour {
+				return fmt.Errorf("tariff effective on %s: invalid rate period %v-%v", tariff.Effective.Format(time.DateOnly), rate.From, rate.To)
+			}
+		}
+	}
+	return nil
+}
+
+// Tariff returns the tariff that applies at time t. Returns false if no tariff was effective yet.
+func (s Schedule) Tariff(t time.Time) (Tariff, bool) {
+	var current Tariff
+	var found bool
+	for _, tariff := range s.Tariffs {
+		if !tariff.Effective.After(t) && (!found || tariff.Effective.After(current.Effective)) {
+			current, found = tariff, true
+		}
+	}
+	return current, found
+}
+
+// ImportPrice returns the price of imported energy at time t. Returns zero if no Rate applies.
+func (t Tariff) ImportPrice(at time.Time) float64 {
+	for _, rate := range t.Import {
+		if rate.applies(at) {
+			return rate.Price
+		}
+	}
+	return 0
+}
+
+func (r Rate) applies(t time.Time) bool {
+	t = t.Local()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	sinceMidnight := t.Sub(midnight)
+	day := t.Weekday()
+	var inPeriod bool
+	switch {
+	case r.From == 0 && r.To == 0:
+		inPeriod = true
+	case r.From < r.To:
+		inPeriod = sinceMidnight >= r.From && sinceMidnight < r.To
+	default:
+		// the rate runs past midnight: after midnight, it belongs to the rate that started the day before
+		inPeriod = sinceMidnight >= r.From
+		if sinceMidnight < r.To {
+			inPeriod = true
+			day = (day + 6) % 7
+		}
+	}
+	return inPeriod && (len(r.Days) == 0 || slices.Contains(r.Days, day))
+}
+
+// Value returns the value of energy (in Wh) produced at time t. Returns zero if no tariff applies.
+func (s Schedule) Value(t time.Time, energy float64) float64 {
+	tariff, ok := s.Tariff(t)
+	if !ok {
+		return 0
+	}
+	kWh := energy / 1000
+	return kWh * (s.SelfConsumption*tariff.ImportPrice(t) + (1-s.SelfConsumption)*tariff.FeedIn)
+}
+
+// A Period is the production, and its value, during a day or a month.
+type Period struct {
+	Start time.Time `json:"start"`
+	// Energy is the energy produced, in kWh.
+	Energy float64 `json:"energy"`
+	// Value is the value of the energy, in the schedule's currency.
+	Value float64 `json:"value"`
+}
+
+// Daily returns the production and its value for each day in the (chronologically ordered) measurements.
+func (s Schedule) Daily(measurements repository.Measurements) []Period {
+	return s.summarise(measurements, func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	})
+}
+
+// Monthly returns the production and its value for each month in the (chronologically ordered) measurements.
+func (s Schedule) Monthly(measurements repository.Measurements) []Period {
+	return s.summarise(measurements, func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	})
+}
+
+func (s Schedule) summarise(measurements repository.Measurements, start func(time.Time) time.Time) []Period {
+	var periods []Period
+	for i, m := range measurements {
+		periodStart := start(m.Timestamp.Local())
+		if len(periods) == 0 || !periods[len(periods)-1].Start.Equal(periodStart) {
+			periods = append(periods, Period{Start: periodStart})
+		}
+		energy := m.Power * analytics.SampleDuration(measurements, i).Hours()
+		period := &periods[len(periods)-1]
+		period.Energy += energy / 1000
+		period.Value += s.Value(m.Timestamp, energy)
+	}
+	return periods
+}
+
+// A Report summarises the value of the production over a number of periods.
+type Report struct {
+	Currency string   `json:"currency"`
+	Periods  []Period `json:"periods"`
+	// Total is the production and value over all periods. Total.Start is the start of the first period.
+	Total            Period  `json:"total"`
+	InstallationCost float64 `json:"installationCost,omitempty"`
+	// Payback is the fraction of the installation cost recovered by the production's value. Zero if the cost is unknown.
+	Payback float64 `json:"payback,omitempty"`
+}
+
+// NewReport summarises the periods.
+func (s Schedule) NewReport(periods []Period) Report {
+	report := Report{Currency: s.Currency, Periods: periods, InstallationCost: s.InstallationCost}
+	for i, period := range periods {
+		if i == 0 {
+			report.Total.Start = period.Start
+		}
+		report.Total.Energy += period.Energy
+		report.Total.Value += period.Value
+	}
+	if s.InstallationCost > 0 {
+		report.Payback = report.Total.Value / s.InstallationCost
+	}
+	return report
+}
@@ -0,0 +1,148 @@
+package tariff
+
+import (
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// testSchedule has a day/night import tariff from 2024 and a higher one from 2025, with a weekend discount.
+var testSchedule = Schedule{
+	Currency: "EUR",
+	Tariffs: []Tariff{
+		{
+			Effective: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.Local),
+			Import: []Rate{
+				{Price: 0.20, Days: []time.Weekday{time.Saturday, time.Sunday}},
+				{Price: 0.40, From: 7 * time.Hour, To: 23 * time.Hour},
+				{Price: 0.30},
+			},
+			FeedIn: 0.05,
+		},
+		{
+			Effective: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local),
+			Import: []Rate{
+				{Price: 0.20, From: 23 * time.Hour, To: 7 * time.Hour},
+				{Price: 0.30, From: 7 * time.Hour, To: 23 * time.Hour},
+			},
+			FeedIn: 0.10,
+		},
+	},
+	SelfConsumption:  0.5,
+	InstallationCost: 1000,
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		wantErr  string
+	}{
+		{name: "valid", schedule: testSchedule},
+		{name: "no tariffs", schedule: Schedule{}, wantErr: "no tariffs"},
+		{
+			name:     "invalid self-consumption",
+			schedule: Schedule{Tariffs: testSchedule.Tariffs, SelfConsumption: 1.5},
+			wantErr:  "invalid self-consumption 1.5: must be between 0 and 1",
+		},
+		{
+			name:     "no effective date",
+			schedule: Schedule{Tariffs: []Tariff{{FeedIn: 0.1}}},
+			wantErr:  "tariff has no effective date",
+		},
+		{
+			name:     "duplicate effective date",
+			schedule: Schedule{Tariffs: []Tariff{testSchedule.Tariffs[0], testSchedule.Tariffs[0]}},
+			wantErr:  "multiple tariffs effective on 2025-01-01",
+		},
+		{
+			name: "invalid rate",
+			schedule: Schedule{Tariffs: []Tariff{{
+				Effective: testSchedule.Tariffs[0].Effective,
+				Import:    []Rate{{From: 25 * time.Hour}},
+			}}},
+			wantErr: "tariff effective on 2025-01-01: invalid rate period 25h0m0s-0s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSchedule_Value(t *testing.T) {
+	tests := []struct {
+		name string
+		time time.Time
+		want float64
+	}{
+		{name: "before first tariff", time: time.Date(2023, time.December, 31, 12, 0, 0, 0, time.Local), want: 0},
+		{name: "2024 day", time: time.Date(2024, time.June, 3, 12, 0, 0, 0, time.Local), want: 0.5*0.30 + 0.5*0.10},
+		{name: "2024 night, before midnight", time: time.Date(2024, time.June, 3, 23, 30, 0, 0, time.Local), want: 0.5*0.20 + 0.5*0.10},
+		{name: "2024 night, after midnight", time: time.Date(2024, time.June, 4, 6, 0, 0, 0, time.Local), want: 0.5*0.20 + 0.5*0.10},
+		{name: "2025 weekday", time: time.Date(2025, time.June, 4, 12, 0, 0, 0, time.Local), want: 0.5*0.40 + 0.5*0.05},
+		{name: "2025 weekday night", time: time.Date(2025, time.June, 4, 23, 30, 0, 0, time.Local), want: 0.5*0.30 + 0.5*0.05},
+		{name: "2025 weekend", time: time.Date(2025, time.June, 7, 12, 0, 0, 0, time.Local), want: 0.5*0.20 + 0.5*0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1 kWh
+			assert.InDelta(t, tt.want, testSchedule.Value(tt.time, 1000), 1e-9)
+		})
+	}
+}
+
+func TestRate_applies(t *testing.T) {
+	// Friday night rate: applies Friday from 22:00 until Saturday 06:00
+	rate := Rate{From: 22 * time.Hour, To: 6 * time.Hour, Days: []time.Weekday{time.Friday}}
+	friday := time.Date(2025, time.June, 6, 0, 0, 0, 0, time.Local)
+
+	assert.False(t, rate.applies(friday.Add(3*time.Hour)))
+	assert.False(t, rate.applies(friday.Add(21*time.Hour)))
+	assert.True(t, rate.applies(friday.Add(23*time.Hour)))
+	assert.True(t, rate.applies(friday.Add(27*time.Hour)))
+	assert.False(t, rate.applies(friday.Add(30*time.Hour)))
+}
+
+func TestSchedule_Daily_Monthly(t *testing.T) {
+	// 1 kW for 4 hours on two days in June and one day in July
+	var measurements repository.Measurements
+	for _, day := range []time.Time{
+		time.Date(2024, time.June, 3, 10, 0, 0, 0, time.Local),
+		time.Date(2024, time.June, 4, 10, 0, 0, 0, time.Local),
+		time.Date(2024, time.July, 1, 10, 0, 0, 0, time.Local),
+	} {
+		for i := range 4 {
+			measurements = append(measurements, repository.Measurement{Timestamp: day.Add(time.Duration(i) * time.Hour), Power: 1000})
+		}
+	}
+	value := 4 * (0.5*0.30 + 0.5*0.10)
+
+	daily := testSchedule.Daily(measurements)
+	if assert.Len(t, daily, 3) {
+		assert.Equal(t, time.Date(2024, time.June, 4, 0, 0, 0, 0, time.Local), daily[1].Start)
+		assert.InDelta(t, 4, daily[1].Energy, 1e-9)
+		assert.InDelta(t, value, daily[1].Value, 1e-9)
+	}
+
+	monthly := testSchedule.Monthly(measurements)
+	if assert.Len(t, monthly, 2) {
+		assert.Equal(t, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.Local), monthly[0].Start)
+		assert.InDelta(t, 8, monthly[0].Energy, 1e-9)
+		assert.InDelta(t, 2*value, monthly[0].Value, 1e-9)
+	}
+
+	report := testSchedule.NewReport(monthly)
+	assert.Equal(t, "EUR", report.Currency)
+	assert.Equal(t, monthly[0].Start, report.Total.Start)
+	assert.InDelta(t, 12, report.Total.Energy, 1e-9)
+	assert.InDelta(t, 3*value, report.Total.Value, 1e-9)
+	assert.InDelta(t, 3*value/1000, report.Payback, 1e-9)
+}
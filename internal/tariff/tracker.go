@@ -0,0 +1,136 @@
+package tariff
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/prometheus/client_golang/prometheus"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Publisher[T any] interface {
+	Subscribe() <-chan T
+	Unsubscribe(<-chan T)
+}
+
+// A Tracker values the production reported by SolarEdge, as it's produced.
+//
+// The Tracker values the increase in each site's day and month energy since the previous update at the price that
+// applies at the time of the update. The energy already produced when the Tracker receives its first update (e.g. after
+// a restart) is valued at the current price.
+type Tracker struct {
+	SolarEdge Publisher[publisher.SolarEdgeUpdate]
+	Schedule  Schedule
+	Logger    *slog.Logger
+	sites     map[int]*siteValue
+	lock      sync.Mutex
+}
+
+type siteValue struct {
+	name  string
+	day   energyValue
+	month energyValue
+}
+
+// energyValue tracks the value of the energy produced since start.
+type energyValue struct {
+	start  time.Time
+	energy float64
+	value  float64
+}
+
+func (v *energyValue) update(schedule Schedule, now, start time.Time, energy float64) {
+	if !v.start.Equal(start) {
+		*v = energyValue{start: start}
+	}
+	delta := energy - v.energy
+	if delta < 0 {
+		// SolarEdge reset its counter
+		delta = energy
+	}
+	v.energy = energy
+	v.value += schedule.Value(now, delta)
+}
+
+var (
+	importPriceMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "tariff", "import_price"),
+		"Current price of imported energy per kWh",
+		[]string{"currency"},
+		nil,
+	)
+	feedInPriceMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "tariff", "feed_in_price"),
+		"Current price paid for exported energy per kWh",
+		[]string{"currency"},
+		nil,
+	)
+	productionValueMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("solaredge", "production", "value"),
+		"Value of the energy produced during the current day or month",
+		[]string{"site", "siteid", "period", "currency"},
+		nil,
+	)
+)
+
+func (t *Tracker) Run(ctx context.Context) error {
+	ch := t.SolarEdge.Subscribe()
+	defer t.SolarEdge.Unsubscribe(ch)
+
+	t.Logger.Debug("starting tariff tracker")
+	defer t.Logger.Debug("stopped tariff tracker")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update := <-ch:
+			t.update(time.Now(), update)
+		}
+	}
+}
+
+func (t *Tracker) update(now time.Time, update publisher.SolarEdgeUpdate) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.sites == nil {
+		t.sites = make(map[int]*siteValue)
+	}
+	local := now.Local()
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+	month := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.Local)
+	for _, siteUpdate := range update {
+		site, ok := t.sites[siteUpdate.ID]
+		if !ok {
+			site = &siteValue{}
+			t.sites[siteUpdate.ID] = site
+		}
+		site.name = siteUpdate.Name
+		site.day.update(t.Schedule, now, day, siteUpdate.PowerOverview.LastDayData.Energy)
+		site.month.update(t.Schedule, now, month, siteUpdate.PowerOverview.LastMonthData.Energy)
+	}
+}
+
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- importPriceMetric
+	ch <- feedInPriceMetric
+	ch <- productionValueMetric
+}
+
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	if tariff, ok := t.Schedule.Tariff(now); ok {
+		ch <- prometheus.MustNewConstMetric(importPriceMetric, prometheus.GaugeValue, tariff.ImportPrice(now), t.Schedule.Currency)
+		ch <- prometheus.MustNewConstMetric(feedInPriceMetric, prometheus.GaugeValue, tariff.FeedIn, t.Schedule.Currency)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for id, site := range t.sites {
+		siteID := strconv.Itoa(id)
+		ch <- prometheus.MustNewConstMetric(productionValueMetric, prometheus.GaugeValue, site.day.value, site.name, siteID, "day", t.Schedule.Currency)
+		ch <- prometheus.MustNewConstMetric(productionValueMetric, prometheus.GaugeValue, site.month.value, site.name, siteID, "month", t.Schedule.Currency)
+	}
+}
@@ -0,0 +1,77 @@
+package tariff
+
+import (
+	"context"
+	"github.com/clambin/solaredge-monitor/internal/publisher"
+	"github.com/clambin/solaredge-monitor/internal/testutils"
+	"github.com/clambin/solaredge/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker_update(t *testing.T) {
+	tracker := Tracker{Schedule: testSchedule, Logger: slog.New(slog.DiscardHandler)}
+	update := func(dayEnergy, monthEnergy float64) publisher.SolarEdgeUpdate {
+		return publisher.SolarEdgeUpdate{{
+			ID:   1,
+			Name: "foo",
+			PowerOverview: solaredge.PowerOverview{
+				LastDayData:   solaredge.EnergyOverview{Energy: dayEnergy},
+				LastMonthData: solaredge.EnergyOverview{Energy: monthEnergy},
+			},
+		}}
+	}
+	// weekday: 0.5*0.40 + 0.5*0.05 per kWh during the day; 0.5*0.30 + 0.5*0.05 at night
+	dayValue, nightValue := 0.225, 0.175
+
+	morning := time.Date(2025, time.June, 4, 6, 0, 0, 0, time.Local)
+	tracker.update(morning, update(1000, 10000))
+	assert.InDelta(t, nightValue, tracker.sites[1].day.value, 1e-9)
+	assert.InDelta(t, 10*nightValue, tracker.sites[1].month.value, 1e-9)
+
+	tracker.update(morning.Add(6*time.Hour), update(3000, 12000))
+	assert.InDelta(t, nightValue+2*dayValue, tracker.sites[1].day.value, 1e-9)
+	assert.InDelta(t, 10*nightValue+2*dayValue, tracker.sites[1].month.value, 1e-9)
+
+	// next day: day value starts over
+	tracker.update(morning.Add(30*time.Hour), update(1000, 13000))
+	assert.InDelta(t, dayValue, tracker.sites[1].day.value, 1e-9)
+	assert.InDelta(t, 10*nightValue+3*dayValue, tracker.sites[1].month.value, 1e-9)
+}
+
+func TestTracker_Run(t *testing.T) {
+	ch := make(chan publisher.SolarEdgeUpdate)
+	tracker := Tracker{
+		SolarEdge: testutils.FakePublisher[publisher.SolarEdgeUpdate]{Ch: ch},
+		Schedule:  Schedule{Currency: "EUR", Tariffs: []Tariff{{Effective: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local), Import: []Rate{{Price: 0.3}}, FeedIn: 0.5}}},
+		Logger:    slog.New(slog.DiscardHandler),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- tracker.Run(ctx) }()
+	ch <- testutils.TestUpdate
+
+	// no self-consumption: all energy is valued at the feed-in tariff
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCount(&tracker, "solaredge_production_value") == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, testutil.CollectAndCompare(&tracker, strings.NewReader(`
+# HELP solaredge_production_value Value of the energy produced during the current day or month
+# TYPE solaredge_production_value gauge
+solaredge_production_value{currency="EUR",period="day",site="foo",siteid="1"} 0.005
+solaredge_production_value{currency="EUR",period="month",site="foo",siteid="1"} 0.05
+# HELP solaredge_tariff_feed_in_price Current price paid for exported energy per kWh
+# TYPE solaredge_tariff_feed_in_price gauge
+solaredge_tariff_feed_in_price{currency="EUR"} 0.5
+# HELP solaredge_tariff_import_price Current price of imported energy per kWh
+# TYPE solaredge_tariff_import_price gauge
+solaredge_tariff_import_price{currency="EUR"} 0.3
+`)))
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
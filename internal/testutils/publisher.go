@@ -3,6 +3,7 @@ package testutils
 import (
 	"github.com/clambin/solaredge-monitor/internal/publisher"
 	"github.com/clambin/solaredge/v2"
+	"time"
 )
 
 type FakePublisher[T any] struct {
@@ -19,8 +20,9 @@ func (f FakePublisher[T]) Unsubscribe(_ <-chan T) {
 var (
 	TestUpdate = publisher.SolarEdgeUpdate{
 		{
-			ID:   1,
-			Name: "foo",
+			ID:      1,
+			Name:    "foo",
+			Details: testSiteDetails(),
 			PowerOverview: solaredge.PowerOverview{
 				LastYearData:  solaredge.EnergyOverview{Energy: 1000},
 				LastMonthData: solaredge.EnergyOverview{Energy: 100},
@@ -31,6 +33,8 @@ var (
 				{
 					Name:         "inv1",
 					SerialNumber: "1234",
+					Manufacturer: "SolarEdge",
+					Model:        "SE4000H",
 					Telemetry: solaredge.InverterTelemetry{
 						L1Data: struct {
 							AcCurrent     float64 `json:"acCurrent"`
@@ -49,8 +53,13 @@ var (
 							CosPhi        float64
 							ReactivePower float64
 						}{
-							AcCurrent: 10,
-							AcVoltage: 240,
+							AcCurrent:     10,
+							AcFrequency:   50,
+							AcVoltage:     240,
+							ActivePower:   2400,
+							ApparentPower: 2500,
+							CosPhi:        0.96,
+							ReactivePower: 700,
 						}),
 						DcVoltage:        400,
 						PowerLimit:       1,
@@ -76,3 +85,16 @@ var (
 		},
 	}
 )
+
+func testSiteDetails() solaredge.SiteDetails {
+	details := solaredge.SiteDetails{
+		Id:               1,
+		Name:             "foo",
+		PeakPower:        4.5,
+		InstallationDate: solaredge.Date(time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	details.Location.Country = "Belgium"
+	details.Location.City = "Brussels"
+	details.Location.TimeZone = "Europe/Brussels"
+	return details
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/forecast"
+	"log/slog"
+	"net/http"
+)
+
+// PerformanceHandler returns the expected power and performance ratio of each measurement between start and end.
+// Measurements for which the performance ratio can't be determined are left out.
+func PerformanceHandler(repo Repository, performance PerformanceModel, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseReportArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start.IsZero() || end.IsZero() {
+			http.Error(w, "start/end cannot be zero", http.StatusBadRequest)
+			return
+		}
+
+		model := performance.Model()
+		if model == nil {
+			http.Error(w, "performance model not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		measurements, err := repo.Get(start, end)
+		if err != nil {
+			logger.Error("failed to get measurements from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(model.Evaluate(measurements)); err != nil {
+			logger.Error("failed to encode response", "err", err)
+		}
+	})
+}
+
+// ForecastSource provides the production forecast.
+type ForecastSource interface {
+	// Forecast returns the current forecast. Returns nil if no forecast is available yet.
+	Forecast() []forecast.Hour
+}
+
+// ForecastHandler returns the hourly production forecast.
+func ForecastHandler(source ForecastSource, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hours := source.Forecast()
+		if hours == nil {
+			http.Error(w, "forecast not available", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(hours); err != nil {
+			logger.Error("failed to encode response", "err", err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"github.com/clambin/solaredge-monitor/internal/forecast"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/clambin/solaredge-monitor/internal/web/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPerformanceHandler(t *testing.T) {
+	validArgs := url.Values{
+		"start": []string{time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)},
+		"end":   []string{time.Date(2024, time.July, 2, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)},
+	}
+	measurements := repository.Measurements{
+		{Timestamp: time.Date(2024, time.July, 1, 12, 0, 0, 0, time.Local), Power: 1000, Intensity: 50, Weather: "SUN"},
+		{Timestamp: time.Date(2024, time.July, 1, 3, 0, 0, 0, time.Local), Power: 10, Intensity: 1, Weather: "NIGHT"},
+	}
+
+	tests := []struct {
+		name     string
+		args     url.Values
+		model    *analytics.Model
+		dbErr    error
+		wantCode int
+		want     []analytics.Performance
+	}{
+		{
+			name:     "valid",
+			args:     validArgs,
+			model:    fitModel(),
+			wantCode: http.StatusOK,
+			want: []analytics.Performance{
+				{Timestamp: measurements[0].Timestamp, Weather: "SUN", Power: 1000, Intensity: 50, Expected: 2000, Ratio: 0.5},
+			},
+		},
+		{
+			name:     "missing arguments",
+			args:     url.Values{},
+			model:    fitModel(),
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "no model",
+			args:     validArgs,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "db failure",
+			args:     validArgs,
+			model:    fitModel(),
+			dbErr:    errors.New("db failure"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewRepository(t)
+			if tt.wantCode == http.StatusOK || tt.dbErr != nil {
+				r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(measurements, tt.dbErr).Once()
+			}
+			h := web.PerformanceHandler(r, performanceModel{model: tt.model}, discardLogger)
+
+			target := url.URL{Path: "/api/v1/performance", RawQuery: tt.args.Encode()}
+			req, _ := http.NewRequest(http.MethodGet, target.String(), nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			require.Equal(t, tt.wantCode, resp.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+			var got []analytics.Performance
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+			require.Len(t, got, len(tt.want))
+			for i := range got {
+				assert.True(t, tt.want[i].Timestamp.Equal(got[i].Timestamp))
+				assert.InDelta(t, tt.want[i].Expected, got[i].Expected, 0.01)
+				assert.InDelta(t, tt.want[i].Ratio, got[i].Ratio, 0.001)
+			}
+		})
+	}
+}
+
+var _ web.PerformanceModel = performanceModel{}
+
+type performanceModel struct {
+	model *analytics.Model
+}
+
+func (p performanceModel) Model() *analytics.Model {
+	return p.model
+}
+
+// fitModel returns a model where power at noon is 40 times the intensity.
+func fitModel() *analytics.Model {
+	var measurements repository.Measurements
+	for day := range 30 {
+		intensity := float64(10 + 2*day)
+		measurements = append(measurements, repository.Measurement{
+			Timestamp: time.Date(2024, time.June, 1+day, 12, 15, 0, 0, time.Local),
+			Power:     40 * intensity,
+			Intensity: intensity,
+		})
+	}
+	return analytics.NewModel(measurements, 10)
+}
+
+func TestForecastHandler(t *testing.T) {
+	hours := []forecast.Hour{
+		{Time: time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC), Intensity: 50, Power: 2000},
+		{Time: time.Date(2024, time.July, 1, 13, 0, 0, 0, time.UTC), Intensity: 60, Power: 2400},
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/forecast", nil)
+	resp := httptest.NewRecorder()
+	web.ForecastHandler(forecastSource{}, discardLogger).ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	resp = httptest.NewRecorder()
+	web.ForecastHandler(forecastSource{hours: hours}, discardLogger).ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	var got []forecast.Hour
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, hours, got)
+}
+
+var _ web.ForecastSource = forecastSource{}
+
+type forecastSource struct {
+	hours []forecast.Hour
+}
+
+func (f forecastSource) Forecast() []forecast.Hour {
+	return f.hours
+}
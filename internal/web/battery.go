@@ -0,0 +1,69 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web/plotters"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// BatteryHistory provides the history of the site's batteries.
+type BatteryHistory interface {
+	GetBatteryHistory(from, to time.Time) (repository.BatteryHistory, error)
+}
+
+var _ BatteryHistory = &repository.PostgresDB{}
+
+// BatteryPlotterHandler plots the power charging the batteries. Discharging power is plotted as negative values.
+func BatteryPlotterHandler(history BatteryHistory, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, fold, err := parsePlotterArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start.IsZero() || end.IsZero() {
+			http.Error(w, "start/end cannot be zero", http.StatusBadRequest)
+			return
+		}
+
+		states, err := history.GetBatteryHistory(start, end)
+		if err != nil {
+			logger.Error("failed to get battery history from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(states) == 0 {
+			http.Error(w, "no data", http.StatusOK)
+			return
+		}
+
+		config := plotters.TimeSeriesConfig{
+			Title:   "Battery",
+			X:       DefaultXYZConfig.X,
+			XTicker: DefaultXYZConfig.XTicker,
+			Y:       "power (W)",
+			Width:   DefaultXYZConfig.Width,
+			Height:  DefaultXYZConfig.Height,
+		}
+		if fold {
+			states = states.Fold()
+			config.XTicker = "15:04:05"
+		}
+
+		var buf bytes.Buffer
+		if _, err = plotters.TimeSeries(&buf, []plotters.Series{{Name: "charge (+) / discharge (-)", Data: states}}, config); err != nil {
+			logger.Error("failed to generate plot", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("ContentType", "image/png")
+		_, _ = io.Copy(w, &buf)
+	})
+}
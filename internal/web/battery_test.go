@@ -0,0 +1,56 @@
+package web_test
+
+import (
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBatteryPlotterHandler(t *testing.T) {
+	start := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+	var history repository.BatteryHistory
+	for i := range 96 {
+		history = append(history, repository.BatteryState{Timestamp: start.Add(time.Duration(i) * 15 * time.Minute), ChargeLevel: 50, Power: float64(1000 - 20*i)})
+	}
+
+	tests := []struct {
+		name     string
+		args     string
+		history  repository.BatteryHistory
+		err      error
+		wantCode int
+		wantPNG  bool
+	}{
+		{name: "plot", args: "?fold=false&start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", history: history, wantCode: http.StatusOK, wantPNG: true},
+		{name: "folded", args: "?fold=true&start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", history: history, wantCode: http.StatusOK, wantPNG: true},
+		{name: "no data", args: "?fold=false&start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", wantCode: http.StatusOK},
+		{name: "missing arguments", args: "?fold=false", wantCode: http.StatusBadRequest},
+		{name: "db failure", args: "?fold=false&start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", err: errors.New("db failure"), wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := web.BatteryPlotterHandler(fakeBatteryHistory{history: tt.history, err: tt.err}, discardLogger)
+			req, _ := http.NewRequest(http.MethodGet, "/plotter/battery"+tt.args, nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			assert.Equal(t, tt.wantCode, resp.Code)
+			if tt.wantPNG {
+				assert.Equal(t, "image/png", http.DetectContentType(resp.Body.Bytes()))
+			}
+		})
+	}
+}
+
+type fakeBatteryHistory struct {
+	history repository.BatteryHistory
+	err     error
+}
+
+func (f fakeBatteryHistory) GetBatteryHistory(_, _ time.Time) (repository.BatteryHistory, error) {
+	return f.history, f.err
+}
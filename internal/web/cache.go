@@ -20,6 +20,7 @@ type ImageCache struct {
 
 type RedisClient interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
+	Ping(ctx context.Context) *redis.StatusCmd
 	Set(ctx context.Context, key string, value any, expiration time.Duration) *redis.StatusCmd
 }
 
@@ -57,6 +58,11 @@ func (c *ImageCache) Middleware(plotType string, logger *slog.Logger) func(next
 	}
 }
 
+// IsHealthy checks that the cache's Redis server is reachable.
+func (c *ImageCache) IsHealthy(ctx context.Context) error {
+	return c.Client.Ping(ctx).Err()
+}
+
 func (c *ImageCache) getKey(plotType string, start, end time.Time, fold bool) string {
 	return strings.Join([]string{
 		c.Namespace,
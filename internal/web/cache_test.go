@@ -97,3 +97,14 @@ func TestImageCache_Middleware(t *testing.T) {
 		})
 	}
 }
+
+func TestImageCache_IsHealthy(t *testing.T) {
+	ctx := context.Background()
+	c := mocks.NewRedisClient(t)
+	c.EXPECT().Ping(ctx).Return(redis.NewStatusResult("PONG", nil)).Once()
+	cache := ImageCache{Client: c}
+	assert.NoError(t, cache.IsHealthy(ctx))
+
+	c.EXPECT().Ping(ctx).Return(redis.NewStatusResult("", redis.ErrClosed)).Once()
+	assert.ErrorIs(t, cache.IsHealthy(ctx), redis.ErrClosed)
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ClippingReport is the response of the clipping API: the clipping of each day between start and end.
+type ClippingReport struct {
+	Days []ClippingDay `json:"days"`
+	// Ceiling is the power (in W) at which the inverter clips.
+	Ceiling float64 `json:"ceiling"`
+	// LostEnergy is the estimated energy (in Wh) lost due to clipping over all days.
+	LostEnergy float64 `json:"lostEnergy"`
+}
+
+// ClippingDay is the clipping during one day. LostEnergy is zero if no performance model is available.
+type ClippingDay struct {
+	Date       time.Time `json:"date"`
+	Duration   string    `json:"duration"`
+	LostEnergy float64   `json:"lostEnergy"`
+}
+
+// ClippingHandler returns the duration of the clipping and the energy lost, per day, between start and end.
+func ClippingHandler(repo Repository, analyticsConfig AnalyticsConfig, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseReportArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start.IsZero() || end.IsZero() {
+			http.Error(w, "start/end cannot be zero", http.StatusBadRequest)
+			return
+		}
+
+		measurements, err := repo.Get(start, end)
+		if err != nil {
+			logger.Error("failed to get measurements from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		clipping := analytics.Clipping(measurements, analyticsConfig.InverterPower, currentModel(analyticsConfig.Performance))
+		report := ClippingReport{Days: make([]ClippingDay, len(clipping.Days)), Ceiling: clipping.Ceiling, LostEnergy: clipping.LostEnergy()}
+		for i, day := range clipping.Days {
+			report.Days[i] = ClippingDay{Date: day.Date, Duration: day.Duration.String(), LostEnergy: day.LostEnergy}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(report); err != nil {
+			logger.Error("failed to encode response", "err", err)
+		}
+	})
+}
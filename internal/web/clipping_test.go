@@ -0,0 +1,54 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/clambin/solaredge-monitor/internal/web/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClippingHandler(t *testing.T) {
+	measurements := repository.Measurements{
+		{Timestamp: time.Date(2024, time.July, 1, 12, 0, 0, 0, time.Local), Power: 2000, Intensity: 50, Weather: "SUN"},
+		{Timestamp: time.Date(2024, time.July, 1, 12, 15, 0, 0, time.Local), Power: 3000, Intensity: 80, Weather: "SUN"},
+		{Timestamp: time.Date(2024, time.July, 1, 12, 30, 0, 0, time.Local), Power: 3000, Intensity: 90, Weather: "SUN"},
+	}
+	config := web.AnalyticsConfig{InverterPower: 3000, Performance: performanceModel{model: fitModel()}}
+
+	r := mocks.NewRepository(t)
+	r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(measurements, nil).Once()
+	h := web.ClippingHandler(r, config, discardLogger)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/clipping?start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var report web.ClippingReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, 3000.0, report.Ceiling)
+	require.Len(t, report.Days, 1)
+	assert.True(t, report.Days[0].Date.Equal(time.Date(2024, time.July, 1, 0, 0, 0, 0, time.Local)))
+	assert.Equal(t, "30m0s", report.Days[0].Duration)
+	// the model expects 3200 W and 3600 W: (200 W + 600 W) * 15 min
+	assert.InDelta(t, 200, report.Days[0].LostEnergy, 1e-6)
+	assert.InDelta(t, 200, report.LostEnergy, 1e-6)
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/clipping", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(nil, errors.New("db failure")).Once()
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/clipping?start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
+	"log/slog"
+	"net/http"
+	"text/template"
+)
+
+var degradationTemplate = template.Must(template.New("degradation.html").
+	Funcs(template.FuncMap{"percent": func(f float64) string { return fmt.Sprintf("%.2f%%", 100*f) }}).
+	ParseFS(templatesFS, "templates/degradation.html"))
+
+// DegradationHandler reports the degradation rate of the installation over all measurements.
+// See analytics.Degradation for the meaning of minIntensity.
+func DegradationHandler(repo Repository, minIntensity float64, logger *slog.Logger) http.Handler {
+	type Data struct {
+		Error  string
+		Report analytics.DegradationReport
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		start, end, err := repo.GetDataRange()
+		if err != nil {
+			logger.Error("failed to get data range", "err", err)
+			http.Error(w, "database not available", http.StatusInternalServerError)
+			return
+		}
+		measurements, err := repo.Get(start, end)
+		if err != nil {
+			logger.Error("failed to get measurements from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var data Data
+		data.Report, err = analytics.Degradation(measurements, minIntensity)
+		if err != nil {
+			if !errors.Is(err, analytics.ErrInsufficientData) {
+				logger.Error("failed to determine degradation", "err", err)
+			}
+			data.Error = err.Error()
+		}
+
+		if err = degradationTemplate.Execute(w, data); err != nil {
+			logger.Error("failed to generate page", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package web_test
+
+import (
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/clambin/solaredge-monitor/internal/web/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDegradationHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		measurements repository.Measurements
+		rangeErr     error
+		dbErr        error
+		wantCode     int
+		want         string
+	}{
+		{
+			name:         "valid",
+			measurements: makeClearSkyMeasurements(3),
+			wantCode:     http.StatusOK,
+			want:         "Normalised output changes by <b>-1.01%</b> per year",
+		},
+		{
+			name:         "insufficient data",
+			measurements: makeClearSkyMeasurements(1),
+			wantCode:     http.StatusOK,
+			want:         "Unable to determine degradation: insufficient data",
+		},
+		{
+			name:     "no data range",
+			rangeErr: errors.New("db failure"),
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "db failure",
+			dbErr:    errors.New("db failure"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewRepository(t)
+			r.EXPECT().GetDataRange().Return(time.Time{}, time.Time{}, tt.rangeErr).Once()
+			if tt.rangeErr == nil {
+				r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(tt.measurements, tt.dbErr).Once()
+			}
+			h := web.DegradationHandler(r, 50, discardLogger)
+
+			req, _ := http.NewRequest(http.MethodGet, "/degradation", nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			assert.Equal(t, tt.wantCode, resp.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Contains(t, resp.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+// makeClearSkyMeasurements returns daily clear-sky measurements, whose normalised output degrades by 1% per year.
+func makeClearSkyMeasurements(years int) repository.Measurements {
+	start := time.Date(2021, time.January, 1, 12, 0, 0, 0, time.Local)
+	var measurements repository.Measurements
+	for day := range 365 * years {
+		timestamp := start.AddDate(0, 0, day)
+		age := timestamp.Sub(start).Hours() / (365.25 * 24)
+		measurements = append(measurements, repository.Measurement{
+			Timestamp: timestamp,
+			Weather:   "SUN",
+			Power:     40 * (1 - 0.01*age) * 75,
+			Intensity: 75,
+		})
+	}
+	return measurements
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/emissions"
+	"log/slog"
+	"net/http"
+	"text/template"
+)
+
+var emissionsTemplate = template.Must(template.New("emissions.html").ParseFS(templatesFS, "templates/emissions.html"))
+
+// EmissionsHandler returns the emissions avoided by the production between start and end, per month.
+func EmissionsHandler(repo Repository, factors emissions.Factors, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseReportArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start.IsZero() || end.IsZero() {
+			http.Error(w, "start/end cannot be zero", http.StatusBadRequest)
+			return
+		}
+
+		measurements, err := repo.Get(start, end)
+		if err != nil {
+			logger.Error("failed to get measurements from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(factors.NewReport(factors.Monthly(measurements))); err != nil {
+			logger.Error("failed to encode response", "err", err)
+		}
+	})
+}
+
+// EmissionsReportHandler reports the monthly emissions avoided by the production over all measurements.
+func EmissionsReportHandler(repo Repository, factors emissions.Factors, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		start, end, err := repo.GetDataRange()
+		if err != nil {
+			logger.Error("failed to get data range", "err", err)
+			http.Error(w, "database not available", http.StatusInternalServerError)
+			return
+		}
+		measurements, err := repo.Get(start, end)
+		if err != nil {
+			logger.Error("failed to get measurements from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err = emissionsTemplate.Execute(w, factors.NewReport(factors.Monthly(measurements))); err != nil {
+			logger.Error("failed to generate page", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
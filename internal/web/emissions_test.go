@@ -0,0 +1,77 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/emissions"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/clambin/solaredge-monitor/internal/web/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testFactors = emissions.Factors{
+	Country:        "BE",
+	CO2:            map[int]float64{2024: 0.5},
+	TreeAbsorption: 20,
+	FossilFuel:     0.25,
+}
+
+func TestEmissionsHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		dbErr    error
+		wantCode int
+	}{
+		{name: "valid", args: "?start=2024-01-01T00:00:00Z&end=2025-01-01T00:00:00Z", wantCode: http.StatusOK},
+		{name: "missing arguments", wantCode: http.StatusBadRequest},
+		{name: "db failure", args: "?start=2024-01-01T00:00:00Z&end=2025-01-01T00:00:00Z", dbErr: errors.New("db failure"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewRepository(t)
+			if tt.wantCode != http.StatusBadRequest {
+				r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(makeProduction(), tt.dbErr).Once()
+			}
+			h := web.EmissionsHandler(r, testFactors, discardLogger)
+
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/emissions"+tt.args, nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			require.Equal(t, tt.wantCode, resp.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var report emissions.Report
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+			assert.Equal(t, "BE", report.Country)
+			assert.Len(t, report.Periods, 12)
+			assert.InDelta(t, 48, report.Total.Energy, 1e-6)
+			assert.InDelta(t, 24, report.Total.CO2, 1e-6)
+			assert.InDelta(t, 1.2, report.Total.Trees, 1e-6)
+			assert.InDelta(t, 12, report.Total.FossilFuel, 1e-6)
+		})
+	}
+}
+
+func TestEmissionsReportHandler(t *testing.T) {
+	r := mocks.NewRepository(t)
+	r.EXPECT().GetDataRange().Return(time.Time{}, time.Time{}, nil).Once()
+	r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(makeProduction(), nil).Once()
+	h := web.EmissionsReportHandler(r, testFactors, discardLogger)
+
+	req, _ := http.NewRequest(http.MethodGet, "/emissions", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "avoiding <b>24.0 kg CO2</b>")
+	assert.Contains(t, resp.Body.String(), "<b>1.2 trees</b>")
+	assert.Contains(t, resp.Body.String(), "<td>2024-06</td>")
+}
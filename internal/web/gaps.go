@@ -0,0 +1,143 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/gaps"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"log/slog"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// GapFinder finds the gaps in the measurements during daylight.
+type GapFinder interface {
+	Find(from, to time.Time) ([]repository.Gap, error)
+	Completeness(from, to time.Time) ([]gaps.Day, error)
+}
+
+var _ GapFinder = gaps.Finder{}
+
+var completenessTemplate = template.Must(template.New("completeness.html").ParseFS(templatesFS, "templates/completeness.html"))
+
+// completenessHistory is the default period of the completeness calendar.
+const completenessHistory = 90 * 24 * time.Hour
+
+// GapsHandler returns the gaps in the measurements during daylight between start and end.
+func GapsHandler(finder GapFinder, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseReportArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start.IsZero() || end.IsZero() {
+			http.Error(w, "start/end cannot be zero", http.StatusBadRequest)
+			return
+		}
+
+		found, err := finder.Find(start, end)
+		if err != nil {
+			logger.Error("failed to get gaps from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(found); err != nil {
+			logger.Error("failed to encode response", "err", err)
+		}
+	})
+}
+
+// CompletenessHandler shows a calendar with the completeness of each day's measurements between start and end.
+// Defaults to the last 90 days.
+func CompletenessHandler(finder GapFinder, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseReportArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if end.IsZero() {
+			end = time.Now()
+		}
+		if start.IsZero() {
+			start = end.Add(-completenessHistory)
+		}
+
+		days, err := finder.Completeness(start, end)
+		if err != nil {
+			logger.Error("failed to get gaps from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err = completenessTemplate.Execute(w, newCalendar(days)); err != nil {
+			logger.Error("failed to generate page", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+type calendarMonth struct {
+	Name string
+	// Weeks holds the days of the month, from Monday to Sunday. Days outside the month are nil.
+	Weeks [][]*calendarDay
+}
+
+type calendarDay struct {
+	Day          int
+	Completeness float64
+	Missing      time.Duration
+	// Class is the CSS class of the day: complete, partial, incomplete or empty (no data expected).
+	Class string
+}
+
+// newCalendar groups the days per month and per week.
+func newCalendar(days []gaps.Day) []calendarMonth {
+	var months []calendarMonth
+	var week []*calendarDay
+	for _, day := range days {
+		if len(months) == 0 || day.Date.Day() == 1 {
+			if len(week) > 0 {
+				months[len(months)-1].Weeks = append(months[len(months)-1].Weeks, padWeek(week))
+			}
+			months = append(months, calendarMonth{Name: day.Date.Format("January 2006")})
+			// Monday is the first day of the week
+			week = make([]*calendarDay, (int(day.Date.Weekday())+6)%7)
+		}
+		week = append(week, &calendarDay{
+			Day:          day.Date.Day(),
+			Completeness: 100 * day.Completeness(),
+			Missing:      day.Missing.Round(time.Minute),
+			Class:        completenessClass(day),
+		})
+		if len(week) == 7 {
+			months[len(months)-1].Weeks = append(months[len(months)-1].Weeks, week)
+			week = nil
+		}
+	}
+	if len(week) > 0 {
+		months[len(months)-1].Weeks = append(months[len(months)-1].Weeks, padWeek(week))
+	}
+	return months
+}
+
+func padWeek(week []*calendarDay) []*calendarDay {
+	return append(week, make([]*calendarDay, 7-len(week))...)
+}
+
+func completenessClass(day gaps.Day) string {
+	switch completeness := day.Completeness(); {
+	case day.Daylight == 0:
+		return "empty"
+	case completeness >= 0.99:
+		return "complete"
+	case completeness >= 0.9:
+		return "partial"
+	default:
+		return "incomplete"
+	}
+}
@@ -0,0 +1,88 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/gaps"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGapsHandler(t *testing.T) {
+	timestamp := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	finder := fakeGapFinder{
+		gaps: []repository.Gap{{From: timestamp, To: timestamp.Add(time.Hour)}},
+	}
+	h := web.GapsHandler(&finder, discardLogger)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/gaps?start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var got []repository.Gap
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, finder.gaps, got)
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/gaps", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	finder.err = errors.New("db failure")
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/gaps?start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
+
+func TestCompletenessHandler(t *testing.T) {
+	// 31 July 2024 is a Wednesday
+	date := time.Date(2024, time.July, 31, 0, 0, 0, 0, time.UTC)
+	finder := fakeGapFinder{
+		days: []gaps.Day{
+			{Date: date, Daylight: 10 * time.Hour, Missing: 0},
+			{Date: date.AddDate(0, 0, 1), Daylight: 10 * time.Hour, Missing: 5 * time.Hour},
+			{Date: date.AddDate(0, 0, 2)},
+		},
+	}
+	h := web.CompletenessHandler(&finder, discardLogger)
+
+	req, _ := http.NewRequest(http.MethodGet, "/completeness", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+	assert.Contains(t, body, "<h2>July 2024</h2>")
+	assert.Contains(t, body, "<h2>August 2024</h2>")
+	assert.Contains(t, body, `<td class="complete" title="0s missing">31<br>100%</td>`)
+	assert.Contains(t, body, `<td class="incomplete" title="5h0m0s missing">1<br>50%</td>`)
+	assert.Contains(t, body, `<td class="empty" title="0s missing">2<br>100%</td>`)
+	// both weeks are padded to start on Monday and end on Sunday
+	assert.Equal(t, 2*7-3, strings.Count(body, "<td></td>"))
+
+	finder.err = errors.New("db failure")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
+
+type fakeGapFinder struct {
+	gaps []repository.Gap
+	days []gaps.Day
+	err  error
+}
+
+func (f *fakeGapFinder) Find(_, _ time.Time) ([]repository.Gap, error) {
+	return f.gaps, f.err
+}
+
+func (f *fakeGapFinder) Completeness(_, _ time.Time) ([]gaps.Day, error) {
+	return f.days, f.err
+}
@@ -3,10 +3,13 @@ package web
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/analytics"
 	"github.com/clambin/solaredge-monitor/internal/repository"
 	"github.com/clambin/solaredge-monitor/internal/web/plotters"
 	"gonum.org/v1/plot/palette/moreland"
+	"gonum.org/v1/plot/plotter"
 	"io"
 	"log/slog"
 	"net/http"
@@ -16,11 +19,20 @@ import (
 	"time"
 )
 
-func ReportHandler(repo Repository, logger *slog.Logger) http.Handler {
+// ReportPages lists the optional pages that the report links to.
+type ReportPages struct {
+	Savings      bool
+	Emissions    bool
+	Inventory    bool
+	Completeness bool
+}
+
+func ReportHandler(repo Repository, plotTypes []string, pages ReportPages, logger *slog.Logger) http.Handler {
 	type Data struct {
 		Args      string
 		PlotTypes []string
 		FoldTypes []string
+		Pages     ReportPages
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -40,9 +52,10 @@ func ReportHandler(repo Repository, logger *slog.Logger) http.Handler {
 
 		reportTemplate := template.Must(template.ParseFS(templatesFS, "templates/report.html"))
 		data := Data{
-			PlotTypes: []string{"scatter", "heatmap"},
+			PlotTypes: plotTypes,
 			FoldTypes: []string{"false", "true"},
 			Args:      values.Encode(),
+			Pages:     pages,
 		}
 
 		//	w.WriteHeader(http.StatusOK)
@@ -119,6 +132,12 @@ type Repository interface {
 
 var _ Repository = &repository.PostgresDB{}
 
+// PerformanceModel provides the model of the installation's expected output.
+type PerformanceModel interface {
+	// Model returns the current model. Returns nil if no model is available yet.
+	Model() *analytics.Model
+}
+
 var DefaultXYZConfig = plotters.XYZConfig{
 	Title:   "Report",
 	X:       "time",
@@ -133,8 +152,12 @@ var DefaultXYZConfig = plotters.XYZConfig{
 	ColorMap: moreland.SmoothBlueRed(),
 }
 
+// PlotterHandler generates a plot of the requested type. The "performance" plot is a scatter plot that highlights
+// the measurements whose power is well below the power expected by the performance model. The "clipping" plot
+// highlights the measurements where the inverter capped the power.
 func PlotterHandler(
 	repository Repository,
+	analyticsConfig AnalyticsConfig,
 	plotter string,
 	logger *slog.Logger,
 ) http.Handler {
@@ -156,20 +179,41 @@ func PlotterHandler(
 			return
 		}
 
+		// the plots show the intensity, so measurements without intensity can't be plotted
+		measurements = measurements.WithIntensity()
 		if len(measurements) == 0 {
 			http.Error(w, "no data", http.StatusOK)
 			return
 		}
 
 		config := DefaultXYZConfig
+		var highlight []int
+		switch plotter {
+		case "performance":
+			if highlight, err = underperformingMeasurements(analyticsConfig.Performance, measurements); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		case "clipping":
+			if analyticsConfig.InverterPower <= 0 {
+				config.Title = "Clipping unknown: the inverter's rated power is not configured"
+				break
+			}
+			report := analytics.Clipping(measurements, analyticsConfig.InverterPower, currentModel(analyticsConfig.Performance))
+			highlight = report.Clipped
+			config.Title = fmt.Sprintf("Clipping at %.0f W (estimated loss: %.1f kWh)", report.Ceiling, report.LostEnergy()/1000)
+		}
 		if fold {
 			measurements = measurements.Fold()
 			config.XTicker = "15:04:05"
 		}
+		if highlight != nil {
+			config.Highlight = selectXYs(measurements, highlight)
+		}
 
 		var buf bytes.Buffer
 		switch plotter {
-		case "scatter":
+		case "scatter", "performance", "clipping":
 			_, err = plotters.XYZScatter(&buf, measurements, config)
 		case "heatmap":
 			_, err = plotters.XYZHeatmap(&buf, measurements, config, 50, 50)
@@ -186,6 +230,37 @@ func PlotterHandler(
 	})
 }
 
+// underperformingMeasurements returns the index of each measurement whose performance ratio is below analytics.UnderperformanceRatio.
+func underperformingMeasurements(performance PerformanceModel, measurements repository.Measurements) ([]int, error) {
+	model := currentModel(performance)
+	if model == nil {
+		return nil, errors.New("performance model not available")
+	}
+	underperforming := make([]int, 0)
+	for i, measurement := range measurements {
+		if ratio, _, ok := model.PerformanceRatio(measurement); ok && ratio < analytics.UnderperformanceRatio {
+			underperforming = append(underperforming, i)
+		}
+	}
+	return underperforming, nil
+}
+
+// currentModel returns the current performance model. Returns nil if no model is available.
+func currentModel(performance PerformanceModel) *analytics.Model {
+	if performance == nil {
+		return nil
+	}
+	return performance.Model()
+}
+
+func selectXYs(data plotter.XYer, indices []int) plotter.XYs {
+	xys := make(plotter.XYs, len(indices))
+	for i, index := range indices {
+		xys[i].X, xys[i].Y = data.XY(index)
+	}
+	return xys
+}
+
 func redirectWithDataRange(w http.ResponseWriter, r *http.Request, repo Repository, logger *slog.Logger) {
 	start, end, err := repo.GetDataRange()
 	if err != nil {
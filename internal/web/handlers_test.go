@@ -46,7 +46,7 @@ func TestReportsHandler(t *testing.T) {
 
 	r := mocks.NewRepository(t)
 	r.EXPECT().GetDataRange().Return(time.Time{}, time.Time{}, nil).Maybe()
-	h := web.ReportHandler(r, discardLogger)
+	h := web.ReportHandler(r, []string{"scatter", "heatmap"}, web.ReportPages{}, discardLogger)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -179,7 +179,7 @@ func TestPlotterHandler(t *testing.T) {
 	}
 
 	r := mocks.NewRepository(t)
-	h := web.PlotterHandler(r, "scatter", discardLogger)
+	h := web.PlotterHandler(r, web.AnalyticsConfig{}, "scatter", discardLogger)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -195,3 +195,70 @@ func TestPlotterHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestPlotterHandler_Performance(t *testing.T) {
+	args := url.Values{
+		"start": []string{time.Date(2024, time.July, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339)},
+		"end":   []string{time.Date(2024, time.July, 2, 0, 0, 0, 0, time.Local).Format(time.RFC3339)},
+		"fold":  []string{"true"},
+	}
+	measurements := repository.Measurements{
+		{Timestamp: time.Date(2024, time.July, 1, 12, 0, 0, 0, time.Local), Power: 2000, Intensity: 50, Weather: "SUN"},
+		{Timestamp: time.Date(2024, time.July, 1, 12, 15, 0, 0, time.Local), Power: 500, Intensity: 50, Weather: "SUN"},
+	}
+
+	tests := []struct {
+		name     string
+		model    web.PerformanceModel
+		wantCode int
+	}{
+		{name: "valid", model: performanceModel{model: fitModel()}, wantCode: http.StatusOK},
+		{name: "no model", model: performanceModel{}, wantCode: http.StatusServiceUnavailable},
+		{name: "no performance model", wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewRepository(t)
+			r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(measurements, nil).Once()
+			h := web.PlotterHandler(r, web.AnalyticsConfig{Performance: tt.model}, "performance", discardLogger)
+
+			target := url.URL{Path: "/plotter/performance", RawQuery: args.Encode()}
+			req, _ := http.NewRequest(http.MethodGet, target.String(), nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			assert.Equal(t, tt.wantCode, resp.Code)
+		})
+	}
+}
+
+func TestPlotterHandler_Clipping(t *testing.T) {
+	args := url.Values{
+		"start": []string{time.Date(2024, time.July, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339)},
+		"end":   []string{time.Date(2024, time.July, 2, 0, 0, 0, 0, time.Local).Format(time.RFC3339)},
+		"fold":  []string{"false"},
+	}
+	measurements := repository.Measurements{
+		{Timestamp: time.Date(2024, time.July, 1, 12, 0, 0, 0, time.Local), Power: 2000, Intensity: 50, Weather: "SUN"},
+		{Timestamp: time.Date(2024, time.July, 1, 12, 15, 0, 0, time.Local), Power: 3000, Intensity: 80, Weather: "SUN"},
+		{Timestamp: time.Date(2024, time.July, 1, 12, 30, 0, 0, time.Local), Power: 3000, Intensity: 90, Weather: "SUN"},
+	}
+
+	for _, config := range []web.AnalyticsConfig{
+		// rated power unknown
+		{},
+		{InverterPower: 3000},
+		{InverterPower: 3000, Performance: performanceModel{model: fitModel()}},
+	} {
+		r := mocks.NewRepository(t)
+		r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(measurements, nil).Once()
+		h := web.PlotterHandler(r, config, "clipping", discardLogger)
+
+		target := url.URL{Path: "/plotter/clipping", RawQuery: args.Encode()}
+		req, _ := http.NewRequest(http.MethodGet, target.String(), nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.NotZero(t, resp.Body.Len())
+	}
+}
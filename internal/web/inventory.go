@@ -0,0 +1,53 @@
+package web
+
+import (
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"log/slog"
+	"net/http"
+	"slices"
+	"text/template"
+	"time"
+)
+
+// Inventory provides the equipment installed at the sites and the changes to it.
+type Inventory interface {
+	GetInventory() ([]repository.Equipment, error)
+	GetEquipmentEvents(from, to time.Time) ([]repository.EquipmentEvent, error)
+}
+
+var _ Inventory = &repository.PostgresDB{}
+
+var inventoryTemplate = template.Must(template.New("inventory.html").ParseFS(templatesFS, "templates/inventory.html"))
+
+// inventoryHistory is how far back InventoryHandler lists the changes to the equipment.
+const inventoryHistory = 365 * 24 * time.Hour
+
+// InventoryHandler lists the equipment installed at each site and the changes recorded during the last year, most recent first.
+func InventoryHandler(inventory Inventory, logger *slog.Logger) http.Handler {
+	type Data struct {
+		Equipment []repository.Equipment
+		Events    []repository.EquipmentEvent
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var data Data
+		var err error
+		if data.Equipment, err = inventory.GetInventory(); err != nil {
+			logger.Error("failed to get inventory from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+		if data.Events, err = inventory.GetEquipmentEvents(time.Now().Add(-inventoryHistory), time.Time{}); err != nil {
+			logger.Error("failed to get equipment events from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+		slices.Reverse(data.Events)
+
+		if err = inventoryTemplate.Execute(w, data); err != nil {
+			logger.Error("failed to generate page", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
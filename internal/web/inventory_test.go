@@ -0,0 +1,57 @@
+package web_test
+
+import (
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInventoryHandler(t *testing.T) {
+	timestamp := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	inventory := fakeInventory{
+		equipment: []repository.Equipment{
+			{SiteID: 1, Type: "inverter", SerialNumber: "INV1", Name: "Inverter 1", Firmware: "4.1.1", Optimizers: 16, FirstSeen: timestamp, LastSeen: timestamp},
+		},
+		events: []repository.EquipmentEvent{
+			{Timestamp: timestamp, SiteID: 1, Type: "inverter", SerialNumber: "INV1", Name: "Inverter 1", Change: "firmware", Old: "4.1.0", New: "4.1.1"},
+			{Timestamp: timestamp.Add(time.Hour), SiteID: 1, Type: "battery", SerialNumber: "BAT1", Name: "Battery 1", Change: "removed"},
+		},
+	}
+	h := web.InventoryHandler(&inventory, discardLogger)
+
+	req, _ := http.NewRequest(http.MethodGet, "/inventory", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+	assert.Contains(t, body, "<td>INV1</td>")
+	assert.Contains(t, body, "<td>4.1.0</td>")
+	// most recent event first
+	assert.Less(t, strings.Index(body, "<td>removed</td>"), strings.Index(body, "<td>firmware</td>"))
+
+	inventory.err = errors.New("db failure")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
+
+type fakeInventory struct {
+	equipment []repository.Equipment
+	events    []repository.EquipmentEvent
+	err       error
+}
+
+func (f *fakeInventory) GetInventory() ([]repository.Equipment, error) {
+	return f.equipment, f.err
+}
+
+func (f *fakeInventory) GetEquipmentEvents(_, _ time.Time) ([]repository.EquipmentEvent, error) {
+	return f.events, f.err
+}
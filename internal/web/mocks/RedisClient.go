@@ -73,6 +73,54 @@ func (_c *RedisClient_Get_Call) RunAndReturn(run func(context.Context, string) *
 	return _c
 }
 
+// Ping provides a mock function with given fields: ctx
+func (_m *RedisClient) Ping(ctx context.Context) *redis.StatusCmd {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ping")
+	}
+
+	var r0 *redis.StatusCmd
+	if rf, ok := ret.Get(0).(func(context.Context) *redis.StatusCmd); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*redis.StatusCmd)
+		}
+	}
+
+	return r0
+}
+
+// RedisClient_Ping_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Ping'
+type RedisClient_Ping_Call struct {
+	*mock.Call
+}
+
+// Ping is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *RedisClient_Expecter) Ping(ctx interface{}) *RedisClient_Ping_Call {
+	return &RedisClient_Ping_Call{Call: _e.mock.On("Ping", ctx)}
+}
+
+func (_c *RedisClient_Ping_Call) Run(run func(ctx context.Context)) *RedisClient_Ping_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *RedisClient_Ping_Call) Return(_a0 *redis.StatusCmd) *RedisClient_Ping_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *RedisClient_Ping_Call) RunAndReturn(run func(context.Context) *redis.StatusCmd) *RedisClient_Ping_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Set provides a mock function with given fields: ctx, key, value, expiration
 func (_m *RedisClient) Set(ctx context.Context, key string, value any, expiration time.Duration) *redis.StatusCmd {
 	ret := _m.Called(ctx, key, value, expiration)
@@ -0,0 +1,51 @@
+package plotters
+
+import (
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+	"io"
+)
+
+type TimeSeriesConfig struct {
+	Title   string
+	X       string
+	XTicker string
+	Y       string
+	Width   float64
+	Height  float64
+}
+
+// A Series is a named line in a TimeSeries plot. The X values of Data are Unix timestamps.
+type Series struct {
+	Name string
+	Data plotter.XYer
+}
+
+// TimeSeries plots each series as a line.
+func TimeSeries(w io.Writer, series []Series, config TimeSeriesConfig) (int64, error) {
+	p := plot.New()
+	p.Title.Text = config.Title
+	p.Title.Padding = vg.Centimeter
+	p.X.Label.Text = config.X
+	p.X.Tick.Marker = plot.TimeTicks{Format: config.XTicker}
+	p.Y.Label.Text = config.Y
+	p.Add(plotter.NewGrid())
+	for i, s := range series {
+		line, err := plotter.NewLine(s.Data)
+		if err != nil {
+			return 0, err
+		}
+		line.Color = plotutil.Color(i)
+		p.Add(line)
+		p.Legend.Add(s.Name, line)
+	}
+	p.Legend.Top = true
+
+	rawImg := vgimg.New(vg.Points(config.Width), vg.Points(config.Height))
+	p.Draw(draw.New(rawImg))
+	return vgimg.PngCanvas{Canvas: rawImg}.WriteTo(w)
+}
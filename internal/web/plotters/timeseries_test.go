@@ -0,0 +1,38 @@
+package plotters
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gonum.org/v1/plot/plotter"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestTimeSeries(t *testing.T) {
+	config := TimeSeriesConfig{
+		Title:   "Battery",
+		X:       "time",
+		XTicker: "15:04",
+		Y:       "power (W)",
+		Width:   800,
+		Height:  600,
+	}
+	data := make(plotter.XYs, 100)
+	for i := range data {
+		data[i].X = float64(1719835200 + 900*i)
+		data[i].Y = 1000 * math.Sin(float64(i)/10)
+	}
+
+	var output bytes.Buffer
+	_, err := TimeSeries(&output, []Series{{Name: "power", Data: data}}, config)
+	require.NoError(t, err)
+	img, err := png.Decode(&output)
+	require.NoError(t, err)
+	assert.Greater(t, img.Bounds().Dx(), img.Bounds().Dy())
+
+	data[10].Y = math.NaN()
+	_, err = TimeSeries(&output, []Series{{Name: "power", Data: data}}, config)
+	assert.Error(t, err)
+}
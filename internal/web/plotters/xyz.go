@@ -7,19 +7,22 @@ import (
 	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/draw"
 	"gonum.org/v1/plot/vg/vgimg"
+	"image/color"
 	"io"
 	"strconv"
 )
 
 type XYZConfig struct {
 	ColorMap palette.ColorMap
-	Title    string
-	X        string
-	XTicker  string
-	Y        string
-	Ranges   []float64
-	Width    float64
-	Height   float64
+	// Highlight holds the points that XYZScatter marks on top of the data. Optional.
+	Highlight plotter.XYer
+	Title     string
+	X         string
+	XTicker   string
+	Y         string
+	Ranges    []float64
+	Width     float64
+	Height    float64
 }
 
 func XYZScatter(w io.Writer, data plotter.XYZer, config XYZConfig) (int64, error) {
@@ -29,6 +32,13 @@ func XYZScatter(w io.Writer, data plotter.XYZer, config XYZConfig) (int64, error
 		return 0, err
 	}
 	p.Add(sc)
+	if config.Highlight != nil && config.Highlight.Len() > 0 {
+		hl, err := highlightPlot(config.Highlight)
+		if err != nil {
+			return 0, err
+		}
+		p.Add(hl)
+	}
 	addLegend(p, config)
 	return writePng(w, p, config.Width, config.Height)
 }
@@ -73,6 +83,17 @@ func scatterPlot(data plotter.XYZer, config XYZConfig) (*plotter.Scatter, error)
 	return sc, nil
 }
 
+var highlightColor = color.RGBA{R: 255, A: 255}
+
+func highlightPlot(data plotter.XYer) (*plotter.Scatter, error) {
+	sc, err := plotter.NewScatter(data)
+	if err != nil {
+		return nil, err
+	}
+	sc.GlyphStyle = draw.GlyphStyle{Color: highlightColor, Radius: vg.Points(5), Shape: draw.RingGlyph{}}
+	return sc, nil
+}
+
 func heatmapPlot(data plotter.XYZer, config XYZConfig, rows, cols int) *plotter.HeatMap {
 	g := makeGrid(data, rows, cols)
 	p := config.ColorMap.Palette(len(config.Ranges))
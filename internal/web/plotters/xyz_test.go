@@ -42,6 +42,30 @@ func TestPlotXYZScatter(t *testing.T) {
 	assert.Equal(t, golden, output.Bytes())
 }
 
+func TestPlotXYZScatter_Highlight(t *testing.T) {
+	config := XYZConfig{
+		Title:    "Report",
+		X:        "time",
+		XTicker:  "2006-01-02\n15:04:05",
+		Y:        "solar intensity (%)",
+		Width:    800,
+		Height:   600,
+		Ranges:   []float64{0, 1000, 2000, 3000, 4000},
+		ColorMap: moreland.SmoothBlueRed(),
+	}
+
+	data := buildData(100)
+	var plain bytes.Buffer
+	_, err := XYZScatter(&plain, data, config)
+	require.NoError(t, err)
+
+	config.Highlight = data[10:12]
+	var highlighted bytes.Buffer
+	_, err = XYZScatter(&highlighted, data, config)
+	require.NoError(t, err)
+	assert.NotEqual(t, plain.Bytes(), highlighted.Bytes())
+}
+
 func TestPlotXYZHeatmap(t *testing.T) {
 	config := XYZConfig{
 		Title:    "Report",
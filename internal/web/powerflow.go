@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// PowerFlowHistory provides the power flows of a site with a meter.
+type PowerFlowHistory interface {
+	GetPowerFlows(from, to time.Time) (repository.PowerFlows, error)
+}
+
+var _ PowerFlowHistory = &repository.PostgresDB{}
+
+// PowerFlowReport is the response of the power flow API. The ratios are omitted if nothing was produced or consumed.
+type PowerFlowReport struct {
+	PowerFlows           repository.PowerFlows `json:"powerFlows"`
+	SelfConsumptionRatio *float64              `json:"selfConsumptionRatio,omitempty"`
+	SelfSufficiencyRatio *float64              `json:"selfSufficiencyRatio,omitempty"`
+}
+
+// PowerFlowsHandler returns the power flows between start and end, and the resulting self-consumption and self-sufficiency.
+func PowerFlowsHandler(history PowerFlowHistory, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseReportArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start.IsZero() || end.IsZero() {
+			http.Error(w, "start/end cannot be zero", http.StatusBadRequest)
+			return
+		}
+
+		powerFlows, err := history.GetPowerFlows(start, end)
+		if err != nil {
+			logger.Error("failed to get power flows from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report := PowerFlowReport{PowerFlows: powerFlows}
+		balance := powerFlows.Balance()
+		if ratio, ok := balance.SelfConsumptionRatio(); ok {
+			report.SelfConsumptionRatio = &ratio
+		}
+		if ratio, ok := balance.SelfSufficiencyRatio(); ok {
+			report.SelfSufficiencyRatio = &ratio
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(report); err != nil {
+			logger.Error("failed to encode response", "err", err)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPowerFlowsHandler(t *testing.T) {
+	timestamp := time.Date(2024, time.July, 1, 12, 0, 0, 0, time.UTC)
+	history := fakePowerFlowHistory{
+		powerFlows: repository.PowerFlows{
+			{Timestamp: timestamp, Production: 3000, Consumption: 1000, Grid: -2000},
+			{Timestamp: timestamp.Add(15 * time.Minute), Production: 1000, Consumption: 2000, Grid: 1000},
+		},
+	}
+	h := web.PowerFlowsHandler(&history, discardLogger)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/powerflows?start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var got web.PowerFlowReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, history.powerFlows, got.PowerFlows)
+	require.NotNil(t, got.SelfConsumptionRatio)
+	assert.Equal(t, 0.5, *got.SelfConsumptionRatio)
+	require.NotNil(t, got.SelfSufficiencyRatio)
+	assert.InDelta(t, 2.0/3, *got.SelfSufficiencyRatio, 1e-9)
+
+	history.powerFlows = nil
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/powerflows?start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	got = web.PowerFlowReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Nil(t, got.SelfConsumptionRatio)
+	assert.Nil(t, got.SelfSufficiencyRatio)
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/powerflows", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	history.err = errors.New("db failure")
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/powerflows?start=2024-07-01T00:00:00Z&end=2024-07-02T00:00:00Z", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
+
+type fakePowerFlowHistory struct {
+	powerFlows repository.PowerFlows
+	err        error
+}
+
+func (f *fakePowerFlowHistory) GetPowerFlows(_, _ time.Time) (repository.PowerFlows, error) {
+	return f.powerFlows, f.err
+}
@@ -9,17 +9,55 @@ import (
 //go:embed static/*
 var staticFS embed.FS
 
-func addRoutes(m *http.ServeMux, repo Repository, imageCache *ImageCache, logger *slog.Logger) {
+func addRoutes(m *http.ServeMux, repo Repository, analyticsConfig AnalyticsConfig, imageCache *ImageCache, logger *slog.Logger) {
 	logger = logger.With("component", "handler")
-	m.Handle("GET /report", ReportHandler(repo, logger.With("handler", "report")))
+	plotTypes := []string{"scatter", "heatmap", "clipping"}
+	performance := analyticsConfig.Performance
+	if performance != nil {
+		plotTypes = append(plotTypes, "performance")
+		m.Handle("GET /api/v1/performance", PerformanceHandler(repo, performance, logger.With("handler", "performance-api")))
+	}
+	if analyticsConfig.InverterPower > 0 {
+		m.Handle("GET /api/v1/clipping", ClippingHandler(repo, analyticsConfig, logger.With("handler", "clipping-api")))
+	}
+	if analyticsConfig.Battery != nil {
+		plotTypes = append(plotTypes, "battery")
+	}
+	if analyticsConfig.Forecast != nil {
+		m.Handle("GET /api/v1/forecast", ForecastHandler(analyticsConfig.Forecast, logger.With("handler", "forecast-api")))
+	}
+	if analyticsConfig.Tariffs != nil {
+		m.Handle("GET /api/v1/savings", SavingsHandler(repo, *analyticsConfig.Tariffs, logger.With("handler", "savings-api")))
+		m.Handle("GET /savings", SavingsReportHandler(repo, *analyticsConfig.Tariffs, logger.With("handler", "savings")))
+	}
+	if analyticsConfig.Emissions != nil {
+		m.Handle("GET /api/v1/emissions", EmissionsHandler(repo, *analyticsConfig.Emissions, logger.With("handler", "emissions-api")))
+		m.Handle("GET /emissions", EmissionsReportHandler(repo, *analyticsConfig.Emissions, logger.With("handler", "emissions")))
+	}
+	if analyticsConfig.Inventory != nil {
+		m.Handle("GET /inventory", InventoryHandler(analyticsConfig.Inventory, logger.With("handler", "inventory")))
+	}
+	if analyticsConfig.Gaps != nil {
+		m.Handle("GET /api/v1/gaps", GapsHandler(analyticsConfig.Gaps, logger.With("handler", "gaps-api")))
+		m.Handle("GET /completeness", CompletenessHandler(analyticsConfig.Gaps, logger.With("handler", "completeness")))
+	}
+	if analyticsConfig.PowerFlows != nil {
+		m.Handle("GET /api/v1/powerflows", PowerFlowsHandler(analyticsConfig.PowerFlows, logger.With("handler", "powerflows-api")))
+	}
+	m.Handle("GET /degradation", DegradationHandler(repo, analyticsConfig.MinIntensity, logger.With("handler", "degradation")))
+	m.Handle("GET /report", ReportHandler(repo, plotTypes, ReportPages{
+		Savings:      analyticsConfig.Tariffs != nil,
+		Emissions:    analyticsConfig.Emissions != nil,
+		Inventory:    analyticsConfig.Inventory != nil,
+		Completeness: analyticsConfig.Gaps != nil,
+	}, logger.With("handler", "report")))
 	m.Handle("GET /plot/{plotType}", PlotHandler(logger.With("handler", "plot")))
-	for _, plotType := range []string{"scatter", "heatmap"} {
-		m.Handle("GET /plotter/"+plotType,
-			imageCache.Middleware(plotType, logger.With("cache", plotType))(
-				PlotterHandler(repo, plotType, logger.With("handler", plotType)),
-			),
-		)
-
+	for _, plotType := range plotTypes {
+		h := PlotterHandler(repo, analyticsConfig, plotType, logger.With("handler", plotType))
+		if plotType == "battery" {
+			h = BatteryPlotterHandler(analyticsConfig.Battery, logger.With("handler", plotType))
+		}
+		m.Handle("GET /plotter/"+plotType, imageCache.Middleware(plotType, logger.With("cache", plotType))(h))
 	}
 	m.Handle("/static/", http.FileServer(http.FS(staticFS)))
 	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/internal/tariff"
+	"log/slog"
+	"net/http"
+	"text/template"
+)
+
+var savingsTemplate = template.Must(template.New("savings.html").
+	Funcs(template.FuncMap{"percent": func(f float64) string { return fmt.Sprintf("%.1f%%", 100*f) }}).
+	ParseFS(templatesFS, "templates/savings.html"))
+
+// SavingsHandler returns the value of the production between start and end, per day or per month (the default).
+func SavingsHandler(repo Repository, schedule tariff.Schedule, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parseReportArguments(r)
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start.IsZero() || end.IsZero() {
+			http.Error(w, "start/end cannot be zero", http.StatusBadRequest)
+			return
+		}
+		summarise := schedule.Monthly
+		switch period := r.URL.Query().Get("period"); period {
+		case "", "month":
+		case "day":
+			summarise = schedule.Daily
+		default:
+			http.Error(w, "bad request: invalid period: "+period, http.StatusBadRequest)
+			return
+		}
+
+		measurements, err := repo.Get(start, end)
+		if err != nil {
+			logger.Error("failed to get measurements from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(schedule.NewReport(summarise(measurements))); err != nil {
+			logger.Error("failed to encode response", "err", err)
+		}
+	})
+}
+
+// SavingsReportHandler reports the monthly value of the production over all measurements, and the payback of the installation.
+func SavingsReportHandler(repo Repository, schedule tariff.Schedule, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		start, end, err := repo.GetDataRange()
+		if err != nil {
+			logger.Error("failed to get data range", "err", err)
+			http.Error(w, "database not available", http.StatusInternalServerError)
+			return
+		}
+		measurements, err := repo.Get(start, end)
+		if err != nil {
+			logger.Error("failed to get measurements from database", "err", err)
+			http.Error(w, fmt.Errorf("database: %w", err).Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err = savingsTemplate.Execute(w, schedule.NewReport(schedule.Monthly(measurements))); err != nil {
+			logger.Error("failed to generate page", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
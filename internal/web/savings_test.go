@@ -0,0 +1,90 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/clambin/solaredge-monitor/internal/repository"
+	"github.com/clambin/solaredge-monitor/internal/tariff"
+	"github.com/clambin/solaredge-monitor/internal/web"
+	"github.com/clambin/solaredge-monitor/internal/web/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testSchedule = tariff.Schedule{
+	Currency:         "EUR",
+	Tariffs:          []tariff.Tariff{{Effective: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local), FeedIn: 0.5}},
+	InstallationCost: 100,
+}
+
+// makeProduction returns measurements of 1 kW during 4 hours on the first of each month of 2024.
+func makeProduction() repository.Measurements {
+	var measurements repository.Measurements
+	for month := time.January; month <= time.December; month++ {
+		for hour := 10; hour < 14; hour++ {
+			measurements = append(measurements, repository.Measurement{Timestamp: time.Date(2024, month, 1, hour, 0, 0, 0, time.Local), Power: 1000})
+		}
+	}
+	return measurements
+}
+
+func TestSavingsHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        string
+		dbErr       error
+		wantCode    int
+		wantPeriods int
+	}{
+		{name: "monthly", args: "?start=2024-01-01T00:00:00Z&end=2025-01-01T00:00:00Z", wantCode: http.StatusOK, wantPeriods: 12},
+		{name: "daily", args: "?start=2024-01-01T00:00:00Z&end=2025-01-01T00:00:00Z&period=day", wantCode: http.StatusOK, wantPeriods: 12},
+		{name: "invalid period", args: "?start=2024-01-01T00:00:00Z&end=2025-01-01T00:00:00Z&period=week", wantCode: http.StatusBadRequest},
+		{name: "missing arguments", wantCode: http.StatusBadRequest},
+		{name: "db failure", args: "?start=2024-01-01T00:00:00Z&end=2025-01-01T00:00:00Z", dbErr: errors.New("db failure"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mocks.NewRepository(t)
+			if tt.wantCode != http.StatusBadRequest {
+				r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(makeProduction(), tt.dbErr).Once()
+			}
+			h := web.SavingsHandler(r, testSchedule, discardLogger)
+
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/savings"+tt.args, nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			require.Equal(t, tt.wantCode, resp.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var report tariff.Report
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+			assert.Equal(t, "EUR", report.Currency)
+			assert.Len(t, report.Periods, tt.wantPeriods)
+			assert.InDelta(t, 48, report.Total.Energy, 1e-6)
+			assert.InDelta(t, 24, report.Total.Value, 1e-6)
+			assert.InDelta(t, 0.24, report.Payback, 1e-6)
+		})
+	}
+}
+
+func TestSavingsReportHandler(t *testing.T) {
+	r := mocks.NewRepository(t)
+	r.EXPECT().GetDataRange().Return(time.Time{}, time.Time{}, nil).Once()
+	r.EXPECT().Get(mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(makeProduction(), nil).Once()
+	h := web.SavingsReportHandler(r, testSchedule, discardLogger)
+
+	req, _ := http.NewRequest(http.MethodGet, "/savings", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Total production: <b>48.0 kWh</b>, worth <b>24.00 EUR</b>")
+	assert.Contains(t, resp.Body.String(), "This recovers <b>24.0%</b> of the installation cost of 100.00 EUR")
+	assert.Contains(t, resp.Body.String(), "<td>2024-06</td>")
+}
@@ -1,12 +1,39 @@
 package web
 
 import (
+	"github.com/clambin/solaredge-monitor/internal/emissions"
+	"github.com/clambin/solaredge-monitor/internal/tariff"
 	"log/slog"
 	"net/http"
 )
 
-func New(repo Repository, imageCache *ImageCache, logger *slog.Logger) http.Handler {
+// AnalyticsConfig configures the analytics pages of the web server.
+type AnalyticsConfig struct {
+	// Performance provides the expected-power model. If nil, the performance API and plot aren't available.
+	Performance PerformanceModel
+	// MinIntensity is the minimum solar intensity of the measurements used by the degradation report.
+	MinIntensity float64
+	// InverterPower is the rated AC power of the inverter(s), in W. Used to detect clipping. If zero, the clipping plot
+	// doesn't show any clipping and the clipping API isn't available.
+	InverterPower float64
+	// Forecast provides the production forecast. If nil, the forecast API isn't available.
+	Forecast ForecastSource
+	// Tariffs values the production. If nil, the savings API and report aren't available.
+	Tariffs *tariff.Schedule
+	// Battery provides the history of the site's batteries. If nil, the battery plot isn't available.
+	Battery BatteryHistory
+	// Emissions determine the emissions avoided by the production. If nil, the emissions API and report aren't available.
+	Emissions *emissions.Factors
+	// Inventory provides the equipment installed at the sites. If nil, the inventory page isn't available.
+	Inventory Inventory
+	// Gaps finds the gaps in the measurements. If nil, the gaps API and completeness calendar aren't available.
+	Gaps GapFinder
+	// PowerFlows provides the power flows of sites with a meter. If nil, the power flow API isn't available.
+	PowerFlows PowerFlowHistory
+}
+
+func New(repo Repository, analyticsConfig AnalyticsConfig, imageCache *ImageCache, logger *slog.Logger) http.Handler {
 	mux := http.NewServeMux()
-	addRoutes(mux, repo, imageCache, logger)
+	addRoutes(mux, repo, analyticsConfig, imageCache, logger)
 	return mux
 }
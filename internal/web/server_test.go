@@ -40,6 +40,21 @@ func TestNewHTTPServer(t *testing.T) {
 			target:         "/plotter/heatmap?fold=false",
 			wantStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:           "performance api not available",
+			target:         "/api/v1/performance",
+			wantStatusCode: http.StatusSeeOther,
+		},
+		{
+			name:           "clipping",
+			target:         "/plotter/clipping?fold=false",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "degradation",
+			target:         "/degradation",
+			wantStatusCode: http.StatusOK,
+		},
 		{
 			name:           "contour",
 			target:         "/plotter/contour",
@@ -48,7 +63,7 @@ func TestNewHTTPServer(t *testing.T) {
 	}
 
 	r := repo{measurements: makeMeasurements(100)}
-	s := web.New(r, nil, slog.Default())
+	s := web.New(r, web.AnalyticsConfig{MinIntensity: 50}, nil, slog.Default())
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,125 @@
+package oauth2crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/clambin/solaredge-monitor/oauth2file"
+	"github.com/clambin/tado/v2/oauth2store"
+	"golang.org/x/oauth2"
+	"strings"
+)
+
+var _ oauth2store.TokenStore = &TokenStore{}
+
+// A TokenStore encrypts the access and refresh token of an oauth2 token before saving it in the underlying TokenStore.
+//
+// Tokens are encrypted with AES-GCM. The remaining fields (token type, expiry) are saved as is, so the underlying
+// store can still be inspected without the key.
+type TokenStore struct {
+	oauth2store.TokenStore
+	aead cipher.AEAD
+}
+
+// NewEncryptedTokenStore returns a TokenStore that encrypts tokens with key before saving them in store.
+// The key must be 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256.
+func NewEncryptedTokenStore(store oauth2store.TokenStore, key []byte) (*TokenStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenStore{TokenStore: store, aead: aead}, nil
+}
+
+func (t TokenStore) Save(token *oauth2.Token) error {
+	encrypted := *token
+	var err error
+	if encrypted.AccessToken, err = t.encrypt("access_token", token.AccessToken); err != nil {
+		return err
+	}
+	if encrypted.RefreshToken, err = t.encrypt("refresh_token", token.RefreshToken); err != nil {
+		return err
+	}
+	return t.TokenStore.Save(&encrypted)
+}
+
+func (t TokenStore) Load() (*oauth2.Token, error) {
+	token, err := t.TokenStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken, err = t.decrypt("access_token", token.AccessToken); err != nil {
+		return nil, err
+	}
+	if token.RefreshToken, err = t.decrypt("refresh_token", token.RefreshToken); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Delete removes the token from the underlying store, if the underlying store supports it.
+func (t TokenStore) Delete() error {
+	store, ok := t.TokenStore.(interface{ Delete() error })
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return store.Delete()
+}
+
+// encrypt encrypts value and returns the base64-encoded nonce and ciphertext. The field name is used as additional
+// data, so the encrypted access and refresh token can't be swapped.
+func (t TokenStore) encrypt(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	nonce := make([]byte, t.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := t.aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (t TokenStore) decrypt(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sealed) < t.aead.NonceSize() {
+		return "", fmt.Errorf("%s: not encrypted", field)
+	}
+	nonce, ciphertext := sealed[:t.aead.NonceSize()], sealed[t.aead.NonceSize():]
+	plaintext, err := t.aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// ParseKey decodes a base64-encoded key, e.g. as generated by `openssl rand -base64 32`.
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if l := len(key); l != 16 && l != 24 && l != 32 {
+		return nil, fmt.Errorf("invalid key: must be 16, 24 or 32 bytes long, got %d", l)
+	}
+	return key, nil
+}
+
+// ReadKeyFile reads a base64-encoded key from a file. Like a token file, the key file must only be accessible by its owner.
+func ReadKeyFile(path string) ([]byte, error) {
+	content, err := oauth2file.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKey(string(content))
+}
@@ -0,0 +1,139 @@
+package oauth2crypt_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/clambin/solaredge-monitor/oauth2crypt"
+	"github.com/clambin/solaredge-monitor/oauth2file"
+	"golang.org/x/oauth2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStore(t *testing.T) {
+	var s fakeTokenStore
+	key := make([]byte, 32)
+	ts, err := oauth2crypt.NewEncryptedTokenStore(&s, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok := &oauth2.Token{AccessToken: "abc", RefreshToken: "def", Expiry: time.Now().Add(time.Hour)}
+	if err = ts.Save(tok); err != nil {
+		t.Fatal(err)
+	}
+	if s.token.AccessToken == tok.AccessToken || s.token.RefreshToken == tok.RefreshToken {
+		t.Errorf("token saved in plaintext: %v", s.token)
+	}
+	if !s.token.Expiry.Equal(tok.Expiry) {
+		t.Errorf("expiry mismatch: want %v, got %v", tok.Expiry, s.token.Expiry)
+	}
+	if tok.AccessToken != "abc" {
+		t.Errorf("Save modified the token: %v", tok)
+	}
+
+	tok2, err := ts.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok2.AccessToken != tok.AccessToken || tok2.RefreshToken != tok.RefreshToken {
+		t.Errorf("token mismatch: want %v, got %v", tok, tok2)
+	}
+
+	// a different key can't decrypt the token
+	key[0] = 1
+	ts2, _ := oauth2crypt.NewEncryptedTokenStore(&s, key)
+	if _, err = ts2.Load(); err == nil {
+		t.Error("expected an error when decrypting with the wrong key")
+	}
+
+	// swapped tokens don't decrypt
+	s.token.AccessToken, s.token.RefreshToken = s.token.RefreshToken, s.token.AccessToken
+	if _, err = ts.Load(); err == nil {
+		t.Error("expected an error when decrypting swapped tokens")
+	}
+
+	// plaintext tokens don't decrypt
+	s.token = &oauth2.Token{AccessToken: "abc"}
+	if _, err = ts.Load(); err == nil {
+		t.Error("expected an error when loading a plaintext token")
+	}
+
+	if err = ts.Delete(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported, got %v", err)
+	}
+}
+
+func TestTokenStore_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	ts, err := oauth2crypt.NewEncryptedTokenStore(oauth2file.NewFileTokenStore(path), make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ts.Save(&oauth2.Token{AccessToken: "abc", RefreshToken: "def"}); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), `"def"`) {
+		t.Errorf("refresh token saved in plaintext: %s", content)
+	}
+	if err = ts.Delete(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "aes-256", key: base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\n"},
+		{name: "aes-128", key: base64.StdEncoding.EncodeToString(make([]byte, 16))},
+		{name: "invalid length", key: base64.StdEncoding.EncodeToString(make([]byte, 10)), wantErr: true},
+		{name: "not base64", key: "not a key!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := oauth2crypt.ParseKey(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("ParseKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oauth2crypt.ReadKeyFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oauth2crypt.ReadKeyFile(path); err == nil {
+		t.Error("expected an error for a key file readable by others")
+	}
+}
+
+type fakeTokenStore struct {
+	token *oauth2.Token
+}
+
+func (f *fakeTokenStore) Save(token *oauth2.Token) error {
+	f.token = token
+	return nil
+}
+
+func (f *fakeTokenStore) Load() (*oauth2.Token, error) {
+	if f.token == nil {
+		return nil, errors.New("not found")
+	}
+	token := *f.token
+	return &token, nil
+}
@@ -0,0 +1,86 @@
+package oauth2file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clambin/tado/v2/oauth2store"
+	"golang.org/x/oauth2"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var _ oauth2store.TokenStore = &TokenStore{}
+
+// A TokenStore saves an oauth2 token in a file.
+//
+// The file is only accessible by its owner. Save replaces the file atomically, so a crash while saving never leaves
+// a partially written token behind. Load refuses to read a file that is accessible by other users.
+type TokenStore struct {
+	Path string
+}
+
+func NewFileTokenStore(path string) *TokenStore {
+	return &TokenStore{Path: path}
+}
+
+func (t TokenStore) Save(token *oauth2.Token) error {
+	bytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return WriteFile(t.Path, bytes)
+}
+
+func (t TokenStore) Load() (*oauth2.Token, error) {
+	bytes, err := ReadFile(t.Path)
+	if err != nil {
+		return nil, err
+	}
+	var token oauth2.Token
+	err = json.Unmarshal(bytes, &token)
+	return &token, err
+}
+
+// Delete removes the token from the store. Deleting a token that does not exist is not an error.
+func (t TokenStore) Delete() error {
+	if err := os.Remove(t.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// WriteFile atomically replaces the file at path with data. The file is only accessible by its owner.
+func WriteFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	// no-op once the file has been renamed
+	defer func() { _ = os.Remove(f.Name()) }()
+	if err = f.Chmod(0o600); err == nil {
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	return err
+}
+
+// ReadFile reads the file at path. It returns an error if the file is accessible by anyone but its owner.
+func ReadFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return nil, fmt.Errorf("%s: permissions %#o are too open: the file must only be accessible by its owner", path, perm)
+	}
+	return os.ReadFile(path)
+}
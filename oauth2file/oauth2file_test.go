@@ -0,0 +1,62 @@
+package oauth2file_test
+
+import (
+	"errors"
+	"github.com/clambin/solaredge-monitor/oauth2file"
+	"golang.org/x/oauth2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	ts := oauth2file.NewFileTokenStore(path)
+
+	if _, err := ts.Load(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist before save, got %v", err)
+	}
+
+	tok := &oauth2.Token{AccessToken: "abc", RefreshToken: "def", Expiry: time.Now().Add(time.Hour)}
+	for range 2 {
+		if err := ts.Save(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("permissions mismatch: want %#o, got %#o", 0o600, perm)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("expected only the token file, got %d entries", len(entries))
+	}
+
+	tok2, err := ts.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok2.AccessToken != tok.AccessToken || tok2.RefreshToken != tok.RefreshToken {
+		t.Errorf("token mismatch: want %v, got %v", tok, tok2)
+	}
+
+	if err = os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ts.Load(); err == nil {
+		t.Error("expected an error for a token file readable by others")
+	}
+
+	for range 2 {
+		if err = ts.Delete(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = ts.Load(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist after delete, got %v", err)
+	}
+}
@@ -2,19 +2,37 @@ package oauth2redis
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"github.com/clambin/tado/v2/oauth2store"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/oauth2"
 	"time"
 )
 
-var _ oauth2store.TokenStore = &tokenStore{}
+const (
+	// DefaultTimeout is the default timeout of a Redis operation.
+	DefaultTimeout = 5 * time.Second
+	// maxRetries is how often Save retries if another client modified the token while saving it.
+	maxRetries = 3
+)
+
+var _ oauth2store.TokenStore = &TokenStore{}
 
-type tokenStore struct {
+// A TokenStore saves an oauth2 token in Redis.
+//
+// If the RedisClient is a Watcher (e.g. *redis.Client), multiple clients can share a token: Save doesn't overwrite
+// a token that expires later than the token being saved, so a client that refreshed the token concurrently with another
+// client doesn't replace the other client's newer token.
+type TokenStore struct {
 	RedisClient
-	Key string
-	TTL time.Duration
+	Key     string
+	TTL     time.Duration
+	Timeout time.Duration
+	Metrics *Metrics
 }
 
 type RedisClient interface {
@@ -22,24 +40,109 @@ type RedisClient interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
 }
 
+// A Watcher runs a transaction that fails if the watched keys are modified. If the RedisClient is a Watcher, Save
+// doesn't overwrite a token that expires later than the token being saved.
+type Watcher interface {
+	Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
+}
+
+// A Deleter removes keys. Delete requires the RedisClient to be a Deleter.
+type Deleter interface {
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
 func NewRedisTokenStore(redisClient RedisClient, key string, ttl time.Duration) oauth2store.TokenStore {
-	return &tokenStore{
+	return &TokenStore{
 		RedisClient: redisClient,
 		Key:         key,
 		TTL:         ttl,
+		Timeout:     DefaultTimeout,
 	}
 }
 
-func (t tokenStore) Save(token *oauth2.Token) error {
+// Key returns the key under which the token of an account is stored. Different accounts get a different key, so they
+// can share a Redis server. The account is hashed, so the key doesn't reveal it. If account is blank, Key returns prefix.
+func Key(prefix, account string) string {
+	if account == "" {
+		return prefix
+	}
+	hash := sha256.Sum256([]byte(account))
+	return prefix + "/" + hex.EncodeToString(hash[:8])
+}
+
+func (t TokenStore) Save(token *oauth2.Token) error {
 	bytes, err := json.Marshal(token)
-	if err == nil {
-		err = t.RedisClient.Set(context.Background(), t.Key, bytes, t.TTL).Err()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := t.context()
+	defer cancel()
+	if watcher, ok := t.RedisClient.(Watcher); ok {
+		for range maxRetries {
+			err = watcher.Watch(ctx, func(tx *redis.Tx) error {
+				return t.save(ctx, tx, token, bytes)
+			}, t.Key)
+			if !errors.Is(err, redis.TxFailedErr) {
+				break
+			}
+		}
+	} else {
+		err = t.RedisClient.Set(ctx, t.Key, bytes, t.TTL).Err()
 	}
+	if err != nil {
+		t.Metrics.failed("save")
+	}
+	return err
+}
+
+// tx is the part of a redis.Tx used to save a token.
+type tx interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+}
+
+// save saves the token, unless the stored token expires later. If another client modifies the token before the
+// transaction executes, the transaction fails with redis.TxFailedErr.
+func (t TokenStore) save(ctx context.Context, tx tx, token *oauth2.Token, bytes []byte) error {
+	if current, err := decode(tx.Get(ctx, t.Key)); err == nil && current.Expiry.After(token.Expiry) {
+		return nil
+	}
+	_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return pipe.Set(ctx, t.Key, bytes, t.TTL).Err()
+	})
 	return err
 }
 
-func (t tokenStore) Load() (*oauth2.Token, error) {
-	result := t.RedisClient.Get(context.Background(), t.Key)
+func (t TokenStore) Load() (*oauth2.Token, error) {
+	ctx, cancel := t.context()
+	defer cancel()
+	token, err := decode(t.RedisClient.Get(ctx, t.Key))
+	// a missing token is not a failure
+	if err != nil && !errors.Is(err, redis.Nil) {
+		t.Metrics.failed("load")
+	}
+	return token, err
+}
+
+// Delete removes the token from the store. Returns errors.ErrUnsupported if the RedisClient isn't a Deleter.
+func (t TokenStore) Delete() error {
+	deleter, ok := t.RedisClient.(Deleter)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	ctx, cancel := t.context()
+	defer cancel()
+	return deleter.Del(ctx, t.Key).Err()
+}
+
+func (t TokenStore) context() (context.Context, context.CancelFunc) {
+	if t.Timeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), t.Timeout)
+}
+
+func decode(result *redis.StringCmd) (*oauth2.Token, error) {
 	if result.Err() != nil {
 		return nil, result.Err()
 	}
@@ -47,3 +150,33 @@ func (t tokenStore) Load() (*oauth2.Token, error) {
 	err := json.Unmarshal([]byte(result.Val()), &token)
 	return &token, err
 }
+
+var _ prometheus.Collector = &Metrics{}
+
+// Metrics counts the operations of a TokenStore that failed.
+type Metrics struct {
+	failures *prometheus.CounterVec
+}
+
+func NewMetrics(namespace, subsystem string) *Metrics {
+	return &Metrics{
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "token_store_failures_total"),
+			Help: "Number of failed token store operations",
+		}, []string{"operation"}),
+	}
+}
+
+func (m *Metrics) failed(operation string) {
+	if m != nil {
+		m.failures.WithLabelValues(operation).Inc()
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.failures.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.failures.Collect(ch)
+}
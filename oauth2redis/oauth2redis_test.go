@@ -1,10 +1,20 @@
 package oauth2redis_test
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/clambin/solaredge-monitor/oauth2redis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/oauth2"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -12,12 +22,15 @@ import (
 
 func TestTokenStore(t *testing.T) {
 	var c fakeRedisClient
-	ts := oauth2redis.NewRedisTokenStore(&c, "token", 1*time.Minute)
+	ts := oauth2redis.TokenStore{RedisClient: &c, Key: "token", TTL: time.Minute, Metrics: oauth2redis.NewMetrics("", "")}
 
-	tok := &oauth2.Token{AccessToken: "abc", Expiry: time.Now().Add(time.Hour)}
-	if err := ts.Save(tok); err != nil {
-		t.Fatal(err)
+	if _, err := ts.Load(); err != redis.Nil {
+		t.Errorf("expected redis.Nil before save, got %v", err)
 	}
+
+	tok := &oauth2.Token{AccessToken: "abc", Expiry: time.Now().Add(time.Hour)}
+	bytes, _ := json.Marshal(tok)
+	c.value.Store(string(bytes))
 	tok2, err := ts.Load()
 	if err != nil {
 		t.Fatal(err)
@@ -25,12 +38,145 @@ func TestTokenStore(t *testing.T) {
 	if tok2.AccessToken != tok.AccessToken {
 		t.Errorf("access token mismatch: want %q, got %q", tok.AccessToken, tok2.AccessToken)
 	}
+	if err = ts.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ts.Load(); err != redis.Nil {
+		t.Errorf("expected redis.Nil after delete, got %v", err)
+	}
+
+	// a missing token is not a failure
+	if err = testutil.CollectAndCompare(ts.Metrics, strings.NewReader(``)); err != nil {
+		t.Error(err)
+	}
 }
 
-var _ oauth2redis.RedisClient = &fakeRedisClient{}
+func TestTokenStore_Save_Watch(t *testing.T) {
+	now := time.Now()
+	older := &oauth2.Token{AccessToken: "older", Expiry: now}
+	newer := &oauth2.Token{AccessToken: "newer", Expiry: now.Add(2 * time.Hour)}
+	tests := []struct {
+		name    string
+		current *oauth2.Token
+		// conflicts are the tokens another client saves while the transaction is in progress
+		conflicts   []*oauth2.Token
+		wantErr     error
+		wantToken   string
+		wantWatches int
+		wantFailed  bool
+	}{
+		{name: "no token stored", wantToken: "token", wantWatches: 1},
+		{name: "stored token expires earlier", current: older, wantToken: "token", wantWatches: 1},
+		{name: "stored token expires later", current: newer, wantToken: "newer", wantWatches: 1},
+		{name: "conflict with an older token", conflicts: []*oauth2.Token{older}, wantToken: "token", wantWatches: 2},
+		{name: "conflict with a newer token", conflicts: []*oauth2.Token{newer}, wantToken: "newer", wantWatches: 2},
+		{
+			name:        "conflicts persist",
+			conflicts:   []*oauth2.Token{older, older, older},
+			wantErr:     redis.TxFailedErr,
+			wantToken:   "older",
+			wantWatches: 3,
+			wantFailed:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakeRedisServer{values: make(map[string]string)}
+			if tt.current != nil {
+				s.set("token", tt.current)
+			}
+			s.conflicts = tt.conflicts
+			c := s.client()
+			t.Cleanup(func() { _ = c.Close() })
+			ts := oauth2redis.TokenStore{RedisClient: c, Key: "token", TTL: time.Minute, Timeout: time.Second, Metrics: oauth2redis.NewMetrics("", "")}
+
+			if err := ts.Save(&oauth2.Token{AccessToken: "token", Expiry: now.Add(time.Hour)}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			token, err := ts.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.AccessToken != tt.wantToken {
+				t.Errorf("stored token mismatch: want %q, got %q", tt.wantToken, token.AccessToken)
+			}
+			if got := s.watches.Load(); got != int32(tt.wantWatches) {
+				t.Errorf("save attempts mismatch: want %d, got %d", tt.wantWatches, got)
+			}
+			if failed := testutil.CollectAndCount(ts.Metrics) > 0; failed != tt.wantFailed {
+				t.Errorf("save failure mismatch: want %v, got %v", tt.wantFailed, failed)
+			}
+
+			if err = ts.Delete(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = ts.Load(); !errors.Is(err, redis.Nil) {
+				t.Errorf("expected redis.Nil after delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenStore_Timeout(t *testing.T) {
+	c := fakeRedisClient{block: true}
+	ts := oauth2redis.TokenStore{RedisClient: &c, Key: "token", TTL: time.Minute, Timeout: 10 * time.Millisecond, Metrics: oauth2redis.NewMetrics("solaredge", "tado")}
+
+	if _, err := ts.Load(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if err := testutil.CollectAndCompare(ts.Metrics, strings.NewReader(`
+# HELP solaredge_tado_token_store_failures_total Number of failed token store operations
+# TYPE solaredge_tado_token_store_failures_total counter
+solaredge_tado_token_store_failures_total{operation="load"} 1
+`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTokenStore_BasicClient(t *testing.T) {
+	// a RedisClient that only supports Get & Set, as in earlier versions
+	var c basicRedisClient
+	ts := oauth2redis.NewRedisTokenStore(&c, "token", time.Minute)
+
+	if err := ts.Save(&oauth2.Token{AccessToken: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := ts.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "abc" {
+		t.Errorf("access token mismatch: want %q, got %q", "abc", tok.AccessToken)
+	}
+	if err = ts.(*oauth2redis.TokenStore).Delete(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported, got %v", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if got := oauth2redis.Key("token", ""); got != "token" {
+		t.Errorf("expected the prefix for a blank account, got %q", got)
+	}
+	foo := oauth2redis.Key("token", "foo@example.com")
+	if !strings.HasPrefix(foo, "token/") || strings.Contains(foo, "foo") {
+		t.Errorf("unexpected key: %q", foo)
+	}
+	if foo != oauth2redis.Key("token", "foo@example.com") {
+		t.Error("key is not stable")
+	}
+	if foo == oauth2redis.Key("token", "bar@example.com") {
+		t.Error("different accounts got the same key")
+	}
+}
+
+var (
+	_ oauth2redis.RedisClient = &fakeRedisClient{}
+	_ oauth2redis.Deleter     = &fakeRedisClient{}
+)
 
 type fakeRedisClient struct {
 	value atomic.Value
+	block bool
 }
 
 func (f *fakeRedisClient) Set(ctx context.Context, _ string, value any, _ time.Duration) *redis.StatusCmd {
@@ -38,13 +184,198 @@ func (f *fakeRedisClient) Set(ctx context.Context, _ string, value any, _ time.D
 	return redis.NewStatusCmd(ctx)
 }
 
+func (f *fakeRedisClient) Del(ctx context.Context, _ ...string) *redis.IntCmd {
+	f.value.Store("")
+	return redis.NewIntCmd(ctx)
+}
+
 func (f *fakeRedisClient) Get(ctx context.Context, _ string) *redis.StringCmd {
 	cmd := redis.NewStringCmd(ctx)
+	if f.block {
+		<-ctx.Done()
+		cmd.SetErr(ctx.Err())
+		return cmd
+	}
 	value := f.value.Load()
-	if value != nil {
+	if value != nil && value != "" {
 		cmd.SetVal(value.(string))
 	} else {
 		cmd.SetErr(redis.Nil)
 	}
 	return cmd
 }
+
+var _ oauth2redis.RedisClient = &basicRedisClient{}
+
+type basicRedisClient struct {
+	value []byte
+}
+
+func (b *basicRedisClient) Set(ctx context.Context, _ string, value any, _ time.Duration) *redis.StatusCmd {
+	b.value = value.([]byte)
+	return redis.NewStatusCmd(ctx)
+}
+
+func (b *basicRedisClient) Get(ctx context.Context, _ string) *redis.StringCmd {
+	cmd := redis.NewStringCmd(ctx)
+	if b.value == nil {
+		cmd.SetErr(redis.Nil)
+	} else {
+		cmd.SetVal(string(b.value))
+	}
+	return cmd
+}
+
+// fakeRedisServer implements the Redis commands used by TokenStore, so TokenStore can be tested with a real *redis.Client,
+// including transactions.
+type fakeRedisServer struct {
+	values   map[string]string
+	versions map[string]int
+	// conflicts are saved, one per transaction, right before the transaction executes, as if by another client.
+	conflicts []*oauth2.Token
+	watches   atomic.Int32
+	lock      sync.Mutex
+}
+
+func (s *fakeRedisServer) client() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Dialer: func(context.Context, string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go s.serve(server)
+			return client, nil
+		},
+		DisableIdentity: true,
+	})
+}
+
+func (s *fakeRedisServer) set(key string, token *oauth2.Token) {
+	bytes, _ := json.Marshal(token)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.do([]string{"SET", key, string(bytes)})
+}
+
+func (s *fakeRedisServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	var watched map[string]int
+	var queued [][]string
+	var multi bool
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		var reply string
+		s.lock.Lock()
+		switch command := strings.ToUpper(args[0]); {
+		case command == "WATCH":
+			s.watches.Add(1)
+			watched = make(map[string]int)
+			for _, key := range args[1:] {
+				watched[key] = s.versions[key]
+			}
+			reply = "+OK\r\n"
+		case command == "UNWATCH":
+			watched = nil
+			reply = "+OK\r\n"
+		case command == "MULTI":
+			multi = true
+			reply = "+OK\r\n"
+		case command == "EXEC":
+			reply = s.exec(watched, queued)
+			watched, queued, multi = nil, nil, false
+		case multi:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			reply = s.do(args)
+		}
+		s.lock.Unlock()
+		if _, err = io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedisServer) exec(watched map[string]int, queued [][]string) string {
+	if len(s.conflicts) > 0 {
+		bytes, _ := json.Marshal(s.conflicts[0])
+		s.conflicts = s.conflicts[1:]
+		for key := range watched {
+			s.do([]string{"SET", key, string(bytes)})
+		}
+	}
+	for key, version := range watched {
+		if s.versions[key] != version {
+			return "*-1\r\n"
+		}
+	}
+	reply := "*" + strconv.Itoa(len(queued)) + "\r\n"
+	for _, args := range queued {
+		reply += s.do(args)
+	}
+	return reply
+}
+
+func (s *fakeRedisServer) do(args []string) string {
+	if s.versions == nil {
+		s.versions = make(map[string]int)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	case "SET":
+		s.values[args[1]] = args[2]
+		s.versions[args[1]]++
+		return "+OK\r\n"
+	case "DEL":
+		var deleted int
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				s.versions[key]++
+				deleted++
+			}
+		}
+		return ":" + strconv.Itoa(deleted) + "\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+// readCommand reads a command, sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	count, err := readLength(r, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		var length int
+		if length, err = readLength(r, '$'); err != nil {
+			return nil, err
+		}
+		arg := make([]byte, length+2)
+		if _, err = io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:length])
+	}
+	return args, nil
+}
+
+func readLength(r *bufio.Reader, prefix byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 3 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected input: %q", line)
+	}
+	return strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+}
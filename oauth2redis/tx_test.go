@@ -0,0 +1,89 @@
+package oauth2redis
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+	"testing"
+	"time"
+)
+
+func TestTokenStore_save(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		current *oauth2.Token
+		token   *oauth2.Token
+		want    string
+	}{
+		{
+			name:  "no token stored",
+			token: &oauth2.Token{AccessToken: "new", Expiry: now.Add(time.Hour)},
+			want:  "new",
+		},
+		{
+			name:    "stored token is older",
+			current: &oauth2.Token{AccessToken: "old", Expiry: now},
+			token:   &oauth2.Token{AccessToken: "new", Expiry: now.Add(time.Hour)},
+			want:    "new",
+		},
+		{
+			name:    "stored token is newer",
+			current: &oauth2.Token{AccessToken: "newer", Expiry: now.Add(2 * time.Hour)},
+			token:   &oauth2.Token{AccessToken: "new", Expiry: now.Add(time.Hour)},
+			want:    "newer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f fakeTx
+			if tt.current != nil {
+				f.value, _ = json.Marshal(tt.current)
+			}
+			ts := TokenStore{Key: "token", TTL: time.Minute}
+			bytes, _ := json.Marshal(tt.token)
+			if err := ts.save(t.Context(), &f, tt.token, bytes); err != nil {
+				t.Fatal(err)
+			}
+			var got oauth2.Token
+			if err := json.Unmarshal(f.value, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.AccessToken != tt.want {
+				t.Errorf("stored token mismatch: want %q, got %q", tt.want, got.AccessToken)
+			}
+		})
+	}
+}
+
+var _ tx = &fakeTx{}
+
+type fakeTx struct {
+	value []byte
+}
+
+func (f *fakeTx) Get(ctx context.Context, _ string) *redis.StringCmd {
+	cmd := redis.NewStringCmd(ctx)
+	if f.value != nil {
+		cmd.SetVal(string(f.value))
+	} else {
+		cmd.SetErr(redis.Nil)
+	}
+	return cmd
+}
+
+func (f *fakeTx) TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+	return nil, fn(fakePipeliner{tx: f})
+}
+
+// fakePipeliner implements the part of redis.Pipeliner used by TokenStore.
+type fakePipeliner struct {
+	redis.Pipeliner
+	tx *fakeTx
+}
+
+func (f fakePipeliner) Set(ctx context.Context, _ string, value any, _ time.Duration) *redis.StatusCmd {
+	f.tx.value = value.([]byte)
+	return redis.NewStatusCmd(ctx)
+}